@@ -3,6 +3,7 @@ package auth
 import (
 	"fmt"
 	"net/http"
+	"sync"
 
 	"github.com/estafette/estafette-ci-api/config"
 	"github.com/gin-gonic/gin"
@@ -13,10 +14,12 @@ import (
 type Middleware interface {
 	MiddlewareFunc() gin.HandlerFunc
 	APIKeyMiddlewareFunc() gin.HandlerFunc
+	UpdateConfig(config.AuthConfig)
 }
 
 type authMiddlewareImpl struct {
-	config config.AuthConfig
+	config      config.AuthConfig
+	configMutex sync.RWMutex
 }
 
 // NewAuthMiddleware returns a new auth.AuthMiddleware
@@ -32,15 +35,17 @@ func NewAuthMiddleware(config config.AuthConfig) (authMiddleware Middleware) {
 func (m *authMiddlewareImpl) MiddlewareFunc() gin.HandlerFunc {
 	return func(c *gin.Context) {
 
+		config := m.getConfig()
+
 		// if no form of authentication is enabled return 401
-		if !m.config.IAP.Enable {
+		if !config.IAP.Enable {
 			c.AbortWithStatus(http.StatusUnauthorized)
 		}
 
-		if m.config.IAP.Enable {
+		if config.IAP.Enable {
 
 			tokenString := c.Request.Header.Get("x-goog-iap-jwt-assertion")
-			user, err := GetUserFromIAPJWT(tokenString, m.config.IAP.Audience)
+			user, err := GetUserFromIAPJWT(tokenString, config.IAP.Audience)
 			if err != nil {
 				log.Warn().Str("jwt", tokenString).Err(err).Msg("Checking iap jwt failed")
 				c.AbortWithStatus(http.StatusUnauthorized)
@@ -55,8 +60,10 @@ func (m *authMiddlewareImpl) MiddlewareFunc() gin.HandlerFunc {
 func (m *authMiddlewareImpl) APIKeyMiddlewareFunc() gin.HandlerFunc {
 	return func(c *gin.Context) {
 
+		config := m.getConfig()
+
 		authorizationHeader := c.GetHeader("Authorization")
-		if authorizationHeader != fmt.Sprintf("Bearer %v", m.config.APIKey) {
+		if authorizationHeader != fmt.Sprintf("Bearer %v", config.APIKey) {
 			log.Error().
 				Str("authorizationHeader", authorizationHeader).
 				Msg("Authorization header bearer token is incorrect")
@@ -68,3 +75,18 @@ func (m *authMiddlewareImpl) APIKeyMiddlewareFunc() gin.HandlerFunc {
 		c.Set(gin.AuthUserKey, "apiKey")
 	}
 }
+
+// UpdateConfig swaps in a reloaded auth config; requests handled from then on use it
+func (m *authMiddlewareImpl) UpdateConfig(config config.AuthConfig) {
+	m.configMutex.Lock()
+	defer m.configMutex.Unlock()
+
+	m.config = config
+}
+
+func (m *authMiddlewareImpl) getConfig() config.AuthConfig {
+	m.configMutex.RLock()
+	defer m.configMutex.RUnlock()
+
+	return m.config
+}
@@ -9,9 +9,11 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 
 	bbcontracts "github.com/estafette/estafette-ci-api/bitbucket/contracts"
 	"github.com/estafette/estafette-ci-api/config"
+	contracts "github.com/estafette/estafette-ci-contracts"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sethgrid/pester"
 )
@@ -21,12 +23,19 @@ type APIClient interface {
 	GetAccessToken() (bbcontracts.AccessToken, error)
 	GetAuthenticatedRepositoryURL(bbcontracts.AccessToken, string) (string, error)
 	GetEstafetteManifest(bbcontracts.AccessToken, bbcontracts.RepositoryPushEvent) (bool, string, error)
+	GetBranchRevision(bbcontracts.AccessToken, string, string) (string, error)
+	GetChangedFiles(bbcontracts.AccessToken, bbcontracts.RepositoryPushEvent) ([]string, error)
+	SetBuildStatus(bbcontracts.AccessToken, string, string, bbcontracts.BuildStatus) error
 
 	JobVarsFunc() func(string, string, string) (string, string, error)
+	BuildStatusFunc() func(contracts.Build, *contracts.BuildLog, string) error
+
+	UpdateConfig(config.BitbucketConfig)
 }
 
 type apiClientImpl struct {
 	config                          config.BitbucketConfig
+	configMutex                     sync.RWMutex
 	prometheusOutboundAPICallTotals *prometheus.CounterVec
 }
 
@@ -44,7 +53,7 @@ func (bb *apiClientImpl) GetAccessToken() (accessToken bbcontracts.AccessToken,
 	// track call via prometheus
 	bb.prometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "bitbucket"}).Inc()
 
-	basicAuthenticationToken := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%v:%v", bb.config.AppOAuthKey, bb.config.AppOAuthSecret)))
+	basicAuthenticationToken := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%v:%v", bb.getConfig().AppOAuthKey, bb.getConfig().AppOAuthSecret)))
 
 	// form values
 	data := url.Values{}
@@ -133,6 +142,167 @@ func (bb *apiClientImpl) GetEstafetteManifest(accessToken bbcontracts.AccessToke
 	return
 }
 
+// GetBranchRevision returns the hash of the last commit of a branch
+func (bb *apiClientImpl) GetBranchRevision(accessToken bbcontracts.AccessToken, fullRepoName, branch string) (revision string, err error) {
+
+	// track call via prometheus
+	bb.prometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "bitbucket"}).Inc()
+
+	// create client, in order to add headers
+	client := pester.New()
+	client.MaxRetries = 3
+	client.Backoff = pester.ExponentialJitterBackoff
+	client.KeepLog = true
+	request, err := http.NewRequest("GET", fmt.Sprintf("https://api.bitbucket.org/2.0/repositories/%v/refs/branches/%v", fullRepoName, branch), nil)
+	if err != nil {
+		return
+	}
+
+	// add headers
+	request.Header.Add("Authorization", fmt.Sprintf("Bearer %v", accessToken.AccessToken))
+
+	// perform actual request
+	response, err := client.Do(request)
+	if err != nil {
+		return
+	}
+
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Retrieving branch %v for Bitbucket repository %v failed with status code %v", branch, fullRepoName, response.StatusCode)
+	}
+
+	var branchResponse struct {
+		Target struct {
+			Hash string `json:"hash"`
+		} `json:"target"`
+	}
+
+	// unmarshal json body
+	err = json.Unmarshal(body, &branchResponse)
+	if err != nil {
+		return
+	}
+
+	return branchResponse.Target.Hash, nil
+}
+
+// GetChangedFiles returns the files changed by a push; it returns nil if they can't be determined because the push created the branch
+func (bb *apiClientImpl) GetChangedFiles(accessToken bbcontracts.AccessToken, pushEvent bbcontracts.RepositoryPushEvent) (changedFiles []string, err error) {
+
+	if len(pushEvent.Push.Changes) == 0 || pushEvent.Push.Changes[0].New == nil || pushEvent.Push.Changes[0].Old == nil {
+		return nil, nil
+	}
+
+	// unlike git bitbucket expects the new commit first in the range
+	nextURL := fmt.Sprintf("https://api.bitbucket.org/2.0/repositories/%v/diffstat/%v..%v", pushEvent.Repository.FullName, pushEvent.Push.Changes[0].New.Target.Hash, pushEvent.Push.Changes[0].Old.Target.Hash)
+
+	changedFiles = make([]string, 0)
+	for nextURL != "" {
+
+		// track call via prometheus
+		bb.prometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "bitbucket"}).Inc()
+
+		// create client, in order to add headers
+		client := pester.New()
+		client.MaxRetries = 3
+		client.Backoff = pester.ExponentialJitterBackoff
+		client.KeepLog = true
+		request, err := http.NewRequest("GET", nextURL, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		// add headers
+		request.Header.Add("Authorization", fmt.Sprintf("Bearer %v", accessToken.AccessToken))
+
+		// perform actual request
+		response, err := client.Do(request)
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		if response.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("Retrieving diffstat for Bitbucket repository %v failed with status code %v", pushEvent.Repository.FullName, response.StatusCode)
+		}
+
+		var diffStat bbcontracts.DiffStat
+
+		// unmarshal json body
+		err = json.Unmarshal(body, &diffStat)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, v := range diffStat.Values {
+			if v.New != nil {
+				changedFiles = append(changedFiles, v.New.Path)
+			}
+			if v.Old != nil && (v.New == nil || v.Old.Path != v.New.Path) {
+				changedFiles = append(changedFiles, v.Old.Path)
+			}
+		}
+
+		nextURL = diffStat.Next
+	}
+
+	return
+}
+
+// SetBuildStatus creates or updates the build status of a commit
+func (bb *apiClientImpl) SetBuildStatus(accessToken bbcontracts.AccessToken, fullRepoName, revision string, buildStatus bbcontracts.BuildStatus) (err error) {
+
+	// track call via prometheus
+	bb.prometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "bitbucket"}).Inc()
+
+	// https://developer.atlassian.com/bitbucket/api/2/reference/resource/repositories/%7Busername%7D/%7Brepo_slug%7D/commit/%7Bnode%7D/statuses/build
+
+	requestBody, err := json.Marshal(buildStatus)
+	if err != nil {
+		return
+	}
+
+	// create client, in order to add headers
+	client := pester.New()
+	client.MaxRetries = 3
+	client.Backoff = pester.ExponentialJitterBackoff
+	client.KeepLog = true
+	request, err := http.NewRequest("POST", fmt.Sprintf("https://api.bitbucket.org/2.0/repositories/%v/commit/%v/statuses/build", fullRepoName, revision), bytes.NewBuffer(requestBody))
+	if err != nil {
+		return
+	}
+
+	// add headers
+	request.Header.Add("Authorization", fmt.Sprintf("Bearer %v", accessToken.AccessToken))
+	request.Header.Add("Content-Type", "application/json")
+
+	// perform actual request
+	response, err := client.Do(request)
+	if err != nil {
+		return
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusCreated {
+		return fmt.Errorf("Setting build status %v for revision %v of Bitbucket repository %v failed with status code %v", buildStatus.State, revision, fullRepoName, response.StatusCode)
+	}
+
+	return
+}
+
 // JobVarsFunc returns a function that can get an access token and authenticated url for a repository
 func (bb *apiClientImpl) JobVarsFunc() func(string, string, string) (string, string, error) {
 	return func(repoSource, repoOwner, repoName string) (token string, url string, err error) {
@@ -150,3 +320,32 @@ func (bb *apiClientImpl) JobVarsFunc() func(string, string, string) (string, str
 		return accessToken.AccessToken, url, nil
 	}
 }
+
+// BuildStatusFunc returns a function that sets the Bitbucket build status of a build's commit
+func (bb *apiClientImpl) BuildStatusFunc() func(contracts.Build, *contracts.BuildLog, string) error {
+	return func(build contracts.Build, buildLog *contracts.BuildLog, detailsURL string) error {
+
+		// get access token
+		accessToken, err := bb.GetAccessToken()
+		if err != nil {
+			return err
+		}
+
+		return bb.SetBuildStatus(accessToken, fmt.Sprintf("%v/%v", build.RepoOwner, build.RepoName), build.RepoRevision, getBuildStatus(build, detailsURL))
+	}
+}
+
+// UpdateConfig swaps in a reloaded config; access tokens requested from then on use its oauth key and secret
+func (bb *apiClientImpl) UpdateConfig(config config.BitbucketConfig) {
+	bb.configMutex.Lock()
+	defer bb.configMutex.Unlock()
+
+	bb.config = config
+}
+
+func (bb *apiClientImpl) getConfig() config.BitbucketConfig {
+	bb.configMutex.RLock()
+	defer bb.configMutex.RUnlock()
+
+	return bb.config
+}
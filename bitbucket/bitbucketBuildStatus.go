@@ -0,0 +1,38 @@
+package bitbucket
+
+import (
+	"fmt"
+
+	bbcontracts "github.com/estafette/estafette-ci-api/bitbucket/contracts"
+	contracts "github.com/estafette/estafette-ci-contracts"
+)
+
+// the key is shared with the injected bitbucket-status stage, so either one overwrites the status set by the other
+const buildStatusKey = "estafette"
+
+// getBuildStatus maps the status of a build to a Bitbucket build status
+func getBuildStatus(build contracts.Build, detailsURL string) bbcontracts.BuildStatus {
+
+	buildStatus := bbcontracts.BuildStatus{
+		Key:  buildStatusKey,
+		Name: fmt.Sprintf("Estafette CI %v", build.BuildVersion),
+		URL:  detailsURL,
+	}
+
+	switch build.BuildStatus {
+	case "succeeded":
+		buildStatus.State = "SUCCESSFUL"
+		buildStatus.Description = "Build succeeded"
+	case "failed":
+		buildStatus.State = "FAILED"
+		buildStatus.Description = "Build failed"
+	case "canceling", "canceled":
+		buildStatus.State = "STOPPED"
+		buildStatus.Description = "Build canceled"
+	default:
+		buildStatus.State = "INPROGRESS"
+		buildStatus.Description = "Build running"
+	}
+
+	return buildStatus
+}
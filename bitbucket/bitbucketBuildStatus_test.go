@@ -0,0 +1,56 @@
+package bitbucket
+
+import (
+	"testing"
+
+	contracts "github.com/estafette/estafette-ci-contracts"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetBuildStatus(t *testing.T) {
+
+	detailsURL := "https://ci.estafette.io/pipelines/bitbucket.org/xivart/icarus-mobile/builds/390605593734184965/logs"
+
+	t.Run("ReturnsInProgressForRunningBuild", func(t *testing.T) {
+
+		build := contracts.Build{BuildVersion: "1.0.5", BuildStatus: "running"}
+
+		// act
+		buildStatus := getBuildStatus(build, detailsURL)
+
+		assert.Equal(t, "INPROGRESS", buildStatus.State)
+		assert.Equal(t, "estafette", buildStatus.Key)
+		assert.Equal(t, "Estafette CI 1.0.5", buildStatus.Name)
+		assert.Equal(t, detailsURL, buildStatus.URL)
+	})
+
+	t.Run("ReturnsSuccessfulForSucceededBuild", func(t *testing.T) {
+
+		build := contracts.Build{BuildStatus: "succeeded"}
+
+		// act
+		buildStatus := getBuildStatus(build, detailsURL)
+
+		assert.Equal(t, "SUCCESSFUL", buildStatus.State)
+	})
+
+	t.Run("ReturnsFailedForFailedBuild", func(t *testing.T) {
+
+		build := contracts.Build{BuildStatus: "failed"}
+
+		// act
+		buildStatus := getBuildStatus(build, detailsURL)
+
+		assert.Equal(t, "FAILED", buildStatus.State)
+	})
+
+	t.Run("ReturnsStoppedForCancelingOrCanceledBuild", func(t *testing.T) {
+
+		// act
+		cancelingStatus := getBuildStatus(contracts.Build{BuildStatus: "canceling"}, detailsURL)
+		canceledStatus := getBuildStatus(contracts.Build{BuildStatus: "canceled"}, detailsURL)
+
+		assert.Equal(t, "STOPPED", cancelingStatus.State)
+		assert.Equal(t, "STOPPED", canceledStatus.State)
+	})
+}
@@ -5,6 +5,7 @@ import (
 
 	bbcontracts "github.com/estafette/estafette-ci-api/bitbucket/contracts"
 	"github.com/estafette/estafette-ci-api/cockroach"
+	"github.com/estafette/estafette-ci-api/config"
 	"github.com/estafette/estafette-ci-api/estafette"
 )
 
@@ -23,10 +24,11 @@ type eventDispatcherImpl struct {
 	apiClient         APIClient
 	ciBuilderClient   estafette.CiBuilderClient
 	cockroachDBClient cockroach.DBClient
+	apiServerConfig   config.APIServerConfig
 }
 
 // NewBitbucketDispatcher returns a new github.EventWorker to handle events channeled by bitbucket.EventDispatcher
-func NewBitbucketDispatcher(stopChannel <-chan struct{}, waitGroup *sync.WaitGroup, maxWorkers int, apiClient APIClient, ciBuilderClient estafette.CiBuilderClient, cockroachDBClient cockroach.DBClient, eventsChannel chan bbcontracts.RepositoryPushEvent) EventDispatcher {
+func NewBitbucketDispatcher(stopChannel <-chan struct{}, waitGroup *sync.WaitGroup, maxWorkers int, apiClient APIClient, ciBuilderClient estafette.CiBuilderClient, cockroachDBClient cockroach.DBClient, apiServerConfig config.APIServerConfig, eventsChannel chan bbcontracts.RepositoryPushEvent) EventDispatcher {
 	return &eventDispatcherImpl{
 		waitGroup:         waitGroup,
 		stopChannel:       stopChannel,
@@ -36,6 +38,7 @@ func NewBitbucketDispatcher(stopChannel <-chan struct{}, waitGroup *sync.WaitGro
 		apiClient:         apiClient,
 		ciBuilderClient:   ciBuilderClient,
 		cockroachDBClient: cockroachDBClient,
+		apiServerConfig:   apiServerConfig,
 	}
 }
 
@@ -43,7 +46,7 @@ func NewBitbucketDispatcher(stopChannel <-chan struct{}, waitGroup *sync.WaitGro
 func (d *eventDispatcherImpl) Run() {
 	// starting n number of workers
 	for i := 0; i < d.maxWorkers; i++ {
-		worker := NewBitbucketEventWorker(d.stopChannel, d.waitGroup, d.workerPool, d.apiClient, d.ciBuilderClient, d.cockroachDBClient)
+		worker := NewBitbucketEventWorker(d.stopChannel, d.waitGroup, d.workerPool, d.apiClient, d.ciBuilderClient, d.cockroachDBClient, d.apiServerConfig)
 		worker.ListenToEventChannels()
 	}
 
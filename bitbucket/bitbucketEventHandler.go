@@ -6,6 +6,7 @@ import (
 	"net/http"
 
 	bbcontracts "github.com/estafette/estafette-ci-api/bitbucket/contracts"
+	"github.com/estafette/estafette-ci-api/estafette"
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog/log"
@@ -15,17 +16,22 @@ import (
 type EventHandler interface {
 	Handle(*gin.Context)
 	HandlePushEvent(pushEvent bbcontracts.RepositoryPushEvent)
+	HandleRepositoryTransferEvent(bbcontracts.RepositoryTransferEvent)
+	HandleRepositoryUpdatedEvent(bbcontracts.RepositoryUpdatedEvent)
+	HandleRepositoryDeletedEvent(bbcontracts.RepositoryDeletedEvent)
 }
 
 type eventHandlerImpl struct {
 	eventsChannel                chan bbcontracts.RepositoryPushEvent
+	repositoryEventHelper        estafette.RepositoryEventHelper
 	prometheusInboundEventTotals *prometheus.CounterVec
 }
 
 // NewBitbucketEventHandler returns a new bitbucket.EventHandler
-func NewBitbucketEventHandler(eventsChannel chan bbcontracts.RepositoryPushEvent, prometheusInboundEventTotals *prometheus.CounterVec) EventHandler {
+func NewBitbucketEventHandler(eventsChannel chan bbcontracts.RepositoryPushEvent, repositoryEventHelper estafette.RepositoryEventHelper, prometheusInboundEventTotals *prometheus.CounterVec) EventHandler {
 	return &eventHandlerImpl{
 		eventsChannel:                eventsChannel,
+		repositoryEventHelper:        repositoryEventHelper,
 		prometheusInboundEventTotals: prometheusInboundEventTotals,
 	}
 }
@@ -57,12 +63,45 @@ func (h *eventHandlerImpl) Handle(c *gin.Context) {
 
 		h.HandlePushEvent(pushEvent)
 
+	case "repo:transfer":
+
+		// unmarshal json body
+		var transferEvent bbcontracts.RepositoryTransferEvent
+		err := json.Unmarshal(body, &transferEvent)
+		if err != nil {
+			log.Error().Err(err).Str("body", string(body)).Msg("Deserializing body to BitbucketRepositoryTransferEvent failed")
+			return
+		}
+
+		h.HandleRepositoryTransferEvent(transferEvent)
+
+	case "repo:updated":
+
+		// unmarshal json body
+		var updatedEvent bbcontracts.RepositoryUpdatedEvent
+		err := json.Unmarshal(body, &updatedEvent)
+		if err != nil {
+			log.Error().Err(err).Str("body", string(body)).Msg("Deserializing body to BitbucketRepositoryUpdatedEvent failed")
+			return
+		}
+
+		h.HandleRepositoryUpdatedEvent(updatedEvent)
+
+	case "repo:deleted":
+
+		// unmarshal json body
+		var deletedEvent bbcontracts.RepositoryDeletedEvent
+		err := json.Unmarshal(body, &deletedEvent)
+		if err != nil {
+			log.Error().Err(err).Str("body", string(body)).Msg("Deserializing body to BitbucketRepositoryDeletedEvent failed")
+			return
+		}
+
+		h.HandleRepositoryDeletedEvent(deletedEvent)
+
 	case
 		"repo:fork",
-		"repo:updated",
-		"repo:transfer",
 		"repo:created",
-		"repo:deleted",
 		"repo:commit_comment_created",
 		"repo:commit_status_created",
 		"repo:commit_status_updated",
@@ -88,6 +127,46 @@ func (h *eventHandlerImpl) Handle(c *gin.Context) {
 
 func (h *eventHandlerImpl) HandlePushEvent(pushEvent bbcontracts.RepositoryPushEvent) {
 
+	// bitbucket doesn't have a separate event for deleting a branch, it's a push without new state
+	if deletedBranch := pushEvent.GetDeletedBranch(); deletedBranch != "" {
+		err := h.repositoryEventHelper.BranchDeleted("bitbucket.org", pushEvent.GetRepoOwner(), pushEvent.GetRepoName(), deletedBranch)
+		if err != nil {
+			log.Error().Err(err).Msgf("Failed canceling builds for deleted branch %v of %v", deletedBranch, pushEvent.Repository.FullName)
+		}
+		return
+	}
+
 	// test making api calls for bitbucket app in the background
 	h.eventsChannel <- pushEvent
 }
+
+func (h *eventHandlerImpl) HandleRepositoryTransferEvent(transferEvent bbcontracts.RepositoryTransferEvent) {
+
+	err := h.repositoryEventHelper.RepositoryRenamed("bitbucket.org", transferEvent.PreviousOwner.UserName, transferEvent.Repository.GetRepoName(), transferEvent.Repository.GetRepoOwner(), transferEvent.Repository.GetRepoName())
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed moving pipeline for transferred repository %v", transferEvent.Repository.FullName)
+	}
+}
+
+func (h *eventHandlerImpl) HandleRepositoryUpdatedEvent(updatedEvent bbcontracts.RepositoryUpdatedEvent) {
+
+	// only renames affect the pipeline
+	if updatedEvent.Changes.FullName == nil || updatedEvent.Changes.FullName.Old == "" || updatedEvent.Changes.FullName.Old == updatedEvent.Changes.FullName.New {
+		return
+	}
+
+	previousRepository := bbcontracts.Repository{FullName: updatedEvent.Changes.FullName.Old}
+
+	err := h.repositoryEventHelper.RepositoryRenamed("bitbucket.org", previousRepository.GetRepoOwner(), previousRepository.GetRepoName(), updatedEvent.Repository.GetRepoOwner(), updatedEvent.Repository.GetRepoName())
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed moving pipeline for renamed repository %v", updatedEvent.Repository.FullName)
+	}
+}
+
+func (h *eventHandlerImpl) HandleRepositoryDeletedEvent(deletedEvent bbcontracts.RepositoryDeletedEvent) {
+
+	err := h.repositoryEventHelper.RepositoryDeleted("bitbucket.org", deletedEvent.Repository.GetRepoOwner(), deletedEvent.Repository.GetRepoName())
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed archiving pipeline for deleted repository %v", deletedEvent.Repository.FullName)
+	}
+}
@@ -1,11 +1,13 @@
 package bitbucket
 
 import (
+	"fmt"
 	"strconv"
 	"sync"
 
 	bbcontracts "github.com/estafette/estafette-ci-api/bitbucket/contracts"
 	"github.com/estafette/estafette-ci-api/cockroach"
+	"github.com/estafette/estafette-ci-api/config"
 	"github.com/estafette/estafette-ci-api/estafette"
 	"github.com/estafette/estafette-ci-contracts"
 	manifest "github.com/estafette/estafette-ci-manifest"
@@ -16,6 +18,7 @@ import (
 type EventWorker interface {
 	ListenToEventChannels()
 	CreateJobForBitbucketPush(bbcontracts.RepositoryPushEvent)
+	CreateJobForTrigger(estafette.TriggerEvent) (*cockroach.Build, error)
 }
 
 type eventWorkerImpl struct {
@@ -26,10 +29,11 @@ type eventWorkerImpl struct {
 	apiClient         APIClient
 	CiBuilderClient   estafette.CiBuilderClient
 	cockroachDBClient cockroach.DBClient
+	apiServerConfig   config.APIServerConfig
 }
 
 // NewBitbucketEventWorker returns the bitbucket.EventWorker
-func NewBitbucketEventWorker(stopChannel <-chan struct{}, waitGroup *sync.WaitGroup, workerPool chan chan bbcontracts.RepositoryPushEvent, apiClient APIClient, ciBuilderClient estafette.CiBuilderClient, cockroachDBClient cockroach.DBClient) EventWorker {
+func NewBitbucketEventWorker(stopChannel <-chan struct{}, waitGroup *sync.WaitGroup, workerPool chan chan bbcontracts.RepositoryPushEvent, apiClient APIClient, ciBuilderClient estafette.CiBuilderClient, cockroachDBClient cockroach.DBClient, apiServerConfig config.APIServerConfig) EventWorker {
 	return &eventWorkerImpl{
 		waitGroup:         waitGroup,
 		stopChannel:       stopChannel,
@@ -38,6 +42,7 @@ func NewBitbucketEventWorker(stopChannel <-chan struct{}, waitGroup *sync.WaitGr
 		apiClient:         apiClient,
 		CiBuilderClient:   ciBuilderClient,
 		cockroachDBClient: cockroachDBClient,
+		apiServerConfig:   apiServerConfig,
 	}
 }
 
@@ -64,12 +69,78 @@ func (w *eventWorkerImpl) ListenToEventChannels() {
 }
 
 func (w *eventWorkerImpl) CreateJobForBitbucketPush(pushEvent bbcontracts.RepositoryPushEvent) {
+	w.createJobForBitbucketPush(pushEvent, "", nil)
+}
+
+// CreateJobForTrigger rebuilds a branch the same way a push would, with the firing trigger stored as the reason for the build;
+// without a revision the last commit of the branch gets built
+func (w *eventWorkerImpl) CreateJobForTrigger(triggerEvent estafette.TriggerEvent) (*cockroach.Build, error) {
+
+	if triggerEvent.RepoRevision == "" {
+		accessToken, err := w.apiClient.GetAccessToken()
+		if err != nil {
+			log.Error().Err(err).
+				Msg("Retrieving access token failed")
+			return nil, err
+		}
+
+		triggerEvent.RepoRevision, err = w.apiClient.GetBranchRevision(accessToken, fmt.Sprintf("%v/%v", triggerEvent.RepoOwner, triggerEvent.RepoName), triggerEvent.RepoBranch)
+		if err != nil {
+			log.Error().Err(err).
+				Msgf("Retrieving last commit of branch %v for Bitbucket repository %v/%v failed", triggerEvent.RepoBranch, triggerEvent.RepoOwner, triggerEvent.RepoName)
+			return nil, err
+		}
+	}
+
+	pushEvent := bbcontracts.RepositoryPushEvent{
+		Repository: bbcontracts.Repository{
+			Name:     triggerEvent.RepoName,
+			FullName: fmt.Sprintf("%v/%v", triggerEvent.RepoOwner, triggerEvent.RepoName),
+			Links: bbcontracts.RepositoryLinks{
+				HTML: bbcontracts.Link{
+					Href: fmt.Sprintf("https://%v/%v/%v", triggerEvent.RepoSource, triggerEvent.RepoOwner, triggerEvent.RepoName),
+				},
+			},
+		},
+		Push: bbcontracts.PushEvent{
+			Changes: []bbcontracts.PushEventChange{
+				bbcontracts.PushEventChange{
+					New: &bbcontracts.PushEventChangeObject{
+						Type: "branch",
+						Name: triggerEvent.RepoBranch,
+						Target: bbcontracts.PushEventChangeObjectTarget{
+							Hash: triggerEvent.RepoRevision,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	return w.createJobForBitbucketPush(pushEvent, triggerEvent.Reason, triggerEvent.EnvironmentVariables)
+}
+
+func (w *eventWorkerImpl) createJobForBitbucketPush(pushEvent bbcontracts.RepositoryPushEvent, triggerReason string, environmentVariables map[string]string) (build *cockroach.Build, err error) {
 
 	// check to see that it's a cloneable event
-	if len(pushEvent.Push.Changes) == 0 || pushEvent.Push.Changes[0].New == nil || pushEvent.Push.Changes[0].New.Type != "branch" || len(pushEvent.Push.Changes[0].New.Target.Hash) == 0 {
+	if len(pushEvent.Push.Changes) == 0 || pushEvent.Push.Changes[0].New == nil || (pushEvent.Push.Changes[0].New.Type != "branch" && pushEvent.Push.Changes[0].New.Type != "tag") || len(pushEvent.Push.Changes[0].New.Target.Hash) == 0 {
 		return
 	}
 
+	// pushes to archived pipelines don't get built until the pipeline is unarchived
+	if triggerReason == "" {
+		var pipeline *cockroach.Pipeline
+		pipeline, err = w.cockroachDBClient.GetPipeline(pushEvent.GetRepoSource(), pushEvent.GetRepoOwner(), pushEvent.GetRepoName(), true)
+		if err != nil {
+			log.Error().Err(err).Msgf("Failed retrieving pipeline %v/%v/%v to check whether it's archived", pushEvent.GetRepoSource(), pushEvent.GetRepoOwner(), pushEvent.GetRepoName())
+			return
+		}
+		if pipeline != nil && pipeline.Archived {
+			log.Info().Msgf("Pipeline %v/%v/%v is archived, rejecting push", pushEvent.GetRepoSource(), pushEvent.GetRepoOwner(), pushEvent.GetRepoName())
+			return
+		}
+	}
+
 	// get access token
 	accessToken, err := w.apiClient.GetAccessToken()
 	if err != nil {
@@ -90,6 +161,20 @@ func (w *eventWorkerImpl) CreateJobForBitbucketPush(pushEvent bbcontracts.Reposi
 		return
 	}
 
+	// tags only get built if the manifest opts in with a matching tag trigger
+	if pushEvent.GetRepoTag() != "" {
+		var tagTriggered bool
+		tagTriggered, err = estafette.IsTagTriggered(manifestString, pushEvent.GetRepoTag())
+		if err != nil {
+			log.Error().Err(err).
+				Msgf("Reading tag triggers from manifest for repo %v and tag %v failed", pushEvent.Repository.FullName, pushEvent.GetRepoTag())
+			return
+		}
+		if !tagTriggered {
+			return nil, nil
+		}
+	}
+
 	mft, err := manifest.ReadManifest(manifestString)
 	builderTrack := "stable"
 	hasValidManifest := false
@@ -110,6 +195,30 @@ func (w *eventWorkerImpl) CreateJobForBitbucketPush(pushEvent bbcontracts.Reposi
 		}
 	}
 
+	// skip the build if the head commit asks to skip ci or the push didn't change any files matching the paths filter of the manifest;
+	// the skipped build is stored for the build history, but the pipeline keeps showing its last build that did run
+	skipReason := ""
+	if triggerReason == "" {
+		if skipDirective := estafette.GetSkipDirective(pushEvent.Push.Changes[0].New.Target.Message, w.apiServerConfig.SkipDirectives); skipDirective != "" {
+			skipReason = fmt.Sprintf("Commit message contains %v", skipDirective)
+		}
+	}
+	if skipReason == "" && hasValidManifest && triggerReason == "" {
+		var pathFilter estafette.PathFilter
+		pathFilter, err = estafette.GetPathFilter(manifestString)
+		if err != nil {
+			log.Warn().Err(err).Msgf("Reading paths filter from manifest for repo %v and revision %v failed, building anyway", pushEvent.Repository.FullName, pushEvent.GetRepoRevision())
+		} else if len(pathFilter.Include) > 0 || len(pathFilter.Exclude) > 0 {
+			var changedFiles []string
+			changedFiles, err = w.apiClient.GetChangedFiles(accessToken, pushEvent)
+			if err != nil {
+				log.Warn().Err(err).Msgf("Retrieving changed files for repo %v and revision %v failed, building anyway", pushEvent.Repository.FullName, pushEvent.GetRepoRevision())
+			} else {
+				skipReason = pathFilter.GetSkipReason(changedFiles)
+			}
+		}
+	}
+
 	// get authenticated url for the repository
 	authenticatedRepositoryURL, err := w.apiClient.GetAuthenticatedRepositoryURL(accessToken, pushEvent.Repository.Links.HTML.Href)
 	if err != nil {
@@ -118,18 +227,24 @@ func (w *eventWorkerImpl) CreateJobForBitbucketPush(pushEvent bbcontracts.Reposi
 		return
 	}
 
-	// get autoincrement number
-	autoincrement, err := w.cockroachDBClient.GetAutoIncrement("bitbucket", pushEvent.Repository.FullName)
-	if err != nil {
-		log.Error().Err(err).
-			Msgf("Failed generating autoincrement for Bitbucket repository %v", pushEvent.Repository.FullName)
+	// get autoincrement number; skipped builds don't get a version
+	autoincrement := 0
+	if skipReason == "" {
+		autoincrement, err = w.cockroachDBClient.GetAutoIncrement("bitbucket", pushEvent.Repository.FullName)
+		if err != nil {
+			log.Error().Err(err).
+				Msgf("Failed generating autoincrement for Bitbucket repository %v", pushEvent.Repository.FullName)
+		}
 	}
 
 	// set build version number
 	buildVersion := ""
 	buildStatus := "failed"
-	if hasValidManifest {
-		buildVersion = mft.Version.Version(manifest.EstafetteVersionParams{
+	if skipReason != "" {
+		buildStatus = "skipped"
+	} else if hasValidManifest {
+		version := estafette.WithVersionTag(mft.Version, pushEvent.GetRepoTag())
+		buildVersion = version.Version(manifest.EstafetteVersionParams{
 			AutoIncrement: autoincrement,
 			Branch:        pushEvent.GetRepoBranch(),
 			Revision:      pushEvent.GetRepoRevision(),
@@ -180,18 +295,23 @@ func (w *eventWorkerImpl) CreateJobForBitbucketPush(pushEvent bbcontracts.Reposi
 	}
 
 	// store build in db
-	insertedBuild, err := w.cockroachDBClient.InsertBuild(contracts.Build{
-		RepoSource:     pushEvent.GetRepoSource(),
-		RepoOwner:      pushEvent.GetRepoOwner(),
-		RepoName:       pushEvent.GetRepoName(),
-		RepoBranch:     pushEvent.GetRepoBranch(),
-		RepoRevision:   pushEvent.GetRepoRevision(),
-		BuildVersion:   buildVersion,
-		BuildStatus:    buildStatus,
-		Labels:         labels,
-		ReleaseTargets: releaseTargets,
-		Manifest:       manifestString,
-		Commits:        commits,
+	insertedBuild, err := w.cockroachDBClient.InsertBuild(cockroach.Build{
+		Build: contracts.Build{
+			RepoSource:     pushEvent.GetRepoSource(),
+			RepoOwner:      pushEvent.GetRepoOwner(),
+			RepoName:       pushEvent.GetRepoName(),
+			RepoBranch:     pushEvent.GetRepoBranch(),
+			RepoRevision:   pushEvent.GetRepoRevision(),
+			BuildVersion:   buildVersion,
+			BuildStatus:    buildStatus,
+			Labels:         labels,
+			ReleaseTargets: releaseTargets,
+			Manifest:       manifestString,
+			Commits:        commits,
+		},
+		RepoTag:       pushEvent.GetRepoTag(),
+		TriggerReason: triggerReason,
+		SkipReason:    skipReason,
 	})
 	if err != nil {
 		log.Error().Err(err).
@@ -199,6 +319,11 @@ func (w *eventWorkerImpl) CreateJobForBitbucketPush(pushEvent bbcontracts.Reposi
 		return
 	}
 
+	if skipReason != "" {
+		log.Info().Msgf("Skipped build for Bitbucket repository %v revision %v: %v", pushEvent.Repository.FullName, pushEvent.GetRepoRevision(), skipReason)
+		return &insertedBuild, nil
+	}
+
 	buildID, err := strconv.Atoi(insertedBuild.ID)
 	if err != nil {
 		log.Warn().Err(err).Msgf("Failed to convert build id %v to int", insertedBuild.ID)
@@ -206,19 +331,21 @@ func (w *eventWorkerImpl) CreateJobForBitbucketPush(pushEvent bbcontracts.Reposi
 
 	// define ci builder params
 	ciBuilderParams := estafette.CiBuilderParams{
-		JobType:              "build",
-		RepoSource:           pushEvent.GetRepoSource(),
-		RepoOwner:            pushEvent.GetRepoOwner(),
-		RepoName:             pushEvent.GetRepoName(),
-		RepoURL:              authenticatedRepositoryURL,
-		RepoBranch:           pushEvent.GetRepoBranch(),
-		RepoRevision:         pushEvent.GetRepoRevision(),
-		EnvironmentVariables: map[string]string{"ESTAFETTE_BITBUCKET_API_TOKEN": accessToken.AccessToken},
-		Track:                builderTrack,
-		AutoIncrement:        autoincrement,
-		VersionNumber:        buildVersion,
-		Manifest:             mft,
-		BuildID:              buildID,
+		JobType:                   "build",
+		RepoSource:                pushEvent.GetRepoSource(),
+		RepoOwner:                 pushEvent.GetRepoOwner(),
+		RepoName:                  pushEvent.GetRepoName(),
+		RepoURL:                   authenticatedRepositoryURL,
+		RepoBranch:                pushEvent.GetRepoBranch(),
+		RepoRevision:              pushEvent.GetRepoRevision(),
+		RepoTag:                   pushEvent.GetRepoTag(),
+		EnvironmentVariables:      map[string]string{"ESTAFETTE_BITBUCKET_API_TOKEN": accessToken.AccessToken},
+		Track:                     builderTrack,
+		AutoIncrement:             autoincrement,
+		VersionNumber:             buildVersion,
+		Manifest:                  mft,
+		BuildID:                   buildID,
+		ExtraEnvironmentVariables: environmentVariables,
 	}
 
 	// create ci builder job
@@ -229,7 +356,23 @@ func (w *eventWorkerImpl) CreateJobForBitbucketPush(pushEvent bbcontracts.Reposi
 				Interface("params", ciBuilderParams).
 				Msgf("Creating estafette-ci-builder job for Bitbucket repository %v/%v revision %v failed", ciBuilderParams.RepoOwner, ciBuilderParams.RepoName, ciBuilderParams.RepoRevision)
 
-			return
+			return &insertedBuild, err
 		}
+
+		// report the build as in progress straightaway, so a builder that never starts doesn't leave bitbucket without status
+		go w.setBuildStatus(accessToken, insertedBuild)
+	}
+
+	return &insertedBuild, nil
+}
+
+func (w *eventWorkerImpl) setBuildStatus(accessToken bbcontracts.AccessToken, build cockroach.Build) {
+
+	detailsURL := fmt.Sprintf("%vpipelines/%v/%v/%v/builds/%v/logs", w.apiServerConfig.BaseURL, build.RepoSource, build.RepoOwner, build.RepoName, build.ID)
+	fullRepoName := fmt.Sprintf("%v/%v", build.RepoOwner, build.RepoName)
+
+	err := w.apiClient.SetBuildStatus(accessToken, fullRepoName, build.RepoRevision, getBuildStatus(build.Build, detailsURL))
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed setting build status for Bitbucket repository %v revision %v", fullRepoName, build.RepoRevision)
 	}
 }
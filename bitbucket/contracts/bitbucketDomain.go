@@ -190,7 +190,91 @@ func (pe *RepositoryPushEvent) GetRepoBranch() string {
 	return pe.Push.Changes[0].New.Name
 }
 
+// GetRepoTag returns the tag of the push event, or an empty string if a branch was pushed
+func (pe *RepositoryPushEvent) GetRepoTag() string {
+	if pe.Push.Changes[0].New.Type == "tag" {
+		return pe.Push.Changes[0].New.Name
+	}
+	return ""
+}
+
 // GetRepoRevision returns the revision of the push event
 func (pe *RepositoryPushEvent) GetRepoRevision() string {
 	return pe.Push.Changes[0].New.Target.Hash
 }
+
+// DiffStat represents a page of files changed between two Bitbucket commits
+type DiffStat struct {
+	Values []DiffStatValue `json:"values"`
+	Next   string          `json:"next,omitempty"`
+}
+
+// DiffStatValue represents a single changed file; Old is nil for added files and New for removed files
+type DiffStatValue struct {
+	Status string        `json:"status"`
+	Old    *DiffStatFile `json:"old,omitempty"`
+	New    *DiffStatFile `json:"new,omitempty"`
+}
+
+// DiffStatFile represents the path of a changed file
+type DiffStatFile struct {
+	Path string `json:"path"`
+}
+
+// GetDeletedBranch returns the branch deleted by the push event, or an empty string if no branch was deleted
+func (pe *RepositoryPushEvent) GetDeletedBranch() string {
+	if len(pe.Push.Changes) > 0 && pe.Push.Changes[0].New == nil && pe.Push.Changes[0].Old != nil && pe.Push.Changes[0].Old.Type == "branch" {
+		return pe.Push.Changes[0].Old.Name
+	}
+	return ""
+}
+
+// RepositoryTransferEvent represents a Bitbucket repo:transfer event
+type RepositoryTransferEvent struct {
+	Actor         Owner      `json:"actor"`
+	Repository    Repository `json:"repository"`
+	PreviousOwner Owner      `json:"previous_owner"`
+}
+
+// RepositoryUpdatedEvent represents a Bitbucket repo:updated event
+type RepositoryUpdatedEvent struct {
+	Actor      Owner             `json:"actor"`
+	Repository Repository        `json:"repository"`
+	Changes    RepositoryChanges `json:"changes"`
+}
+
+// RepositoryChanges represents the old and new values of the changed properties of a Bitbucket repository
+type RepositoryChanges struct {
+	FullName *RepositoryChange `json:"full_name,omitempty"`
+}
+
+// RepositoryChange represents the old and new value of a single property
+type RepositoryChange struct {
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+// RepositoryDeletedEvent represents a Bitbucket repo:deleted event
+type RepositoryDeletedEvent struct {
+	Actor      Owner      `json:"actor"`
+	Repository Repository `json:"repository"`
+}
+
+// GetRepoOwner returns the repository owner
+func (r *Repository) GetRepoOwner() string {
+	return strings.Split(r.FullName, "/")[0]
+}
+
+// GetRepoName returns the repository name
+func (r *Repository) GetRepoName() string {
+	return strings.Split(r.FullName, "/")[1]
+}
+
+// BuildStatus represents the status of a build for a Bitbucket commit; statuses with the same key replace each other
+type BuildStatus struct {
+	State       string `json:"state"`
+	Key         string `json:"key"`
+	Name        string `json:"name,omitempty"`
+	URL         string `json:"url"`
+	Description string `json:"description,omitempty"`
+}
@@ -73,3 +73,44 @@ func TestCommit(t *testing.T) {
 		assert.Equal(t, "log api call response body on error only", message)
 	})
 }
+
+func TestRepositoryPushEventGetDeletedBranch(t *testing.T) {
+
+	t.Run("ReturnsOldBranchNameIfBranchIsDeleted", func(t *testing.T) {
+
+		pe := RepositoryPushEvent{
+			Push: PushEvent{
+				Changes: []PushEventChange{
+					PushEventChange{
+						Old:    &PushEventChangeObject{Type: "branch", Name: "feature-x"},
+						Closed: true,
+					},
+				},
+			},
+		}
+
+		// act
+		branch := pe.GetDeletedBranch()
+
+		assert.Equal(t, "feature-x", branch)
+	})
+
+	t.Run("ReturnsEmptyStringIfBranchIsPushed", func(t *testing.T) {
+
+		pe := RepositoryPushEvent{
+			Push: PushEvent{
+				Changes: []PushEventChange{
+					PushEventChange{
+						Old: &PushEventChangeObject{Type: "branch", Name: "feature-x"},
+						New: &PushEventChangeObject{Type: "branch", Name: "feature-x"},
+					},
+				},
+			},
+		}
+
+		// act
+		branch := pe.GetDeletedBranch()
+
+		assert.Equal(t, "", branch)
+	})
+}
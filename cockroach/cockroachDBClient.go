@@ -24,47 +24,74 @@ type DBClient interface {
 	Connect() error
 	ConnectWithDriverAndSource(string, string) error
 	GetAutoIncrement(string, string) (int, error)
+	ClaimCronTrigger(string, string, string, string, string, time.Time) (bool, error)
+	GetCronTriggers() ([]*CronTrigger, error)
 
-	InsertBuild(contracts.Build) (contracts.Build, error)
+	InsertBuild(Build) (Build, error)
 	UpdateBuildStatus(string, string, string, int, string) error
-	InsertRelease(contracts.Release) (contracts.Release, error)
+	InsertRelease(Release) (Release, error)
 	UpdateReleaseStatus(string, string, string, int, string) error
 	InsertBuildLog(contracts.BuildLog) error
 	InsertReleaseLog(contracts.ReleaseLog) error
 
 	UpsertComputedPipeline(string, string, string) error
+	UpsertCronTriggers(Build) error
 	UpdateComputedPipelineFirstInsertedAt(string, string, string) error
 	UpsertComputedRelease(string, string, string, string, string) error
 	UpdateComputedReleaseFirstInsertedAt(string, string, string, string, string) error
+	RenamePipeline(string, string, string, string, string) error
+	ArchiveComputedPipeline(string, string, string) error
+	UnarchiveComputedPipeline(string, string, string) error
 
-	GetPipelines(int, int, map[string][]string, bool) ([]*contracts.Pipeline, error)
-	GetPipelinesByRepoName(string, bool) ([]*contracts.Pipeline, error)
+	GetPipelines(int, int, map[string][]string, bool) ([]*Pipeline, error)
+	GetPipelinesByRepoName(string, bool) ([]*Pipeline, error)
+	GetPipelinesWithManifestContaining(string) ([]*Pipeline, error)
 	GetPipelinesCount(map[string][]string) (int, error)
-	GetPipeline(string, string, string, bool) (*contracts.Pipeline, error)
-	GetPipelineBuilds(string, string, string, int, int, map[string][]string, bool) ([]*contracts.Build, error)
+	GetPipeline(string, string, string, bool) (*Pipeline, error)
+	GetPipelineBuilds(string, string, string, int, int, map[string][]string, bool) ([]*Build, error)
 	GetPipelineBuildsCount(string, string, string, map[string][]string) (int, error)
-	GetPipelineBuild(string, string, string, string, bool) (*contracts.Build, error)
-	GetPipelineBuildByID(string, string, string, int, bool) (*contracts.Build, error)
-	GetLastPipelineBuild(string, string, string, bool) (*contracts.Build, error)
-	GetFirstPipelineBuild(string, string, string, bool) (*contracts.Build, error)
-	GetLastPipelineRelease(string, string, string, string, string) (*contracts.Release, error)
-	GetFirstPipelineRelease(string, string, string, string, string) (*contracts.Release, error)
-	GetPipelineBuildsByVersion(string, string, string, string, bool) ([]*contracts.Build, error)
+	GetPipelineBuild(string, string, string, string, bool) (*Build, error)
+	GetPipelineBuildByID(string, string, string, int, bool) (*Build, error)
+	GetLastPipelineBuild(string, string, string, bool) (*Build, error)
+	GetFirstPipelineBuild(string, string, string, bool) (*Build, error)
+	GetLastPipelineRelease(string, string, string, string, string) (*Release, error)
+	GetFirstPipelineRelease(string, string, string, string, string) (*Release, error)
+	GetPipelineBuildsByVersion(string, string, string, string, bool) ([]*Build, error)
 	GetPipelineBuildLogs(string, string, string, string, string, string) (*contracts.BuildLog, error)
-	GetPipelineReleases(string, string, string, int, int, map[string][]string) ([]*contracts.Release, error)
+	GetPipelineReleases(string, string, string, int, int, map[string][]string) ([]*Release, error)
 	GetPipelineReleasesCount(string, string, string, map[string][]string) (int, error)
-	GetPipelineRelease(string, string, string, int) (*contracts.Release, error)
+	GetPipelineRelease(string, string, string, int) (*Release, error)
 	GetPipelineLastReleasesByName(string, string, string, string, []string) ([]contracts.Release, error)
 	GetPipelineReleaseLogs(string, string, string, int) (*contracts.ReleaseLog, error)
 	GetBuildsCount(map[string][]string) (int, error)
 	GetReleasesCount(map[string][]string) (int, error)
 	GetBuildsDuration(map[string][]string) (time.Duration, error)
-	GetFirstBuildTimes() ([]time.Time, error)
-	GetFirstReleaseTimes() ([]time.Time, error)
+	GetFirstBuildTimes(map[string][]string) ([]time.Time, error)
+	GetFirstReleaseTimes(map[string][]string) ([]time.Time, error)
 	GetPipelineBuildsDurations(string, string, string, map[string][]string) ([]map[string]interface{}, error)
 	GetPipelineReleasesDurations(string, string, string, map[string][]string) ([]map[string]interface{}, error)
 
+	GetManagedCredentials() ([]*contracts.CredentialConfig, error)
+	GetManagedCredential(string) (*contracts.CredentialConfig, error)
+	InsertManagedCredential(contracts.CredentialConfig, string) error
+	UpdateManagedCredential(contracts.CredentialConfig, string) error
+	DeleteManagedCredential(string, string) error
+	GetManagedTrustedImages() ([]*contracts.TrustedImageConfig, error)
+	GetManagedTrustedImage(string) (*contracts.TrustedImageConfig, error)
+	InsertManagedTrustedImage(contracts.TrustedImageConfig, string) error
+	UpdateManagedTrustedImage(contracts.TrustedImageConfig, string) error
+	DeleteManagedTrustedImage(string, string) error
+	GetManagedConfigAuditLog(int, int) ([]*ManagedConfigAuditLogEntry, error)
+
+	GetManifestTemplates() ([]*ManifestTemplate, error)
+	GetManifestTemplate(string, int) (*ManifestTemplate, error)
+	GetManifestTemplateVersions(string) ([]*ManifestTemplate, error)
+	InsertManifestTemplate(ManifestTemplate) (*ManifestTemplate, error)
+	DeleteManifestTemplate(string) error
+
 	selectBuildsQuery() sq.SelectBuilder
+	selectLastPipelineBuildQuery(string, string, string) sq.SelectBuilder
+	selectCronTriggersQuery() sq.SelectBuilder
 	selectPipelinesQuery() sq.SelectBuilder
 	selectReleasesQuery() sq.SelectBuilder
 }
@@ -179,7 +206,103 @@ func (dbc *cockroachDBClientImpl) GetAutoIncrement(gitSource, gitFullname string
 	return
 }
 
-func (dbc *cockroachDBClientImpl) InsertBuild(build contracts.Build) (insertedBuild contracts.Build, err error) {
+// ClaimCronTrigger records the firing of a cron trigger for a scheduled time; only the first api replica to do so gets claimed set to true
+func (dbc *cockroachDBClientImpl) ClaimCronTrigger(repoSource, repoOwner, repoName, cronSchedule, repoBranch string, scheduledAt time.Time) (claimed bool, err error) {
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+
+	result, err := dbc.databaseConnection.Exec(
+		`
+		INSERT INTO
+			cron_trigger_runs
+		(
+			repo_source,
+			repo_owner,
+			repo_name,
+			cron_schedule,
+			repo_branch,
+			scheduled_at
+		)
+		VALUES
+		(
+			$1,
+			$2,
+			$3,
+			$4,
+			$5,
+			$6
+		)
+		ON CONFLICT
+		(
+			repo_source,
+			repo_owner,
+			repo_name,
+			cron_schedule,
+			repo_branch,
+			scheduled_at
+		)
+		DO NOTHING
+		`,
+		repoSource,
+		repoOwner,
+		repoName,
+		cronSchedule,
+		repoBranch,
+		scheduledAt,
+	)
+	if err != nil {
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return
+	}
+
+	return rowsAffected == 1, nil
+}
+
+// GetCronTriggers returns the cron triggers of all pipelines that aren't archived, as stored from the manifest of the last build of each branch
+func (dbc *cockroachDBClientImpl) GetCronTriggers() (cronTriggers []*CronTrigger, err error) {
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+
+	rows, err := dbc.selectCronTriggersQuery().RunWith(dbc.databaseConnection).Query()
+	if err != nil {
+		return
+	}
+
+	cronTriggers = make([]*CronTrigger, 0)
+
+	defer rows.Close()
+	for rows.Next() {
+		cronTrigger := CronTrigger{}
+
+		if err = rows.Scan(
+			&cronTrigger.RepoSource,
+			&cronTrigger.RepoOwner,
+			&cronTrigger.RepoName,
+			&cronTrigger.RepoBranch,
+			&cronTrigger.Cron); err != nil {
+			return
+		}
+
+		cronTriggers = append(cronTriggers, &cronTrigger)
+	}
+
+	return
+}
+
+func (dbc *cockroachDBClientImpl) selectCronTriggersQuery() sq.SelectBuilder {
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	return psql.
+		Select("a.repo_source, a.repo_owner, a.repo_name, a.repo_branch, a.cron_schedule").
+		From("cron_triggers a").
+		Join("computed_pipelines b ON a.repo_source = b.repo_source AND a.repo_owner = b.repo_owner AND a.repo_name = b.repo_name").
+		Where(sq.Eq{"b.archived": false}).
+		OrderBy("a.repo_source, a.repo_owner, a.repo_name, a.repo_branch, a.cron_schedule")
+}
+
+func (dbc *cockroachDBClientImpl) InsertBuild(build Build) (insertedBuild Build, err error) {
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
 	sort.Slice(build.Labels, func(i, j int) bool {
@@ -210,12 +333,15 @@ func (dbc *cockroachDBClientImpl) InsertBuild(build contracts.Build) (insertedBu
 			repo_name,
 			repo_branch,
 			repo_revision,
+			repo_tag,
 			build_version,
 			build_status,
 			labels,
 			release_targets,
 			manifest,
-			commits
+			commits,
+			trigger_reason,
+			skip_reason
 		)
 		VALUES
 		(
@@ -229,7 +355,10 @@ func (dbc *cockroachDBClientImpl) InsertBuild(build contracts.Build) (insertedBu
 			$8,
 			$9,
 			$10,
-			$11
+			$11,
+			$12,
+			$13,
+			$14
 		)
 		RETURNING
 			id
@@ -239,12 +368,15 @@ func (dbc *cockroachDBClientImpl) InsertBuild(build contracts.Build) (insertedBu
 		build.RepoName,
 		build.RepoBranch,
 		build.RepoRevision,
+		build.RepoTag,
 		build.BuildVersion,
 		build.BuildStatus,
 		labelsBytes,
 		releaseTargetsBytes,
 		build.Manifest,
 		commitsBytes,
+		build.TriggerReason,
+		build.SkipReason,
 	)
 
 	insertedBuild = build
@@ -253,8 +385,127 @@ func (dbc *cockroachDBClientImpl) InsertBuild(build contracts.Build) (insertedBu
 		return
 	}
 
-	// update computed tables
-	go dbc.UpsertComputedPipeline(insertedBuild.RepoSource, insertedBuild.RepoOwner, insertedBuild.RepoName)
+	// update computed tables; a skipped build leaves the pipeline's last build as it was
+	if updatesComputedPipeline(insertedBuild) {
+		go dbc.UpsertComputedPipeline(insertedBuild.RepoSource, insertedBuild.RepoOwner, insertedBuild.RepoName)
+	}
+	go dbc.UpsertCronTriggers(insertedBuild)
+
+	return
+}
+
+// updatesComputedPipeline returns false for builds skipped by a skip directive or the paths filter; they have no version and didn't run, so the pipeline keeps showing its last real build
+func updatesComputedPipeline(build Build) bool {
+	return build.BuildStatus != "skipped"
+}
+
+// UpsertCronTriggers replaces the stored cron triggers of the build's branch with the ones in its manifest, so the cron trigger scheduler doesn't have to read the manifests of all pipelines every minute
+func (dbc *cockroachDBClientImpl) UpsertCronTriggers(build Build) (err error) {
+
+	cronTriggers, err := getCronTriggers(build)
+	if err != nil {
+		log.Warn().Err(err).Msgf("Failed reading cron triggers from manifest of build %v of %v/%v/%v, keeping the stored ones", build.ID, build.RepoSource, build.RepoOwner, build.RepoName)
+		return
+	}
+
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+
+	tx, err := dbc.databaseConnection.Begin()
+	if err != nil {
+		return
+	}
+
+	_, err = tx.Exec(
+		`
+		DELETE FROM
+			cron_triggers
+		WHERE
+			repo_source=$1 AND
+			repo_owner=$2 AND
+			repo_name=$3 AND
+			repo_branch=$4
+		`,
+		build.RepoSource,
+		build.RepoOwner,
+		build.RepoName,
+		build.RepoBranch,
+	)
+	if err != nil {
+		tx.Rollback()
+		log.Error().Err(err).Msgf("Failed removing cron triggers of %v/%v/%v and branch %v", build.RepoSource, build.RepoOwner, build.RepoName, build.RepoBranch)
+		return
+	}
+
+	for _, t := range cronTriggers {
+		_, err = tx.Exec(
+			`
+			UPSERT INTO
+				cron_triggers
+			(
+				repo_source,
+				repo_owner,
+				repo_name,
+				repo_branch,
+				cron_schedule
+			)
+			VALUES
+			(
+				$1,
+				$2,
+				$3,
+				$4,
+				$5
+			)
+			`,
+			t.RepoSource,
+			t.RepoOwner,
+			t.RepoName,
+			t.RepoBranch,
+			t.Cron,
+		)
+		if err != nil {
+			tx.Rollback()
+			log.Error().Err(err).Msgf("Failed storing cron trigger '%v' of %v/%v/%v and branch %v", t.Cron, t.RepoSource, t.RepoOwner, t.RepoName, t.RepoBranch)
+			return
+		}
+	}
+
+	return tx.Commit()
+}
+
+// getCronTriggers reads the cron triggers for the build's branch from the triggers section of its manifest; a trigger's branch defaults to master and triggers for other branches are left to the builds of those branches
+func getCronTriggers(build Build) (cronTriggers []*CronTrigger, err error) {
+
+	var aux struct {
+		Triggers []struct {
+			Cron   string `yaml:"cron"`
+			Branch string `yaml:"branch"`
+		} `yaml:"triggers"`
+	}
+
+	if err = yaml.Unmarshal([]byte(build.Manifest), &aux); err != nil {
+		return
+	}
+
+	cronTriggers = make([]*CronTrigger, 0)
+	for _, t := range aux.Triggers {
+		if t.Cron == "" {
+			continue
+		}
+		if t.Branch == "" {
+			t.Branch = "master"
+		}
+		if t.Branch != build.RepoBranch {
+			continue
+		}
+		cronTriggers = append(cronTriggers, &CronTrigger{
+			RepoSource: build.RepoSource,
+			RepoOwner:  build.RepoOwner,
+			RepoName:   build.RepoName,
+			RepoBranch: t.Branch,
+			Cron:       t.Cron,
+		})
+	}
 
 	return
 }
@@ -294,7 +545,7 @@ func (dbc *cockroachDBClientImpl) UpdateBuildStatus(repoSource, repoOwner, repoN
 	return
 }
 
-func (dbc *cockroachDBClientImpl) InsertRelease(release contracts.Release) (insertedRelease contracts.Release, err error) {
+func (dbc *cockroachDBClientImpl) InsertRelease(release Release) (insertedRelease Release, err error) {
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
 	// insert logs
@@ -310,7 +561,9 @@ func (dbc *cockroachDBClientImpl) InsertRelease(release contracts.Release) (inse
 			release_action,
 			release_version,
 			release_status,
-			triggered_by
+			triggered_by,
+			is_rollback,
+			deployment_id
 		)
 		VALUES
 		(
@@ -321,7 +574,9 @@ func (dbc *cockroachDBClientImpl) InsertRelease(release contracts.Release) (inse
 			$5,
 			$6,
 			$7,
-			$8
+			$8,
+			$9,
+			$10
 		)
 		RETURNING 
 			id
@@ -334,6 +589,8 @@ func (dbc *cockroachDBClientImpl) InsertRelease(release contracts.Release) (inse
 		release.ReleaseVersion,
 		release.ReleaseStatus,
 		release.TriggeredBy,
+		release.IsRollback,
+		release.DeploymentID,
 	)
 
 	if err != nil {
@@ -389,6 +646,8 @@ func (dbc *cockroachDBClientImpl) UpdateReleaseStatus(repoSource, repoOwner, rep
 			release_version,
 			release_status,
 			triggered_by,
+			is_rollback,
+			deployment_id,
 			inserted_at,
 			updated_at,
 			duration::INT
@@ -750,7 +1009,9 @@ func (dbc *cockroachDBClientImpl) UpsertComputedRelease(repoSource, repoOwner, r
 			updated_at,
 			triggered_by,
 			duration,
-			release_action
+			release_action,
+			is_rollback,
+			deployment_id
 		)
 		VALUES
 		(
@@ -766,7 +1027,9 @@ func (dbc *cockroachDBClientImpl) UpsertComputedRelease(repoSource, repoOwner, r
 			$9,
 			$10,
 			AGE($9,$8),
-			$11
+			$11,
+			$12,
+			$13
 		)
 		ON CONFLICT
 		(
@@ -783,7 +1046,9 @@ func (dbc *cockroachDBClientImpl) UpsertComputedRelease(repoSource, repoOwner, r
 			inserted_at = excluded.inserted_at,
 			updated_at = excluded.updated_at,
 			triggered_by = excluded.triggered_by,
-			duration = AGE(excluded.updated_at,excluded.inserted_at)
+			duration = AGE(excluded.updated_at,excluded.inserted_at),
+			is_rollback = excluded.is_rollback,
+			deployment_id = excluded.deployment_id
 		`,
 		lastRelease.ID,
 		lastRelease.RepoSource,
@@ -796,6 +1061,8 @@ func (dbc *cockroachDBClientImpl) UpsertComputedRelease(repoSource, repoOwner, r
 		lastRelease.UpdatedAt,
 		lastRelease.TriggeredBy,
 		lastRelease.Action,
+		lastRelease.IsRollback,
+		lastRelease.DeploymentID,
 	)
 	if err != nil {
 		log.Error().Err(err).Msgf("Failed upserting computed release %v/%v/%v/%v/%v", repoSource, repoOwner, repoName, releaseName, releaseAction)
@@ -848,7 +1115,136 @@ func (dbc *cockroachDBClientImpl) UpdateComputedReleaseFirstInsertedAt(repoSourc
 	return
 }
 
-func (dbc *cockroachDBClientImpl) GetPipelines(pageNumber, pageSize int, filters map[string][]string, optimized bool) (pipelines []*contracts.Pipeline, err error) {
+// RenamePipeline moves all builds, releases and computed rows of a pipeline to a new owner and/or name in a single transaction; it returns an error without moving anything if computed rows or build versions for the new name are left over
+func (dbc *cockroachDBClientImpl) RenamePipeline(repoSource, fromRepoOwner, fromRepoName, toRepoOwner, toRepoName string) (err error) {
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+
+	tx, err := dbc.databaseConnection.Begin()
+	if err != nil {
+		return
+	}
+
+	// build_versions is keyed by the short source name the event workers use, like github instead of github.com
+	gitSource := strings.Split(repoSource, ".")[0]
+
+	// the computed rows and build versions are unique per pipeline, so leftovers of an earlier pipeline with the new name would make the move fail halfway
+	conflictChecks := []struct {
+		table string
+		query string
+		args  []interface{}
+	}{
+		{"computed_pipelines", "SELECT COUNT(*) FROM computed_pipelines WHERE repo_source=$1 AND repo_owner=$2 AND repo_name=$3", []interface{}{repoSource, toRepoOwner, toRepoName}},
+		{"computed_releases", "SELECT COUNT(*) FROM computed_releases WHERE repo_source=$1 AND repo_owner=$2 AND repo_name=$3", []interface{}{repoSource, toRepoOwner, toRepoName}},
+		{"build_versions", "SELECT COUNT(*) FROM build_versions WHERE repo_source=$1 AND repo_full_name=$2", []interface{}{gitSource, fmt.Sprintf("%v/%v", toRepoOwner, toRepoName)}},
+	}
+	for _, c := range conflictChecks {
+		var count int
+		if err = tx.QueryRow(c.query, c.args...).Scan(&count); err != nil {
+			tx.Rollback()
+			return
+		}
+		if count > 0 {
+			tx.Rollback()
+			return fmt.Errorf("Pipeline %v/%v/%v can't be renamed to %v/%v/%v because table %v still has rows for that name; remove them first", repoSource, fromRepoOwner, fromRepoName, repoSource, toRepoOwner, toRepoName, c.table)
+		}
+	}
+
+	tables := []string{"builds", "build_logs", "releases", "release_logs", "computed_pipelines", "computed_releases", "cron_trigger_runs", "cron_triggers"}
+	for _, table := range tables {
+		_, err = tx.Exec(
+			fmt.Sprintf(`
+			UPDATE
+				%v
+			SET
+				repo_owner=$1,
+				repo_name=$2
+			WHERE
+				repo_source=$3 AND
+				repo_owner=$4 AND
+				repo_name=$5
+			`, table),
+			toRepoOwner,
+			toRepoName,
+			repoSource,
+			fromRepoOwner,
+			fromRepoName,
+		)
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+	}
+
+	_, err = tx.Exec(
+		`
+		UPDATE
+			build_versions
+		SET
+			repo_full_name=$1,
+			updated_at=now()
+		WHERE
+			repo_source=$2 AND
+			repo_full_name=$3
+		`,
+		fmt.Sprintf("%v/%v", toRepoOwner, toRepoName),
+		gitSource,
+		fmt.Sprintf("%v/%v", fromRepoOwner, fromRepoName),
+	)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	return tx.Commit()
+}
+
+// ArchiveComputedPipeline flags a pipeline as archived, for example when its repository has been deleted
+func (dbc *cockroachDBClientImpl) ArchiveComputedPipeline(repoSource, repoOwner, repoName string) (err error) {
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+
+	_, err = dbc.databaseConnection.Exec(
+		`
+		UPDATE
+			computed_pipelines
+		SET
+			archived=true
+		WHERE
+			repo_source=$1 AND
+			repo_owner=$2 AND
+			repo_name=$3
+		`,
+		repoSource,
+		repoOwner,
+		repoName,
+	)
+
+	return
+}
+
+// UnarchiveComputedPipeline clears the archived flag of a pipeline, so it shows up and builds again
+func (dbc *cockroachDBClientImpl) UnarchiveComputedPipeline(repoSource, repoOwner, repoName string) (err error) {
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+
+	_, err = dbc.databaseConnection.Exec(
+		`
+		UPDATE
+			computed_pipelines
+		SET
+			archived=false
+		WHERE
+			repo_source=$1 AND
+			repo_owner=$2 AND
+			repo_name=$3
+		`,
+		repoSource,
+		repoOwner,
+		repoName,
+	)
+
+	return
+}
+
+func (dbc *cockroachDBClientImpl) GetPipelines(pageNumber, pageSize int, filters map[string][]string, optimized bool) (pipelines []*Pipeline, err error) {
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
@@ -863,6 +1259,10 @@ func (dbc *cockroachDBClientImpl) GetPipelines(pageNumber, pageSize int, filters
 	if err != nil {
 		return
 	}
+	query, err = whereClauseGeneratorForArchivedFilter(query, "a", filters)
+	if err != nil {
+		return
+	}
 
 	// execute query
 	rows, err := query.RunWith(dbc.databaseConnection).Query()
@@ -878,7 +1278,7 @@ func (dbc *cockroachDBClientImpl) GetPipelines(pageNumber, pageSize int, filters
 	return
 }
 
-func (dbc *cockroachDBClientImpl) GetPipelinesByRepoName(repoName string, optimized bool) (pipelines []*contracts.Pipeline, err error) {
+func (dbc *cockroachDBClientImpl) GetPipelinesByRepoName(repoName string, optimized bool) (pipelines []*Pipeline, err error) {
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
@@ -900,6 +1300,33 @@ func (dbc *cockroachDBClientImpl) GetPipelinesByRepoName(repoName string, optimi
 	return
 }
 
+// likeEscaper escapes the wildcards of a LIKE pattern, so a value is matched literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// GetPipelinesWithManifestContaining returns the pipelines whose latest manifest contains a string, including their manifest for further inspection
+func (dbc *cockroachDBClientImpl) GetPipelinesWithManifestContaining(value string) (pipelines []*Pipeline, err error) {
+
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+
+	// generate query
+	query := dbc.selectPipelinesQuery().
+		Where("a.manifest LIKE ?", fmt.Sprintf("%%%v%%", likeEscaper.Replace(value))).
+		OrderBy("a.repo_source,a.repo_owner,a.repo_name")
+
+	// execute query
+	rows, err := query.RunWith(dbc.databaseConnection).Query()
+	if err != nil {
+		return
+	}
+
+	// read rows
+	if pipelines, err = dbc.scanPipelines(rows, false); err != nil {
+		return
+	}
+
+	return
+}
+
 func (dbc *cockroachDBClientImpl) GetPipelinesCount(filters map[string][]string) (totalCount int, err error) {
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
@@ -915,6 +1342,10 @@ func (dbc *cockroachDBClientImpl) GetPipelinesCount(filters map[string][]string)
 	if err != nil {
 		return
 	}
+	query, err = whereClauseGeneratorForArchivedFilter(query, "a", filters)
+	if err != nil {
+		return
+	}
 
 	// execute query
 	row := query.RunWith(dbc.databaseConnection).QueryRow()
@@ -925,7 +1356,7 @@ func (dbc *cockroachDBClientImpl) GetPipelinesCount(filters map[string][]string)
 	return
 }
 
-func (dbc *cockroachDBClientImpl) GetPipeline(repoSource, repoOwner, repoName string, optimized bool) (pipeline *contracts.Pipeline, err error) {
+func (dbc *cockroachDBClientImpl) GetPipeline(repoSource, repoOwner, repoName string, optimized bool) (pipeline *Pipeline, err error) {
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
@@ -946,7 +1377,7 @@ func (dbc *cockroachDBClientImpl) GetPipeline(repoSource, repoOwner, repoName st
 	return
 }
 
-func (dbc *cockroachDBClientImpl) GetPipelineBuilds(repoSource, repoOwner, repoName string, pageNumber, pageSize int, filters map[string][]string, optimized bool) (builds []*contracts.Build, err error) {
+func (dbc *cockroachDBClientImpl) GetPipelineBuilds(repoSource, repoOwner, repoName string, pageNumber, pageSize int, filters map[string][]string, optimized bool) (builds []*Build, err error) {
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
@@ -1007,7 +1438,7 @@ func (dbc *cockroachDBClientImpl) GetPipelineBuildsCount(repoSource, repoOwner,
 	return
 }
 
-func (dbc *cockroachDBClientImpl) GetPipelineBuild(repoSource, repoOwner, repoName, repoRevision string, optimized bool) (build *contracts.Build, err error) {
+func (dbc *cockroachDBClientImpl) GetPipelineBuild(repoSource, repoOwner, repoName, repoRevision string, optimized bool) (build *Build, err error) {
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
@@ -1029,7 +1460,7 @@ func (dbc *cockroachDBClientImpl) GetPipelineBuild(repoSource, repoOwner, repoNa
 	return
 }
 
-func (dbc *cockroachDBClientImpl) GetPipelineBuildByID(repoSource, repoOwner, repoName string, id int, optimized bool) (build *contracts.Build, err error) {
+func (dbc *cockroachDBClientImpl) GetPipelineBuildByID(repoSource, repoOwner, repoName string, id int, optimized bool) (build *Build, err error) {
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
@@ -1051,17 +1482,13 @@ func (dbc *cockroachDBClientImpl) GetPipelineBuildByID(repoSource, repoOwner, re
 	return
 }
 
-func (dbc *cockroachDBClientImpl) GetLastPipelineBuild(repoSource, repoOwner, repoName string, optimized bool) (build *contracts.Build, err error) {
+// GetLastPipelineBuild returns the newest build of a pipeline that wasn't skipped
+func (dbc *cockroachDBClientImpl) GetLastPipelineBuild(repoSource, repoOwner, repoName string, optimized bool) (build *Build, err error) {
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
 	// generate query
-	query := dbc.selectBuildsQuery().
-		Where(sq.Eq{"a.repo_source": repoSource}).
-		Where(sq.Eq{"a.repo_owner": repoOwner}).
-		Where(sq.Eq{"a.repo_name": repoName}).
-		OrderBy("a.inserted_at DESC").
-		Limit(uint64(1))
+	query := dbc.selectLastPipelineBuildQuery(repoSource, repoOwner, repoName)
 
 	// execute query
 	row := query.RunWith(dbc.databaseConnection).QueryRow()
@@ -1072,7 +1499,17 @@ func (dbc *cockroachDBClientImpl) GetLastPipelineBuild(repoSource, repoOwner, re
 	return
 }
 
-func (dbc *cockroachDBClientImpl) GetFirstPipelineBuild(repoSource, repoOwner, repoName string, optimized bool) (build *contracts.Build, err error) {
+func (dbc *cockroachDBClientImpl) selectLastPipelineBuildQuery(repoSource, repoOwner, repoName string) sq.SelectBuilder {
+	return dbc.selectBuildsQuery().
+		Where(sq.Eq{"a.repo_source": repoSource}).
+		Where(sq.Eq{"a.repo_owner": repoOwner}).
+		Where(sq.Eq{"a.repo_name": repoName}).
+		Where(sq.NotEq{"a.build_status": "skipped"}).
+		OrderBy("a.inserted_at DESC").
+		Limit(uint64(1))
+}
+
+func (dbc *cockroachDBClientImpl) GetFirstPipelineBuild(repoSource, repoOwner, repoName string, optimized bool) (build *Build, err error) {
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
@@ -1093,7 +1530,7 @@ func (dbc *cockroachDBClientImpl) GetFirstPipelineBuild(repoSource, repoOwner, r
 	return
 }
 
-func (dbc *cockroachDBClientImpl) GetLastPipelineRelease(repoSource, repoOwner, repoName, releaseName, releaseAction string) (release *contracts.Release, err error) {
+func (dbc *cockroachDBClientImpl) GetLastPipelineRelease(repoSource, repoOwner, repoName, releaseName, releaseAction string) (release *Release, err error) {
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
@@ -1116,7 +1553,7 @@ func (dbc *cockroachDBClientImpl) GetLastPipelineRelease(repoSource, repoOwner,
 	return
 }
 
-func (dbc *cockroachDBClientImpl) GetFirstPipelineRelease(repoSource, repoOwner, repoName, releaseName, releaseAction string) (release *contracts.Release, err error) {
+func (dbc *cockroachDBClientImpl) GetFirstPipelineRelease(repoSource, repoOwner, repoName, releaseName, releaseAction string) (release *Release, err error) {
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
@@ -1139,7 +1576,7 @@ func (dbc *cockroachDBClientImpl) GetFirstPipelineRelease(repoSource, repoOwner,
 	return
 }
 
-func (dbc *cockroachDBClientImpl) GetPipelineBuildsByVersion(repoSource, repoOwner, repoName, buildVersion string, optimized bool) (builds []*contracts.Build, err error) {
+func (dbc *cockroachDBClientImpl) GetPipelineBuildsByVersion(repoSource, repoOwner, repoName, buildVersion string, optimized bool) (builds []*Build, err error) {
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
 	// generate query
@@ -1218,7 +1655,7 @@ func (dbc *cockroachDBClientImpl) GetPipelineBuildLogs(repoSource, repoOwner, re
 	return
 }
 
-func (dbc *cockroachDBClientImpl) GetPipelineReleases(repoSource, repoOwner, repoName string, pageNumber, pageSize int, filters map[string][]string) (releases []*contracts.Release, err error) {
+func (dbc *cockroachDBClientImpl) GetPipelineReleases(repoSource, repoOwner, repoName string, pageNumber, pageSize int, filters map[string][]string) (releases []*Release, err error) {
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
@@ -1279,7 +1716,7 @@ func (dbc *cockroachDBClientImpl) GetPipelineReleasesCount(repoSource, repoOwner
 	return
 }
 
-func (dbc *cockroachDBClientImpl) GetPipelineRelease(repoSource, repoOwner, repoName string, id int) (release *contracts.Release, err error) {
+func (dbc *cockroachDBClientImpl) GetPipelineRelease(repoSource, repoOwner, repoName string, id int) (release *Release, err error) {
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
@@ -1326,7 +1763,7 @@ func (dbc *cockroachDBClientImpl) GetPipelineLastReleasesByName(repoSource, repo
 	}
 
 	// read rows
-	releasesPointers := []*contracts.Release{}
+	releasesPointers := []*Release{}
 	if releasesPointers, err = dbc.scanReleases(rows); err != nil {
 		return releases, err
 	}
@@ -1334,7 +1771,7 @@ func (dbc *cockroachDBClientImpl) GetPipelineLastReleasesByName(repoSource, repo
 	// copy pointer values
 	releases = make([]contracts.Release, 0)
 	for _, r := range releasesPointers {
-		releases = append(releases, *r)
+		releases = append(releases, r.Release)
 	}
 
 	return
@@ -1397,6 +1834,10 @@ func (dbc *cockroachDBClientImpl) GetBuildsCount(filters map[string][]string) (t
 	if err != nil {
 		return
 	}
+	query, err = whereClauseGeneratorForArchivedPipelineFilter(query, "a", filters)
+	if err != nil {
+		return
+	}
 
 	// execute query
 	row := query.RunWith(dbc.databaseConnection).QueryRow()
@@ -1422,7 +1863,11 @@ func (dbc *cockroachDBClientImpl) GetReleasesCount(filters map[string][]string)
 	if err != nil {
 		return
 	}
-
+	query, err = whereClauseGeneratorForArchivedPipelineFilter(query, "a", filters)
+	if err != nil {
+		return
+	}
+
 	// execute query
 	row := query.RunWith(dbc.databaseConnection).QueryRow()
 	if err = row.Scan(&totalCount); err != nil {
@@ -1447,6 +1892,10 @@ func (dbc *cockroachDBClientImpl) GetBuildsDuration(filters map[string][]string)
 	if err != nil {
 		return
 	}
+	query, err = whereClauseGeneratorForArchivedPipelineFilter(query, "a", filters)
+	if err != nil {
+		return
+	}
 
 	// execute query
 	row := query.RunWith(dbc.databaseConnection).QueryRow()
@@ -1465,7 +1914,7 @@ func (dbc *cockroachDBClientImpl) GetBuildsDuration(filters map[string][]string)
 	return
 }
 
-func (dbc *cockroachDBClientImpl) GetFirstBuildTimes() (buildTimes []time.Time, err error) {
+func (dbc *cockroachDBClientImpl) GetFirstBuildTimes(filters map[string][]string) (buildTimes []time.Time, err error) {
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
@@ -1476,6 +1925,12 @@ func (dbc *cockroachDBClientImpl) GetFirstBuildTimes() (buildTimes []time.Time,
 			From("computed_pipelines a").
 			OrderBy("a.first_inserted_at")
 
+	// dynamically set where clauses for filtering
+	query, err = whereClauseGeneratorForArchivedFilter(query, "a", filters)
+	if err != nil {
+		return
+	}
+
 	buildTimes = make([]time.Time, 0)
 
 	// execute query
@@ -1501,7 +1956,7 @@ func (dbc *cockroachDBClientImpl) GetFirstBuildTimes() (buildTimes []time.Time,
 	return
 }
 
-func (dbc *cockroachDBClientImpl) GetFirstReleaseTimes() (releaseTimes []time.Time, err error) {
+func (dbc *cockroachDBClientImpl) GetFirstReleaseTimes(filters map[string][]string) (releaseTimes []time.Time, err error) {
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
@@ -1513,6 +1968,12 @@ func (dbc *cockroachDBClientImpl) GetFirstReleaseTimes() (releaseTimes []time.Ti
 			GroupBy("a.repo_source,a.repo_owner,a.repo_name").
 			OrderBy("MIN(a.first_inserted_at)")
 
+	// dynamically set where clauses for filtering
+	query, err = whereClauseGeneratorForArchivedPipelineFilter(query, "a", filters)
+	if err != nil {
+		return
+	}
+
 	releaseTimes = make([]time.Time, 0)
 
 	rows, err := query.RunWith(dbc.databaseConnection).Query()
@@ -1658,6 +2119,468 @@ func (dbc *cockroachDBClientImpl) GetPipelineReleasesDurations(repoSource, repoO
 	return
 }
 
+// GetManagedCredentials returns the credentials managed through the api, ordered by name
+func (dbc *cockroachDBClientImpl) GetManagedCredentials() (credentials []*contracts.CredentialConfig, err error) {
+
+	credentials = make([]*contracts.CredentialConfig, 0)
+
+	items, err := dbc.getManagedConfigItems(managedConfigItemTypeCredential, "")
+	if err != nil {
+		return
+	}
+
+	for _, data := range items {
+		var credential contracts.CredentialConfig
+		if err = json.Unmarshal(data, &credential); err != nil {
+			return
+		}
+		credentials = append(credentials, &credential)
+	}
+
+	return
+}
+
+// GetManagedCredential returns the credential managed through the api with the name or nil if it doesn't exist
+func (dbc *cockroachDBClientImpl) GetManagedCredential(name string) (credential *contracts.CredentialConfig, err error) {
+
+	items, err := dbc.getManagedConfigItems(managedConfigItemTypeCredential, name)
+	if err != nil || len(items) == 0 {
+		return
+	}
+
+	credential = &contracts.CredentialConfig{}
+	err = json.Unmarshal(items[0], credential)
+
+	return
+}
+
+// InsertManagedCredential stores a new credential and records its creation by the user in the audit log; its secret values should already be encrypted
+func (dbc *cockroachDBClientImpl) InsertManagedCredential(credential contracts.CredentialConfig, userEmail string) error {
+	return dbc.writeManagedConfigItem(managedConfigItemTypeCredential, credential.Name, credential, "create", userEmail)
+}
+
+// UpdateManagedCredential replaces an existing credential and records the update by the user in the audit log; its secret values should already be encrypted
+func (dbc *cockroachDBClientImpl) UpdateManagedCredential(credential contracts.CredentialConfig, userEmail string) error {
+	return dbc.writeManagedConfigItem(managedConfigItemTypeCredential, credential.Name, credential, "update", userEmail)
+}
+
+// DeleteManagedCredential removes a credential and records the deletion by the user in the audit log
+func (dbc *cockroachDBClientImpl) DeleteManagedCredential(name, userEmail string) error {
+	return dbc.writeManagedConfigItem(managedConfigItemTypeCredential, name, nil, "delete", userEmail)
+}
+
+// GetManagedTrustedImages returns the trusted images managed through the api, ordered by path
+func (dbc *cockroachDBClientImpl) GetManagedTrustedImages() (trustedImages []*contracts.TrustedImageConfig, err error) {
+
+	trustedImages = make([]*contracts.TrustedImageConfig, 0)
+
+	items, err := dbc.getManagedConfigItems(managedConfigItemTypeTrustedImage, "")
+	if err != nil {
+		return
+	}
+
+	for _, data := range items {
+		var trustedImage contracts.TrustedImageConfig
+		if err = json.Unmarshal(data, &trustedImage); err != nil {
+			return
+		}
+		trustedImages = append(trustedImages, &trustedImage)
+	}
+
+	return
+}
+
+// GetManagedTrustedImage returns the trusted image managed through the api with the path or nil if it doesn't exist
+func (dbc *cockroachDBClientImpl) GetManagedTrustedImage(imagePath string) (trustedImage *contracts.TrustedImageConfig, err error) {
+
+	items, err := dbc.getManagedConfigItems(managedConfigItemTypeTrustedImage, imagePath)
+	if err != nil || len(items) == 0 {
+		return
+	}
+
+	trustedImage = &contracts.TrustedImageConfig{}
+	err = json.Unmarshal(items[0], trustedImage)
+
+	return
+}
+
+// InsertManagedTrustedImage stores a new trusted image and records its creation by the user in the audit log
+func (dbc *cockroachDBClientImpl) InsertManagedTrustedImage(trustedImage contracts.TrustedImageConfig, userEmail string) error {
+	return dbc.writeManagedConfigItem(managedConfigItemTypeTrustedImage, trustedImage.ImagePath, trustedImage, "create", userEmail)
+}
+
+// UpdateManagedTrustedImage replaces an existing trusted image and records the update by the user in the audit log
+func (dbc *cockroachDBClientImpl) UpdateManagedTrustedImage(trustedImage contracts.TrustedImageConfig, userEmail string) error {
+	return dbc.writeManagedConfigItem(managedConfigItemTypeTrustedImage, trustedImage.ImagePath, trustedImage, "update", userEmail)
+}
+
+// DeleteManagedTrustedImage removes a trusted image and records the deletion by the user in the audit log
+func (dbc *cockroachDBClientImpl) DeleteManagedTrustedImage(imagePath, userEmail string) error {
+	return dbc.writeManagedConfigItem(managedConfigItemTypeTrustedImage, imagePath, nil, "delete", userEmail)
+}
+
+// GetManagedConfigAuditLog returns the changes to managed credentials and trusted images, newest first
+func (dbc *cockroachDBClientImpl) GetManagedConfigAuditLog(pageNumber, pageSize int) (entries []*ManagedConfigAuditLogEntry, err error) {
+
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+
+	entries = make([]*ManagedConfigAuditLogEntry, 0)
+
+	query :=
+		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+			Select("a.id, a.item_type, a.name, a.action, a.user_email, a.data, a.inserted_at").
+			From("managed_config_audit_log a").
+			OrderBy("a.inserted_at DESC").
+			Limit(uint64(pageSize)).
+			Offset(uint64((pageNumber - 1) * pageSize))
+
+	rows, err := query.RunWith(dbc.databaseConnection).Query()
+	if err != nil {
+		return
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		entry := ManagedConfigAuditLogEntry{}
+		var data []uint8
+
+		if err = rows.Scan(
+			&entry.ID,
+			&entry.ItemType,
+			&entry.Name,
+			&entry.Action,
+			&entry.UserEmail,
+			&data,
+			&entry.InsertedAt); err != nil {
+			return
+		}
+		entry.Data = string(data)
+
+		entries = append(entries, &entry)
+	}
+
+	return
+}
+
+// GetManifestTemplates returns the latest version of all manifest templates, ordered by name
+func (dbc *cockroachDBClientImpl) GetManifestTemplates() (manifestTemplates []*ManifestTemplate, err error) {
+
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+
+	query :=
+		dbc.selectManifestTemplatesQuery().
+			Where("a.version = (SELECT MAX(b.version) FROM manifest_templates b WHERE b.name = a.name)").
+			OrderBy("a.name")
+
+	rows, err := query.RunWith(dbc.databaseConnection).Query()
+	if err != nil {
+		return
+	}
+
+	return dbc.scanManifestTemplates(rows)
+}
+
+// GetManifestTemplate returns a version of a manifest template, or its latest version if the version is 0; it returns nil if it doesn't exist
+func (dbc *cockroachDBClientImpl) GetManifestTemplate(name string, version int) (manifestTemplate *ManifestTemplate, err error) {
+
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+
+	query :=
+		dbc.selectManifestTemplatesQuery().
+			Where(sq.Eq{"a.name": name}).
+			OrderBy("a.version DESC").
+			Limit(uint64(1))
+
+	if version > 0 {
+		query = query.Where(sq.Eq{"a.version": version})
+	}
+
+	rows, err := query.RunWith(dbc.databaseConnection).Query()
+	if err != nil {
+		return
+	}
+
+	manifestTemplates, err := dbc.scanManifestTemplates(rows)
+	if err != nil || len(manifestTemplates) == 0 {
+		return
+	}
+
+	return manifestTemplates[0], nil
+}
+
+// GetManifestTemplateVersions returns all versions of a manifest template, newest first
+func (dbc *cockroachDBClientImpl) GetManifestTemplateVersions(name string) (manifestTemplates []*ManifestTemplate, err error) {
+
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+
+	query :=
+		dbc.selectManifestTemplatesQuery().
+			Where(sq.Eq{"a.name": name}).
+			OrderBy("a.version DESC")
+
+	rows, err := query.RunWith(dbc.databaseConnection).Query()
+	if err != nil {
+		return
+	}
+
+	return dbc.scanManifestTemplates(rows)
+}
+
+// InsertManifestTemplate stores the manifest template as the next version of the template with its name and returns it with the version set
+func (dbc *cockroachDBClientImpl) InsertManifestTemplate(manifestTemplate ManifestTemplate) (insertedManifestTemplate *ManifestTemplate, err error) {
+
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+
+	placeholdersBytes, err := json.Marshal(manifestTemplate.Placeholders)
+	if err != nil {
+		return
+	}
+
+	// determine the next version in the same statement, so concurrent saves can't end up with the same version
+	row := dbc.databaseConnection.QueryRow(
+		`
+		INSERT INTO
+			manifest_templates
+		(
+			name,
+			version,
+			description,
+			template,
+			placeholders,
+			inserted_by
+		)
+		VALUES
+		(
+			$1,
+			(SELECT COALESCE(MAX(version), 0) + 1 FROM manifest_templates WHERE name = $1),
+			$2,
+			$3,
+			$4,
+			$5
+		)
+		RETURNING
+			version,
+			inserted_at
+		`,
+		manifestTemplate.Name,
+		manifestTemplate.Description,
+		manifestTemplate.Template,
+		placeholdersBytes,
+		manifestTemplate.InsertedBy,
+	)
+
+	insertedManifestTemplate = &manifestTemplate
+	if err = row.Scan(&insertedManifestTemplate.Version, &insertedManifestTemplate.InsertedAt); err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+// DeleteManifestTemplate removes all versions of a manifest template
+func (dbc *cockroachDBClientImpl) DeleteManifestTemplate(name string) (err error) {
+
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+
+	_, err = dbc.databaseConnection.Exec(
+		`
+		DELETE FROM
+			manifest_templates
+		WHERE
+			name=$1
+		`,
+		name,
+	)
+
+	return
+}
+
+func (dbc *cockroachDBClientImpl) selectManifestTemplatesQuery() sq.SelectBuilder {
+	return sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+		Select("a.name, a.version, a.description, a.template, a.placeholders, a.inserted_by, a.inserted_at").
+		From("manifest_templates a")
+}
+
+func (dbc *cockroachDBClientImpl) scanManifestTemplates(rows *sql.Rows) (manifestTemplates []*ManifestTemplate, err error) {
+
+	manifestTemplates = make([]*ManifestTemplate, 0)
+
+	defer rows.Close()
+	for rows.Next() {
+		manifestTemplate := ManifestTemplate{}
+		var placeholdersData []uint8
+
+		if err = rows.Scan(
+			&manifestTemplate.Name,
+			&manifestTemplate.Version,
+			&manifestTemplate.Description,
+			&manifestTemplate.Template,
+			&placeholdersData,
+			&manifestTemplate.InsertedBy,
+			&manifestTemplate.InsertedAt); err != nil {
+			return
+		}
+
+		if len(placeholdersData) > 0 {
+			if err = json.Unmarshal(placeholdersData, &manifestTemplate.Placeholders); err != nil {
+				return
+			}
+		}
+
+		manifestTemplates = append(manifestTemplates, &manifestTemplate)
+	}
+
+	return
+}
+
+const (
+	managedConfigItemTypeCredential   = "credential"
+	managedConfigItemTypeTrustedImage = "trusted-image"
+)
+
+// getManagedConfigItems returns the json data of all managed config items of a type or of the one with the name
+func (dbc *cockroachDBClientImpl) getManagedConfigItems(itemType, name string) (items [][]uint8, err error) {
+
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+
+	query :=
+		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+			Select("a.data").
+			From("managed_config_items a").
+			Where(sq.Eq{"a.item_type": itemType}).
+			OrderBy("a.name")
+
+	if name != "" {
+		query = query.Where(sq.Eq{"a.name": name})
+	}
+
+	rows, err := query.RunWith(dbc.databaseConnection).Query()
+	if err != nil {
+		return
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var data []uint8
+		if err = rows.Scan(&data); err != nil {
+			return
+		}
+		items = append(items, data)
+	}
+
+	return
+}
+
+// writeManagedConfigItem creates, updates or deletes a managed config item and records the change in the audit log in a single transaction
+func (dbc *cockroachDBClientImpl) writeManagedConfigItem(itemType, name string, item interface{}, action, userEmail string) (err error) {
+
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+
+	var data []byte
+	if item != nil {
+		data, err = json.Marshal(item)
+		if err != nil {
+			return
+		}
+	}
+
+	tx, err := dbc.databaseConnection.Begin()
+	if err != nil {
+		return
+	}
+
+	switch action {
+	case "create":
+		_, err = tx.Exec(
+			`
+			INSERT INTO
+				managed_config_items
+			(
+				item_type,
+				name,
+				data
+			)
+			VALUES
+			(
+				$1,
+				$2,
+				$3
+			)
+			`,
+			itemType,
+			name,
+			data,
+		)
+	case "update":
+		_, err = tx.Exec(
+			`
+			UPDATE
+				managed_config_items
+			SET
+				data=$1,
+				updated_at=now()
+			WHERE
+				item_type=$2 AND
+				name=$3
+			`,
+			data,
+			itemType,
+			name,
+		)
+	case "delete":
+		_, err = tx.Exec(
+			`
+			DELETE FROM
+				managed_config_items
+			WHERE
+				item_type=$1 AND
+				name=$2
+			`,
+			itemType,
+			name,
+		)
+	default:
+		err = fmt.Errorf("Action %v is not supported for managed config items", action)
+	}
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	_, err = tx.Exec(
+		`
+		INSERT INTO
+			managed_config_audit_log
+		(
+			item_type,
+			name,
+			action,
+			user_email,
+			data
+		)
+		VALUES
+		(
+			$1,
+			$2,
+			$3,
+			$4,
+			$5
+		)
+		`,
+		itemType,
+		name,
+		action,
+		userEmail,
+		data,
+	)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	return tx.Commit()
+}
+
 func whereClauseGeneratorForAllFilters(query sq.SelectBuilder, alias string, filters map[string][]string) (sq.SelectBuilder, error) {
 
 	query, err := whereClauseGeneratorForSinceFilter(query, alias, filters)
@@ -1672,6 +2595,14 @@ func whereClauseGeneratorForAllFilters(query sq.SelectBuilder, alias string, fil
 	if err != nil {
 		return query, err
 	}
+	query, err = whereClauseGeneratorForBranchFilter(query, alias, filters)
+	if err != nil {
+		return query, err
+	}
+	query, err = whereClauseGeneratorForTagFilter(query, alias, filters)
+	if err != nil {
+		return query, err
+	}
 
 	return query, nil
 }
@@ -1682,6 +2613,10 @@ func whereClauseGeneratorForAllReleaseFilters(query sq.SelectBuilder, alias stri
 	if err != nil {
 		return query, err
 	}
+	query, err = whereClauseGeneratorForReleaseNameFilter(query, alias, filters)
+	if err != nil {
+		return query, err
+	}
 	query, err = whereClauseGeneratorForSinceFilter(query, alias, filters)
 	if err != nil {
 		return query, err
@@ -1718,6 +2653,24 @@ func whereClauseGeneratorForStatusFilter(query sq.SelectBuilder, alias string, f
 	return query, nil
 }
 
+func whereClauseGeneratorForBranchFilter(query sq.SelectBuilder, alias string, filters map[string][]string) (sq.SelectBuilder, error) {
+
+	if branches, ok := filters["branch"]; ok && len(branches) > 0 {
+		query = query.Where(sq.Eq{fmt.Sprintf("%v.repo_branch", alias): branches})
+	}
+
+	return query, nil
+}
+
+func whereClauseGeneratorForTagFilter(query sq.SelectBuilder, alias string, filters map[string][]string) (sq.SelectBuilder, error) {
+
+	if tags, ok := filters["tag"]; ok && len(tags) > 0 {
+		query = query.Where(sq.Eq{fmt.Sprintf("%v.repo_tag", alias): tags})
+	}
+
+	return query, nil
+}
+
 func whereClauseGeneratorForReleaseStatusFilter(query sq.SelectBuilder, alias string, filters map[string][]string) (sq.SelectBuilder, error) {
 
 	if statuses, ok := filters["status"]; ok && len(statuses) > 0 && statuses[0] != "all" {
@@ -1727,6 +2680,41 @@ func whereClauseGeneratorForReleaseStatusFilter(query sq.SelectBuilder, alias st
 	return query, nil
 }
 
+func whereClauseGeneratorForReleaseNameFilter(query sq.SelectBuilder, alias string, filters map[string][]string) (sq.SelectBuilder, error) {
+
+	if names, ok := filters["release"]; ok && len(names) > 0 {
+		query = query.Where(sq.Eq{fmt.Sprintf("%v.release", alias): names})
+	}
+	if actions, ok := filters["action"]; ok && len(actions) > 0 {
+		query = query.Where(sq.Eq{fmt.Sprintf("%v.release_action", alias): actions})
+	}
+
+	return query, nil
+}
+
+func whereClauseGeneratorForArchivedFilter(query sq.SelectBuilder, alias string, filters map[string][]string) (sq.SelectBuilder, error) {
+
+	if archived, ok := filters["archived"]; ok && len(archived) > 0 {
+		query = query.Where(sq.Eq{fmt.Sprintf("%v.archived", alias): archived[0] == "true"})
+	}
+
+	return query, nil
+}
+
+// whereClauseGeneratorForArchivedPipelineFilter filters rows of tables other than computed_pipelines on whether their pipeline is archived
+func whereClauseGeneratorForArchivedPipelineFilter(query sq.SelectBuilder, alias string, filters map[string][]string) (sq.SelectBuilder, error) {
+
+	if archived, ok := filters["archived"]; ok && len(archived) > 0 {
+		operator := "NOT EXISTS"
+		if archived[0] == "true" {
+			operator = "EXISTS"
+		}
+		query = query.Where(fmt.Sprintf("%v (SELECT 1 FROM computed_pipelines cp WHERE cp.repo_source=%v.repo_source AND cp.repo_owner=%v.repo_owner AND cp.repo_name=%v.repo_name AND cp.archived=true)", operator, alias, alias, alias))
+	}
+
+	return query, nil
+}
+
 func whereClauseGeneratorForLabelsFilter(query sq.SelectBuilder, alias string, filters map[string][]string) (sq.SelectBuilder, error) {
 
 	if labels, ok := filters["labels"]; ok && len(labels) > 0 {
@@ -1772,9 +2760,9 @@ func limitClauseGeneratorForLastFilter(query sq.SelectBuilder, filters map[strin
 	return query, nil
 }
 
-func (dbc *cockroachDBClientImpl) scanBuild(row sq.RowScanner, optimized, enriched bool) (build *contracts.Build, err error) {
+func (dbc *cockroachDBClientImpl) scanBuild(row sq.RowScanner, optimized, enriched bool) (build *Build, err error) {
 
-	build = &contracts.Build{}
+	build = &Build{}
 	var labelsData, releaseTargetsData, commitsData []uint8
 	var seconds int
 
@@ -1785,12 +2773,15 @@ func (dbc *cockroachDBClientImpl) scanBuild(row sq.RowScanner, optimized, enrich
 		&build.RepoName,
 		&build.RepoBranch,
 		&build.RepoRevision,
+		&build.RepoTag,
 		&build.BuildVersion,
 		&build.BuildStatus,
 		&labelsData,
 		&releaseTargetsData,
 		&build.Manifest,
 		&commitsData,
+		&build.TriggerReason,
+		&build.SkipReason,
 		&build.InsertedAt,
 		&build.UpdatedAt,
 		&seconds); err != nil {
@@ -1817,14 +2808,14 @@ func (dbc *cockroachDBClientImpl) scanBuild(row sq.RowScanner, optimized, enrich
 	return
 }
 
-func (dbc *cockroachDBClientImpl) scanBuilds(rows *sql.Rows, optimized bool) (builds []*contracts.Build, err error) {
+func (dbc *cockroachDBClientImpl) scanBuilds(rows *sql.Rows, optimized bool) (builds []*Build, err error) {
 
-	builds = make([]*contracts.Build, 0)
+	builds = make([]*Build, 0)
 
 	defer rows.Close()
 	for rows.Next() {
 
-		build := contracts.Build{}
+		build := Build{}
 		var labelsData, releaseTargetsData, commitsData []uint8
 		var seconds int
 
@@ -1835,12 +2826,15 @@ func (dbc *cockroachDBClientImpl) scanBuilds(rows *sql.Rows, optimized bool) (bu
 			&build.RepoName,
 			&build.RepoBranch,
 			&build.RepoRevision,
+			&build.RepoTag,
 			&build.BuildVersion,
 			&build.BuildStatus,
 			&labelsData,
 			&releaseTargetsData,
 			&build.Manifest,
 			&commitsData,
+			&build.TriggerReason,
+			&build.SkipReason,
 			&build.InsertedAt,
 			&build.UpdatedAt,
 			&seconds); err != nil {
@@ -1863,9 +2857,9 @@ func (dbc *cockroachDBClientImpl) scanBuilds(rows *sql.Rows, optimized bool) (bu
 	return
 }
 
-func (dbc *cockroachDBClientImpl) scanPipeline(row sq.RowScanner, optimized bool) (pipeline *contracts.Pipeline, err error) {
+func (dbc *cockroachDBClientImpl) scanPipeline(row sq.RowScanner, optimized bool) (pipeline *Pipeline, err error) {
 
-	pipeline = &contracts.Pipeline{}
+	pipeline = &Pipeline{}
 	var labelsData, releaseTargetsData, commitsData []uint8
 	var seconds int
 
@@ -1884,7 +2878,8 @@ func (dbc *cockroachDBClientImpl) scanPipeline(row sq.RowScanner, optimized bool
 		&commitsData,
 		&pipeline.InsertedAt,
 		&pipeline.UpdatedAt,
-		&seconds); err != nil {
+		&seconds,
+		&pipeline.Archived); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
@@ -1904,14 +2899,14 @@ func (dbc *cockroachDBClientImpl) scanPipeline(row sq.RowScanner, optimized bool
 	return
 }
 
-func (dbc *cockroachDBClientImpl) scanPipelines(rows *sql.Rows, optimized bool) (pipelines []*contracts.Pipeline, err error) {
+func (dbc *cockroachDBClientImpl) scanPipelines(rows *sql.Rows, optimized bool) (pipelines []*Pipeline, err error) {
 
-	pipelines = make([]*contracts.Pipeline, 0)
+	pipelines = make([]*Pipeline, 0)
 
 	defer rows.Close()
 	for rows.Next() {
 
-		pipeline := contracts.Pipeline{}
+		pipeline := Pipeline{}
 		var labelsData, releaseTargetsData, commitsData []uint8
 		var seconds int
 
@@ -1930,7 +2925,8 @@ func (dbc *cockroachDBClientImpl) scanPipelines(rows *sql.Rows, optimized bool)
 			&commitsData,
 			&pipeline.InsertedAt,
 			&pipeline.UpdatedAt,
-			&seconds); err != nil {
+			&seconds,
+			&pipeline.Archived); err != nil {
 			return
 		}
 
@@ -1950,9 +2946,9 @@ func (dbc *cockroachDBClientImpl) scanPipelines(rows *sql.Rows, optimized bool)
 	return
 }
 
-func (dbc *cockroachDBClientImpl) scanRelease(row sq.RowScanner) (release *contracts.Release, err error) {
+func (dbc *cockroachDBClientImpl) scanRelease(row sq.RowScanner) (release *Release, err error) {
 
-	release = &contracts.Release{}
+	release = &Release{}
 	var seconds int
 	var id int
 
@@ -1966,6 +2962,8 @@ func (dbc *cockroachDBClientImpl) scanRelease(row sq.RowScanner) (release *contr
 		&release.ReleaseVersion,
 		&release.ReleaseStatus,
 		&release.TriggeredBy,
+		&release.IsRollback,
+		&release.DeploymentID,
 		&release.InsertedAt,
 		&release.UpdatedAt,
 		&seconds); err != nil {
@@ -1982,14 +2980,14 @@ func (dbc *cockroachDBClientImpl) scanRelease(row sq.RowScanner) (release *contr
 	return
 }
 
-func (dbc *cockroachDBClientImpl) scanReleases(rows *sql.Rows) (releases []*contracts.Release, err error) {
+func (dbc *cockroachDBClientImpl) scanReleases(rows *sql.Rows) (releases []*Release, err error) {
 
-	releases = make([]*contracts.Release, 0)
+	releases = make([]*Release, 0)
 
 	defer rows.Close()
 	for rows.Next() {
 
-		release := contracts.Release{}
+		release := Release{}
 		var seconds int
 		var id int
 
@@ -2003,6 +3001,8 @@ func (dbc *cockroachDBClientImpl) scanReleases(rows *sql.Rows) (releases []*cont
 			&release.ReleaseVersion,
 			&release.ReleaseStatus,
 			&release.TriggeredBy,
+			&release.IsRollback,
+			&release.DeploymentID,
 			&release.InsertedAt,
 			&release.UpdatedAt,
 			&seconds); err != nil {
@@ -2023,7 +3023,7 @@ func (dbc *cockroachDBClientImpl) selectBuildsQuery() sq.SelectBuilder {
 	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
 
 	return psql.
-		Select("a.id, a.repo_source, a.repo_owner, a.repo_name, a.repo_branch, a.repo_revision, a.build_version, a.build_status, a.labels, a.release_targets, a.manifest, a.commits, a.inserted_at, a.updated_at, a.duration::INT").
+		Select("a.id, a.repo_source, a.repo_owner, a.repo_name, a.repo_branch, a.repo_revision, a.repo_tag, a.build_version, a.build_status, a.labels, a.release_targets, a.manifest, a.commits, a.trigger_reason, a.skip_reason, a.inserted_at, a.updated_at, a.duration::INT").
 		From("builds a")
 }
 
@@ -2031,7 +3031,7 @@ func (dbc *cockroachDBClientImpl) selectPipelinesQuery() sq.SelectBuilder {
 	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
 
 	return psql.
-		Select("a.pipeline_id, a.repo_source, a.repo_owner, a.repo_name, a.repo_branch, a.repo_revision, a.build_version, a.build_status, a.labels, a.release_targets, a.manifest, a.commits, a.inserted_at, a.updated_at, a.duration::INT").
+		Select("a.pipeline_id, a.repo_source, a.repo_owner, a.repo_name, a.repo_branch, a.repo_revision, a.build_version, a.build_status, a.labels, a.release_targets, a.manifest, a.commits, a.inserted_at, a.updated_at, a.duration::INT, a.archived").
 		From("computed_pipelines a")
 }
 
@@ -2039,7 +3039,7 @@ func (dbc *cockroachDBClientImpl) selectReleasesQuery() sq.SelectBuilder {
 	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
 
 	return psql.
-		Select("a.id, a.repo_source, a.repo_owner, a.repo_name, a.release, a.release_action, a.release_version, a.release_status, a.triggered_by, a.inserted_at, a.updated_at, a.duration::INT").
+		Select("a.id, a.repo_source, a.repo_owner, a.repo_name, a.release, a.release_action, a.release_version, a.release_status, a.triggered_by, a.is_rollback, a.deployment_id, a.inserted_at, a.updated_at, a.duration::INT").
 		From("releases a")
 }
 
@@ -2047,7 +3047,7 @@ func (dbc *cockroachDBClientImpl) selectComputedReleasesQuery() sq.SelectBuilder
 	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
 
 	return psql.
-		Select("a.release_id, a.repo_source, a.repo_owner, a.repo_name, a.release, a.release_action, a.release_version, a.release_status, a.triggered_by, a.inserted_at, a.updated_at, a.duration::INT").
+		Select("a.release_id, a.repo_source, a.repo_owner, a.repo_name, a.release, a.release_action, a.release_version, a.release_status, a.triggered_by, a.is_rollback, a.deployment_id, a.inserted_at, a.updated_at, a.duration::INT").
 		From("computed_releases a")
 }
 
@@ -2067,15 +3067,15 @@ func (dbc *cockroachDBClientImpl) selectReleaseLogsQuery() sq.SelectBuilder {
 		From("release_logs a")
 }
 
-func (dbc *cockroachDBClientImpl) enrichPipeline(pipeline *contracts.Pipeline) {
+func (dbc *cockroachDBClientImpl) enrichPipeline(pipeline *Pipeline) {
 	dbc.getLatestReleasesForPipeline(pipeline)
 }
 
-func (dbc *cockroachDBClientImpl) enrichBuild(build *contracts.Build) {
+func (dbc *cockroachDBClientImpl) enrichBuild(build *Build) {
 	dbc.getLatestReleasesForBuild(build)
 }
 
-func (dbc *cockroachDBClientImpl) setPipelinePropertiesFromJSONB(pipeline *contracts.Pipeline, labelsData, releaseTargetsData, commitsData []uint8, optimized bool) (err error) {
+func (dbc *cockroachDBClientImpl) setPipelinePropertiesFromJSONB(pipeline *Pipeline, labelsData, releaseTargetsData, commitsData []uint8, optimized bool) (err error) {
 
 	if len(labelsData) > 0 {
 		if err = json.Unmarshal(labelsData, &pipeline.Labels); err != nil {
@@ -2118,7 +3118,7 @@ func (dbc *cockroachDBClientImpl) setPipelinePropertiesFromJSONB(pipeline *contr
 	return
 }
 
-func (dbc *cockroachDBClientImpl) setBuildPropertiesFromJSONB(build *contracts.Build, labelsData, releaseTargetsData, commitsData []uint8, optimized bool) (err error) {
+func (dbc *cockroachDBClientImpl) setBuildPropertiesFromJSONB(build *Build, labelsData, releaseTargetsData, commitsData []uint8, optimized bool) (err error) {
 
 	if len(labelsData) > 0 {
 		if err = json.Unmarshal(labelsData, &build.Labels); err != nil {
@@ -2161,11 +3161,11 @@ func (dbc *cockroachDBClientImpl) setBuildPropertiesFromJSONB(build *contracts.B
 	return
 }
 
-func (dbc *cockroachDBClientImpl) getLatestReleasesForPipeline(pipeline *contracts.Pipeline) {
+func (dbc *cockroachDBClientImpl) getLatestReleasesForPipeline(pipeline *Pipeline) {
 	pipeline.ReleaseTargets = dbc.getLatestReleases(pipeline.ReleaseTargets, pipeline.RepoSource, pipeline.RepoOwner, pipeline.RepoName)
 }
 
-func (dbc *cockroachDBClientImpl) getLatestReleasesForBuild(build *contracts.Build) {
+func (dbc *cockroachDBClientImpl) getLatestReleasesForBuild(build *Build) {
 	build.ReleaseTargets = dbc.getLatestReleases(build.ReleaseTargets, build.RepoSource, build.RepoOwner, build.RepoName)
 }
 
@@ -2201,23 +3201,25 @@ func getActionNamesFromReleaseTarget(releaseTarget contracts.ReleaseTarget) (act
 	return
 }
 
-func (dbc *cockroachDBClientImpl) mapBuildToPipeline(build *contracts.Build) (pipeline *contracts.Pipeline) {
-	return &contracts.Pipeline{
-		ID:                   build.ID,
-		RepoSource:           build.RepoSource,
-		RepoOwner:            build.RepoOwner,
-		RepoName:             build.RepoName,
-		RepoBranch:           build.RepoBranch,
-		RepoRevision:         build.RepoRevision,
-		BuildVersion:         build.BuildVersion,
-		BuildStatus:          build.BuildStatus,
-		Labels:               build.Labels,
-		ReleaseTargets:       build.ReleaseTargets,
-		Manifest:             build.Manifest,
-		ManifestWithDefaults: build.ManifestWithDefaults,
-		Commits:              build.Commits,
-		InsertedAt:           build.InsertedAt,
-		UpdatedAt:            build.UpdatedAt,
-		Duration:             build.Duration,
+func (dbc *cockroachDBClientImpl) mapBuildToPipeline(build *Build) (pipeline *Pipeline) {
+	return &Pipeline{
+		Pipeline: contracts.Pipeline{
+			ID:                   build.ID,
+			RepoSource:           build.RepoSource,
+			RepoOwner:            build.RepoOwner,
+			RepoName:             build.RepoName,
+			RepoBranch:           build.RepoBranch,
+			RepoRevision:         build.RepoRevision,
+			BuildVersion:         build.BuildVersion,
+			BuildStatus:          build.BuildStatus,
+			Labels:               build.Labels,
+			ReleaseTargets:       build.ReleaseTargets,
+			Manifest:             build.Manifest,
+			ManifestWithDefaults: build.ManifestWithDefaults,
+			Commits:              build.Commits,
+			InsertedAt:           build.InsertedAt,
+			UpdatedAt:            build.UpdatedAt,
+			Duration:             build.Duration,
+		},
 	}
 }
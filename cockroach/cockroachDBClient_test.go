@@ -5,7 +5,9 @@ import (
 	"strconv"
 	"testing"
 
+	sq "github.com/Masterminds/squirrel"
 	"github.com/estafette/estafette-ci-api/config"
+	contracts "github.com/estafette/estafette-ci-contracts"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
 )
@@ -31,7 +33,7 @@ func TestQueryBuilder(t *testing.T) {
 		sql, _, err := query.ToSql()
 
 		assert.Nil(t, err)
-		assert.Equal(t, "SELECT a.id, a.repo_source, a.repo_owner, a.repo_name, a.repo_branch, a.repo_revision, a.build_version, a.build_status, a.labels, a.release_targets, a.manifest, a.commits, a.inserted_at, a.updated_at, a.duration::INT FROM builds a", sql)
+		assert.Equal(t, "SELECT a.id, a.repo_source, a.repo_owner, a.repo_name, a.repo_branch, a.repo_revision, a.repo_tag, a.build_version, a.build_status, a.labels, a.release_targets, a.manifest, a.commits, a.trigger_reason, a.skip_reason, a.inserted_at, a.updated_at, a.duration::INT FROM builds a", sql)
 	})
 
 	t.Run("GeneratesQueryWithStatusFilter", func(t *testing.T) {
@@ -48,7 +50,7 @@ func TestQueryBuilder(t *testing.T) {
 		sql, _, err := query.ToSql()
 
 		assert.Nil(t, err)
-		assert.Equal(t, "SELECT a.id, a.repo_source, a.repo_owner, a.repo_name, a.repo_branch, a.repo_revision, a.build_version, a.build_status, a.labels, a.release_targets, a.manifest, a.commits, a.inserted_at, a.updated_at, a.duration::INT FROM builds a WHERE a.build_status IN ($1)", sql)
+		assert.Equal(t, "SELECT a.id, a.repo_source, a.repo_owner, a.repo_name, a.repo_branch, a.repo_revision, a.repo_tag, a.build_version, a.build_status, a.labels, a.release_targets, a.manifest, a.commits, a.trigger_reason, a.skip_reason, a.inserted_at, a.updated_at, a.duration::INT FROM builds a WHERE a.build_status IN ($1)", sql)
 	})
 
 	t.Run("GeneratesQueryWithSinceFilter", func(t *testing.T) {
@@ -65,7 +67,7 @@ func TestQueryBuilder(t *testing.T) {
 		sql, _, err := query.ToSql()
 
 		assert.Nil(t, err)
-		assert.Equal(t, "SELECT a.id, a.repo_source, a.repo_owner, a.repo_name, a.repo_branch, a.repo_revision, a.build_version, a.build_status, a.labels, a.release_targets, a.manifest, a.commits, a.inserted_at, a.updated_at, a.duration::INT FROM builds a WHERE a.inserted_at >= $1", sql)
+		assert.Equal(t, "SELECT a.id, a.repo_source, a.repo_owner, a.repo_name, a.repo_branch, a.repo_revision, a.repo_tag, a.build_version, a.build_status, a.labels, a.release_targets, a.manifest, a.commits, a.trigger_reason, a.skip_reason, a.inserted_at, a.updated_at, a.duration::INT FROM builds a WHERE a.inserted_at >= $1", sql)
 	})
 
 	t.Run("GeneratesQueryWithLabelsFilter", func(t *testing.T) {
@@ -82,7 +84,7 @@ func TestQueryBuilder(t *testing.T) {
 		sql, _, err := query.ToSql()
 
 		assert.Nil(t, err)
-		assert.Equal(t, "SELECT a.id, a.repo_source, a.repo_owner, a.repo_name, a.repo_branch, a.repo_revision, a.build_version, a.build_status, a.labels, a.release_targets, a.manifest, a.commits, a.inserted_at, a.updated_at, a.duration::INT FROM builds a WHERE a.labels @> $1", sql)
+		assert.Equal(t, "SELECT a.id, a.repo_source, a.repo_owner, a.repo_name, a.repo_branch, a.repo_revision, a.repo_tag, a.build_version, a.build_status, a.labels, a.release_targets, a.manifest, a.commits, a.trigger_reason, a.skip_reason, a.inserted_at, a.updated_at, a.duration::INT FROM builds a WHERE a.labels @> $1", sql)
 	})
 
 	t.Run("GeneratesQueryWithLabelsFilterAndOrderBy", func(t *testing.T) {
@@ -104,7 +106,24 @@ func TestQueryBuilder(t *testing.T) {
 		sql, _, err := query.ToSql()
 
 		assert.Nil(t, err)
-		assert.Equal(t, "SELECT a.id, a.repo_source, a.repo_owner, a.repo_name, a.repo_branch, a.repo_revision, a.build_version, a.build_status, a.labels, a.release_targets, a.manifest, a.commits, a.inserted_at, a.updated_at, a.duration::INT FROM builds a WHERE a.labels @> $1 ORDER BY a.inserted_at DESC LIMIT 15 OFFSET 15", sql)
+		assert.Equal(t, "SELECT a.id, a.repo_source, a.repo_owner, a.repo_name, a.repo_branch, a.repo_revision, a.repo_tag, a.build_version, a.build_status, a.labels, a.release_targets, a.manifest, a.commits, a.trigger_reason, a.skip_reason, a.inserted_at, a.updated_at, a.duration::INT FROM builds a WHERE a.labels @> $1 ORDER BY a.inserted_at DESC LIMIT 15 OFFSET 15", sql)
+	})
+
+	t.Run("GeneratesBuildsQueryWithTagFilter", func(t *testing.T) {
+
+		query := cdbClient.selectBuildsQuery()
+
+		query, _ = whereClauseGeneratorForAllFilters(query, "a", map[string][]string{
+			"tag": []string{
+				"v1.2.0",
+			},
+		})
+
+		// act
+		sql, _, err := query.ToSql()
+
+		assert.Nil(t, err)
+		assert.Equal(t, "SELECT a.id, a.repo_source, a.repo_owner, a.repo_name, a.repo_branch, a.repo_revision, a.repo_tag, a.build_version, a.build_status, a.labels, a.release_targets, a.manifest, a.commits, a.trigger_reason, a.skip_reason, a.inserted_at, a.updated_at, a.duration::INT FROM builds a WHERE a.repo_tag IN ($1)", sql)
 	})
 
 	t.Run("GeneratesGetPipelinesQuery", func(t *testing.T) {
@@ -118,7 +137,125 @@ func TestQueryBuilder(t *testing.T) {
 		sql, _, err := query.ToSql()
 
 		assert.Nil(t, err)
-		assert.Equal(t, "SELECT a.pipeline_id, a.repo_source, a.repo_owner, a.repo_name, a.repo_branch, a.repo_revision, a.build_version, a.build_status, a.labels, a.release_targets, a.manifest, a.commits, a.inserted_at, a.updated_at, a.duration::INT FROM computed_pipelines a ORDER BY a.repo_source,a.repo_owner,a.repo_name LIMIT 2 OFFSET 20", sql)
+		assert.Equal(t, "SELECT a.pipeline_id, a.repo_source, a.repo_owner, a.repo_name, a.repo_branch, a.repo_revision, a.build_version, a.build_status, a.labels, a.release_targets, a.manifest, a.commits, a.inserted_at, a.updated_at, a.duration::INT, a.archived FROM computed_pipelines a ORDER BY a.repo_source,a.repo_owner,a.repo_name LIMIT 2 OFFSET 20", sql)
+	})
+
+	t.Run("GeneratesGetPipelinesQueryWithArchivedFilter", func(t *testing.T) {
+
+		query := cdbClient.selectPipelinesQuery()
+
+		query, _ = whereClauseGeneratorForArchivedFilter(query, "a", map[string][]string{
+			"archived": []string{"false"},
+		})
+
+		// act
+		sql, args, err := query.ToSql()
+
+		assert.Nil(t, err)
+		assert.Equal(t, "SELECT a.pipeline_id, a.repo_source, a.repo_owner, a.repo_name, a.repo_branch, a.repo_revision, a.build_version, a.build_status, a.labels, a.release_targets, a.manifest, a.commits, a.inserted_at, a.updated_at, a.duration::INT, a.archived FROM computed_pipelines a WHERE a.archived = $1", sql)
+		assert.Equal(t, []interface{}{false}, args)
+	})
+
+	t.Run("GeneratesBuildsCountQueryExcludingArchivedPipelines", func(t *testing.T) {
+
+		query := sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+			Select("COUNT(*)").
+			From("builds a")
+
+		query, _ = whereClauseGeneratorForArchivedPipelineFilter(query, "a", map[string][]string{
+			"archived": []string{"false"},
+		})
+
+		// act
+		sql, _, err := query.ToSql()
+
+		assert.Nil(t, err)
+		assert.Equal(t, "SELECT COUNT(*) FROM builds a WHERE NOT EXISTS (SELECT 1 FROM computed_pipelines cp WHERE cp.repo_source=a.repo_source AND cp.repo_owner=a.repo_owner AND cp.repo_name=a.repo_name AND cp.archived=true)", sql)
+	})
+
+	t.Run("GeneratesReleasesQueryWithReleaseAndActionFilter", func(t *testing.T) {
+
+		query := cdbClient.selectReleasesQuery()
+
+		query, _ = whereClauseGeneratorForAllReleaseFilters(query, "a", map[string][]string{
+			"status": []string{
+				"succeeded",
+			},
+			"release": []string{
+				"production",
+			},
+			"action": []string{
+				"",
+			},
+		})
+
+		// act
+		sql, _, err := query.ToSql()
+
+		assert.Nil(t, err)
+		assert.Equal(t, "SELECT a.id, a.repo_source, a.repo_owner, a.repo_name, a.release, a.release_action, a.release_version, a.release_status, a.triggered_by, a.is_rollback, a.deployment_id, a.inserted_at, a.updated_at, a.duration::INT FROM releases a WHERE a.release_status IN ($1) AND a.release IN ($2) AND a.release_action IN ($3)", sql)
+	})
+}
+
+func TestSkippedBuilds(t *testing.T) {
+
+	t.Run("GeneratesLastPipelineBuildQueryExcludingSkippedBuilds", func(t *testing.T) {
+
+		query := cdbClient.selectLastPipelineBuildQuery("github.com", "estafette", "estafette-ci-api")
+
+		// act
+		sql, args, err := query.ToSql()
+
+		assert.Nil(t, err)
+		assert.Equal(t, "SELECT a.id, a.repo_source, a.repo_owner, a.repo_name, a.repo_branch, a.repo_revision, a.repo_tag, a.build_version, a.build_status, a.labels, a.release_targets, a.manifest, a.commits, a.trigger_reason, a.skip_reason, a.inserted_at, a.updated_at, a.duration::INT FROM builds a WHERE a.repo_source = $1 AND a.repo_owner = $2 AND a.repo_name = $3 AND a.build_status <> $4 ORDER BY a.inserted_at DESC LIMIT 1", sql)
+		assert.Equal(t, []interface{}{"github.com", "estafette", "estafette-ci-api", "skipped"}, args)
+	})
+
+	t.Run("DoesNotUpdateComputedPipelineForBuildSkippedByDirective", func(t *testing.T) {
+
+		build := Build{
+			Build:      contracts.Build{RepoSource: "github.com", RepoOwner: "estafette", RepoName: "estafette-ci-api", BuildStatus: "skipped"},
+			SkipReason: "Commit message contains [skip ci]",
+		}
+
+		// act
+		updates := updatesComputedPipeline(build)
+
+		assert.False(t, updates)
+	})
+
+	t.Run("DoesNotUpdateComputedPipelineForBuildSkippedByPathsFilter", func(t *testing.T) {
+
+		// a push changing no files in the paths filter is stored with its manifest, but without version
+		build := Build{
+			Build: contracts.Build{
+				RepoSource:   "github.com",
+				RepoOwner:    "estafette",
+				RepoName:     "estafette-ci-api",
+				RepoBranch:   "master",
+				RepoRevision: "f0677f01cc6d54a5b042224a9eb374e98f979985",
+				BuildStatus:  "skipped",
+				Manifest:     "paths:\n  include:\n  - 'cmd/**'\n",
+			},
+			SkipReason: "None of the 2 changed files match the paths filter of the manifest",
+		}
+
+		// act
+		updates := updatesComputedPipeline(build)
+
+		assert.False(t, updates)
+	})
+
+	t.Run("UpdatesComputedPipelineForRunningBuild", func(t *testing.T) {
+
+		build := Build{
+			Build: contracts.Build{RepoSource: "github.com", RepoOwner: "estafette", RepoName: "estafette-ci-api", BuildVersion: "1.0.5", BuildStatus: "running"},
+		}
+
+		// act
+		updates := updatesComputedPipeline(build)
+
+		assert.True(t, updates)
 	})
 }
 
@@ -138,3 +275,89 @@ func TestAutoincrement(t *testing.T) {
 	})
 
 }
+
+func TestCronTriggers(t *testing.T) {
+
+	t.Run("ReadsCronTriggersForBuildBranchFromManifestWithBranchDefaultingToMaster", func(t *testing.T) {
+
+		build := Build{
+			Build: contracts.Build{
+				RepoSource: "github.com",
+				RepoOwner:  "estafette",
+				RepoName:   "estafette-ci-api",
+				RepoBranch: "master",
+				Manifest: `
+builder:
+  track: stable
+
+triggers:
+- cron: '0 3 * * *'
+- cron: '0 4 * * 1'
+  branch: release
+- pipeline: github.com/estafette/estafette-ci-contracts
+
+stages:
+  build:
+    image: golang:1.11.2-alpine3.8
+`,
+			},
+		}
+
+		// act
+		cronTriggers, err := getCronTriggers(build)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(cronTriggers))
+		assert.Equal(t, "github.com", cronTriggers[0].RepoSource)
+		assert.Equal(t, "estafette", cronTriggers[0].RepoOwner)
+		assert.Equal(t, "estafette-ci-api", cronTriggers[0].RepoName)
+		assert.Equal(t, "master", cronTriggers[0].RepoBranch)
+		assert.Equal(t, "0 3 * * *", cronTriggers[0].Cron)
+	})
+
+	t.Run("ReadsCronTriggersForOtherBranchOnlyFromBuildsOfThatBranch", func(t *testing.T) {
+
+		build := Build{
+			Build: contracts.Build{
+				RepoBranch: "release",
+				Manifest:   "triggers:\n- cron: '0 3 * * *'\n- cron: '0 4 * * 1'\n  branch: release\n",
+			},
+		}
+
+		// act
+		cronTriggers, err := getCronTriggers(build)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(cronTriggers))
+		assert.Equal(t, "release", cronTriggers[0].RepoBranch)
+		assert.Equal(t, "0 4 * * 1", cronTriggers[0].Cron)
+	})
+
+	t.Run("ReturnsNoCronTriggersIfManifestHasNoTriggersSection", func(t *testing.T) {
+
+		build := Build{
+			Build: contracts.Build{
+				RepoBranch: "master",
+				Manifest:   "builder:\n  track: stable\n",
+			},
+		}
+
+		// act
+		cronTriggers, err := getCronTriggers(build)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(cronTriggers))
+	})
+
+	t.Run("SelectsOnlyCronTriggersOfPipelinesThatAreNotArchived", func(t *testing.T) {
+
+		query := cdbClient.selectCronTriggersQuery()
+
+		// act
+		sql, args, err := query.ToSql()
+
+		assert.Nil(t, err)
+		assert.Equal(t, "SELECT a.repo_source, a.repo_owner, a.repo_name, a.repo_branch, a.cron_schedule FROM cron_triggers a JOIN computed_pipelines b ON a.repo_source = b.repo_source AND a.repo_owner = b.repo_owner AND a.repo_name = b.repo_name WHERE b.archived = $1 ORDER BY a.repo_source, a.repo_owner, a.repo_name, a.repo_branch, a.cron_schedule", sql)
+		assert.Equal(t, []interface{}{false}, args)
+	})
+}
@@ -1,6 +1,10 @@
 package cockroach
 
-import "time"
+import (
+	"time"
+
+	"github.com/estafette/estafette-ci-contracts"
+)
 
 // BuildVersionDetail represents a specific build, including version number, repo, branch, revision and manifest
 type BuildVersionDetail struct {
@@ -13,3 +17,66 @@ type BuildVersionDetail struct {
 	Manifest     string
 	InsertedAt   time.Time
 }
+
+// ManagedConfigAuditLogEntry records a change to a credential or trusted image managed through the api and the user who made it
+type ManagedConfigAuditLogEntry struct {
+	ID         int       `json:"id"`
+	ItemType   string    `json:"itemType"`
+	Name       string    `json:"name"`
+	Action     string    `json:"action"`
+	UserEmail  string    `json:"userEmail"`
+	Data       string    `json:"data,omitempty"`
+	InsertedAt time.Time `json:"insertedAt"`
+}
+
+// ManifestTemplate is a version of a template to generate a manifest from; saving a template again adds a new version, so generated manifests can be traced back
+type ManifestTemplate struct {
+	Name         string                        `json:"name"`
+	Version      int                           `json:"version"`
+	Description  string                        `json:"description,omitempty"`
+	Template     string                        `json:"template"`
+	Placeholders []ManifestTemplatePlaceholder `json:"placeholders"`
+	InsertedBy   string                        `json:"insertedBy,omitempty"`
+	InsertedAt   time.Time                     `json:"insertedAt"`
+}
+
+// ManifestTemplatePlaceholder describes a {{.Name}} placeholder in a manifest template and the values it accepts
+type ManifestTemplatePlaceholder struct {
+	Name            string `json:"name"`
+	Description     string `json:"description,omitempty"`
+	Type            string `json:"type"`
+	Default         string `json:"default,omitempty"`
+	ValidationRegex string `json:"validationRegex,omitempty"`
+}
+
+// CronTrigger schedules builds of a branch of a pipeline; it's stored from the triggers section of the manifest of the last build of that branch
+type CronTrigger struct {
+	RepoSource string
+	RepoOwner  string
+	RepoName   string
+	RepoBranch string
+	Cron       string
+}
+
+// Build is a contracts.Build with the fields this api stores on top of the contracts; the json of the embedded build is inlined
+type Build struct {
+	contracts.Build
+	RepoTag       string `json:"repoTag,omitempty"`
+	TriggerReason string `json:"triggerReason,omitempty"`
+	SkipReason    string `json:"skipReason,omitempty"`
+}
+
+// Pipeline is a contracts.Pipeline with the fields this api stores on top of the contracts; the json of the embedded pipeline is inlined
+type Pipeline struct {
+	contracts.Pipeline
+	Archived bool `json:"archived,omitempty"`
+}
+
+// Release is a contracts.Release with the fields this api stores on top of the contracts; the json of the embedded release is inlined
+type Release struct {
+	contracts.Release
+	IsRollback bool `json:"isRollback,omitempty"`
+
+	// DeploymentID is the Github deployment the release was started for, so its statuses end up on that deployment
+	DeploymentID int `json:"deploymentID,omitempty"`
+}
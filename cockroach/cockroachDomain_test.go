@@ -0,0 +1,31 @@
+package cockroach
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/estafette/estafette-ci-contracts"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildJSON(t *testing.T) {
+
+	t.Run("InlinesContractsBuildAlongsideExtraFields", func(t *testing.T) {
+
+		build := Build{
+			Build: contracts.Build{
+				RepoSource: "github.com",
+			},
+			TriggerReason: "cron 0 3 * * *",
+		}
+
+		// act
+		data, err := json.Marshal(build)
+
+		assert.Nil(t, err)
+		var fields map[string]interface{}
+		json.Unmarshal(data, &fields)
+		assert.Equal(t, "github.com", fields["repoSource"])
+		assert.Equal(t, "cron 0 3 * * *", fields["triggerReason"])
+	})
+}
@@ -1,7 +1,12 @@
 package config
 
 import (
+	"fmt"
 	"io/ioutil"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
 
 	contracts "github.com/estafette/estafette-ci-contracts"
 	crypt "github.com/estafette/estafette-ci-crypt"
@@ -18,20 +23,86 @@ type APIConfig struct {
 	Credentials    []*contracts.CredentialConfig   `yaml:"credentials,omitempty" json:"credentials,omitempty"`
 	TrustedImages  []*contracts.TrustedImageConfig `yaml:"trustedImages,omitempty" json:"trustedImages,omitempty"`
 	RegistryMirror *string                         `yaml:"registryMirror,omitempty" json:"registryMirror,omitempty"`
+	ManifestRules  *ManifestRulesConfig            `yaml:"manifestRules,omitempty" json:"manifestRules,omitempty"`
+}
+
+// ManifestRulesConfig enables and disables manifest lint rules by id, for all pipelines or only for the ones of a repository owner; the owner settings take precedence
+type ManifestRulesConfig struct {
+	Enabled  []string                    `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+	Disabled []string                    `yaml:"disabled,omitempty" json:"disabled,omitempty"`
+	Owners   []*ManifestRulesOwnerConfig `yaml:"owners,omitempty" json:"owners,omitempty"`
+}
+
+// ManifestRulesOwnerConfig enables and disables manifest lint rules for the pipelines of a repository owner
+type ManifestRulesOwnerConfig struct {
+	Owner    string   `yaml:"owner" json:"owner"`
+	Enabled  []string `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+	Disabled []string `yaml:"disabled,omitempty" json:"disabled,omitempty"`
+}
+
+// IsEnabled returns whether the rule applies to pipelines of the owner; rules that aren't mentioned keep their default
+func (c *ManifestRulesConfig) IsEnabled(ruleID, owner string, enabledByDefault bool) bool {
+
+	if c == nil {
+		return enabledByDefault
+	}
+
+	enabled := enabledByDefault
+	if stringSliceContains(c.Enabled, ruleID) {
+		enabled = true
+	}
+	if stringSliceContains(c.Disabled, ruleID) {
+		enabled = false
+	}
+
+	for _, o := range c.Owners {
+		if owner == "" || !strings.EqualFold(o.Owner, owner) {
+			continue
+		}
+		if stringSliceContains(o.Enabled, ruleID) {
+			enabled = true
+		}
+		if stringSliceContains(o.Disabled, ruleID) {
+			enabled = false
+		}
+	}
+
+	return enabled
+}
+
+func stringSliceContains(slice []string, value string) bool {
+	for _, v := range slice {
+		if v == value {
+			return true
+		}
+	}
+	return false
 }
 
 // APIServerConfig represents configuration for the api server
 type APIServerConfig struct {
-	BaseURL                string `yaml:"baseURL"`
-	ServiceURL             string `yaml:"serviceURL"`
-	EventChannelBufferSize int    `yaml:"eventChannelBufferSize"`
-	MaxWorkers             int    `yaml:"maxWorkers"`
+	BaseURL                string   `yaml:"baseURL"`
+	ServiceURL             string   `yaml:"serviceURL"`
+	EventChannelBufferSize int      `yaml:"eventChannelBufferSize"`
+	MaxWorkers             int      `yaml:"maxWorkers"`
+	SkipDirectives         []string `yaml:"skipDirectives,omitempty"`
 }
 
 // AuthConfig determines whether to use IAP for authentication and authorization
 type AuthConfig struct {
-	IAP    *IAPAuthConfig `yaml:"iap"`
-	APIKey string         `yaml:"apiKey"`
+	IAP            *IAPAuthConfig `yaml:"iap"`
+	APIKey         string         `yaml:"apiKey"`
+	Administrators []string       `yaml:"administrators,omitempty"`
+}
+
+// IsAdministrator returns true if the email address belongs to one of the configured administrators
+func (c *AuthConfig) IsAdministrator(email string) bool {
+	for _, a := range c.Administrators {
+		if strings.EqualFold(a, email) {
+			return true
+		}
+	}
+	return false
 }
 
 // IAPAuthConfig sets iap config in case it's used for authentication and authorization
@@ -58,15 +129,96 @@ type APIConfigIntegrations struct {
 	Slack     *SlackConfig     `yaml:"slack,omitempty"`
 }
 
-// GithubConfig is used to configure github integration
+// GithubConfig is used to configure github integration; the app configured at the top level talks to github.com unless its base urls are set, further apps can be added for other github enterprise hosts or side by side on the same host
 type GithubConfig struct {
-	PrivateKeyPath         string `yaml:"privateKeyPath"`
-	AppID                  string `yaml:"appID"`
-	ClientID               string `yaml:"clientID"`
-	ClientSecret           string `yaml:"clientSecret"`
-	WebhookSecret          string `yaml:"webhookSecret"`
-	EventChannelBufferSize int    `yaml:"eventChannelBufferSize"`
-	MaxWorkers             int    `yaml:"maxWorkers"`
+	PrivateKeyPath         string             `yaml:"privateKeyPath"`
+	AppID                  string             `yaml:"appID"`
+	ClientID               string             `yaml:"clientID"`
+	ClientSecret           string             `yaml:"clientSecret"`
+	WebhookSecret          string             `yaml:"webhookSecret"`
+	APIBaseURL             string             `yaml:"apiBaseURL,omitempty"`
+	WebBaseURL             string             `yaml:"webBaseURL,omitempty"`
+	Apps                   []*GithubAppConfig `yaml:"apps,omitempty"`
+	EventChannelBufferSize int                `yaml:"eventChannelBufferSize"`
+	MaxWorkers             int                `yaml:"maxWorkers"`
+}
+
+// GithubAppConfig is used to configure a single github app
+type GithubAppConfig struct {
+	PrivateKeyPath string `yaml:"privateKeyPath"`
+	AppID          string `yaml:"appID"`
+	ClientID       string `yaml:"clientID"`
+	ClientSecret   string `yaml:"clientSecret"`
+	WebhookSecret  string `yaml:"webhookSecret"`
+	APIBaseURL     string `yaml:"apiBaseURL,omitempty"`
+	WebBaseURL     string `yaml:"webBaseURL,omitempty"`
+}
+
+// GetApps returns the app configured at the top level followed by the additional apps
+func (c *GithubConfig) GetApps() (apps []*GithubAppConfig) {
+
+	apps = []*GithubAppConfig{}
+	if c.AppID != "" {
+		apps = append(apps, &GithubAppConfig{
+			PrivateKeyPath: c.PrivateKeyPath,
+			AppID:          c.AppID,
+			ClientID:       c.ClientID,
+			ClientSecret:   c.ClientSecret,
+			WebhookSecret:  c.WebhookSecret,
+			APIBaseURL:     c.APIBaseURL,
+			WebBaseURL:     c.WebBaseURL,
+		})
+	}
+
+	return append(apps, c.Apps...)
+}
+
+// GetAppsByRepoSource returns the apps for the github host a repository source refers to
+func (c *GithubConfig) GetAppsByRepoSource(repoSource string) (apps []*GithubAppConfig) {
+
+	apps = []*GithubAppConfig{}
+	for _, a := range c.GetApps() {
+		if a.GetRepoSource() == repoSource {
+			apps = append(apps, a)
+		}
+	}
+
+	return
+}
+
+// GetAppByID returns the app with the app id, or nil if there's no such app
+func (c *GithubConfig) GetAppByID(appID string) *GithubAppConfig {
+	for _, a := range c.GetApps() {
+		if a.AppID == appID {
+			return a
+		}
+	}
+	return nil
+}
+
+// GetAPIBaseURL returns the url of the github api without trailing slash, defaulting to the api of github.com
+func (c *GithubAppConfig) GetAPIBaseURL() string {
+	if c.APIBaseURL == "" {
+		return "https://api.github.com"
+	}
+	return strings.TrimSuffix(c.APIBaseURL, "/")
+}
+
+// GetWebBaseURL returns the url of the github web interface without trailing slash, defaulting to github.com
+func (c *GithubAppConfig) GetWebBaseURL() string {
+	if c.WebBaseURL == "" {
+		return "https://github.com"
+	}
+	return strings.TrimSuffix(c.WebBaseURL, "/")
+}
+
+// GetRepoSource returns the repository source for repositories hosted by this app's github host, which is the host name of the web base url
+func (c *GithubAppConfig) GetRepoSource() string {
+	webBaseURL, err := url.Parse(c.GetWebBaseURL())
+	if err != nil || webBaseURL.Host == "" {
+		return "github.com"
+	}
+	return webBaseURL.Host
 }
 
 // BitbucketConfig is used to configure bitbucket integration
@@ -88,9 +240,11 @@ type SlackConfig struct {
 	MaxWorkers             int    `yaml:"maxWorkers"`
 }
 
-// ConfigReader reads the api config from file
+// ConfigReader reads the api config from a base file, optional overlay files and environment variable overrides
 type ConfigReader interface {
 	ReadConfigFromFile(string, bool) (*APIConfig, error)
+	ReadLayeredConfig(string, []string, bool) (*APIConfig, []ConfigSource, error)
+	ValidateConfigFile(string, []string) error
 }
 
 type configReaderImpl struct {
@@ -106,20 +260,78 @@ func NewConfigReader(secretHelper crypt.SecretHelper) ConfigReader {
 
 // ReadConfigFromFile is used to read configuration from a file set from a configmap
 func (h *configReaderImpl) ReadConfigFromFile(configPath string, decryptSecrets bool) (config *APIConfig, err error) {
+	config, _, err = h.ReadLayeredConfig(configPath, nil, decryptSecrets)
+	return
+}
 
-	log.Info().Msgf("Reading %v file...", configPath)
+// ReadLayeredConfig reads the base config file, merges the overlay files into it in order and applies ESTAFETTE_CI_API_CONFIG_ prefixed environment variable overrides, all before decrypting; it returns the source of each value as well
+func (h *configReaderImpl) ReadLayeredConfig(configPath string, overlayPaths []string, decryptSecrets bool) (config *APIConfig, sources []ConfigSource, err error) {
+
+	configPaths := append([]string{configPath}, overlayPaths...)
+
+	log.Info().Msgf("Reading %v file(s)...", strings.Join(configPaths, ", "))
+
+	var merged interface{}
+	sourceByPath := map[string]string{}
+	for _, path := range configPaths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return config, sources, err
+		}
 
-	data, err := ioutil.ReadFile(configPath)
+		var layer yaml.MapSlice
+		if err := yaml.Unmarshal(data, &layer); err != nil {
+			return config, sources, fmt.Errorf("Failed unmarshalling config file %v: %v", path, err)
+		}
+
+		merged = mergeNodes(merged, layer)
+		for _, leaf := range getLeaves(nil, layer) {
+			sourceByPath[leaf.path()] = path
+		}
+	}
+
+	// environment variables can override any scalar field of the config structs and any value in the files
+	leafKeys := getStructLeafKeys(nil, reflect.TypeOf(APIConfig{}))
+	for _, leaf := range getLeaves(nil, merged) {
+		leafKeys = append(leafKeys, leaf.keys)
+	}
+	for _, keys := range leafKeys {
+		leaf := configLeaf{keys: keys}
+		if value, ok := os.LookupEnv(leaf.envVarName()); ok {
+			// plaintext credentials would end up in the encrypted config served by the api and passed on to builders
+			if leaf.isSensitiveKey() && value != "" && !secretEnvelopeRegex.MatchString(value) {
+				return config, sources, fmt.Errorf("Environment variable %v overrides a credential, so its value has to be an estafette.secret(...) envelope", leaf.envVarName())
+			}
+			merged = setLeaf(merged, keys, parseEnvVarValue(value))
+			sourceByPath[leaf.path()] = fmt.Sprintf("env %v", leaf.envVarName())
+		}
+	}
+
+	for _, leaf := range getLeaves(nil, merged) {
+		sources = append(sources, ConfigSource{
+			Path:   leaf.path(),
+			Value:  redactValue(leaf),
+			Source: sourceByPath[leaf.path()],
+		})
+	}
+
+	data, err := yaml.Marshal(merged)
 	if err != nil {
-		return config, err
+		return
 	}
 
 	// decrypt secrets before unmarshalling
 	if decryptSecrets {
 
+		// secrets that fail to decrypt end up empty, so check them all first
+		err = validateSecrets(data, h.secretHelper)
+		if err != nil {
+			return config, sources, err
+		}
+
 		decryptedData, err := h.secretHelper.DecryptAllEnvelopes(string(data))
 		if err != nil {
-			log.Fatal().Err(err).Msg("Failed decrypting secrets in config file")
+			return config, sources, fmt.Errorf("Failed decrypting secrets in config file %v: %v", configPath, err)
 		}
 
 		data = []byte(decryptedData)
@@ -127,10 +339,24 @@ func (h *configReaderImpl) ReadConfigFromFile(configPath string, decryptSecrets
 
 	// unmarshal into structs
 	if err := yaml.Unmarshal(data, &config); err != nil {
-		return config, err
+		return config, sources, err
+	}
+	if config == nil {
+		config = &APIConfig{}
 	}
 
-	log.Info().Msgf("Finished reading %v file successfully", configPath)
+	log.Info().Msgf("Finished reading %v file(s) successfully", strings.Join(configPaths, ", "))
 
 	return
 }
+
+// ValidateConfigFile reads the config file with its overlays, decrypting its secrets, and returns config.ValidationErrors for all problems found in it
+func (h *configReaderImpl) ValidateConfigFile(configPath string, overlayPaths []string) error {
+
+	config, _, err := h.ReadLayeredConfig(configPath, overlayPaths, true)
+	if err != nil {
+		return err
+	}
+
+	return config.Validate()
+}
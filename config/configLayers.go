@@ -0,0 +1,196 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// ConfigSource tells where the effective value at a yaml path in the config came from; the value is redacted if it's a secret
+type ConfigSource struct {
+	Path   string `json:"path"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+// envVarPrefix is prepended to the upper-cased yaml path of a leaf value to form the environment variable overriding it, e.g. ESTAFETTE_CI_API_CONFIG_DATABASE_PORT; it's long enough not to collide with the ESTAFETTE_ variables estafette sets itself
+const envVarPrefix = "ESTAFETTE_CI_API_CONFIG_"
+
+// configLeaf is a scalar value in the unmarshalled yaml, with the keys and sequence indexes leading up to it
+type configLeaf struct {
+	keys  []interface{}
+	value interface{}
+}
+
+func (l configLeaf) path() string {
+	path := ""
+	for _, key := range l.keys {
+		if index, ok := key.(int); ok {
+			path += fmt.Sprintf("[%v]", index)
+			continue
+		}
+		if path != "" {
+			path += "."
+		}
+		path += fmt.Sprintf("%v", key)
+	}
+	return path
+}
+
+func (l configLeaf) envVarName() string {
+	parts := make([]string, len(l.keys))
+	for i, key := range l.keys {
+		parts[i] = strings.ToUpper(fmt.Sprintf("%v", key))
+	}
+	return envVarPrefix + strings.Join(parts, "_")
+}
+
+// getLeaves returns all scalar values in the unmarshalled yaml in the order of the file
+func getLeaves(keys []interface{}, node interface{}) (leaves []configLeaf) {
+	switch n := node.(type) {
+	case yaml.MapSlice:
+		for _, item := range n {
+			leaves = append(leaves, getLeaves(appendKey(keys, item.Key), item.Value)...)
+		}
+	case []interface{}:
+		for i, item := range n {
+			leaves = append(leaves, getLeaves(appendKey(keys, i), item)...)
+		}
+	default:
+		leaves = append(leaves, configLeaf{keys: keys, value: node})
+	}
+	return
+}
+
+// getStructLeafKeys returns the keys of all scalar fields of the config structs, so they can be overridden by environment variables even if they're absent from the files; sequences can only be overridden for items present in the files
+func getStructLeafKeys(keys []interface{}, t reflect.Type) (leafKeys [][]interface{}) {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		switch fieldType.Kind() {
+		case reflect.Struct:
+			leafKeys = append(leafKeys, getStructLeafKeys(appendKey(keys, name), fieldType)...)
+		case reflect.Slice, reflect.Map:
+		default:
+			leafKeys = append(leafKeys, appendKey(keys, name))
+		}
+	}
+	return
+}
+
+// mergeNodes merges the overlay into the base; maps get merged key by key, sequences and scalars get replaced
+func mergeNodes(base, overlay interface{}) interface{} {
+	baseMap, baseIsMap := base.(yaml.MapSlice)
+	overlayMap, overlayIsMap := overlay.(yaml.MapSlice)
+	if !baseIsMap || !overlayIsMap {
+		return overlay
+	}
+
+	merged := append(yaml.MapSlice{}, baseMap...)
+	for _, overlayItem := range overlayMap {
+		found := false
+		for i, item := range merged {
+			if item.Key == overlayItem.Key {
+				merged[i].Value = mergeNodes(item.Value, overlayItem.Value)
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, overlayItem)
+		}
+	}
+	return merged
+}
+
+// setLeaf returns the node with the value set at the keys, creating maps along the way where needed
+func setLeaf(node interface{}, keys []interface{}, value interface{}) interface{} {
+	if len(keys) == 0 {
+		return value
+	}
+
+	if index, ok := keys[0].(int); ok {
+		sequence, ok := node.([]interface{})
+		if !ok || index >= len(sequence) {
+			return node
+		}
+		sequence[index] = setLeaf(sequence[index], keys[1:], value)
+		return sequence
+	}
+
+	mapSlice, _ := node.(yaml.MapSlice)
+	for i, item := range mapSlice {
+		if item.Key == keys[0] {
+			mapSlice[i].Value = setLeaf(item.Value, keys[1:], value)
+			return mapSlice
+		}
+	}
+	return append(mapSlice, yaml.MapItem{Key: keys[0], Value: setLeaf(nil, keys[1:], value)})
+}
+
+// parseEnvVarValue turns numbers and booleans into their yaml types, so they unmarshal into int and bool fields
+func parseEnvVarValue(value string) interface{} {
+	if i, err := strconv.Atoi(value); err == nil {
+		return i
+	}
+	if b, err := strconv.ParseBool(value); err == nil && (value == "true" || value == "false") {
+		return b
+	}
+	return value
+}
+
+var sensitiveKeyRegex = regexp.MustCompile(`(?i)(secret|password|token|key|keyfile)$`)
+
+// isSensitiveKey tells whether the last key leading up to a value marks it as a credential
+func (l configLeaf) isSensitiveKey() bool {
+	return len(l.keys) > 0 && sensitiveKeyRegex.MatchString(fmt.Sprintf("%v", l.keys[len(l.keys)-1]))
+}
+
+// redactValue hides secret envelopes and values of keys that hold credentials
+func redactValue(leaf configLeaf) string {
+	if leaf.value == nil {
+		return ""
+	}
+	if leaf.isSensitiveKey() {
+		return "***"
+	}
+	return secretEnvelopeRegex.ReplaceAllLiteralString(fmt.Sprintf("%v", leaf.value), "***")
+}
+
+// RedactConfig hides secret envelopes and values of keys that hold credentials in the marshalled config, so values that didn't come in an envelope don't leak either
+func RedactConfig(data []byte) ([]byte, error) {
+
+	var node yaml.MapSlice
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+
+	var redacted interface{} = node
+	for _, leaf := range getLeaves(nil, node) {
+		if leaf.value == nil {
+			continue
+		}
+		redacted = setLeaf(redacted, leaf.keys, redactValue(leaf))
+	}
+
+	return yaml.Marshal(redacted)
+}
+
+func appendKey(keys []interface{}, key interface{}) []interface{} {
+	return append(append([]interface{}{}, keys...), key)
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestConfigLeaf(t *testing.T) {
+
+	t.Run("ReturnsPathWithIndexesForSequenceItems", func(t *testing.T) {
+
+		leaf := configLeaf{keys: []interface{}{"integrations", "github", "apps", 0, "appID"}}
+
+		// act
+		path := leaf.path()
+
+		assert.Equal(t, "integrations.github.apps[0].appID", path)
+	})
+
+	t.Run("ReturnsUpperCasedEnvVarNameWithPrefix", func(t *testing.T) {
+
+		leaf := configLeaf{keys: []interface{}{"integrations", "github", "apps", 0, "appID"}}
+
+		// act
+		envVarName := leaf.envVarName()
+
+		assert.Equal(t, "ESTAFETTE_CI_API_CONFIG_INTEGRATIONS_GITHUB_APPS_0_APPID", envVarName)
+	})
+}
+
+func TestMergeNodes(t *testing.T) {
+
+	t.Run("MergesMapsKeyByKeyAndReplacesSequences", func(t *testing.T) {
+
+		var base, overlay yaml.MapSlice
+		yaml.Unmarshal([]byte("a:\n  b: 1\n  c: 2\nd:\n- 1\n- 2\n"), &base)
+		yaml.Unmarshal([]byte("a:\n  c: 3\n  e: 4\nd:\n- 5\n"), &overlay)
+
+		// act
+		merged := mergeNodes(base, overlay)
+
+		data, _ := yaml.Marshal(merged)
+		assert.Equal(t, "a:\n  b: 1\n  c: 3\n  e: 4\nd:\n- 5\n", string(data))
+	})
+}
+
+func TestRedactValue(t *testing.T) {
+
+	t.Run("RedactsSecretEnvelopes", func(t *testing.T) {
+
+		leaf := configLeaf{keys: []interface{}{"credentials", 0, "webhook"}, value: "estafette.secret(deFTz5Bdjg6SUe29.oPIkXbze5G9PNEWS2-ZnArl8BCqHnx4MdTdxHg37th9u)"}
+
+		// act
+		value := redactValue(leaf)
+
+		assert.Equal(t, "***", value)
+	})
+
+	t.Run("RedactsValuesOfKeysHoldingCredentials", func(t *testing.T) {
+
+		leaf := configLeaf{keys: []interface{}{"auth", "apiKey"}, value: "plaintextkey"}
+
+		// act
+		value := redactValue(leaf)
+
+		assert.Equal(t, "***", value)
+	})
+
+	t.Run("DoesNotRedactOtherValues", func(t *testing.T) {
+
+		leaf := configLeaf{keys: []interface{}{"integrations", "github", "privateKeyPath"}, value: "/github-app-key/private-key.pem"}
+
+		// act
+		value := redactValue(leaf)
+
+		assert.Equal(t, "/github-app-key/private-key.pem", value)
+	})
+}
+
+func TestRedactConfig(t *testing.T) {
+
+	t.Run("RedactsPlaintextCredentialsAndSecretEnvelopes", func(t *testing.T) {
+
+		data := []byte("database:\n  host: cockroachdb-public\n  password: plaintext\nauth:\n  apiKey: estafette.secret(abc.def)\n")
+
+		// act
+		redacted, err := RedactConfig(data)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "database:\n  host: cockroachdb-public\n  password: '***'\nauth:\n  apiKey: '***'\n", string(redacted))
+	})
+}
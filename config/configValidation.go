@@ -0,0 +1,179 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	crypt "github.com/estafette/estafette-ci-crypt"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// ValidationError is a problem with a single value in the config file, located by its yaml path
+type ValidationError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (e ValidationError) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%v: %v", e.Path, e.Message)
+}
+
+// ValidationErrors holds all problems found in the config file, so they can be fixed in one go
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Validate returns config.ValidationErrors for missing required values, invalid ports and credentials that are duplicate or can't be injected; it returns nil if the config is valid
+func (c *APIConfig) Validate() error {
+
+	errors := ValidationErrors{}
+
+	addError := func(path, format string, a ...interface{}) {
+		errors = append(errors, ValidationError{Path: path, Message: fmt.Sprintf(format, a...)})
+	}
+	required := func(path, value string) {
+		if value == "" {
+			addError(path, "is required")
+		}
+	}
+
+	if c.APIServer == nil {
+		addError("apiServer", "is required")
+	} else {
+		required("apiServer.baseURL", c.APIServer.BaseURL)
+		required("apiServer.serviceURL", c.APIServer.ServiceURL)
+	}
+
+	if c.Auth == nil {
+		addError("auth", "is required")
+	} else {
+		required("auth.apiKey", c.Auth.APIKey)
+		if c.Auth.IAP == nil {
+			addError("auth.iap", "is required")
+		} else if c.Auth.IAP.Enable {
+			required("auth.iap.audience", c.Auth.IAP.Audience)
+		}
+	}
+
+	if c.Database == nil {
+		addError("database", "is required")
+	} else {
+		required("database.databaseName", c.Database.DatabaseName)
+		required("database.host", c.Database.Host)
+		required("database.user", c.Database.User)
+		if c.Database.Port < 1 || c.Database.Port > 65535 {
+			addError("database.port", "%v is not a valid port, it should be between 1 and 65535", c.Database.Port)
+		}
+	}
+
+	if c.Integrations == nil {
+		addError("integrations", "is required")
+	} else {
+		if c.Integrations.Github == nil {
+			addError("integrations.github", "is required")
+		} else {
+			// the top-level app settings are only used when no apps are listed
+			if len(c.Integrations.Github.Apps) == 0 {
+				required("integrations.github.appID", c.Integrations.Github.AppID)
+				required("integrations.github.privateKeyPath", c.Integrations.Github.PrivateKeyPath)
+				required("integrations.github.webhookSecret", c.Integrations.Github.WebhookSecret)
+			}
+			for i, app := range c.Integrations.Github.Apps {
+				required(fmt.Sprintf("integrations.github.apps[%v].appID", i), app.AppID)
+				required(fmt.Sprintf("integrations.github.apps[%v].privateKeyPath", i), app.PrivateKeyPath)
+				required(fmt.Sprintf("integrations.github.apps[%v].webhookSecret", i), app.WebhookSecret)
+			}
+		}
+		if c.Integrations.Bitbucket == nil {
+			addError("integrations.bitbucket", "is required")
+		}
+		if c.Integrations.Slack == nil {
+			addError("integrations.slack", "is required")
+		}
+	}
+
+	credentialTypes := map[string]bool{}
+	credentialIndexByName := map[string]int{}
+	for i, credential := range c.Credentials {
+		required(fmt.Sprintf("credentials[%v].name", i), credential.Name)
+		required(fmt.Sprintf("credentials[%v].type", i), credential.Type)
+		if j, ok := credentialIndexByName[credential.Name]; ok && credential.Name != "" {
+			addError(fmt.Sprintf("credentials[%v].name", i), "%v is already used by credentials[%v]", credential.Name, j)
+		} else {
+			credentialIndexByName[credential.Name] = i
+		}
+		credentialTypes[credential.Type] = true
+	}
+
+	for i, trustedImage := range c.TrustedImages {
+		required(fmt.Sprintf("trustedImages[%v].path", i), trustedImage.ImagePath)
+		for j, credentialType := range trustedImage.InjectedCredentialTypes {
+			if !credentialTypes[credentialType] {
+				addError(fmt.Sprintf("trustedImages[%v].injectedCredentialTypes[%v]", i, j), "there are no credentials of type %v", credentialType)
+			}
+		}
+	}
+
+	if len(errors) > 0 {
+		return errors
+	}
+
+	return nil
+}
+
+// validateSecrets returns config.ValidationErrors for all secrets in the config file that can't be decrypted with the secret decryption key
+func validateSecrets(data []byte, secretHelper crypt.SecretHelper) error {
+
+	// unmarshal into a map slice to keep the order of the file for the errors
+	var root yaml.MapSlice
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return ValidationErrors{ValidationError{Message: err.Error()}}
+	}
+
+	errors := ValidationErrors{}
+	walkStringValues("", root, func(path, value string) {
+		for _, envelope := range secretEnvelopeRegex.FindAllString(value, -1) {
+			if _, err := secretHelper.DecryptEnvelope(envelope); err != nil {
+				errors = append(errors, ValidationError{Path: path, Message: fmt.Sprintf("secret can't be decrypted: %v", err)})
+			}
+		}
+	})
+
+	if len(errors) > 0 {
+		return errors
+	}
+
+	return nil
+}
+
+var secretEnvelopeRegex = regexp.MustCompile(`estafette\.secret\([a-zA-Z0-9.=_-]+\)`)
+
+// walkStringValues calls the func for each string value in the unmarshalled yaml with its path
+func walkStringValues(path string, value interface{}, f func(string, string)) {
+	switch v := value.(type) {
+	case yaml.MapSlice:
+		for _, item := range v {
+			childPath := fmt.Sprintf("%v", item.Key)
+			if path != "" {
+				childPath = path + "." + childPath
+			}
+			walkStringValues(childPath, item.Value, f)
+		}
+	case []interface{}:
+		for i, item := range v {
+			walkStringValues(fmt.Sprintf("%v[%v]", path, i), item, f)
+		}
+	case string:
+		f(path, v)
+	}
+}
@@ -0,0 +1,155 @@
+package config
+
+import (
+	"testing"
+
+	contracts "github.com/estafette/estafette-ci-contracts"
+	crypt "github.com/estafette/estafette-ci-crypt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+
+	readTestConfig := func() *APIConfig {
+		configReader := NewConfigReader(crypt.NewSecretHelper("SazbwMf3NZxVVbBqQHebPcXCqrVn3DDp"))
+		config, _ := configReader.ReadConfigFromFile("test-config.yaml", true)
+		return config
+	}
+
+	t.Run("ReturnsNilForTestConfig", func(t *testing.T) {
+
+		config := readTestConfig()
+
+		// act
+		err := config.Validate()
+
+		assert.Nil(t, err)
+	})
+
+	t.Run("ReturnsErrorWithPathForMissingSection", func(t *testing.T) {
+
+		config := readTestConfig()
+		config.Integrations.Github = nil
+
+		// act
+		err := config.Validate()
+
+		assert.Equal(t, ValidationErrors{ValidationError{Path: "integrations.github", Message: "is required"}}, err)
+	})
+
+	t.Run("ReturnsErrorWithPathForMissingRequiredField", func(t *testing.T) {
+
+		config := readTestConfig()
+		config.Integrations.Github.Apps[0].WebhookSecret = ""
+
+		// act
+		err := config.Validate()
+
+		assert.Equal(t, ValidationErrors{ValidationError{Path: "integrations.github.apps[0].webhookSecret", Message: "is required"}}, err)
+	})
+
+	t.Run("ReturnsNilForMissingTopLevelGithubAppIfAppsAreListed", func(t *testing.T) {
+
+		config := readTestConfig()
+		config.Integrations.Github.AppID = ""
+		config.Integrations.Github.PrivateKeyPath = ""
+		config.Integrations.Github.WebhookSecret = ""
+
+		// act
+		err := config.Validate()
+
+		assert.Nil(t, err)
+	})
+
+	t.Run("ReturnsErrorsForMissingTopLevelGithubAppIfNoAppsAreListed", func(t *testing.T) {
+
+		config := readTestConfig()
+		config.Integrations.Github.AppID = ""
+		config.Integrations.Github.PrivateKeyPath = ""
+		config.Integrations.Github.WebhookSecret = ""
+		config.Integrations.Github.Apps = nil
+
+		// act
+		err := config.Validate()
+
+		assert.Equal(t, ValidationErrors{
+			ValidationError{Path: "integrations.github.appID", Message: "is required"},
+			ValidationError{Path: "integrations.github.privateKeyPath", Message: "is required"},
+			ValidationError{Path: "integrations.github.webhookSecret", Message: "is required"},
+		}, err)
+	})
+
+	t.Run("ReturnsErrorForInvalidPort", func(t *testing.T) {
+
+		config := readTestConfig()
+		config.Database.Port = 262570
+
+		// act
+		err := config.Validate()
+
+		assert.Equal(t, ValidationErrors{ValidationError{Path: "database.port", Message: "262570 is not a valid port, it should be between 1 and 65535"}}, err)
+	})
+
+	t.Run("ReturnsErrorForDuplicateCredentialName", func(t *testing.T) {
+
+		config := readTestConfig()
+		config.Credentials = append(config.Credentials, &contracts.CredentialConfig{Name: "github-api-token", Type: "github-api-token"})
+
+		// act
+		err := config.Validate()
+
+		assert.Equal(t, ValidationErrors{ValidationError{Path: "credentials[8].name", Message: "github-api-token is already used by credentials[5]"}}, err)
+	})
+
+	t.Run("ReturnsErrorForInjectedCredentialTypeWithoutCredentials", func(t *testing.T) {
+
+		config := readTestConfig()
+		config.TrustedImages[1].InjectedCredentialTypes = []string{"kubernetes-engin"}
+
+		// act
+		err := config.Validate()
+
+		assert.Equal(t, ValidationErrors{ValidationError{Path: "trustedImages[1].injectedCredentialTypes[0]", Message: "there are no credentials of type kubernetes-engin"}}, err)
+	})
+
+	t.Run("ReturnsAllErrors", func(t *testing.T) {
+
+		config := readTestConfig()
+		config.APIServer.BaseURL = ""
+		config.Database.Port = 0
+
+		// act
+		err := config.Validate()
+
+		assert.Equal(t, 2, len(err.(ValidationErrors)))
+		assert.Equal(t, "apiServer.baseURL: is required; database.port: 0 is not a valid port, it should be between 1 and 65535", err.Error())
+	})
+}
+
+func TestValidateConfigFile(t *testing.T) {
+
+	t.Run("ReturnsNilForTestConfig", func(t *testing.T) {
+
+		configReader := NewConfigReader(crypt.NewSecretHelper("SazbwMf3NZxVVbBqQHebPcXCqrVn3DDp"))
+
+		// act
+		err := configReader.ValidateConfigFile("test-config.yaml", nil)
+
+		assert.Nil(t, err)
+	})
+
+	t.Run("ReturnsErrorWithPathForEachSecretThatCanNotBeDecrypted", func(t *testing.T) {
+
+		configReader := NewConfigReader(crypt.NewSecretHelper("AazbwMf3NZxVVbBqQHebPcXCqrVn3DDp"))
+
+		// act
+		err := configReader.ValidateConfigFile("test-config.yaml", nil)
+
+		validationErrors, ok := err.(ValidationErrors)
+		assert.True(t, ok)
+		assert.Equal(t, 10, len(validationErrors))
+		assert.Equal(t, "integrations.github.clientSecret", validationErrors[0].Path)
+		assert.Equal(t, "integrations.github.apps[0].clientSecret", validationErrors[2].Path)
+		assert.Equal(t, "database.password", validationErrors[9].Path)
+	})
+}
@@ -0,0 +1,165 @@
+package config
+
+import (
+	"crypto/sha256"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+)
+
+// ConfigWatcher reloads the api config when its base or overlay files change - for example when the configmap it's mounted from gets updated - or when the process receives a SIGHUP
+type ConfigWatcher interface {
+	Run()
+	Reload(string) error
+}
+
+type configWatcherImpl struct {
+	waitGroup                    *sync.WaitGroup
+	stopChannel                  <-chan struct{}
+	configReader                 ConfigReader
+	configFilePath               string
+	overlayFilePaths             []string
+	pollInterval                 time.Duration
+	reloadFunc                   func(*APIConfig, *APIConfig, []ConfigSource)
+	prometheusConfigReloadTotals *prometheus.CounterVec
+
+	// serializes reloads triggered by the file poller and SIGHUP
+	reloadMutex sync.Mutex
+	checksum    [sha256.Size]byte
+}
+
+// NewConfigWatcher returns a new config.ConfigWatcher; the reload func gets called with the decrypted and encrypted config and the sources of its values after they've been read and validated successfully
+func NewConfigWatcher(stopChannel <-chan struct{}, waitGroup *sync.WaitGroup, configReader ConfigReader, configFilePath string, overlayFilePaths []string, pollInterval time.Duration, reloadFunc func(*APIConfig, *APIConfig, []ConfigSource), prometheusConfigReloadTotals *prometheus.CounterVec) ConfigWatcher {
+
+	configWatcher := &configWatcherImpl{
+		waitGroup:                    waitGroup,
+		stopChannel:                  stopChannel,
+		configReader:                 configReader,
+		configFilePath:               configFilePath,
+		overlayFilePaths:             overlayFilePaths,
+		pollInterval:                 pollInterval,
+		reloadFunc:                   reloadFunc,
+		prometheusConfigReloadTotals: prometheusConfigReloadTotals,
+	}
+
+	// the config got read at startup, so only a change from here on triggers a reload
+	configWatcher.checksum, _ = configWatcher.getChecksum()
+
+	return configWatcher
+}
+
+// Run polls the config files for changes and listens for SIGHUP until the stop channel closes
+func (w *configWatcherImpl) Run() {
+
+	sighups := make(chan os.Signal, 1)
+	signal.Notify(sighups, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(sighups)
+
+		ticker := time.NewTicker(w.pollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				checksum, err := w.getChecksum()
+				if err != nil {
+					log.Warn().Err(err).Msgf("Failed checking config files %v for changes", w.getConfigFilePaths())
+					continue
+				}
+				if checksum != w.getLastChecksum() {
+					w.reloadInBackground("file")
+				}
+			case <-sighups:
+				w.reloadInBackground("sighup")
+			case <-w.stopChannel:
+				log.Debug().Msg("Stopping config watcher...")
+				return
+			}
+		}
+	}()
+}
+
+// Reload reads, merges, decrypts and validates the config files and hands the result to the reload func; on failure the config in use stays untouched
+func (w *configWatcherImpl) Reload(trigger string) (err error) {
+
+	w.reloadMutex.Lock()
+	defer w.reloadMutex.Unlock()
+
+	// remember the checksum before reading, so a broken file doesn't get retried on every poll but a fix does get picked up
+	w.checksum, _ = w.getChecksum()
+
+	defer func() {
+		result := "succeeded"
+		if err != nil {
+			result = "failed"
+		}
+		w.prometheusConfigReloadTotals.With(prometheus.Labels{"trigger": trigger, "result": result}).Inc()
+	}()
+
+	config, _, err := w.configReader.ReadLayeredConfig(w.configFilePath, w.overlayFilePaths, true)
+	if err != nil {
+		log.Error().Err(err).Str("trigger", trigger).Msgf("Failed reloading config files %v, keeping last good config", w.getConfigFilePaths())
+		return
+	}
+
+	encryptedConfig, sources, err := w.configReader.ReadLayeredConfig(w.configFilePath, w.overlayFilePaths, false)
+	if err != nil {
+		log.Error().Err(err).Str("trigger", trigger).Msgf("Failed reloading config files %v without decrypting, keeping last good config", w.getConfigFilePaths())
+		return
+	}
+
+	err = config.Validate()
+	if err != nil {
+		log.Error().Err(err).Str("trigger", trigger).Msgf("Reloaded config files %v are invalid, keeping last good config", w.getConfigFilePaths())
+		return
+	}
+
+	w.reloadFunc(config, encryptedConfig, sources)
+
+	log.Info().Str("trigger", trigger).Msgf("Reloaded config files %v successfully", w.getConfigFilePaths())
+
+	return
+}
+
+func (w *configWatcherImpl) reloadInBackground(trigger string) {
+	w.waitGroup.Add(1)
+	go func() {
+		defer w.waitGroup.Done()
+		w.Reload(trigger)
+	}()
+}
+
+func (w *configWatcherImpl) getChecksum() (checksum [sha256.Size]byte, err error) {
+
+	// configmap volumes swap a symlink on update, so read the files instead of relying on their modification time
+	hash := sha256.New()
+	for _, configFilePath := range w.getConfigFilePaths() {
+		data, err := ioutil.ReadFile(configFilePath)
+		if err != nil {
+			return checksum, err
+		}
+		hash.Write(data)
+	}
+	copy(checksum[:], hash.Sum(nil))
+
+	return
+}
+
+func (w *configWatcherImpl) getConfigFilePaths() []string {
+	return append([]string{w.configFilePath}, w.overlayFilePaths...)
+}
+
+func (w *configWatcherImpl) getLastChecksum() [sha256.Size]byte {
+	w.reloadMutex.Lock()
+	defer w.reloadMutex.Unlock()
+
+	return w.checksum
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	crypt "github.com/estafette/estafette-ci-crypt"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigWatcherReload(t *testing.T) {
+
+	prometheusConfigReloadTotals := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "config_reload_totals"}, []string{"trigger", "result"})
+
+	newConfigWatcher := func(configFilePath string, reloadFunc func(*APIConfig, *APIConfig, []ConfigSource)) ConfigWatcher {
+		configReader := NewConfigReader(crypt.NewSecretHelper("SazbwMf3NZxVVbBqQHebPcXCqrVn3DDp"))
+		return NewConfigWatcher(make(chan struct{}), &sync.WaitGroup{}, configReader, configFilePath, nil, time.Minute, reloadFunc, prometheusConfigReloadTotals)
+	}
+
+	writeConfigFile := func(t *testing.T, data []byte) string {
+		dir, err := ioutil.TempDir("", "config")
+		assert.Nil(t, err)
+		configFilePath := filepath.Join(dir, "config.yaml")
+		err = ioutil.WriteFile(configFilePath, data, 0644)
+		assert.Nil(t, err)
+		return configFilePath
+	}
+
+	t.Run("CallsReloadFuncWithDecryptedAndEncryptedConfig", func(t *testing.T) {
+
+		data, _ := ioutil.ReadFile("test-config.yaml")
+		configFilePath := writeConfigFile(t, data)
+		defer os.RemoveAll(filepath.Dir(configFilePath))
+
+		var reloadedConfig, reloadedEncryptedConfig *APIConfig
+		configWatcher := newConfigWatcher(configFilePath, func(config, encryptedConfig *APIConfig, sources []ConfigSource) {
+			reloadedConfig = config
+			reloadedEncryptedConfig = encryptedConfig
+		})
+
+		// act
+		err := configWatcher.Reload("sighup")
+
+		assert.Nil(t, err)
+		assert.NotNil(t, reloadedConfig)
+		assert.NotNil(t, reloadedEncryptedConfig)
+		assert.Equal(t, reloadedConfig.APIServer.BaseURL, reloadedEncryptedConfig.APIServer.BaseURL)
+	})
+
+	t.Run("ReturnsErrorAndDoesNotCallReloadFuncIfConfigIsInvalid", func(t *testing.T) {
+
+		configFilePath := writeConfigFile(t, []byte("apiServer:\n  baseURL: https://ci.estafette.io/\n"))
+		defer os.RemoveAll(filepath.Dir(configFilePath))
+
+		reloadFuncCalled := false
+		configWatcher := newConfigWatcher(configFilePath, func(config, encryptedConfig *APIConfig, sources []ConfigSource) {
+			reloadFuncCalled = true
+		})
+
+		// act
+		err := configWatcher.Reload("file")
+
+		assert.NotNil(t, err)
+		assert.False(t, reloadFuncCalled)
+	})
+
+	t.Run("ReturnsErrorAndDoesNotCallReloadFuncIfFileIsMissing", func(t *testing.T) {
+
+		reloadFuncCalled := false
+		configWatcher := newConfigWatcher("does-not-exist.yaml", func(config, encryptedConfig *APIConfig, sources []ConfigSource) {
+			reloadFuncCalled = true
+		})
+
+		// act
+		err := configWatcher.Reload("file")
+
+		assert.NotNil(t, err)
+		assert.False(t, reloadFuncCalled)
+	})
+}
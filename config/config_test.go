@@ -2,6 +2,7 @@ package config
 
 import (
 	"encoding/json"
+	"os"
 	"testing"
 
 	crypt "github.com/estafette/estafette-ci-crypt"
@@ -35,6 +36,10 @@ func TestReadConfigFromFile(t *testing.T) {
 		assert.Equal(t, "this is my secret", githubConfig.ClientSecret)
 		assert.Equal(t, 100, githubConfig.EventChannelBufferSize)
 		assert.Equal(t, 5, githubConfig.MaxWorkers)
+		assert.Equal(t, 1, len(githubConfig.Apps))
+		assert.Equal(t, "3", githubConfig.Apps[0].AppID)
+		assert.Equal(t, "this is my secret", githubConfig.Apps[0].WebhookSecret)
+		assert.Equal(t, "https://github.estafette.io/api/v3/", githubConfig.Apps[0].APIBaseURL)
 	})
 
 	t.Run("ReturnsBitbucketConfig", func(t *testing.T) {
@@ -83,6 +88,7 @@ func TestReadConfigFromFile(t *testing.T) {
 		assert.Equal(t, "http://estafette-ci-api.estafette.svc.cluster.local/", apiServerConfig.ServiceURL)
 		assert.Equal(t, 100, apiServerConfig.EventChannelBufferSize)
 		assert.Equal(t, 5, apiServerConfig.MaxWorkers)
+		assert.Equal(t, []string{"[skip ci]", "[ci skip]"}, apiServerConfig.SkipDirectives)
 	})
 
 	t.Run("ReturnsAuthConfig", func(t *testing.T) {
@@ -97,6 +103,7 @@ func TestReadConfigFromFile(t *testing.T) {
 		assert.True(t, authConfig.IAP.Enable)
 		assert.Equal(t, "/projects/***/global/backendServices/***", authConfig.IAP.Audience)
 		assert.Equal(t, "this is my secret", authConfig.APIKey)
+		assert.Equal(t, []string{"admin@estafette.io"}, authConfig.Administrators)
 	})
 
 	t.Run("ReturnsDatabaseConfig", func(t *testing.T) {
@@ -170,6 +177,22 @@ func TestReadConfigFromFile(t *testing.T) {
 		assert.Equal(t, "https://mirror.gcr.io", *registryMirrorConfig)
 	})
 
+	t.Run("ReturnsManifestRules", func(t *testing.T) {
+
+		configReader := NewConfigReader(crypt.NewSecretHelper("SazbwMf3NZxVVbBqQHebPcXCqrVn3DDp"))
+
+		// act
+		config, _ := configReader.ReadConfigFromFile("test-config.yaml", true)
+
+		manifestRulesConfig := config.ManifestRules
+
+		assert.NotNil(t, manifestRulesConfig)
+		assert.Equal(t, []string{"missing-labels"}, manifestRulesConfig.Disabled)
+		assert.Equal(t, 1, len(manifestRulesConfig.Owners))
+		assert.Equal(t, "estafette", manifestRulesConfig.Owners[0].Owner)
+		assert.Equal(t, []string{"missing-labels"}, manifestRulesConfig.Owners[0].Enabled)
+	})
+
 	t.Run("AllowsCredentialConfigWithComplexAdditionalPropertiesToBeJSONMarshalled", func(t *testing.T) {
 
 		configReader := NewConfigReader(crypt.NewSecretHelper("SazbwMf3NZxVVbBqQHebPcXCqrVn3DDp"))
@@ -185,3 +208,254 @@ func TestReadConfigFromFile(t *testing.T) {
 		assert.Equal(t, "{\"name\":\"gke-estafette-production\",\"type\":\"kubernetes-engine\",\"additionalProperties\":{\"cluster\":\"production-europe-west2\",\"defaults\":{\"autoscale\":{\"min\":2},\"container\":{\"repository\":\"estafette\"},\"namespace\":\"estafette\",\"sidecar\":{\"image\":\"estafette/openresty-sidecar:1.13.6.1-alpine\",\"type\":\"openresty\"}},\"project\":\"estafette-production\",\"region\":\"europe-west2\",\"serviceAccountKeyfile\":\"{}\"}}", string(bytes))
 	})
 }
+
+func TestReadLayeredConfig(t *testing.T) {
+
+	t.Run("MergesOverlayIntoBaseConfig", func(t *testing.T) {
+
+		configReader := NewConfigReader(crypt.NewSecretHelper("SazbwMf3NZxVVbBqQHebPcXCqrVn3DDp"))
+
+		// act
+		config, _, err := configReader.ReadLayeredConfig("test-config.yaml", []string{"test-config-overlay.yaml"}, true)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "https://ci.estafette.dev/", config.APIServer.BaseURL)
+		assert.Equal(t, "http://estafette-ci-api.estafette.svc.cluster.local/", config.APIServer.ServiceURL)
+		assert.Equal(t, "cockroachdb-public.estafette-dev.svc.cluster.local", config.Database.Host)
+		assert.Equal(t, 26257, config.Database.Port)
+		assert.Equal(t, 1, len(config.TrustedImages))
+		assert.Equal(t, 8, len(config.Credentials))
+	})
+
+	t.Run("AppliesEnvironmentVariableOverridesAfterOverlays", func(t *testing.T) {
+
+		os.Setenv("ESTAFETTE_CI_API_CONFIG_DATABASE_PORT", "26258")
+		os.Setenv("ESTAFETTE_CI_API_CONFIG_APISERVER_BASEURL", "https://ci.estafette.local/")
+		os.Setenv("ESTAFETTE_CI_API_CONFIG_INTEGRATIONS_GITHUB_APPS_0_APPID", "4")
+		defer os.Unsetenv("ESTAFETTE_CI_API_CONFIG_DATABASE_PORT")
+		defer os.Unsetenv("ESTAFETTE_CI_API_CONFIG_APISERVER_BASEURL")
+		defer os.Unsetenv("ESTAFETTE_CI_API_CONFIG_INTEGRATIONS_GITHUB_APPS_0_APPID")
+
+		configReader := NewConfigReader(crypt.NewSecretHelper("SazbwMf3NZxVVbBqQHebPcXCqrVn3DDp"))
+
+		// act
+		config, _, err := configReader.ReadLayeredConfig("test-config.yaml", []string{"test-config-overlay.yaml"}, true)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 26258, config.Database.Port)
+		assert.Equal(t, "https://ci.estafette.local/", config.APIServer.BaseURL)
+		assert.Equal(t, "4", config.Integrations.Github.Apps[0].AppID)
+	})
+
+	t.Run("AppliesEnvironmentVariableOverridesForFieldsAbsentFromFiles", func(t *testing.T) {
+
+		os.Setenv("ESTAFETTE_CI_API_CONFIG_INTEGRATIONS_GITHUB_APIBASEURL", "https://github.estafette.io/api/v3/")
+		defer os.Unsetenv("ESTAFETTE_CI_API_CONFIG_INTEGRATIONS_GITHUB_APIBASEURL")
+
+		configReader := NewConfigReader(crypt.NewSecretHelper("SazbwMf3NZxVVbBqQHebPcXCqrVn3DDp"))
+
+		// act
+		config, _, err := configReader.ReadLayeredConfig("test-config.yaml", nil, true)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "https://github.estafette.io/api/v3/", config.Integrations.Github.APIBaseURL)
+	})
+
+	t.Run("ReturnsErrorForPlaintextEnvironmentVariableOverrideOfCredential", func(t *testing.T) {
+
+		os.Setenv("ESTAFETTE_CI_API_CONFIG_DATABASE_PASSWORD", "plaintext")
+		defer os.Unsetenv("ESTAFETTE_CI_API_CONFIG_DATABASE_PASSWORD")
+
+		configReader := NewConfigReader(crypt.NewSecretHelper("SazbwMf3NZxVVbBqQHebPcXCqrVn3DDp"))
+
+		// act
+		_, _, err := configReader.ReadLayeredConfig("test-config.yaml", nil, false)
+
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ReturnsSourceOfEachValueWithSecretsRedacted", func(t *testing.T) {
+
+		os.Setenv("ESTAFETTE_CI_API_CONFIG_DATABASE_PORT", "26258")
+		defer os.Unsetenv("ESTAFETTE_CI_API_CONFIG_DATABASE_PORT")
+
+		configReader := NewConfigReader(crypt.NewSecretHelper("SazbwMf3NZxVVbBqQHebPcXCqrVn3DDp"))
+
+		// act
+		_, sources, err := configReader.ReadLayeredConfig("test-config.yaml", []string{"test-config-overlay.yaml"}, false)
+
+		assert.Nil(t, err)
+		sourcesByPath := map[string]ConfigSource{}
+		for _, source := range sources {
+			sourcesByPath[source.Path] = source
+		}
+		assert.Equal(t, ConfigSource{Path: "apiServer.baseURL", Value: "https://ci.estafette.dev/", Source: "test-config-overlay.yaml"}, sourcesByPath["apiServer.baseURL"])
+		assert.Equal(t, ConfigSource{Path: "apiServer.serviceURL", Value: "http://estafette-ci-api.estafette.svc.cluster.local/", Source: "test-config.yaml"}, sourcesByPath["apiServer.serviceURL"])
+		assert.Equal(t, ConfigSource{Path: "database.port", Value: "26258", Source: "env ESTAFETTE_CI_API_CONFIG_DATABASE_PORT"}, sourcesByPath["database.port"])
+		assert.Equal(t, ConfigSource{Path: "database.password", Value: "***", Source: "test-config.yaml"}, sourcesByPath["database.password"])
+		assert.Equal(t, ConfigSource{Path: "credentials[0].password", Value: "***", Source: "test-config.yaml"}, sourcesByPath["credentials[0].password"])
+		assert.Equal(t, ConfigSource{Path: "trustedImages[0].injectedCredentialTypes[0]", Value: "container-registry", Source: "test-config-overlay.yaml"}, sourcesByPath["trustedImages[0].injectedCredentialTypes[0]"])
+		_, hasDroppedTrustedImage := sourcesByPath["trustedImages[1].path"]
+		assert.False(t, hasDroppedTrustedImage)
+	})
+}
+
+func TestIsAdministrator(t *testing.T) {
+
+	t.Run("ReturnsTrueIfEmailIsConfiguredAsAdministratorIgnoringCase", func(t *testing.T) {
+
+		authConfig := AuthConfig{
+			Administrators: []string{"admin@estafette.io"},
+		}
+
+		// act
+		isAdministrator := authConfig.IsAdministrator("Admin@estafette.io")
+
+		assert.True(t, isAdministrator)
+	})
+
+	t.Run("ReturnsFalseIfEmailIsNotConfiguredAsAdministrator", func(t *testing.T) {
+
+		authConfig := AuthConfig{
+			Administrators: []string{"admin@estafette.io"},
+		}
+
+		// act
+		isAdministrator := authConfig.IsAdministrator("someone@estafette.io")
+
+		assert.False(t, isAdministrator)
+	})
+}
+
+func TestManifestRulesConfigIsEnabled(t *testing.T) {
+
+	t.Run("ReturnsDefaultIfConfigIsNil", func(t *testing.T) {
+
+		var manifestRulesConfig *ManifestRulesConfig
+
+		// act
+		enabled := manifestRulesConfig.IsEnabled("too-many-retries", "estafette", true)
+
+		assert.True(t, enabled)
+	})
+
+	t.Run("ReturnsFalseIfRuleIsDisabledForAllOwners", func(t *testing.T) {
+
+		manifestRulesConfig := &ManifestRulesConfig{
+			Disabled: []string{"too-many-retries"},
+		}
+
+		// act
+		enabled := manifestRulesConfig.IsEnabled("too-many-retries", "estafette", true)
+
+		assert.False(t, enabled)
+	})
+
+	t.Run("ReturnsOwnerSettingOverGlobalSetting", func(t *testing.T) {
+
+		manifestRulesConfig := &ManifestRulesConfig{
+			Disabled: []string{"missing-labels"},
+			Owners: []*ManifestRulesOwnerConfig{
+				&ManifestRulesOwnerConfig{Owner: "estafette", Enabled: []string{"missing-labels"}},
+			},
+		}
+
+		// act
+		enabledForOwner := manifestRulesConfig.IsEnabled("missing-labels", "Estafette", false)
+		enabledForOthers := manifestRulesConfig.IsEnabled("missing-labels", "other", false)
+
+		assert.True(t, enabledForOwner)
+		assert.False(t, enabledForOthers)
+	})
+}
+
+func TestGithubConfigGetApps(t *testing.T) {
+
+	githubConfig := GithubConfig{
+		AppID:         "15",
+		WebhookSecret: "secret",
+		Apps: []*GithubAppConfig{
+			&GithubAppConfig{
+				AppID:      "3",
+				APIBaseURL: "https://github.estafette.io/api/v3/",
+				WebBaseURL: "https://github.estafette.io/",
+			},
+		},
+	}
+
+	t.Run("ReturnsTopLevelAppFollowedByAdditionalApps", func(t *testing.T) {
+
+		// act
+		apps := githubConfig.GetApps()
+
+		assert.Equal(t, 2, len(apps))
+		assert.Equal(t, "15", apps[0].AppID)
+		assert.Equal(t, "secret", apps[0].WebhookSecret)
+		assert.Equal(t, "3", apps[1].AppID)
+	})
+
+	t.Run("ReturnsNoTopLevelAppIfItHasNoAppID", func(t *testing.T) {
+
+		githubConfig := GithubConfig{
+			Apps: []*GithubAppConfig{&GithubAppConfig{AppID: "3"}},
+		}
+
+		// act
+		apps := githubConfig.GetApps()
+
+		assert.Equal(t, 1, len(apps))
+		assert.Equal(t, "3", apps[0].AppID)
+	})
+
+	t.Run("ReturnsAppsByRepoSource", func(t *testing.T) {
+
+		// act
+		apps := githubConfig.GetAppsByRepoSource("github.estafette.io")
+
+		assert.Equal(t, 1, len(apps))
+		assert.Equal(t, "3", apps[0].AppID)
+	})
+
+	t.Run("ReturnsAppByID", func(t *testing.T) {
+
+		// act
+		app := githubConfig.GetAppByID("3")
+
+		assert.NotNil(t, app)
+		assert.Equal(t, "github.estafette.io", app.GetRepoSource())
+	})
+}
+
+func TestGithubAppConfigBaseURLs(t *testing.T) {
+
+	t.Run("ReturnsGithubComURLsIfBaseURLsAreNotSet", func(t *testing.T) {
+
+		app := GithubAppConfig{}
+
+		// act
+		apiBaseURL := app.GetAPIBaseURL()
+		webBaseURL := app.GetWebBaseURL()
+		repoSource := app.GetRepoSource()
+
+		assert.Equal(t, "https://api.github.com", apiBaseURL)
+		assert.Equal(t, "https://github.com", webBaseURL)
+		assert.Equal(t, "github.com", repoSource)
+	})
+
+	t.Run("ReturnsConfiguredURLsWithoutTrailingSlashAndRepoSourceFromWebBaseURL", func(t *testing.T) {
+
+		app := GithubAppConfig{
+			APIBaseURL: "https://github.estafette.io/api/v3/",
+			WebBaseURL: "https://github.estafette.io/",
+		}
+
+		// act
+		apiBaseURL := app.GetAPIBaseURL()
+		webBaseURL := app.GetWebBaseURL()
+		repoSource := app.GetRepoSource()
+
+		assert.Equal(t, "https://github.estafette.io/api/v3", apiBaseURL)
+		assert.Equal(t, "https://github.estafette.io", webBaseURL)
+		assert.Equal(t, "github.estafette.io", repoSource)
+	})
+}
@@ -0,0 +1,115 @@
+package estafette
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/estafette/estafette-ci-api/cockroach"
+	"github.com/estafette/estafette-ci-api/config"
+	contracts "github.com/estafette/estafette-ci-contracts"
+	"github.com/rs/zerolog/log"
+)
+
+// BuildStatusHelper reports the status of a build and its stages, or of a release, to the git provider hosting its repository
+type BuildStatusHelper interface {
+	ReportBuildStatus(cockroach.Build, *contracts.BuildLog)
+	ReportReleaseStatus(cockroach.Release, *cockroach.Build)
+}
+
+type buildStatusHelperImpl struct {
+	config                   config.APIServerConfig
+	cockroachDBClient        cockroach.DBClient
+	githubBuildStatusFunc    func(contracts.Build, *contracts.BuildLog, string) error
+	bitbucketBuildStatusFunc func(contracts.Build, *contracts.BuildLog, string) error
+	githubReleaseStatusFunc  func(cockroach.Release, string, string) error
+
+	// release statuses are reported one at a time, so a status sent late can't overwrite a newer one
+	releaseStatusMutex sync.Mutex
+}
+
+// NewBuildStatusHelper returns a new estafette.BuildStatusHelper
+func NewBuildStatusHelper(config config.APIServerConfig, cockroachDBClient cockroach.DBClient, githubBuildStatusFunc func(contracts.Build, *contracts.BuildLog, string) error, bitbucketBuildStatusFunc func(contracts.Build, *contracts.BuildLog, string) error, githubReleaseStatusFunc func(cockroach.Release, string, string) error) BuildStatusHelper {
+	return &buildStatusHelperImpl{
+		config:                   config,
+		cockroachDBClient:        cockroachDBClient,
+		githubBuildStatusFunc:    githubBuildStatusFunc,
+		bitbucketBuildStatusFunc: bitbucketBuildStatusFunc,
+		githubReleaseStatusFunc:  githubReleaseStatusFunc,
+	}
+}
+
+// ReportBuildStatus reports the build status; if no build log is passed the last stored log of the build is used for providers reporting per stage
+func (bh *buildStatusHelperImpl) ReportBuildStatus(build cockroach.Build, buildLog *contracts.BuildLog) {
+
+	// bitbucket only gets a status for the build as a whole
+	if buildLog == nil && build.RepoSource != "bitbucket.org" {
+		var err error
+		buildLog, err = bh.cockroachDBClient.GetPipelineBuildLogs(build.RepoSource, build.RepoOwner, build.RepoName, build.RepoBranch, build.RepoRevision, build.ID)
+		if err != nil {
+			log.Error().Err(err).Msgf("Failed retrieving logs of build %v of %v/%v/%v for reporting its status", build.ID, build.RepoSource, build.RepoOwner, build.RepoName)
+			return
+		}
+	}
+
+	detailsURL := fmt.Sprintf("%vpipelines/%v/%v/%v/builds/%v/logs", bh.config.BaseURL, build.RepoSource, build.RepoOwner, build.RepoName, build.ID)
+
+	var err error
+	switch build.RepoSource {
+	case "bitbucket.org":
+		err = bh.bitbucketBuildStatusFunc(build.Build, buildLog, detailsURL)
+	default:
+		// github.com and github enterprise hosts
+		err = bh.githubBuildStatusFunc(build.Build, buildLog, detailsURL)
+	}
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed reporting status of build %v of %v/%v/%v to %v", build.ID, build.RepoSource, build.RepoOwner, build.RepoName, build.RepoSource)
+	}
+}
+
+// ReportReleaseStatus reports the release status as a deployment of the released revision; if no build is passed the succeeded build for the release version is looked up
+func (bh *buildStatusHelperImpl) ReportReleaseStatus(release cockroach.Release, build *cockroach.Build) {
+
+	// only github knows about deployments
+	if release.RepoSource == "bitbucket.org" {
+		return
+	}
+
+	// reports run in their own goroutines and can arrive out of order; re-reading the release while holding the lock makes the last report carry the current status
+	bh.releaseStatusMutex.Lock()
+	defer bh.releaseStatusMutex.Unlock()
+
+	if releaseID, err := strconv.Atoi(release.ID); err == nil {
+		currentRelease, err := bh.cockroachDBClient.GetPipelineRelease(release.RepoSource, release.RepoOwner, release.RepoName, releaseID)
+		if err != nil {
+			log.Warn().Err(err).Msgf("Failed retrieving release %v of %v/%v/%v for reporting its status, reporting status %v", release.ID, release.RepoSource, release.RepoOwner, release.RepoName, release.ReleaseStatus)
+		} else if currentRelease != nil {
+			release = *currentRelease
+		}
+	}
+
+	if build == nil {
+		builds, err := bh.cockroachDBClient.GetPipelineBuildsByVersion(release.RepoSource, release.RepoOwner, release.RepoName, release.ReleaseVersion, false)
+		if err != nil {
+			log.Error().Err(err).Msgf("Failed retrieving builds for version %v of %v/%v/%v for reporting status of release %v", release.ReleaseVersion, release.RepoSource, release.RepoOwner, release.RepoName, release.ID)
+			return
+		}
+		for _, b := range builds {
+			if b.BuildStatus == "succeeded" {
+				build = b
+				break
+			}
+		}
+		if build == nil {
+			log.Warn().Msgf("No succeeded build for version %v of %v/%v/%v for reporting status of release %v", release.ReleaseVersion, release.RepoSource, release.RepoOwner, release.RepoName, release.ID)
+			return
+		}
+	}
+
+	logURL := fmt.Sprintf("%vpipelines/%v/%v/%v/releases/%v/logs", bh.config.BaseURL, release.RepoSource, release.RepoOwner, release.RepoName, release.ID)
+
+	err := bh.githubReleaseStatusFunc(release, build.RepoRevision, logURL)
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed reporting status of release %v of %v/%v/%v to %v", release.ID, release.RepoSource, release.RepoOwner, release.RepoName, release.RepoSource)
+	}
+}
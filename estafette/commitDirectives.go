@@ -0,0 +1,51 @@
+package estafette
+
+import (
+	"regexp"
+	"strings"
+
+	manifest "github.com/estafette/estafette-ci-manifest"
+)
+
+// DefaultSkipDirectives are used when no skip directives are configured
+var DefaultSkipDirectives = []string{"[skip ci]", "[ci skip]", "[no ci]", "[skip estafette]"}
+
+var releaseDirectiveRegex = regexp.MustCompile(`(?i)\[ci release:\s*([a-zA-Z0-9_.\-]+)\s*\]`)
+
+// GetSkipDirective returns the first skip directive found in a commit message, or an empty string if there's none; the match is case-insensitive
+func GetSkipDirective(message string, skipDirectives []string) string {
+
+	if len(skipDirectives) == 0 {
+		skipDirectives = DefaultSkipDirectives
+	}
+
+	lowercaseMessage := strings.ToLower(message)
+	for _, d := range skipDirectives {
+		if d != "" && strings.Contains(lowercaseMessage, strings.ToLower(d)) {
+			return d
+		}
+	}
+
+	return ""
+}
+
+// GetReleaseDirectives returns the release targets of all [ci release:<target>] directives in a commit message
+func GetReleaseDirectives(message string) (targets []string) {
+
+	targets = make([]string, 0)
+	for _, match := range releaseDirectiveRegex.FindAllStringSubmatch(message, -1) {
+		targets = append(targets, match[1])
+	}
+
+	return
+}
+
+// isReleaseBranch returns true if the branch is one of the release branches of the manifest's semver version, master for manifests without one
+func isReleaseBranch(mft *manifest.EstafetteManifest, branch string) bool {
+
+	if mft == nil || mft.Version.SemVer == nil || len(mft.Version.SemVer.ReleaseBranch.Values) == 0 {
+		return branch == "master"
+	}
+
+	return mft.Version.SemVer.ReleaseBranch.Contains(branch)
+}
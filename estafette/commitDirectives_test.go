@@ -0,0 +1,89 @@
+package estafette
+
+import (
+	"testing"
+
+	manifest "github.com/estafette/estafette-ci-manifest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetSkipDirective(t *testing.T) {
+
+	t.Run("ReturnsMatchingDirectiveIgnoringCase", func(t *testing.T) {
+
+		// act
+		directive := GetSkipDirective("update readme [Skip CI]", []string{"[skip ci]", "[ci skip]"})
+
+		assert.Equal(t, "[skip ci]", directive)
+	})
+
+	t.Run("ReturnsEmptyStringIfMessageHasNoDirective", func(t *testing.T) {
+
+		// act
+		directive := GetSkipDirective("fix nil pointer in handler", []string{"[skip ci]"})
+
+		assert.Equal(t, "", directive)
+	})
+
+	t.Run("UsesDefaultSkipDirectivesIfNoneAreConfigured", func(t *testing.T) {
+
+		// act
+		directive := GetSkipDirective("update docs [ci skip]", nil)
+
+		assert.Equal(t, "[ci skip]", directive)
+	})
+}
+
+func TestGetReleaseDirectives(t *testing.T) {
+
+	t.Run("ReturnsTargetsOfAllReleaseDirectives", func(t *testing.T) {
+
+		// act
+		targets := GetReleaseDirectives("bump dependencies [ci release:staging]\n\n[CI release: production-eu]")
+
+		assert.Equal(t, []string{"staging", "production-eu"}, targets)
+	})
+
+	t.Run("ReturnsNoTargetsIfMessageHasNoReleaseDirective", func(t *testing.T) {
+
+		// act
+		targets := GetReleaseDirectives("bump dependencies [skip ci]")
+
+		assert.Equal(t, 0, len(targets))
+	})
+}
+
+func TestIsReleaseBranch(t *testing.T) {
+
+	mft := &manifest.EstafetteManifest{
+		Version: manifest.EstafetteVersion{
+			SemVer: &manifest.EstafetteSemverVersion{
+				ReleaseBranch: manifest.StringOrStringArray{Values: []string{"master", "release"}},
+			},
+		},
+	}
+
+	t.Run("ReturnsTrueForReleaseBranchOfManifest", func(t *testing.T) {
+
+		// act
+		result := isReleaseBranch(mft, "release")
+
+		assert.True(t, result)
+	})
+
+	t.Run("ReturnsFalseForOtherBranch", func(t *testing.T) {
+
+		// act
+		result := isReleaseBranch(mft, "feature-x")
+
+		assert.False(t, result)
+	})
+
+	t.Run("ReturnsTrueOnlyForMasterWithoutSemverVersion", func(t *testing.T) {
+
+		mft := &manifest.EstafetteManifest{Version: manifest.EstafetteVersion{Custom: &manifest.EstafetteCustomVersion{}}}
+
+		assert.True(t, isReleaseBranch(mft, "master"))
+		assert.False(t, isReleaseBranch(mft, "feature-x"))
+	})
+}
@@ -0,0 +1,133 @@
+package estafette
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSchedule is a parsed 5-field cron expression (minute hour day-of-month month day-of-week)
+type cronSchedule struct {
+	minutes     map[int]bool
+	hours       map[int]bool
+	daysOfMonth map[int]bool
+	months      map[int]bool
+	daysOfWeek  map[int]bool
+
+	daysOfMonthRestricted bool
+	daysOfWeekRestricted  bool
+}
+
+type cronField struct {
+	name string
+	min  int
+	max  int
+}
+
+var cronFields = []cronField{
+	cronField{name: "minute", min: 0, max: 59},
+	cronField{name: "hour", min: 0, max: 23},
+	cronField{name: "day of month", min: 1, max: 31},
+	cronField{name: "month", min: 1, max: 12},
+	cronField{name: "day of week", min: 0, max: 7},
+}
+
+// parseCronSchedule parses a cron expression supporting *, lists, ranges and steps, like 0 3 * * 1-5 or */15 * * * *
+func parseCronSchedule(expression string) (schedule *cronSchedule, err error) {
+
+	fields := strings.Fields(expression)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("Cron expression '%v' should have %v fields, but has %v", expression, len(cronFields), len(fields))
+	}
+
+	values := make([]map[int]bool, len(cronFields))
+	for i, f := range fields {
+		values[i], err = parseCronField(f, cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("Cron expression '%v' is invalid: %v", expression, err)
+		}
+	}
+
+	// both 0 and 7 mean sunday
+	if values[4][7] {
+		values[4][0] = true
+		delete(values[4], 7)
+	}
+
+	schedule = &cronSchedule{
+		minutes:               values[0],
+		hours:                 values[1],
+		daysOfMonth:           values[2],
+		months:                values[3],
+		daysOfWeek:            values[4],
+		daysOfMonthRestricted: fields[2] != "*",
+		daysOfWeekRestricted:  fields[4] != "*",
+	}
+
+	return
+}
+
+func parseCronField(value string, field cronField) (values map[int]bool, err error) {
+
+	values = map[int]bool{}
+
+	for _, part := range strings.Split(value, ",") {
+
+		rangePart := part
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			rangePart = part[:i]
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step <= 0 {
+				return nil, fmt.Errorf("step '%v' for %v is not a positive number", part[i+1:], field.name)
+			}
+		}
+
+		start, end := field.min, field.max
+		if rangePart != "*" {
+			bounds := strings.SplitN(rangePart, "-", 2)
+			start, err = strconv.Atoi(bounds[0])
+			if err != nil {
+				return nil, fmt.Errorf("value '%v' for %v is not a number", bounds[0], field.name)
+			}
+			end = start
+			if len(bounds) == 2 {
+				end, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					return nil, fmt.Errorf("value '%v' for %v is not a number", bounds[1], field.name)
+				}
+			} else if step > 1 {
+				// 5/15 means starting at 5 every 15
+				end = field.max
+			}
+		}
+
+		if start < field.min || end > field.max || start > end {
+			return nil, fmt.Errorf("range '%v' for %v is outside of %v-%v", rangePart, field.name, field.min, field.max)
+		}
+
+		for v := start; v <= end; v += step {
+			values[v] = true
+		}
+	}
+
+	return
+}
+
+// matches returns true if the schedule fires at the minute of the given time
+func (s *cronSchedule) matches(t time.Time) bool {
+
+	if !s.minutes[t.Minute()] || !s.hours[t.Hour()] || !s.months[int(t.Month())] {
+		return false
+	}
+
+	// like cron, if both day of month and day of week are restricted either of them has to match
+	dayOfMonthMatches := s.daysOfMonth[t.Day()]
+	dayOfWeekMatches := s.daysOfWeek[int(t.Weekday())]
+	if s.daysOfMonthRestricted && s.daysOfWeekRestricted {
+		return dayOfMonthMatches || dayOfWeekMatches
+	}
+
+	return dayOfMonthMatches && dayOfWeekMatches
+}
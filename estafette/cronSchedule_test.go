@@ -0,0 +1,102 @@
+package estafette
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCronSchedule(t *testing.T) {
+
+	t.Run("ReturnsErrorIfExpressionDoesNotHaveFiveFields", func(t *testing.T) {
+
+		// act
+		_, err := parseCronSchedule("0 3 * *")
+
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ReturnsErrorIfValueIsOutOfRange", func(t *testing.T) {
+
+		// act
+		_, err := parseCronSchedule("0 24 * * *")
+
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ReturnsErrorIfStepIsNotAPositiveNumber", func(t *testing.T) {
+
+		// act
+		_, err := parseCronSchedule("*/0 * * * *")
+
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ParsesListsRangesAndSteps", func(t *testing.T) {
+
+		// act
+		schedule, err := parseCronSchedule("*/15 1,13 * * 1-5")
+
+		assert.Nil(t, err)
+		assert.Equal(t, map[int]bool{0: true, 15: true, 30: true, 45: true}, schedule.minutes)
+		assert.Equal(t, map[int]bool{1: true, 13: true}, schedule.hours)
+		assert.Equal(t, map[int]bool{1: true, 2: true, 3: true, 4: true, 5: true}, schedule.daysOfWeek)
+	})
+
+	t.Run("TreatsSevenAsSunday", func(t *testing.T) {
+
+		// act
+		schedule, err := parseCronSchedule("0 3 * * 7")
+
+		assert.Nil(t, err)
+		assert.Equal(t, map[int]bool{0: true}, schedule.daysOfWeek)
+	})
+}
+
+func TestCronScheduleMatches(t *testing.T) {
+
+	t.Run("ReturnsTrueForNightlySchedule", func(t *testing.T) {
+
+		schedule, _ := parseCronSchedule("0 3 * * *")
+
+		// act
+		matches := schedule.matches(time.Date(2018, 11, 7, 3, 0, 0, 0, time.UTC))
+
+		assert.True(t, matches)
+	})
+
+	t.Run("ReturnsFalseForOtherMinute", func(t *testing.T) {
+
+		schedule, _ := parseCronSchedule("0 3 * * *")
+
+		// act
+		matches := schedule.matches(time.Date(2018, 11, 7, 3, 1, 0, 0, time.UTC))
+
+		assert.False(t, matches)
+	})
+
+	t.Run("ReturnsFalseOnWeekendForWeekdaySchedule", func(t *testing.T) {
+
+		schedule, _ := parseCronSchedule("0 3 * * 1-5")
+
+		// act
+		matches := schedule.matches(time.Date(2018, 11, 10, 3, 0, 0, 0, time.UTC))
+
+		assert.False(t, matches)
+	})
+
+	t.Run("ReturnsTrueIfEitherDayOfMonthOrDayOfWeekMatchesWhenBothAreRestricted", func(t *testing.T) {
+
+		schedule, _ := parseCronSchedule("0 3 1 * 0")
+
+		// act
+		matchesFirstOfMonth := schedule.matches(time.Date(2018, 11, 1, 3, 0, 0, 0, time.UTC))
+		matchesSunday := schedule.matches(time.Date(2018, 11, 11, 3, 0, 0, 0, time.UTC))
+		matchesOtherDay := schedule.matches(time.Date(2018, 11, 12, 3, 0, 0, 0, time.UTC))
+
+		assert.True(t, matchesFirstOfMonth)
+		assert.True(t, matchesSunday)
+		assert.False(t, matchesOtherDay)
+	})
+}
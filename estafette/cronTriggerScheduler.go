@@ -0,0 +1,126 @@
+package estafette
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/estafette/estafette-ci-api/cockroach"
+	"github.com/rs/zerolog/log"
+)
+
+// CronTriggerScheduler fires builds for the cron triggers defined in the manifests of all pipelines
+type CronTriggerScheduler interface {
+	Run()
+}
+
+type cronTriggerSchedulerImpl struct {
+	waitGroup            *sync.WaitGroup
+	stopChannel          <-chan struct{}
+	cockroachDBClient    cockroach.DBClient
+	githubTriggerFunc    func(TriggerEvent) (*cockroach.Build, error)
+	bitbucketTriggerFunc func(TriggerEvent) (*cockroach.Build, error)
+}
+
+// NewCronTriggerScheduler returns a new estafette.CronTriggerScheduler
+func NewCronTriggerScheduler(stopChannel <-chan struct{}, waitGroup *sync.WaitGroup, cockroachDBClient cockroach.DBClient, githubTriggerFunc func(TriggerEvent) (*cockroach.Build, error), bitbucketTriggerFunc func(TriggerEvent) (*cockroach.Build, error)) CronTriggerScheduler {
+	return &cronTriggerSchedulerImpl{
+		waitGroup:            waitGroup,
+		stopChannel:          stopChannel,
+		cockroachDBClient:    cockroachDBClient,
+		githubTriggerFunc:    githubTriggerFunc,
+		bitbucketTriggerFunc: bitbucketTriggerFunc,
+	}
+}
+
+// Run checks all cron triggers at the start of every minute
+func (s *cronTriggerSchedulerImpl) Run() {
+	go func() {
+		for {
+			now := time.Now().UTC()
+			scheduledAt := now.Truncate(time.Minute).Add(time.Minute)
+
+			select {
+			case <-time.After(scheduledAt.Sub(now)):
+				s.waitGroup.Add(1)
+				go func(scheduledAt time.Time) {
+					s.fireCronTriggers(scheduledAt)
+					s.waitGroup.Done()
+				}(scheduledAt)
+			case <-s.stopChannel:
+				log.Debug().Msg("Stopping cron trigger scheduler...")
+				return
+			}
+		}
+	}()
+}
+
+// fireCronTriggers creates builds for all cron triggers matching the scheduled time; the triggers are stored when a build is inserted, from the manifest of the last build of the branch they're for
+func (s *cronTriggerSchedulerImpl) fireCronTriggers(scheduledAt time.Time) {
+
+	triggers, err := s.cockroachDBClient.GetCronTriggers()
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed retrieving cron triggers for firing them at %v", scheduledAt)
+		return
+	}
+
+	for _, t := range triggers {
+
+		schedule, err := parseCronSchedule(t.Cron)
+		if err != nil {
+			log.Warn().Err(err).Msgf("Failed parsing cron trigger for pipeline %v/%v/%v", t.RepoSource, t.RepoOwner, t.RepoName)
+			continue
+		}
+		if !schedule.matches(scheduledAt) {
+			continue
+		}
+
+		s.fireCronTrigger(t, scheduledAt)
+	}
+}
+
+func (s *cronTriggerSchedulerImpl) fireCronTrigger(t *cockroach.CronTrigger, scheduledAt time.Time) {
+
+	// make sure only one api replica fires the trigger
+	claimed, err := s.cockroachDBClient.ClaimCronTrigger(t.RepoSource, t.RepoOwner, t.RepoName, t.Cron, t.RepoBranch, scheduledAt)
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed claiming cron trigger '%v' for pipeline %v/%v/%v and branch %v", t.Cron, t.RepoSource, t.RepoOwner, t.RepoName, t.RepoBranch)
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	// rebuild the revision last built for the branch
+	builds, err := s.cockroachDBClient.GetPipelineBuilds(t.RepoSource, t.RepoOwner, t.RepoName, 1, 1, map[string][]string{"branch": []string{t.RepoBranch}}, true)
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed retrieving last build for cron trigger '%v' for pipeline %v/%v/%v and branch %v", t.Cron, t.RepoSource, t.RepoOwner, t.RepoName, t.RepoBranch)
+		return
+	}
+	if len(builds) == 0 {
+		log.Warn().Msgf("Pipeline %v/%v/%v has no builds for branch %v, skipping cron trigger '%v'", t.RepoSource, t.RepoOwner, t.RepoName, t.RepoBranch, t.Cron)
+		return
+	}
+
+	triggerEvent := TriggerEvent{
+		RepoSource:   t.RepoSource,
+		RepoOwner:    t.RepoOwner,
+		RepoName:     t.RepoName,
+		RepoBranch:   t.RepoBranch,
+		RepoRevision: builds[0].RepoRevision,
+		Reason:       fmt.Sprintf("cron '%v'", t.Cron),
+	}
+
+	log.Info().Msgf("Firing cron trigger '%v' for pipeline %v/%v/%v and branch %v", t.Cron, t.RepoSource, t.RepoOwner, t.RepoName, t.RepoBranch)
+
+	switch t.RepoSource {
+	case "bitbucket.org":
+		_, err = s.bitbucketTriggerFunc(triggerEvent)
+	default:
+		// github.com and github enterprise hosts
+		_, err = s.githubTriggerFunc(triggerEvent)
+	}
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed firing cron trigger '%v' for pipeline %v/%v/%v and branch %v", t.Cron, t.RepoSource, t.RepoOwner, t.RepoName, t.RepoBranch)
+	}
+}
@@ -1,24 +1,23 @@
 package estafette
 
 import (
-	"bytes"
 	"encoding/base64"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"math"
 	"net/http"
 	"path/filepath"
 	"regexp"
 	"strconv"
-	"text/template"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/estafette/estafette-ci-api/auth"
 	"github.com/estafette/estafette-ci-api/cockroach"
 	"github.com/estafette/estafette-ci-api/config"
+	"github.com/estafette/estafette-ci-api/secrets"
 	"github.com/estafette/estafette-ci-contracts"
-	crypt "github.com/estafette/estafette-ci-crypt"
 	manifest "github.com/estafette/estafette-ci-manifest"
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog/log"
@@ -29,6 +28,8 @@ import (
 type APIHandler interface {
 	GetPipelines(*gin.Context)
 	GetPipeline(*gin.Context)
+	ArchivePipeline(*gin.Context)
+	UnarchivePipeline(*gin.Context)
 	GetPipelineBuilds(*gin.Context)
 	GetPipelineBuild(*gin.Context)
 	CreatePipelineBuild(*gin.Context)
@@ -40,6 +41,7 @@ type APIHandler interface {
 	GetPipelineReleases(*gin.Context)
 	GetPipelineRelease(*gin.Context)
 	CreatePipelineRelease(*gin.Context)
+	CreatePipelineRollback(*gin.Context)
 	CancelPipelineRelease(*gin.Context)
 	GetPipelineReleaseLogs(*gin.Context)
 	TailPipelineReleaseLogs(*gin.Context)
@@ -48,6 +50,7 @@ type APIHandler interface {
 	GetPipelineStatsBuildsDurations(*gin.Context)
 	GetPipelineStatsReleasesDurations(*gin.Context)
 	GetPipelineWarnings(*gin.Context)
+	GetPipelineTriggers(*gin.Context)
 
 	GetStatsPipelinesCount(*gin.Context)
 	GetStatsBuildsCount(*gin.Context)
@@ -56,6 +59,7 @@ type APIHandler interface {
 	GetStatsBuildsDuration(*gin.Context)
 	GetStatsBuildsAdoption(*gin.Context)
 	GetStatsReleasesAdoption(*gin.Context)
+	GetStatsImages(*gin.Context)
 
 	GetLoggedInUser(*gin.Context)
 	UpdateComputedTables(*gin.Context)
@@ -63,11 +67,28 @@ type APIHandler interface {
 	GetConfig(*gin.Context)
 	GetConfigCredentials(*gin.Context)
 	GetConfigTrustedImages(*gin.Context)
+	CreateManagedCredential(*gin.Context)
+	UpdateManagedCredential(*gin.Context)
+	DeleteManagedCredential(*gin.Context)
+	CreateManagedTrustedImage(*gin.Context)
+	UpdateManagedTrustedImage(*gin.Context)
+	DeleteManagedTrustedImage(*gin.Context)
+	GetManagedConfigAuditLog(*gin.Context)
 
 	GetManifestTemplates(*gin.Context)
+	GetManifestTemplatesV2(*gin.Context)
+	GetManifestTemplate(*gin.Context)
+	GetManifestTemplateVersions(*gin.Context)
+	CreateManifestTemplate(*gin.Context)
+	UpdateManifestTemplate(*gin.Context)
+	DeleteManifestTemplate(*gin.Context)
+	ImportManifestTemplates(*gin.Context)
 	GenerateManifest(*gin.Context)
 	ValidateManifest(*gin.Context)
 	EncryptSecret(*gin.Context)
+	ReencryptSecrets(*gin.Context)
+
+	UpdateConfig(config.APIServerConfig, config.AuthConfig, config.APIConfig, []config.ConfigSource)
 }
 
 type apiHandlerImpl struct {
@@ -75,28 +96,45 @@ type apiHandlerImpl struct {
 	config               config.APIServerConfig
 	authConfig           config.AuthConfig
 	encryptedConfig      config.APIConfig
+	configSources        []config.ConfigSource
+	configMutex          sync.RWMutex
 	cockroachDBClient    cockroach.DBClient
 	ciBuilderClient      CiBuilderClient
 	warningHelper        WarningHelper
-	secretHelper         crypt.SecretHelper
+	secretHelper         secrets.SecretHelper
+	releaseHelper        ReleaseHelper
+	triggerHelper        PipelineTriggerHelper
+	buildStatusHelper    BuildStatusHelper
 	githubJobVarsFunc    func(string, string, string) (string, string, error)
 	bitbucketJobVarsFunc func(string, string, string) (string, string, error)
+	githubTriggerFunc    func(TriggerEvent) (*cockroach.Build, error)
+	bitbucketTriggerFunc func(TriggerEvent) (*cockroach.Build, error)
+
+	imageStatsCache      map[string]imageStatsCacheEntry
+	imageStatsCacheMutex sync.RWMutex
 }
 
 // NewAPIHandler returns a new estafette.APIHandler
-func NewAPIHandler(configFilePath string, config config.APIServerConfig, authConfig config.AuthConfig, encryptedConfig config.APIConfig, cockroachDBClient cockroach.DBClient, ciBuilderClient CiBuilderClient, warningHelper WarningHelper, secretHelper crypt.SecretHelper, githubJobVarsFunc func(string, string, string) (string, string, error), bitbucketJobVarsFunc func(string, string, string) (string, string, error)) (apiHandler APIHandler) {
+func NewAPIHandler(configFilePath string, config config.APIServerConfig, authConfig config.AuthConfig, encryptedConfig config.APIConfig, configSources []config.ConfigSource, cockroachDBClient cockroach.DBClient, ciBuilderClient CiBuilderClient, warningHelper WarningHelper, secretHelper secrets.SecretHelper, releaseHelper ReleaseHelper, triggerHelper PipelineTriggerHelper, buildStatusHelper BuildStatusHelper, githubJobVarsFunc func(string, string, string) (string, string, error), bitbucketJobVarsFunc func(string, string, string) (string, string, error), githubTriggerFunc func(TriggerEvent) (*cockroach.Build, error), bitbucketTriggerFunc func(TriggerEvent) (*cockroach.Build, error)) (apiHandler APIHandler) {
 
 	apiHandler = &apiHandlerImpl{
 		configFilePath:       configFilePath,
 		config:               config,
 		authConfig:           authConfig,
 		encryptedConfig:      encryptedConfig,
+		configSources:        configSources,
 		cockroachDBClient:    cockroachDBClient,
 		ciBuilderClient:      ciBuilderClient,
 		warningHelper:        warningHelper,
 		secretHelper:         secretHelper,
+		releaseHelper:        releaseHelper,
+		triggerHelper:        triggerHelper,
+		buildStatusHelper:    buildStatusHelper,
 		githubJobVarsFunc:    githubJobVarsFunc,
 		bitbucketJobVarsFunc: bitbucketJobVarsFunc,
+		githubTriggerFunc:    githubTriggerFunc,
+		bitbucketTriggerFunc: bitbucketTriggerFunc,
+		imageStatsCache:      map[string]imageStatsCacheEntry{},
 	}
 
 	return
@@ -122,11 +160,12 @@ func (h *apiHandlerImpl) GetPipelines(c *gin.Context) {
 		pageSize = 100
 	}
 
-	// get filters (?filter[status]=running,succeeded&filter[since]=1w&filter[labels]=team%3Destafette-team)
+	// get filters (?filter[status]=running,succeeded&filter[since]=1w&filter[labels]=team%3Destafette-team&filter[archived]=true)
 	filters := map[string][]string{}
 	filters["status"] = h.getStatusFilter(c)
 	filters["since"] = h.getSinceFilter(c)
 	filters["labels"] = h.getLabelsFilter(c)
+	filters["archived"] = h.getArchivedFilter(c)
 
 	pipelines, err := h.cockroachDBClient.GetPipelines(pageNumber, pageSize, filters, true)
 	if err != nil {
@@ -174,6 +213,61 @@ func (h *apiHandlerImpl) GetPipeline(c *gin.Context) {
 	c.JSON(http.StatusOK, pipeline)
 }
 
+func (h *apiHandlerImpl) ArchivePipeline(c *gin.Context) {
+	h.setPipelineArchived(c, true)
+}
+
+func (h *apiHandlerImpl) UnarchivePipeline(c *gin.Context) {
+	h.setPipelineArchived(c, false)
+}
+
+func (h *apiHandlerImpl) setPipelineArchived(c *gin.Context, archived bool) {
+
+	user := c.MustGet(gin.AuthUserKey).(auth.User)
+
+	source := c.Param("source")
+	owner := c.Param("owner")
+	repo := c.Param("repo")
+
+	authConfig := h.getAuthConfig()
+	if !authConfig.IsAdministrator(user.Email) {
+		errorMessage := fmt.Sprintf("User %v is not allowed to archive or unarchive pipelines", user.Email)
+		log.Warn().Msg(errorMessage)
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"code": http.StatusText(http.StatusForbidden), "message": errorMessage})
+		return
+	}
+
+	pipeline, err := h.cockroachDBClient.GetPipeline(source, owner, repo, true)
+	if err != nil {
+		errorMessage := fmt.Sprintf("Failed retrieving pipeline for %v/%v/%v from db", source, owner, repo)
+		log.Error().Err(err).Msg(errorMessage)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": errorMessage})
+		return
+	}
+	if pipeline == nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"code": http.StatusText(http.StatusNotFound), "message": "Pipeline not found"})
+		return
+	}
+
+	action := "archived"
+	if archived {
+		err = h.cockroachDBClient.ArchiveComputedPipeline(source, owner, repo)
+	} else {
+		action = "unarchived"
+		err = h.cockroachDBClient.UnarchiveComputedPipeline(source, owner, repo)
+	}
+	if err != nil {
+		errorMessage := fmt.Sprintf("Failed updating archived flag of pipeline %v/%v/%v in db", source, owner, repo)
+		log.Error().Err(err).Msg(errorMessage)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": errorMessage})
+		return
+	}
+
+	log.Info().Msgf("Pipeline %v/%v/%v %v by user %v", source, owner, repo, action, user.Email)
+
+	c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("Pipeline %v by user %v", action, user.Email)})
+}
+
 func (h *apiHandlerImpl) GetPipelineBuilds(c *gin.Context) {
 	source := c.Param("source")
 	owner := c.Param("owner")
@@ -201,6 +295,7 @@ func (h *apiHandlerImpl) GetPipelineBuilds(c *gin.Context) {
 	filters["status"] = h.getStatusFilter(c)
 	filters["since"] = h.getSinceFilter(c)
 	filters["labels"] = h.getLabelsFilter(c)
+	filters["tag"] = h.getTagFilter(c)
 
 	builds, err := h.cockroachDBClient.GetPipelineBuilds(source, owner, repo, pageNumber, pageSize, filters, true)
 	if err != nil {
@@ -274,7 +369,7 @@ func (h *apiHandlerImpl) CreatePipelineBuild(c *gin.Context) {
 
 	user := c.MustGet(gin.AuthUserKey).(auth.User)
 
-	var buildCommand contracts.Build
+	var buildCommand BuildCommand
 	c.BindJSON(&buildCommand)
 
 	// match source, owner, repo with values in binded release
@@ -282,16 +377,25 @@ func (h *apiHandlerImpl) CreatePipelineBuild(c *gin.Context) {
 		errorMessage := fmt.Sprintf("RepoSource in path and post data do not match for pipeline %v/%v/%v for build command issued by %v", buildCommand.RepoSource, buildCommand.RepoOwner, buildCommand.RepoName, user)
 		log.Error().Msg(errorMessage)
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"code": http.StatusText(http.StatusBadRequest), "message": errorMessage})
+		return
 	}
 	if buildCommand.RepoOwner != c.Param("owner") {
 		errorMessage := fmt.Sprintf("RepoOwner in path and post data do not match for pipeline %v/%v/%v for build command issued by %v", buildCommand.RepoSource, buildCommand.RepoOwner, buildCommand.RepoName, user)
 		log.Error().Msg(errorMessage)
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"code": http.StatusText(http.StatusBadRequest), "message": errorMessage})
+		return
 	}
 	if buildCommand.RepoName != c.Param("repo") {
 		errorMessage := fmt.Sprintf("RepoName in path and post data do not match for pipeline %v/%v/%v for build command issued by %v", buildCommand.RepoSource, buildCommand.RepoOwner, buildCommand.RepoName, user)
 		log.Error().Msg(errorMessage)
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"code": http.StatusText(http.StatusBadRequest), "message": errorMessage})
+		return
+	}
+
+	// without version build the branch or revision as a new version
+	if buildCommand.BuildVersion == "" {
+		h.createManualPipelineBuild(c, user, buildCommand)
+		return
 	}
 
 	// check if version exists and is valid to re-run
@@ -303,7 +407,7 @@ func (h *apiHandlerImpl) CreatePipelineBuild(c *gin.Context) {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": errorMessage})
 	}
 
-	var failedBuild *contracts.Build
+	var failedBuild *cockroach.Build
 	// ensure there's no succeeded or running builds
 	hasNonFailedBuilds := false
 	for _, b := range builds {
@@ -326,18 +430,20 @@ func (h *apiHandlerImpl) CreatePipelineBuild(c *gin.Context) {
 	}
 
 	// store build in db
-	insertedBuild, err := h.cockroachDBClient.InsertBuild(contracts.Build{
-		RepoSource:     failedBuild.RepoSource,
-		RepoOwner:      failedBuild.RepoOwner,
-		RepoName:       failedBuild.RepoName,
-		RepoBranch:     failedBuild.RepoBranch,
-		RepoRevision:   failedBuild.RepoRevision,
-		BuildVersion:   failedBuild.BuildVersion,
-		BuildStatus:    "running",
-		Labels:         failedBuild.Labels,
-		ReleaseTargets: failedBuild.ReleaseTargets,
-		Manifest:       failedBuild.Manifest,
-		Commits:        failedBuild.Commits,
+	insertedBuild, err := h.cockroachDBClient.InsertBuild(cockroach.Build{
+		Build: contracts.Build{
+			RepoSource:     failedBuild.RepoSource,
+			RepoOwner:      failedBuild.RepoOwner,
+			RepoName:       failedBuild.RepoName,
+			RepoBranch:     failedBuild.RepoBranch,
+			RepoRevision:   failedBuild.RepoRevision,
+			BuildVersion:   failedBuild.BuildVersion,
+			BuildStatus:    "running",
+			Labels:         failedBuild.Labels,
+			ReleaseTargets: failedBuild.ReleaseTargets,
+			Manifest:       failedBuild.Manifest,
+			Commits:        failedBuild.Commits,
+		},
 	})
 	if err != nil {
 		errorMessage := fmt.Sprintf("Failed inserting build into db for rebuilding version %v of repository %v/%v/%v for build command issued by %v", buildCommand.BuildVersion, buildCommand.RepoSource, buildCommand.RepoOwner, buildCommand.RepoName, user)
@@ -355,27 +461,28 @@ func (h *apiHandlerImpl) CreatePipelineBuild(c *gin.Context) {
 	var environmentVariableWithToken map[string]string
 	var gitSource string
 	switch failedBuild.RepoSource {
-	case "github.com":
+	case "bitbucket.org":
 		var accessToken string
-		accessToken, authenticatedRepositoryURL, err = h.githubJobVarsFunc(buildCommand.RepoSource, buildCommand.RepoOwner, buildCommand.RepoName)
+		accessToken, authenticatedRepositoryURL, err = h.bitbucketJobVarsFunc(buildCommand.RepoSource, buildCommand.RepoOwner, buildCommand.RepoName)
 		if err != nil {
-			errorMessage := fmt.Sprintf("Retrieving access token and authenticated github url for repository %v/%v/%v failed for build command issued by %v", buildCommand.BuildVersion, buildCommand.RepoSource, buildCommand.RepoOwner, user)
+			errorMessage := fmt.Sprintf("Retrieving access token and authenticated bitbucket url for repository %v/%v/%v failed for build command issued by %v", buildCommand.BuildVersion, buildCommand.RepoSource, buildCommand.RepoOwner, user)
 			log.Error().Err(err).Msg(errorMessage)
 			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": errorMessage})
 		}
-		environmentVariableWithToken = map[string]string{"ESTAFETTE_GITHUB_API_TOKEN": accessToken}
-		gitSource = "github"
+		environmentVariableWithToken = map[string]string{"ESTAFETTE_BITBUCKET_API_TOKEN": accessToken}
+		gitSource = "bitbucket"
 
-	case "bitbucket.org":
+	default:
+		// github.com and github enterprise hosts
 		var accessToken string
-		accessToken, authenticatedRepositoryURL, err = h.bitbucketJobVarsFunc(buildCommand.RepoSource, buildCommand.RepoOwner, buildCommand.RepoName)
+		accessToken, authenticatedRepositoryURL, err = h.githubJobVarsFunc(buildCommand.RepoSource, buildCommand.RepoOwner, buildCommand.RepoName)
 		if err != nil {
-			errorMessage := fmt.Sprintf("Retrieving access token and authenticated bitbucket url for repository %v/%v/%v failed for build command issued by %v", buildCommand.BuildVersion, buildCommand.RepoSource, buildCommand.RepoOwner, user)
+			errorMessage := fmt.Sprintf("Retrieving access token and authenticated github url for repository %v/%v/%v failed for build command issued by %v", buildCommand.BuildVersion, buildCommand.RepoSource, buildCommand.RepoOwner, user)
 			log.Error().Err(err).Msg(errorMessage)
 			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": errorMessage})
 		}
-		environmentVariableWithToken = map[string]string{"ESTAFETTE_BITBUCKET_API_TOKEN": accessToken}
-		gitSource = "bitbucket"
+		environmentVariableWithToken = map[string]string{"ESTAFETTE_GITHUB_API_TOKEN": accessToken}
+		gitSource = "github"
 	}
 
 	manifest, err := manifest.ReadManifest(failedBuild.Manifest)
@@ -433,6 +540,64 @@ func (h *apiHandlerImpl) CreatePipelineBuild(c *gin.Context) {
 	c.JSON(http.StatusCreated, insertedBuild)
 }
 
+func (h *apiHandlerImpl) createManualPipelineBuild(c *gin.Context, user auth.User, buildCommand BuildCommand) {
+
+	if buildCommand.RepoBranch == "" {
+		errorMessage := fmt.Sprintf("RepoBranch is required for building a branch or revision of pipeline %v/%v/%v for build command issued by %v", buildCommand.RepoSource, buildCommand.RepoOwner, buildCommand.RepoName, user)
+		log.Error().Msg(errorMessage)
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"code": http.StatusText(http.StatusBadRequest), "message": errorMessage})
+		return
+	}
+
+	pipeline, err := h.cockroachDBClient.GetPipeline(buildCommand.RepoSource, buildCommand.RepoOwner, buildCommand.RepoName, false)
+	if err != nil {
+		errorMessage := fmt.Sprintf("Failed retrieving pipeline %v/%v/%v for build command issued by %v", buildCommand.RepoSource, buildCommand.RepoOwner, buildCommand.RepoName, user)
+		log.Error().Err(err).Msg(errorMessage)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": errorMessage})
+		return
+	}
+	if pipeline == nil {
+		errorMessage := fmt.Sprintf("No pipeline %v/%v/%v for build command issued by %v", buildCommand.RepoSource, buildCommand.RepoOwner, buildCommand.RepoName, user)
+		log.Error().Msg(errorMessage)
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"code": http.StatusText(http.StatusNotFound), "message": errorMessage})
+		return
+	}
+
+	triggerEvent := TriggerEvent{
+		RepoSource:           buildCommand.RepoSource,
+		RepoOwner:            buildCommand.RepoOwner,
+		RepoName:             buildCommand.RepoName,
+		RepoBranch:           buildCommand.RepoBranch,
+		RepoRevision:         buildCommand.RepoRevision,
+		Reason:               fmt.Sprintf("manual build by %v", user.Email),
+		EnvironmentVariables: buildCommand.EnvironmentVariables,
+	}
+
+	// fetch the manifest at the revision, allocate a new version and start the build job
+	var insertedBuild *cockroach.Build
+	switch buildCommand.RepoSource {
+	case "bitbucket.org":
+		insertedBuild, err = h.bitbucketTriggerFunc(triggerEvent)
+	default:
+		// github.com and github enterprise hosts
+		insertedBuild, err = h.githubTriggerFunc(triggerEvent)
+	}
+	if err != nil {
+		errorMessage := fmt.Sprintf("Failed building branch %v revision %v of pipeline %v/%v/%v for build command issued by %v", buildCommand.RepoBranch, buildCommand.RepoRevision, buildCommand.RepoSource, buildCommand.RepoOwner, buildCommand.RepoName, user)
+		log.Error().Err(err).Msg(errorMessage)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": errorMessage})
+		return
+	}
+	if insertedBuild == nil {
+		errorMessage := fmt.Sprintf("No manifest for branch %v revision %v of pipeline %v/%v/%v for build command issued by %v", buildCommand.RepoBranch, buildCommand.RepoRevision, buildCommand.RepoSource, buildCommand.RepoOwner, buildCommand.RepoName, user)
+		log.Error().Msg(errorMessage)
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"code": http.StatusText(http.StatusBadRequest), "message": errorMessage})
+		return
+	}
+
+	c.JSON(http.StatusCreated, insertedBuild)
+}
+
 func (h *apiHandlerImpl) CancelPipelineBuild(c *gin.Context) {
 
 	user := c.MustGet(gin.AuthUserKey).(auth.User)
@@ -476,8 +641,11 @@ func (h *apiHandlerImpl) CancelPipelineBuild(c *gin.Context) {
 	if err != nil {
 		log.Error().Err(err).Msgf("Failed updating build status for %v/%v/%v/builds/%v in db", source, owner, repo, revisionOrID)
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": "Failed setting pipeline build status to canceling"})
+		return
 	}
 
+	go h.buildStatusHelper.ReportBuildStatus(*build, nil)
+
 	c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("Canceled build by user %v", user.Email)})
 }
 
@@ -487,7 +655,7 @@ func (h *apiHandlerImpl) GetPipelineBuildLogs(c *gin.Context) {
 	repo := c.Param("repo")
 	revisionOrID := c.Param("revisionOrId")
 
-	var build *contracts.Build
+	var build *cockroach.Build
 	var err error
 	if len(revisionOrID) == 40 {
 		build, err = h.cockroachDBClient.GetPipelineBuild(source, owner, repo, revisionOrID, false)
@@ -593,6 +761,18 @@ func (h *apiHandlerImpl) PostPipelineBuildLogs(c *gin.Context) {
 			Msgf("Failed inserting v2 logs for %v/%v/%v/%v", source, owner, repo, revisionOrID)
 	}
 
+	// report the stage results of the build to the git provider
+	if buildLog.BuildID != "" {
+		buildID, _ := strconv.Atoi(buildLog.BuildID)
+		build, err := h.cockroachDBClient.GetPipelineBuildByID(source, owner, repo, buildID, true)
+		if err != nil {
+			log.Error().Err(err).
+				Msgf("Failed retrieving build for %v/%v/%v/builds/%v for reporting its status", source, owner, repo, revisionOrID)
+		} else if build != nil {
+			go h.buildStatusHelper.ReportBuildStatus(*build, &buildLog)
+		}
+	}
+
 	c.String(http.StatusOK, "Aye aye!")
 }
 
@@ -690,7 +870,7 @@ func (h *apiHandlerImpl) CreatePipelineRelease(c *gin.Context) {
 
 	user := c.MustGet(gin.AuthUserKey).(auth.User)
 
-	var releaseCommand contracts.Release
+	var releaseCommand cockroach.Release
 	c.BindJSON(&releaseCommand)
 
 	// match source, owner, repo with values in binded release
@@ -730,7 +910,7 @@ func (h *apiHandlerImpl) CreatePipelineRelease(c *gin.Context) {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": errorMessage})
 	}
 
-	var build *contracts.Build
+	var build *cockroach.Build
 	// get succeeded build
 	for _, b := range builds {
 		if b.BuildStatus == "succeeded" {
@@ -783,99 +963,72 @@ func (h *apiHandlerImpl) CreatePipelineRelease(c *gin.Context) {
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"code": http.StatusText(http.StatusBadRequest), "message": errorMessage})
 	}
 
-	// create release in database
-	release := contracts.Release{
-		Name:           releaseCommand.Name,
-		Action:         releaseCommand.Action,
-		RepoSource:     releaseCommand.RepoSource,
-		RepoOwner:      releaseCommand.RepoOwner,
-		RepoName:       releaseCommand.RepoName,
-		ReleaseVersion: releaseCommand.ReleaseVersion,
-		ReleaseStatus:  "running",
-		TriggeredBy:    user.Email,
+	// create release in database and start release job
+	release := cockroach.Release{
+		Release: contracts.Release{
+			Name:           releaseCommand.Name,
+			Action:         releaseCommand.Action,
+			RepoSource:     releaseCommand.RepoSource,
+			RepoOwner:      releaseCommand.RepoOwner,
+			RepoName:       releaseCommand.RepoName,
+			ReleaseVersion: releaseCommand.ReleaseVersion,
+			ReleaseStatus:  "running",
+			TriggeredBy:    user.Email,
+		},
 	}
-	insertedRelease, err := h.cockroachDBClient.InsertRelease(release)
+	insertedRelease, err := h.releaseHelper.StartRelease(release, *build)
 	if err != nil {
-		errorMessage := fmt.Sprintf("Failed creating release in database for build %v for pipeline %v/%v/%v and release %v for release command", releaseCommand.ReleaseVersion, releaseCommand.RepoSource, releaseCommand.RepoOwner, releaseCommand.RepoName, releaseCommand.Name)
+		errorMessage := fmt.Sprintf("Failed starting release %v for build %v for pipeline %v/%v/%v for release command", releaseCommand.Name, releaseCommand.ReleaseVersion, releaseCommand.RepoSource, releaseCommand.RepoOwner, releaseCommand.RepoName)
 		log.Error().Err(err).Msg(errorMessage)
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": errorMessage})
+		return
 	}
 
-	// get authenticated url
-	var authenticatedRepositoryURL string
-	var environmentVariableWithToken map[string]string
-	var gitSource string
-	switch build.RepoSource {
-	case "github.com":
-		var accessToken string
-		accessToken, authenticatedRepositoryURL, err = h.githubJobVarsFunc(releaseCommand.RepoSource, releaseCommand.RepoOwner, releaseCommand.RepoName)
-		if err != nil {
-			errorMessage := fmt.Sprintf("Getting access token and authenticated github url for repository %v/%v/%v failed", releaseCommand.RepoSource, releaseCommand.RepoOwner, releaseCommand.RepoName)
-			log.Error().Err(err).Msg(errorMessage)
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": errorMessage})
-		}
-		environmentVariableWithToken = map[string]string{"ESTAFETTE_GITHUB_API_TOKEN": accessToken}
-		gitSource = "github"
+	c.JSON(http.StatusCreated, insertedRelease)
+}
 
-	case "bitbucket.org":
-		var accessToken string
-		accessToken, authenticatedRepositoryURL, err = h.bitbucketJobVarsFunc(releaseCommand.RepoSource, releaseCommand.RepoOwner, releaseCommand.RepoName)
-		if err != nil {
-			errorMessage := fmt.Sprintf("Getting access token and authenticated bitbucket url for repository %v/%v/%v failed", releaseCommand.RepoSource, releaseCommand.RepoOwner, releaseCommand.RepoName)
-			log.Error().Err(err).Msg(errorMessage)
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": errorMessage})
-		}
-		environmentVariableWithToken = map[string]string{"ESTAFETTE_BITBUCKET_API_TOKEN": accessToken}
-		gitSource = "bitbucket"
-	}
+func (h *apiHandlerImpl) CreatePipelineRollback(c *gin.Context) {
 
-	manifest, err := manifest.ReadManifest(build.Manifest)
+	user := c.MustGet(gin.AuthUserKey).(auth.User)
+
+	source := c.Param("source")
+	owner := c.Param("owner")
+	repo := c.Param("repo")
+	// gin requires all routes below releases/ to share the same wildcard name, so the release name comes in as :id
+	releaseName := c.Param("id")
+	releaseAction := c.Query("action")
+
+	pipeline, err := h.cockroachDBClient.GetPipeline(source, owner, repo, false)
 	if err != nil {
-		errorMessage := fmt.Sprintf("Reading the estafette manifest for repository %v/%v/%v build %v failed", releaseCommand.RepoSource, releaseCommand.RepoOwner, releaseCommand.RepoName, releaseCommand.ReleaseVersion)
+		errorMessage := fmt.Sprintf("Failed retrieving pipeline %v/%v/%v for rollback command", source, owner, repo)
 		log.Error().Err(err).Msg(errorMessage)
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": errorMessage})
+		return
+	}
+	if pipeline == nil {
+		errorMessage := fmt.Sprintf("No pipeline %v/%v/%v for rollback command", source, owner, repo)
+		log.Error().Msg(errorMessage)
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"code": http.StatusText(http.StatusNotFound), "message": errorMessage})
+		return
 	}
 
-	// inject steps
-	manifest, err = InjectSteps(manifest, manifest.Builder.Track, gitSource)
+	rollbackRelease, build, err := h.releaseHelper.GetRollbackRelease(pipeline, releaseName, releaseAction)
 	if err != nil {
-		errorMessage := fmt.Sprintf("Failed injecting steps into manifest for %v/%v/%v version %v", releaseCommand.RepoSource, releaseCommand.RepoOwner, releaseCommand.RepoName, releaseCommand.ReleaseVersion)
+		errorMessage := fmt.Sprintf("Failed determining version to roll back release %v for pipeline %v/%v/%v to: %v", releaseName, source, owner, repo, err)
 		log.Error().Err(err).Msg(errorMessage)
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": errorMessage})
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"code": http.StatusText(http.StatusBadRequest), "message": errorMessage})
+		return
 	}
 
-	insertedReleaseID, err := strconv.Atoi(insertedRelease.ID)
+	rollbackRelease.TriggeredBy = user.Email
+	insertedRelease, err := h.releaseHelper.StartRelease(*rollbackRelease, *build)
 	if err != nil {
-		errorMessage := fmt.Sprintf("Converting the release id to a string for repository %v/%v/%v build %v failed", releaseCommand.RepoSource, releaseCommand.RepoOwner, releaseCommand.RepoName, releaseCommand.ReleaseVersion)
+		errorMessage := fmt.Sprintf("Failed starting rollback of release %v to version %v for pipeline %v/%v/%v", releaseName, rollbackRelease.ReleaseVersion, source, owner, repo)
 		log.Error().Err(err).Msg(errorMessage)
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": errorMessage})
+		return
 	}
 
-	// start release job
-	ciBuilderParams := CiBuilderParams{
-		JobType:              "release",
-		RepoSource:           releaseCommand.RepoSource,
-		RepoOwner:            releaseCommand.RepoOwner,
-		RepoName:             releaseCommand.RepoName,
-		RepoURL:              authenticatedRepositoryURL,
-		RepoBranch:           build.RepoBranch,
-		RepoRevision:         build.RepoRevision,
-		EnvironmentVariables: environmentVariableWithToken,
-		Track:                manifest.Builder.Track,
-		VersionNumber:        releaseCommand.ReleaseVersion,
-		Manifest:             manifest,
-		ReleaseID:            insertedReleaseID,
-		ReleaseName:          releaseCommand.Name,
-		ReleaseAction:        releaseCommand.Action,
-	}
-
-	go func(ciBuilderParams CiBuilderParams) {
-		_, err := h.ciBuilderClient.CreateCiBuilderJob(ciBuilderParams)
-		if err != nil {
-			log.Error().Err(err).Msgf("Failed creating release job for %v/%v/%v/%v/%v version %v", ciBuilderParams.RepoSource, ciBuilderParams.RepoOwner, ciBuilderParams.RepoName, ciBuilderParams.RepoBranch, ciBuilderParams.RepoRevision, ciBuilderParams.VersionNumber)
-		}
-	}(ciBuilderParams)
-
 	c.JSON(http.StatusCreated, insertedRelease)
 }
 
@@ -917,8 +1070,12 @@ func (h *apiHandlerImpl) CancelPipelineRelease(c *gin.Context) {
 	if err != nil {
 		log.Error().Err(err).Msgf("Failed updating release status for %v/%v/%v/builds/%v in db", source, owner, repo, id)
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": "Failed setting pipeline release status to canceling"})
+		return
 	}
 
+	release.ReleaseStatus = releaseStatus
+	go h.buildStatusHelper.ReportReleaseStatus(*release, nil)
+
 	c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("Canceled release by user %v", user.Email)})
 }
 
@@ -1141,12 +1298,54 @@ func (h *apiHandlerImpl) GetPipelineWarnings(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"warnings": warnings})
 }
 
+func (h *apiHandlerImpl) GetPipelineTriggers(c *gin.Context) {
+	source := c.Param("source")
+	owner := c.Param("owner")
+	repo := c.Param("repo")
+
+	pipeline, err := h.cockroachDBClient.GetPipeline(source, owner, repo, false)
+	if err != nil {
+		errorMessage := fmt.Sprintf("Failed retrieving pipeline %v/%v/%v from db", source, owner, repo)
+		log.Error().Err(err).Msg(errorMessage)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": errorMessage})
+		return
+	}
+	if pipeline == nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"code": http.StatusText(http.StatusNotFound), "message": "Pipeline not found"})
+		return
+	}
+
+	// the pipelines this pipeline subscribes to
+	triggers, err := GetPipelineTriggers(pipeline.Manifest)
+	if err != nil {
+		errorMessage := fmt.Sprintf("Failed reading triggers from manifest for pipeline %v/%v/%v", source, owner, repo)
+		log.Error().Err(err).Msg(errorMessage)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": errorMessage})
+		return
+	}
+
+	// the pipelines subscribing to this pipeline
+	triggeredPipelines, err := h.triggerHelper.GetTriggeredPipelines(pipeline)
+	if err != nil {
+		errorMessage := fmt.Sprintf("Failed retrieving pipelines triggered by pipeline %v/%v/%v", source, owner, repo)
+		log.Error().Err(err).Msg(errorMessage)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": errorMessage})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"triggers":           triggers,
+		"triggeredPipelines": triggeredPipelines,
+	})
+}
+
 func (h *apiHandlerImpl) GetStatsPipelinesCount(c *gin.Context) {
 
-	// get filters (?filter[status]=running,succeeded&filter[since]=1w
+	// get filters (?filter[status]=running,succeeded&filter[since]=1w&filter[archived]=true
 	filters := map[string][]string{}
 	filters["status"] = h.getStatusFilter(c)
 	filters["since"] = h.getSinceFilter(c)
+	filters["archived"] = h.getArchivedFilter(c)
 
 	pipelinesCount, err := h.cockroachDBClient.GetPipelinesCount(filters)
 	if err != nil {
@@ -1161,10 +1360,11 @@ func (h *apiHandlerImpl) GetStatsPipelinesCount(c *gin.Context) {
 
 func (h *apiHandlerImpl) GetStatsReleasesCount(c *gin.Context) {
 
-	// get filters (?filter[status]=running,succeeded&filter[since]=1w
+	// get filters (?filter[status]=running,succeeded&filter[since]=1w&filter[archived]=true
 	filters := map[string][]string{}
 	filters["status"] = h.getStatusFilter(c)
 	filters["since"] = h.getSinceFilter(c)
+	filters["archived"] = h.getArchivedFilter(c)
 
 	releasesCount, err := h.cockroachDBClient.GetReleasesCount(filters)
 	if err != nil {
@@ -1179,10 +1379,11 @@ func (h *apiHandlerImpl) GetStatsReleasesCount(c *gin.Context) {
 
 func (h *apiHandlerImpl) GetStatsBuildsCount(c *gin.Context) {
 
-	// get filters (?filter[status]=running,succeeded&filter[since]=1w
+	// get filters (?filter[status]=running,succeeded&filter[since]=1w&filter[archived]=true
 	filters := map[string][]string{}
 	filters["status"] = h.getStatusFilter(c)
 	filters["since"] = h.getSinceFilter(c)
+	filters["archived"] = h.getArchivedFilter(c)
 
 	buildsCount, err := h.cockroachDBClient.GetBuildsCount(filters)
 	if err != nil {
@@ -1197,10 +1398,11 @@ func (h *apiHandlerImpl) GetStatsBuildsCount(c *gin.Context) {
 
 func (h *apiHandlerImpl) GetStatsBuildsDuration(c *gin.Context) {
 
-	// get filters (?filter[status]=running,succeeded&filter[since]=1w
+	// get filters (?filter[status]=running,succeeded&filter[since]=1w&filter[archived]=true
 	filters := map[string][]string{}
 	filters["status"] = h.getStatusFilter(c)
 	filters["since"] = h.getSinceFilter(c)
+	filters["archived"] = h.getArchivedFilter(c)
 
 	buildsDuration, err := h.cockroachDBClient.GetBuildsDuration(filters)
 	if err != nil {
@@ -1215,7 +1417,11 @@ func (h *apiHandlerImpl) GetStatsBuildsDuration(c *gin.Context) {
 
 func (h *apiHandlerImpl) GetStatsBuildsAdoption(c *gin.Context) {
 
-	buildTimes, err := h.cockroachDBClient.GetFirstBuildTimes()
+	// get filters (?filter[archived]=true
+	filters := map[string][]string{}
+	filters["archived"] = h.getArchivedFilter(c)
+
+	buildTimes, err := h.cockroachDBClient.GetFirstBuildTimes(filters)
 	if err != nil {
 		errorMessage := "Failed retrieving first build times from db"
 		log.Error().Err(err).Msg(errorMessage)
@@ -1228,7 +1434,12 @@ func (h *apiHandlerImpl) GetStatsBuildsAdoption(c *gin.Context) {
 }
 
 func (h *apiHandlerImpl) GetStatsReleasesAdoption(c *gin.Context) {
-	releaseTimes, err := h.cockroachDBClient.GetFirstReleaseTimes()
+
+	// get filters (?filter[archived]=true
+	filters := map[string][]string{}
+	filters["archived"] = h.getArchivedFilter(c)
+
+	releaseTimes, err := h.cockroachDBClient.GetFirstReleaseTimes(filters)
 	if err != nil {
 		errorMessage := "Failed retrieving first release times from db"
 		log.Error().Err(err).Msg(errorMessage)
@@ -1240,6 +1451,72 @@ func (h *apiHandlerImpl) GetStatsReleasesAdoption(c *gin.Context) {
 	})
 }
 
+func (h *apiHandlerImpl) GetStatsImages(c *gin.Context) {
+
+	// get filters (?filter[image]=extensions/gke&filter[tag]=dev&filter[pinned]=false&filter[labels]=team=estafette-team&filter[archived]=true
+	filters := map[string][]string{}
+	filters["labels"] = h.getLabelsFilter(c)
+	filters["archived"] = h.getArchivedFilter(c)
+
+	imageFilter, _ := c.GetQueryArray("filter[image]")
+	pinnedFilter := c.Query("filter[pinned]")
+	if pinnedFilter != "" && pinnedFilter != "true" && pinnedFilter != "false" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"code": http.StatusText(http.StatusBadRequest), "message": "Filter pinned should be true or false"})
+		return
+	}
+
+	// the image tag filter is applied to the manifests, not to the git tag of the pipelines
+	tagFilter := h.getTagFilter(c)
+
+	pipelineImages, err := h.getPipelineImages(filters)
+	if err != nil {
+		errorMessage := "Failed retrieving pipelines from db"
+		log.Error().Err(err).Msg(errorMessage)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": errorMessage})
+		return
+	}
+
+	images, pipelinesCount := getImageStats(pipelineImages, imageFilter, tagFilter, pinnedFilter)
+
+	c.JSON(http.StatusOK, gin.H{
+		"pipelinesCount": pipelinesCount,
+		"images":         images,
+	})
+}
+
+// getPipelineImages returns the images used by the pipelines matching the label and archived filters, from cache if they've been read recently
+func (h *apiHandlerImpl) getPipelineImages(filters map[string][]string) (pipelineImages []pipelineImageUsage, err error) {
+
+	cacheKey := fmt.Sprintf("labels=%v&archived=%v", strings.Join(filters["labels"], ","), strings.Join(filters["archived"], ","))
+
+	h.imageStatsCacheMutex.RLock()
+	entry, ok := h.imageStatsCache[cacheKey]
+	h.imageStatsCacheMutex.RUnlock()
+	if ok && time.Now().UTC().Before(entry.expiresAt) {
+		return entry.pipelineImages, nil
+	}
+
+	// page through all pipelines, only keeping the images of their latest manifest
+	pipelineImages = []pipelineImageUsage{}
+	pageSize := 100
+	for pageNumber := 1; ; pageNumber++ {
+		pipelinesPage, err := h.cockroachDBClient.GetPipelines(pageNumber, pageSize, filters, false)
+		if err != nil {
+			return nil, err
+		}
+		pipelineImages = append(pipelineImages, getPipelineImages(pipelinesPage)...)
+		if len(pipelinesPage) < pageSize {
+			break
+		}
+	}
+
+	h.imageStatsCacheMutex.Lock()
+	h.imageStatsCache[cacheKey] = imageStatsCacheEntry{pipelineImages: pipelineImages, expiresAt: time.Now().UTC().Add(imageStatsCacheDuration)}
+	h.imageStatsCacheMutex.Unlock()
+
+	return
+}
+
 func (h *apiHandlerImpl) GetLoggedInUser(c *gin.Context) {
 
 	user := c.MustGet(gin.AuthUserKey).(auth.User)
@@ -1296,33 +1573,34 @@ func (h *apiHandlerImpl) GetConfig(c *gin.Context) {
 
 	_ = c.MustGet(gin.AuthUserKey).(auth.User)
 
-	configBytes, err := yaml.Marshal(h.encryptedConfig)
+	configBytes, err := yaml.Marshal(h.getEncryptedConfig())
 	if err != nil {
 		log.Error().Err(err).Msgf("Failed marshalling encrypted config")
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError)})
+		return
 	}
 
-	r, err := regexp.Compile(`estafette\.secret\(([a-zA-Z0-9.=_-]+)\)`)
+	// obfuscate all secrets, including credentials that weren't set as an envelope
+	configBytes, err = config.RedactConfig(configBytes)
 	if err != nil {
-		log.Error().Err(err).Msgf("Failed compiling regex")
+		log.Error().Err(err).Msgf("Failed redacting encrypted config")
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError)})
+		return
 	}
 
-	// obfuscate all secrets
-	configString := r.ReplaceAllLiteralString(string(configBytes), "***")
-
 	// add extra whitespace after each top-level item
 	addWhitespaceRegex := regexp.MustCompile(`\n([a-z])`)
-	configString = addWhitespaceRegex.ReplaceAllString(configString, "\n\n$1")
+	configString := addWhitespaceRegex.ReplaceAllString(string(configBytes), "\n\n$1")
 
-	c.JSON(http.StatusOK, gin.H{"config": configString})
+	// show which file or environment variable each value came from, with secrets redacted
+	c.JSON(http.StatusOK, gin.H{"config": configString, "sources": h.getConfigSources()})
 }
 
 func (h *apiHandlerImpl) GetConfigCredentials(c *gin.Context) {
 
 	_ = c.MustGet(gin.AuthUserKey).(auth.User)
 
-	configBytes, err := yaml.Marshal(h.encryptedConfig.Credentials)
+	configBytes, err := yaml.Marshal(h.getEncryptedConfig().Credentials)
 	if err != nil {
 		log.Error().Err(err).Msgf("Failed marshalling encrypted config")
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError)})
@@ -1341,14 +1619,30 @@ func (h *apiHandlerImpl) GetConfigCredentials(c *gin.Context) {
 	addWhitespaceRegex := regexp.MustCompile(`\n([a-z])`)
 	configString = addWhitespaceRegex.ReplaceAllString(configString, "\n\n$1")
 
-	c.JSON(http.StatusOK, gin.H{"config": configString})
+	// credentials managed through the api come after the ones from the config file, which take precedence
+	managedCredentials, err := h.cockroachDBClient.GetManagedCredentials()
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed retrieving managed credentials from db")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError)})
+		return
+	}
+
+	managedBytes, err := yaml.Marshal(managedCredentials)
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed marshalling managed credentials")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError)})
+		return
+	}
+	managedString := r.ReplaceAllLiteralString(string(managedBytes), "***")
+
+	c.JSON(http.StatusOK, gin.H{"config": configString, "managed": managedString})
 }
 
 func (h *apiHandlerImpl) GetConfigTrustedImages(c *gin.Context) {
 
 	_ = c.MustGet(gin.AuthUserKey).(auth.User)
 
-	configBytes, err := yaml.Marshal(h.encryptedConfig.TrustedImages)
+	configBytes, err := yaml.Marshal(h.getEncryptedConfig().TrustedImages)
 	if err != nil {
 		log.Error().Err(err).Msgf("Failed marshalling encrypted config")
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError)})
@@ -1367,12 +1661,297 @@ func (h *apiHandlerImpl) GetConfigTrustedImages(c *gin.Context) {
 	addWhitespaceRegex := regexp.MustCompile(`\n([a-z])`)
 	configString = addWhitespaceRegex.ReplaceAllString(configString, "\n\n$1")
 
-	c.JSON(http.StatusOK, gin.H{"config": configString})
-}
+	// trusted images managed through the api come after the ones from the config file, which take precedence
+	managedTrustedImages, err := h.cockroachDBClient.GetManagedTrustedImages()
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed retrieving managed trusted images from db")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError)})
+		return
+	}
 
-func (h *apiHandlerImpl) getStatusFilter(c *gin.Context) []string {
-	return h.getStatusFilterWithDefault(c, []string{})
-}
+	managedBytes, err := yaml.Marshal(managedTrustedImages)
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed marshalling managed trusted images")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"config": configString, "managed": string(managedBytes)})
+}
+
+func (h *apiHandlerImpl) CreateManagedCredential(c *gin.Context) {
+	h.writeManagedCredential(c, "create")
+}
+
+func (h *apiHandlerImpl) UpdateManagedCredential(c *gin.Context) {
+	h.writeManagedCredential(c, "update")
+}
+
+func (h *apiHandlerImpl) DeleteManagedCredential(c *gin.Context) {
+	h.writeManagedCredential(c, "delete")
+}
+
+func (h *apiHandlerImpl) writeManagedCredential(c *gin.Context, action string) {
+
+	user := c.MustGet(gin.AuthUserKey).(auth.User)
+
+	if !h.isAdministrator(c, user, "manage credentials") {
+		return
+	}
+
+	credential := contracts.CredentialConfig{Name: c.Param("name")}
+	if action != "delete" {
+		err := c.BindJSON(&credential)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed binding json body")
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"code": http.StatusText(http.StatusBadRequest), "message": "The body is not a valid credential"})
+			return
+		}
+		if action == "update" && credential.Name != c.Param("name") {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"code": http.StatusText(http.StatusBadRequest), "message": "The credential name can't be changed"})
+			return
+		}
+		if credential.Name == "" || credential.Type == "" {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"code": http.StatusText(http.StatusBadRequest), "message": "The credential needs a name and type"})
+			return
+		}
+	}
+
+	for _, cc := range h.getEncryptedConfig().Credentials {
+		if cc.Name == credential.Name {
+			errorMessage := fmt.Sprintf("Credential %v is defined in the config file, which takes precedence", credential.Name)
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"code": http.StatusText(http.StatusConflict), "message": errorMessage})
+			return
+		}
+	}
+
+	existingCredential, err := h.cockroachDBClient.GetManagedCredential(credential.Name)
+	if err != nil {
+		errorMessage := fmt.Sprintf("Failed retrieving managed credential %v from db", credential.Name)
+		log.Error().Err(err).Msg(errorMessage)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": errorMessage})
+		return
+	}
+	if action == "create" && existingCredential != nil {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"code": http.StatusText(http.StatusConflict), "message": fmt.Sprintf("Credential %v already exists", credential.Name)})
+		return
+	}
+	if action != "create" && existingCredential == nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"code": http.StatusText(http.StatusNotFound), "message": fmt.Sprintf("Credential %v not found", credential.Name)})
+		return
+	}
+
+	switch action {
+	case "create", "update":
+		// store all values encrypted, so the credential never leaves the api unencrypted
+		credential.AdditionalProperties, err = encryptCredentialValues(credential.AdditionalProperties, h.secretHelper)
+		if err != nil {
+			log.Error().Err(err).Msgf("Failed encrypting values of credential %v", credential.Name)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError)})
+			return
+		}
+		if action == "create" {
+			err = h.cockroachDBClient.InsertManagedCredential(credential, user.Email)
+		} else {
+			err = h.cockroachDBClient.UpdateManagedCredential(credential, user.Email)
+		}
+	case "delete":
+		err = h.cockroachDBClient.DeleteManagedCredential(credential.Name, user.Email)
+	}
+	if err != nil {
+		errorMessage := fmt.Sprintf("Failed writing managed credential %v to db", credential.Name)
+		log.Error().Err(err).Msg(errorMessage)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": errorMessage})
+		return
+	}
+
+	log.Info().Msgf("Managed credential %v %vd by user %v", credential.Name, action, user.Email)
+
+	c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("Credential %v %vd by user %v", credential.Name, action, user.Email)})
+}
+
+func (h *apiHandlerImpl) CreateManagedTrustedImage(c *gin.Context) {
+	h.writeManagedTrustedImage(c, "create")
+}
+
+func (h *apiHandlerImpl) UpdateManagedTrustedImage(c *gin.Context) {
+	h.writeManagedTrustedImage(c, "update")
+}
+
+func (h *apiHandlerImpl) DeleteManagedTrustedImage(c *gin.Context) {
+	h.writeManagedTrustedImage(c, "delete")
+}
+
+func (h *apiHandlerImpl) writeManagedTrustedImage(c *gin.Context, action string) {
+
+	user := c.MustGet(gin.AuthUserKey).(auth.User)
+
+	if !h.isAdministrator(c, user, "manage trusted images") {
+		return
+	}
+
+	// image paths contain slashes, so they're matched by a catch-all parameter
+	imagePath := strings.TrimPrefix(c.Param("path"), "/")
+
+	trustedImage := contracts.TrustedImageConfig{ImagePath: imagePath}
+	if action != "delete" {
+		err := c.BindJSON(&trustedImage)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed binding json body")
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"code": http.StatusText(http.StatusBadRequest), "message": "The body is not a valid trusted image"})
+			return
+		}
+		if action == "update" && trustedImage.ImagePath != imagePath {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"code": http.StatusText(http.StatusBadRequest), "message": "The trusted image path can't be changed"})
+			return
+		}
+		if trustedImage.ImagePath == "" || strings.Contains(trustedImage.ImagePath, ":") {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"code": http.StatusText(http.StatusBadRequest), "message": "The trusted image needs a path without tag"})
+			return
+		}
+	}
+
+	if contracts.GetTrustedImage(h.getEncryptedConfig().TrustedImages, trustedImage.ImagePath) != nil {
+		errorMessage := fmt.Sprintf("Trusted image %v is defined in the config file, which takes precedence", trustedImage.ImagePath)
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"code": http.StatusText(http.StatusConflict), "message": errorMessage})
+		return
+	}
+
+	existingTrustedImage, err := h.cockroachDBClient.GetManagedTrustedImage(trustedImage.ImagePath)
+	if err != nil {
+		errorMessage := fmt.Sprintf("Failed retrieving managed trusted image %v from db", trustedImage.ImagePath)
+		log.Error().Err(err).Msg(errorMessage)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": errorMessage})
+		return
+	}
+	if action == "create" && existingTrustedImage != nil {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"code": http.StatusText(http.StatusConflict), "message": fmt.Sprintf("Trusted image %v already exists", trustedImage.ImagePath)})
+		return
+	}
+	if action != "create" && existingTrustedImage == nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"code": http.StatusText(http.StatusNotFound), "message": fmt.Sprintf("Trusted image %v not found", trustedImage.ImagePath)})
+		return
+	}
+
+	switch action {
+	case "create":
+		err = h.cockroachDBClient.InsertManagedTrustedImage(trustedImage, user.Email)
+	case "update":
+		err = h.cockroachDBClient.UpdateManagedTrustedImage(trustedImage, user.Email)
+	case "delete":
+		err = h.cockroachDBClient.DeleteManagedTrustedImage(trustedImage.ImagePath, user.Email)
+	}
+	if err != nil {
+		errorMessage := fmt.Sprintf("Failed writing managed trusted image %v to db", trustedImage.ImagePath)
+		log.Error().Err(err).Msg(errorMessage)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": errorMessage})
+		return
+	}
+
+	log.Info().Msgf("Managed trusted image %v %vd by user %v", trustedImage.ImagePath, action, user.Email)
+
+	c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("Trusted image %v %vd by user %v", trustedImage.ImagePath, action, user.Email)})
+}
+
+func (h *apiHandlerImpl) GetManagedConfigAuditLog(c *gin.Context) {
+
+	user := c.MustGet(gin.AuthUserKey).(auth.User)
+
+	if !h.isAdministrator(c, user, "view the managed config audit log") {
+		return
+	}
+
+	// get page number query string value or default to 1
+	pageNumberValue, pageNumberExists := c.GetQuery("page[number]")
+	pageNumber, err := strconv.Atoi(pageNumberValue)
+	if !pageNumberExists || err != nil {
+		pageNumber = 1
+	}
+
+	// get page number query string value or default to 20 (maximize at 100)
+	pageSizeValue, pageSizeExists := c.GetQuery("page[size]")
+	pageSize, err := strconv.Atoi(pageSizeValue)
+	if !pageSizeExists || err != nil {
+		pageSize = 20
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+
+	entries, err := h.cockroachDBClient.GetManagedConfigAuditLog(pageNumber, pageSize)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed retrieving managed config audit log from db")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError)})
+		return
+	}
+
+	// the stored credentials only hold encrypted values, but those don't need to be shown either
+	for _, entry := range entries {
+		entry.Data = secretEnvelopeRegex.ReplaceAllLiteralString(entry.Data, "***")
+	}
+
+	c.JSON(http.StatusOK, gin.H{"items": entries})
+}
+
+// isAdministrator aborts with a 403 if the user isn't an administrator
+func (h *apiHandlerImpl) isAdministrator(c *gin.Context, user auth.User, action string) bool {
+
+	authConfig := h.getAuthConfig()
+	if !authConfig.IsAdministrator(user.Email) {
+		errorMessage := fmt.Sprintf("User %v is not allowed to %v", user.Email, action)
+		log.Warn().Msg(errorMessage)
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"code": http.StatusText(http.StatusForbidden), "message": errorMessage})
+		return false
+	}
+
+	return true
+}
+
+var secretEnvelopeRegex = regexp.MustCompile(`estafette\.secret\(([a-zA-Z0-9.=_-]+)\)`)
+
+// encryptCredentialValues returns the credential values with every string that isn't a secret envelope yet encrypted into one
+func encryptCredentialValues(values map[string]interface{}, secretHelper secrets.SecretHelper) (map[string]interface{}, error) {
+
+	encryptedValues := map[string]interface{}{}
+	for key, value := range values {
+		encryptedValue, err := encryptCredentialValue(value, secretHelper)
+		if err != nil {
+			return values, err
+		}
+		encryptedValues[key] = encryptedValue
+	}
+
+	return encryptedValues, nil
+}
+
+func encryptCredentialValue(value interface{}, secretHelper secrets.SecretHelper) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		if secretEnvelopeRegex.FindString(v) == v {
+			return v, nil
+		}
+		return secretHelper.EncryptEnvelope(v)
+	case map[string]interface{}:
+		return encryptCredentialValues(v, secretHelper)
+	case []interface{}:
+		encryptedValues := make([]interface{}, len(v))
+		for i, item := range v {
+			encryptedValue, err := encryptCredentialValue(item, secretHelper)
+			if err != nil {
+				return value, err
+			}
+			encryptedValues[i] = encryptedValue
+		}
+		return encryptedValues, nil
+	}
+
+	// numbers and booleans aren't secret
+	return value, nil
+}
+
+func (h *apiHandlerImpl) getStatusFilter(c *gin.Context) []string {
+	return h.getStatusFilterWithDefault(c, []string{})
+}
 
 func (h *apiHandlerImpl) getStatusFilterWithDefault(c *gin.Context, defaultStatuses []string) []string {
 
@@ -1386,52 +1965,170 @@ func (h *apiHandlerImpl) getStatusFilterWithDefault(c *gin.Context, defaultStatu
 
 func (h *apiHandlerImpl) GetManifestTemplates(c *gin.Context) {
 
-	configFiles, err := ioutil.ReadDir(filepath.Dir(h.configFilePath))
+	manifestTemplates, err := h.cockroachDBClient.GetManifestTemplates()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed retrieving manifest templates from db")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"templates": getManifestTemplateSummaries(manifestTemplates)})
+}
+
+func (h *apiHandlerImpl) GetManifestTemplatesV2(c *gin.Context) {
+
+	manifestTemplates, err := h.cockroachDBClient.GetManifestTemplates()
 	if err != nil {
-		log.Error().Err(err).Msgf("Failed listing config files directory")
+		log.Error().Err(err).Msg("Failed retrieving manifest templates from db")
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError)})
+		return
 	}
 
-	templates := []interface{}{}
-	for _, f := range configFiles {
+	c.JSON(http.StatusOK, gin.H{"templates": manifestTemplates})
+}
 
-		configfileName := f.Name()
+func (h *apiHandlerImpl) GetManifestTemplate(c *gin.Context) {
 
-		// check if it's a manifest template
-		re := regexp.MustCompile(`^manifest-(.+)\.tmpl`)
-		match := re.FindStringSubmatch(configfileName)
+	name := c.Param("name")
 
-		if len(match) == 2 {
+	// get a specific version with ?version=3 or the latest one
+	version, _ := strconv.Atoi(c.Query("version"))
 
-			// read template file
-			templateFilePath := filepath.Join(filepath.Dir(h.configFilePath), configfileName)
-			data, err := ioutil.ReadFile(templateFilePath)
-			if err != nil {
-				log.Error().Err(err).Msgf("Failed reading template file %v", templateFilePath)
-				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError)})
-			}
+	manifestTemplate, err := h.cockroachDBClient.GetManifestTemplate(name, version)
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed retrieving manifest template %v from db", name)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError)})
+		return
+	}
+	if manifestTemplate == nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"code": http.StatusText(http.StatusNotFound), "message": "Manifest template not found"})
+		return
+	}
 
-			placeholderRegex := regexp.MustCompile(`{{\.([a-zA-Z0-9]+)}}`)
-			placeholderMatches := placeholderRegex.FindAllStringSubmatch(string(data), -1)
+	c.JSON(http.StatusOK, manifestTemplate)
+}
 
-			// reduce and deduplicate [["{{.Application}}","Application"],["{{.Team}}","Team"],["{{.ProjectName}}","ProjectName"],["{{.ProjectName}}","ProjectName"]] to ["Application","Team","ProjectName"]
-			placeholders := []string{}
-			for _, m := range placeholderMatches {
-				if len(m) == 2 && !stringArrayContains(placeholders, m[1]) {
-					placeholders = append(placeholders, m[1])
-				}
-			}
+func (h *apiHandlerImpl) GetManifestTemplateVersions(c *gin.Context) {
 
-			templateData := map[string]interface{}{
-				"template":     match[1],
-				"placeholders": placeholders,
-			}
+	name := c.Param("name")
 
-			templates = append(templates, templateData)
-		}
+	manifestTemplates, err := h.cockroachDBClient.GetManifestTemplateVersions(name)
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed retrieving versions of manifest template %v from db", name)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError)})
+		return
+	}
+	if len(manifestTemplates) == 0 {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"code": http.StatusText(http.StatusNotFound), "message": "Manifest template not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"versions": manifestTemplates})
+}
+
+func (h *apiHandlerImpl) CreateManifestTemplate(c *gin.Context) {
+	h.saveManifestTemplate(c, false)
+}
+
+func (h *apiHandlerImpl) UpdateManifestTemplate(c *gin.Context) {
+	h.saveManifestTemplate(c, true)
+}
+
+func (h *apiHandlerImpl) saveManifestTemplate(c *gin.Context, update bool) {
+
+	user := c.MustGet(gin.AuthUserKey).(auth.User)
+
+	if !h.isAdministrator(c, user, "manage manifest templates") {
+		return
+	}
+
+	var manifestTemplate cockroach.ManifestTemplate
+	err := c.BindJSON(&manifestTemplate)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed binding json body")
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"code": http.StatusText(http.StatusBadRequest), "message": "The body is not a valid manifest template"})
+		return
+	}
+	if update {
+		manifestTemplate.Name = c.Param("name")
 	}
 
-	c.JSON(http.StatusOK, gin.H{"templates": templates})
+	err = validateManifestTemplate(manifestTemplate)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"code": http.StatusText(http.StatusBadRequest), "message": err.Error()})
+		return
+	}
+
+	existingManifestTemplate, err := h.cockroachDBClient.GetManifestTemplate(manifestTemplate.Name, 0)
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed retrieving manifest template %v from db", manifestTemplate.Name)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError)})
+		return
+	}
+	if !update && existingManifestTemplate != nil {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"code": http.StatusText(http.StatusConflict), "message": fmt.Sprintf("Manifest template %v already exists", manifestTemplate.Name)})
+		return
+	}
+	if update && existingManifestTemplate == nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"code": http.StatusText(http.StatusNotFound), "message": "Manifest template not found"})
+		return
+	}
+
+	// saving always adds a new version, so manifests generated from earlier versions can be traced back
+	manifestTemplate.InsertedBy = user.Email
+	insertedManifestTemplate, err := h.cockroachDBClient.InsertManifestTemplate(manifestTemplate)
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed inserting manifest template %v into db", manifestTemplate.Name)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError)})
+		return
+	}
+
+	log.Info().Msgf("Manifest template %v version %v saved by user %v", insertedManifestTemplate.Name, insertedManifestTemplate.Version, user.Email)
+
+	c.JSON(http.StatusOK, insertedManifestTemplate)
+}
+
+func (h *apiHandlerImpl) DeleteManifestTemplate(c *gin.Context) {
+
+	user := c.MustGet(gin.AuthUserKey).(auth.User)
+
+	if !h.isAdministrator(c, user, "manage manifest templates") {
+		return
+	}
+
+	name := c.Param("name")
+
+	err := h.cockroachDBClient.DeleteManifestTemplate(name)
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed deleting manifest template %v from db", name)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError)})
+		return
+	}
+
+	log.Info().Msgf("Manifest template %v deleted by user %v", name, user.Email)
+
+	c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("Manifest template %v deleted by user %v", name, user.Email)})
+}
+
+func (h *apiHandlerImpl) ImportManifestTemplates(c *gin.Context) {
+
+	user := c.MustGet(gin.AuthUserKey).(auth.User)
+
+	if !h.isAdministrator(c, user, "import manifest templates") {
+		return
+	}
+
+	// seed the database with the manifest-*.tmpl files next to the config file, skipping templates that already exist
+	imported, err := ImportManifestTemplateFiles(h.cockroachDBClient, filepath.Dir(h.configFilePath), user.Email)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed importing manifest template files")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError)})
+		return
+	}
+
+	log.Info().Msgf("Manifest templates %v imported by user %v", imported, user.Email)
+
+	c.JSON(http.StatusOK, gin.H{"imported": imported})
 }
 
 func stringArrayContains(array []string, value string) bool {
@@ -1447,6 +2144,7 @@ func (h *apiHandlerImpl) GenerateManifest(c *gin.Context) {
 
 	var aux struct {
 		Template     string            `json:"template"`
+		Version      int               `json:"version,omitempty"`
 		Placeholders map[string]string `json:"placeholders,omitempty"`
 	}
 
@@ -1454,35 +2152,35 @@ func (h *apiHandlerImpl) GenerateManifest(c *gin.Context) {
 	if err != nil {
 		log.Error().Err(err).Msg("Failed binding json body")
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError)})
+		return
 	}
 
-	templateFilePath := filepath.Join(filepath.Dir(h.configFilePath), fmt.Sprintf("manifest-%v.tmpl", aux.Template))
-	data, err := ioutil.ReadFile(templateFilePath)
+	manifestTemplate, err := h.cockroachDBClient.GetManifestTemplate(aux.Template, aux.Version)
 	if err != nil {
-		log.Error().Err(err).Msgf("Failed reading template file %v", templateFilePath)
+		log.Error().Err(err).Msgf("Failed retrieving manifest template %v from db", aux.Template)
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError)})
+		return
 	}
-
-	tmpl, err := template.New(".estafette.yaml").Parse(string(data))
-	if err != nil {
-		log.Error().Err(err).Msgf("Failed parsing template file %v", templateFilePath)
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError)})
+	if manifestTemplate == nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"code": http.StatusText(http.StatusNotFound), "message": "Manifest template not found"})
+		return
 	}
 
-	var renderedTemplate bytes.Buffer
-	err = tmpl.Execute(&renderedTemplate, aux.Placeholders)
+	renderedTemplate, err := renderManifestTemplate(*manifestTemplate, aux.Placeholders)
 	if err != nil {
-		log.Error().Err(err).Msgf("Failed rendering template file %v", templateFilePath)
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError)})
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"code": http.StatusText(http.StatusBadRequest), "message": err.Error()})
+		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"manifest": renderedTemplate.String()})
+	c.JSON(http.StatusOK, gin.H{"manifest": renderedTemplate, "template": manifestTemplate.Name, "version": manifestTemplate.Version})
 }
 
 func (h *apiHandlerImpl) ValidateManifest(c *gin.Context) {
 
 	var aux struct {
 		Template string `json:"template"`
+		// optional, in the form source/owner/repo; checks the manifest against the trusted images, credentials and secrets the pipeline can use
+		Pipeline string `json:"pipeline"`
 	}
 
 	err := c.BindJSON(&aux)
@@ -1491,15 +2189,48 @@ func (h *apiHandlerImpl) ValidateManifest(c *gin.Context) {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError)})
 	}
 
-	_, err = manifest.ReadManifest(aux.Template)
+	gitOwner := ""
+	if aux.Pipeline != "" {
+		pipelineParts := strings.Split(aux.Pipeline, "/")
+		if len(pipelineParts) != 3 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"code": http.StatusText(http.StatusBadRequest), "message": fmt.Sprintf("Pipeline %v is not in the form source/owner/repo", aux.Pipeline)})
+			return
+		}
+		gitOwner = pipelineParts[1]
+	}
+
+	mft, errors, warnings := validateManifest(aux.Template)
+
+	if mft != nil && aux.Pipeline != "" {
+		managedCredentials, err := h.cockroachDBClient.GetManagedCredentials()
+		if err != nil {
+			log.Error().Err(err).Msgf("Failed retrieving managed credentials from db")
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError)})
+			return
+		}
+		managedTrustedImages, err := h.cockroachDBClient.GetManagedTrustedImages()
+		if err != nil {
+			log.Error().Err(err).Msgf("Failed retrieving managed trusted images from db")
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError)})
+			return
+		}
+
+		encryptedConfig := h.getEncryptedConfig()
+		errors = append(errors, validateManifestForPipeline(aux.Template, mft, mergeTrustedImages(encryptedConfig.TrustedImages, managedTrustedImages), mergeCredentials(encryptedConfig.Credentials, managedCredentials), func(text string) error {
+			return h.secretHelper.ValidateAllEnvelopesForPipeline(text, aux.Pipeline)
+		})...)
+	}
+
+	if mft != nil {
+		warnings = append(warnings, getManifestValidationWarnings(aux.Template, h.warningHelper.LintManifest(mft, gitOwner))...)
+	}
+
 	status := "succeeded"
-	errorString := ""
-	if err != nil {
+	if len(errors) > 0 {
 		status = "failed"
-		errorString = err.Error()
 	}
 
-	c.JSON(http.StatusOK, gin.H{"status": status, "errors": errorString})
+	c.JSON(http.StatusOK, gin.H{"status": status, "errors": errors, "warnings": warnings})
 }
 
 func (h *apiHandlerImpl) EncryptSecret(c *gin.Context) {
@@ -1507,6 +2238,7 @@ func (h *apiHandlerImpl) EncryptSecret(c *gin.Context) {
 	var aux struct {
 		Base64Encode bool   `json:"base64"`
 		Value        string `json:"value"`
+		Pipeline     string `json:"pipeline"`
 	}
 
 	err := c.BindJSON(&aux)
@@ -1520,7 +2252,20 @@ func (h *apiHandlerImpl) EncryptSecret(c *gin.Context) {
 		value = base64.URLEncoding.EncodeToString([]byte(value))
 	}
 
-	encryptedString, err := h.secretHelper.EncryptEnvelope(value)
+	// optionally restrict the secret to a single pipeline in the form source/owner/repo
+	var encryptedString string
+	if aux.Pipeline != "" {
+		encryptedString, err = h.secretHelper.EncryptEnvelopeForPipeline(value, aux.Pipeline)
+		if err != nil {
+			log.Warn().Err(err).Msgf("Failed encrypting secret for pipeline %v", aux.Pipeline)
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"code": http.StatusText(http.StatusBadRequest), "message": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"secret": encryptedString})
+		return
+	}
+
+	encryptedString, err = h.secretHelper.EncryptEnvelope(value)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed encrypting secret")
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError)})
@@ -1530,6 +2275,42 @@ func (h *apiHandlerImpl) EncryptSecret(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"secret": encryptedString})
 }
 
+func (h *apiHandlerImpl) ReencryptSecrets(c *gin.Context) {
+
+	user := c.MustGet(gin.AuthUserKey).(auth.User)
+
+	authConfig := h.getAuthConfig()
+	if !authConfig.IsAdministrator(user.Email) {
+		errorMessage := fmt.Sprintf("User %v is not allowed to re-encrypt secrets", user.Email)
+		log.Warn().Msg(errorMessage)
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"code": http.StatusText(http.StatusForbidden), "message": errorMessage})
+		return
+	}
+
+	var aux struct {
+		Value string `json:"value"`
+	}
+
+	err := c.BindJSON(&aux)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed binding json body")
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"code": http.StatusText(http.StatusBadRequest)})
+		return
+	}
+
+	// re-encrypt all secrets in a config file or manifest with the primary key, so legacy keys can be retired
+	reencryptedString, err := h.secretHelper.ReencryptAllEnvelopes(aux.Value)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed re-encrypting secrets")
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"code": http.StatusText(http.StatusBadRequest), "message": err.Error()})
+		return
+	}
+
+	log.Info().Msgf("User %v re-encrypted secrets with the primary key", user.Email)
+
+	c.JSON(http.StatusOK, gin.H{"value": reencryptedString})
+}
+
 func (h *apiHandlerImpl) getSinceFilter(c *gin.Context) []string {
 
 	filterSinceValues, filterSinceExist := c.GetQueryArray("filter[since]")
@@ -1550,6 +2331,24 @@ func (h *apiHandlerImpl) getLastFilter(c *gin.Context, defaultValue int) []strin
 	return []string{strconv.Itoa(defaultValue)}
 }
 
+func (h *apiHandlerImpl) getTagFilter(c *gin.Context) []string {
+	filterTagValues, filterTagExist := c.GetQueryArray("filter[tag]")
+	if filterTagExist {
+		return filterTagValues
+	}
+
+	return []string{}
+}
+
+func (h *apiHandlerImpl) getArchivedFilter(c *gin.Context) []string {
+	filterArchivedValues, filterArchivedExist := c.GetQueryArray("filter[archived]")
+	if filterArchivedExist && len(filterArchivedValues) > 0 && filterArchivedValues[0] != "" {
+		return filterArchivedValues
+	}
+
+	return []string{"false"}
+}
+
 func (h *apiHandlerImpl) getLabelsFilter(c *gin.Context) []string {
 	filterLabelsValues, filterLabelsExist := c.GetQueryArray("filter[labels]")
 	if filterLabelsExist {
@@ -1558,3 +2357,35 @@ func (h *apiHandlerImpl) getLabelsFilter(c *gin.Context) []string {
 
 	return []string{}
 }
+
+// UpdateConfig swaps in a reloaded config
+func (h *apiHandlerImpl) UpdateConfig(config config.APIServerConfig, authConfig config.AuthConfig, encryptedConfig config.APIConfig, configSources []config.ConfigSource) {
+	h.configMutex.Lock()
+	defer h.configMutex.Unlock()
+
+	h.config = config
+	h.authConfig = authConfig
+	h.encryptedConfig = encryptedConfig
+	h.configSources = configSources
+}
+
+func (h *apiHandlerImpl) getAuthConfig() config.AuthConfig {
+	h.configMutex.RLock()
+	defer h.configMutex.RUnlock()
+
+	return h.authConfig
+}
+
+func (h *apiHandlerImpl) getEncryptedConfig() config.APIConfig {
+	h.configMutex.RLock()
+	defer h.configMutex.RUnlock()
+
+	return h.encryptedConfig
+}
+
+func (h *apiHandlerImpl) getConfigSources() []config.ConfigSource {
+	h.configMutex.RLock()
+	defer h.configMutex.RUnlock()
+
+	return h.configSources
+}
@@ -12,6 +12,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/ericchiang/k8s"
@@ -19,8 +20,10 @@ import (
 	corev1 "github.com/ericchiang/k8s/apis/core/v1"
 	metav1 "github.com/ericchiang/k8s/apis/meta/v1"
 	"github.com/ericchiang/k8s/apis/resource"
+	"github.com/estafette/estafette-ci-api/cockroach"
 	"github.com/estafette/estafette-ci-api/config"
 	"github.com/estafette/estafette-ci-api/docker"
+	"github.com/estafette/estafette-ci-api/secrets"
 	contracts "github.com/estafette/estafette-ci-contracts"
 	manifest "github.com/estafette/estafette-ci-manifest"
 	"github.com/prometheus/client_golang/prometheus"
@@ -35,7 +38,8 @@ type CiBuilderClient interface {
 	CancelCiBuilderJob(string) error
 	TailCiBuilderJobLogs(string, chan contracts.TailLogLine) error
 	GetJobName(string, string, string, string) string
-	GetBuilderConfig(CiBuilderParams, string) contracts.BuilderConfig
+	GetBuilderConfig(CiBuilderParams, string) (contracts.BuilderConfig, error)
+	UpdateConfig(config.APIConfig, config.APIConfig)
 }
 
 type ciBuilderClientImpl struct {
@@ -43,12 +47,15 @@ type ciBuilderClientImpl struct {
 	dockerHubClient                 docker.DockerHubAPIClient
 	config                          config.APIConfig
 	encryptedConfig                 config.APIConfig
+	configMutex                     sync.RWMutex
 	secretDecryptionKey             string
+	secretHelper                    secrets.SecretHelper
+	cockroachDBClient               cockroach.DBClient
 	PrometheusOutboundAPICallTotals *prometheus.CounterVec
 }
 
 // NewCiBuilderClient returns a new estafette.CiBuilderClient
-func NewCiBuilderClient(config config.APIConfig, encryptedConfig config.APIConfig, secretDecryptionKey string, prometheusOutboundAPICallTotals *prometheus.CounterVec) (ciBuilderClient CiBuilderClient, err error) {
+func NewCiBuilderClient(config config.APIConfig, encryptedConfig config.APIConfig, secretDecryptionKey string, secretHelper secrets.SecretHelper, cockroachDBClient cockroach.DBClient, prometheusOutboundAPICallTotals *prometheus.CounterVec) (ciBuilderClient CiBuilderClient, err error) {
 
 	var kubeClient *k8s.Client
 
@@ -90,6 +97,8 @@ func NewCiBuilderClient(config config.APIConfig, encryptedConfig config.APIConfi
 		config:                          config,
 		encryptedConfig:                 encryptedConfig,
 		secretDecryptionKey:             secretDecryptionKey,
+		secretHelper:                    secretHelper,
+		cockroachDBClient:               cockroachDBClient,
 		PrometheusOutboundAPICallTotals: prometheusOutboundAPICallTotals,
 	}
 
@@ -110,14 +119,22 @@ func (cbc *ciBuilderClientImpl) CreateCiBuilderJob(ciBuilderParams CiBuilderPara
 	log.Info().Msgf("Creating job %v...", jobName)
 
 	// extend builder config to parameterize the builder and replace all other envvars to improve security
-	localBuilderConfig := cbc.GetBuilderConfig(ciBuilderParams, jobName)
+	localBuilderConfig, err := cbc.GetBuilderConfig(ciBuilderParams, jobName)
+	if err != nil {
+		return
+	}
 
 	builderConfigName := "BUILDER_CONFIG"
 	builderConfigJSONBytes, err := json.Marshal(localBuilderConfig)
 	if err != nil {
 		return
 	}
-	builderConfigValue := string(builderConfigJSONBytes)
+
+	// the builder only gets the primary key, so secrets with a key id, encrypted with a legacy key or restricted to this pipeline need converting; the builder can't check pipeline bindings itself, so this is where secrets of other pipelines get refused
+	builderConfigValue, err := cbc.secretHelper.DowngradeAllEnvelopes(string(builderConfigJSONBytes), getPipeline(ciBuilderParams))
+	if err != nil {
+		return
+	}
 
 	environmentVariables := []*corev1.EnvVar{
 		&corev1.EnvVar{
@@ -441,8 +458,8 @@ func (cbc *ciBuilderClientImpl) GetJobName(jobType, repoOwner, repoSource, id st
 	return strings.ToLower(fmt.Sprintf("%v-%v-%v", jobType, repoName, id))
 }
 
-// GetJobName returns the job name for a build or release job
-func (cbc *ciBuilderClientImpl) GetBuilderConfig(ciBuilderParams CiBuilderParams, jobName string) contracts.BuilderConfig {
+// GetBuilderConfig returns the builder config for a build or release job; it fails if any of its secrets is restricted to another pipeline
+func (cbc *ciBuilderClientImpl) GetBuilderConfig(ciBuilderParams CiBuilderParams, jobName string) (localBuilderConfig contracts.BuilderConfig, err error) {
 
 	// retrieve stages to filter trusted images and credentials
 	stages := ciBuilderParams.Manifest.Stages
@@ -460,8 +477,21 @@ func (cbc *ciBuilderClientImpl) GetBuilderConfig(ciBuilderParams CiBuilderParams
 		}
 	}
 
-	// get configured credentials
-	credentials := cbc.encryptedConfig.Credentials
+	apiConfig, encryptedConfig := cbc.getConfig()
+
+	// get configured credentials and trusted images, including the ones managed through the api; if those can't be read the job still gets the ones from the config file, which only ever grants less
+	managedCredentials, managedErr := cbc.cockroachDBClient.GetManagedCredentials()
+	if managedErr != nil {
+		log.Warn().Err(managedErr).Msgf("Failed retrieving managed credentials for %v/%v/%v, using the ones from the config file only", ciBuilderParams.RepoSource, ciBuilderParams.RepoOwner, ciBuilderParams.RepoName)
+	}
+	managedTrustedImages, managedErr := cbc.cockroachDBClient.GetManagedTrustedImages()
+	if managedErr != nil {
+		log.Warn().Err(managedErr).Msgf("Failed retrieving managed trusted images for %v/%v/%v, using the ones from the config file only", ciBuilderParams.RepoSource, ciBuilderParams.RepoOwner, ciBuilderParams.RepoName)
+	}
+	configuredCredentials := mergeCredentials(encryptedConfig.Credentials, managedCredentials)
+	configuredTrustedImages := mergeTrustedImages(encryptedConfig.TrustedImages, managedTrustedImages)
+
+	credentials := append([]*contracts.CredentialConfig{}, configuredCredentials...)
 
 	// add dynamic github api token credential
 	if token, ok := ciBuilderParams.EnvironmentVariables["ESTAFETTE_GITHUB_API_TOKEN"]; ok {
@@ -486,16 +516,16 @@ func (cbc *ciBuilderClientImpl) GetBuilderConfig(ciBuilderParams CiBuilderParams
 	}
 
 	// filter to only what's needed by the build/release job
-	trustedImages := contracts.FilterTrustedImages(cbc.encryptedConfig.TrustedImages, stages)
+	trustedImages := contracts.FilterTrustedImages(configuredTrustedImages, stages)
 	credentials = contracts.FilterCredentials(credentials, trustedImages)
 
 	// add container-registry credentials to allow private registry images to be used in stages
-	credentials = contracts.AddCredentialsIfNotPresent(credentials, contracts.GetCredentialsByType(cbc.encryptedConfig.Credentials, "container-registry"))
+	credentials = contracts.AddCredentialsIfNotPresent(credentials, contracts.GetCredentialsByType(configuredCredentials, "container-registry"))
 
-	localBuilderConfig := contracts.BuilderConfig{
+	localBuilderConfig = contracts.BuilderConfig{
 		Credentials:    credentials,
 		TrustedImages:  trustedImages,
-		RegistryMirror: cbc.config.RegistryMirror,
+		RegistryMirror: apiConfig.RegistryMirror,
 	}
 
 	localBuilderConfig.Action = &ciBuilderParams.JobType
@@ -508,7 +538,8 @@ func (cbc *ciBuilderClientImpl) GetBuilderConfig(ciBuilderParams CiBuilderParams
 		RepoRevision: ciBuilderParams.RepoRevision,
 	}
 	if ciBuilderParams.Manifest.Version.SemVer != nil {
-		patchWithLabel := ciBuilderParams.Manifest.Version.SemVer.GetPatchWithLabel(manifest.EstafetteVersionParams{
+		version := WithVersionTag(ciBuilderParams.Manifest.Version, ciBuilderParams.RepoTag)
+		patchWithLabel := version.SemVer.GetPatchWithLabel(manifest.EstafetteVersionParams{
 			AutoIncrement: ciBuilderParams.AutoIncrement,
 			Branch:        ciBuilderParams.RepoBranch,
 			Revision:      ciBuilderParams.RepoRevision,
@@ -527,18 +558,18 @@ func (cbc *ciBuilderClientImpl) GetBuilderConfig(ciBuilderParams CiBuilderParams
 		}
 	}
 
-	localBuilderConfig.Manifest = &ciBuilderParams.Manifest
+	localBuilderConfig.Manifest = withGlobalEnvVars(ciBuilderParams.Manifest, ciBuilderParams.ExtraEnvironmentVariables)
 
 	localBuilderConfig.JobName = &jobName
 	localBuilderConfig.CIServer = &contracts.CIServerConfig{
-		BaseURL:          cbc.config.APIServer.BaseURL,
-		BuilderEventsURL: strings.TrimRight(cbc.config.APIServer.ServiceURL, "/") + "/api/commands",
-		PostLogsURL:      strings.TrimRight(cbc.config.APIServer.ServiceURL, "/") + fmt.Sprintf("/api/pipelines/%v/%v/%v/builds/%v/logs", ciBuilderParams.RepoSource, ciBuilderParams.RepoOwner, ciBuilderParams.RepoName, ciBuilderParams.BuildID),
-		APIKey:           cbc.config.Auth.APIKey,
+		BaseURL:          apiConfig.APIServer.BaseURL,
+		BuilderEventsURL: strings.TrimRight(apiConfig.APIServer.ServiceURL, "/") + "/api/commands",
+		PostLogsURL:      strings.TrimRight(apiConfig.APIServer.ServiceURL, "/") + fmt.Sprintf("/api/pipelines/%v/%v/%v/builds/%v/logs", ciBuilderParams.RepoSource, ciBuilderParams.RepoOwner, ciBuilderParams.RepoName, ciBuilderParams.BuildID),
+		APIKey:           apiConfig.Auth.APIKey,
 	}
 
 	if ciBuilderParams.ReleaseID > 0 {
-		localBuilderConfig.CIServer.PostLogsURL = strings.TrimRight(cbc.config.APIServer.ServiceURL, "/") + fmt.Sprintf("/api/pipelines/%v/%v/%v/releases/%v/logs", ciBuilderParams.RepoSource, ciBuilderParams.RepoOwner, ciBuilderParams.RepoName, ciBuilderParams.ReleaseID)
+		localBuilderConfig.CIServer.PostLogsURL = strings.TrimRight(apiConfig.APIServer.ServiceURL, "/") + fmt.Sprintf("/api/pipelines/%v/%v/%v/releases/%v/logs", ciBuilderParams.RepoSource, ciBuilderParams.RepoOwner, ciBuilderParams.RepoName, ciBuilderParams.ReleaseID)
 	}
 
 	if *localBuilderConfig.Action == "build" {
@@ -554,5 +585,88 @@ func (cbc *ciBuilderClientImpl) GetBuilderConfig(ciBuilderParams CiBuilderParams
 		}
 	}
 
-	return localBuilderConfig
+	// secrets in the manifest and credentials can be restricted to a single pipeline
+	builderConfigJSONBytes, err := json.Marshal(localBuilderConfig)
+	if err != nil {
+		return
+	}
+	pipeline := getPipeline(ciBuilderParams)
+	err = cbc.secretHelper.ValidateAllEnvelopesForPipeline(string(builderConfigJSONBytes), pipeline)
+	if err != nil {
+		return localBuilderConfig, fmt.Errorf("Builder config for pipeline %v has secrets it's not allowed to use: %v", pipeline, err)
+	}
+
+	return
+}
+
+// UpdateConfig swaps in a reloaded config; jobs created from then on use its credentials, trusted images and urls
+func (cbc *ciBuilderClientImpl) UpdateConfig(config config.APIConfig, encryptedConfig config.APIConfig) {
+	cbc.configMutex.Lock()
+	defer cbc.configMutex.Unlock()
+
+	cbc.config = config
+	cbc.encryptedConfig = encryptedConfig
+}
+
+func (cbc *ciBuilderClientImpl) getConfig() (config.APIConfig, config.APIConfig) {
+	cbc.configMutex.RLock()
+	defer cbc.configMutex.RUnlock()
+
+	return cbc.config, cbc.encryptedConfig
+}
+
+// withGlobalEnvVars returns a copy of the manifest with the environment variables added to its global env, which the builder already passes on to every stage; they take precedence over the ones in the manifest
+func withGlobalEnvVars(mft manifest.EstafetteManifest, envVars map[string]string) *manifest.EstafetteManifest {
+	if len(envVars) == 0 {
+		return &mft
+	}
+
+	globalEnvVars := map[string]string{}
+	for key, value := range mft.GlobalEnvVars {
+		globalEnvVars[key] = value
+	}
+	for key, value := range envVars {
+		globalEnvVars[key] = value
+	}
+	mft.GlobalEnvVars = globalEnvVars
+
+	return &mft
+}
+
+func getPipeline(ciBuilderParams CiBuilderParams) string {
+	return fmt.Sprintf("%v/%v/%v", ciBuilderParams.RepoSource, ciBuilderParams.RepoOwner, ciBuilderParams.RepoName)
+}
+
+// mergeCredentials adds the credentials managed through the api to the ones from the config file; on a name clash the config file takes precedence
+func mergeCredentials(configCredentials, managedCredentials []*contracts.CredentialConfig) []*contracts.CredentialConfig {
+
+	credentials := append([]*contracts.CredentialConfig{}, configCredentials...)
+	for _, mc := range managedCredentials {
+		nameExists := false
+		for _, cc := range configCredentials {
+			if cc.Name == mc.Name {
+				nameExists = true
+				break
+			}
+		}
+		if !nameExists {
+			credentials = append(credentials, mc)
+		}
+	}
+
+	return credentials
+}
+
+// mergeTrustedImages adds the trusted images managed through the api to the ones from the config file; on a path clash the config file takes precedence
+func mergeTrustedImages(configTrustedImages, managedTrustedImages []*contracts.TrustedImageConfig) []*contracts.TrustedImageConfig {
+
+	trustedImages := append([]*contracts.TrustedImageConfig{}, configTrustedImages...)
+	for _, mti := range managedTrustedImages {
+		if contracts.GetTrustedImage(configTrustedImages, mti.ImagePath) != nil {
+			continue
+		}
+		trustedImages = append(trustedImages, mti)
+	}
+
+	return trustedImages
 }
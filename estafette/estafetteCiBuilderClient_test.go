@@ -3,6 +3,8 @@ package estafette
 import (
 	"testing"
 
+	"github.com/estafette/estafette-ci-contracts"
+	"github.com/estafette/estafette-ci-manifest"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -52,3 +54,75 @@ func TestGetJobName(t *testing.T) {
 		assert.Equal(t, 63, len(jobName))
 	})
 }
+
+func TestMergeCredentials(t *testing.T) {
+
+	t.Run("AddsManagedCredentialsAfterConfigCredentials", func(t *testing.T) {
+
+		configCredentials := []*contracts.CredentialConfig{
+			&contracts.CredentialConfig{Name: "gke-production", Type: "kubernetes-engine"},
+		}
+		managedCredentials := []*contracts.CredentialConfig{
+			&contracts.CredentialConfig{Name: "gke-staging", Type: "kubernetes-engine"},
+		}
+
+		// act
+		credentials := mergeCredentials(configCredentials, managedCredentials)
+
+		assert.Equal(t, 2, len(credentials))
+		assert.Equal(t, "gke-production", credentials[0].Name)
+		assert.Equal(t, "gke-staging", credentials[1].Name)
+		assert.Equal(t, 1, len(configCredentials))
+	})
+
+	t.Run("KeepsConfigCredentialOnNameClash", func(t *testing.T) {
+
+		configCredentials := []*contracts.CredentialConfig{
+			&contracts.CredentialConfig{Name: "gke-production", Type: "kubernetes-engine", AdditionalProperties: map[string]interface{}{"project": "from-file"}},
+		}
+		managedCredentials := []*contracts.CredentialConfig{
+			&contracts.CredentialConfig{Name: "gke-production", Type: "kubernetes-engine", AdditionalProperties: map[string]interface{}{"project": "from-db"}},
+		}
+
+		// act
+		credentials := mergeCredentials(configCredentials, managedCredentials)
+
+		assert.Equal(t, 1, len(credentials))
+		assert.Equal(t, "from-file", credentials[0].AdditionalProperties["project"])
+	})
+}
+
+func TestMergeTrustedImages(t *testing.T) {
+
+	t.Run("KeepsConfigTrustedImageOnPathClash", func(t *testing.T) {
+
+		configTrustedImages := []*contracts.TrustedImageConfig{
+			&contracts.TrustedImageConfig{ImagePath: "extensions/docker", RunDocker: true},
+		}
+		managedTrustedImages := []*contracts.TrustedImageConfig{
+			&contracts.TrustedImageConfig{ImagePath: "extensions/docker", RunDocker: false},
+			&contracts.TrustedImageConfig{ImagePath: "extensions/gke", InjectedCredentialTypes: []string{"kubernetes-engine"}},
+		}
+
+		// act
+		trustedImages := mergeTrustedImages(configTrustedImages, managedTrustedImages)
+
+		assert.Equal(t, 2, len(trustedImages))
+		assert.True(t, trustedImages[0].RunDocker)
+		assert.Equal(t, "extensions/gke", trustedImages[1].ImagePath)
+	})
+}
+
+func TestWithGlobalEnvVars(t *testing.T) {
+
+	t.Run("AddsEnvVarsToCopyOfManifestTakingPrecedence", func(t *testing.T) {
+
+		mft := manifest.EstafetteManifest{GlobalEnvVars: map[string]string{"A": "1", "B": "2"}}
+
+		// act
+		result := withGlobalEnvVars(mft, map[string]string{"B": "3", "C": "4"})
+
+		assert.Equal(t, map[string]string{"A": "1", "B": "3", "C": "4"}, result.GlobalEnvVars)
+		assert.Equal(t, map[string]string{"A": "1", "B": "2"}, mft.GlobalEnvVars)
+	})
+}
@@ -34,6 +34,7 @@ type CiBuilderParams struct {
 	RepoURL              string
 	RepoBranch           string
 	RepoRevision         string
+	RepoTag              string
 	EnvironmentVariables map[string]string
 	Track                string
 	AutoIncrement        int
@@ -44,6 +45,56 @@ type CiBuilderParams struct {
 	ReleaseAction string
 	ReleaseID     int
 	BuildID       int
+
+	// ExtraEnvironmentVariables are added to the global env of the manifest, so the builder passes them to every stage; EnvironmentVariables only hold the git api tokens
+	ExtraEnvironmentVariables map[string]string
+}
+
+// BuildCommand is posted to re-run a failed version, or without version to build a branch or revision as a new version
+type BuildCommand struct {
+	contracts.Build
+	EnvironmentVariables map[string]string `json:"environmentVariables,omitempty"`
+}
+
+// TagTrigger opts a pipeline in to building pushed tags matching a glob like v*, defined in the triggers section of the manifest
+type TagTrigger struct {
+	Tag string `yaml:"tag"`
+}
+
+// PipelineTrigger subscribes a pipeline to builds or releases of another pipeline, defined in the triggers section of the manifest; when fired it builds the head of BuildBranch - defaulting to the manifest's release branch - or, if Release is set, releases the latest succeeded build of that branch
+type PipelineTrigger struct {
+	Pipeline      string `yaml:"pipeline" json:"pipeline"`
+	Event         string `yaml:"event" json:"event"`
+	Status        string `yaml:"status,omitempty" json:"status,omitempty"`
+	Branch        string `yaml:"branch,omitempty" json:"branch,omitempty"`
+	Target        string `yaml:"target,omitempty" json:"target,omitempty"`
+	Release       string `yaml:"release,omitempty" json:"release,omitempty"`
+	ReleaseAction string `yaml:"releaseAction,omitempty" json:"releaseAction,omitempty"`
+	BuildBranch   string `yaml:"buildBranch,omitempty" json:"buildBranch,omitempty"`
+}
+
+// TriggeredPipeline is a pipeline subscribed with a trigger to another pipeline
+type TriggeredPipeline struct {
+	Pipeline string          `json:"pipeline"`
+	Trigger  PipelineTrigger `json:"trigger"`
+}
+
+// TriggerEvent represents a cron or pipeline trigger firing a build of a pipeline
+type TriggerEvent struct {
+	RepoSource   string
+	RepoOwner    string
+	RepoName     string
+	RepoBranch   string
+	RepoRevision string
+	Reason       string
+
+	EnvironmentVariables map[string]string
+}
+
+// PathFilter limits builds to pushes that change files matching the include globs and not matching the exclude globs, defined in the paths section of the manifest
+type PathFilter struct {
+	Include []string `yaml:"include,omitempty" json:"include,omitempty"`
+	Exclude []string `yaml:"exclude,omitempty" json:"exclude,omitempty"`
 }
 
 type zeroLogLine struct {
@@ -19,11 +19,13 @@ type eventDispatcherImpl struct {
 	maxWorkers             int
 	ciBuilderClient        CiBuilderClient
 	cockroachDBClient      cockroach.DBClient
+	pipelineTriggerHelper  PipelineTriggerHelper
+	buildStatusHelper      BuildStatusHelper
 	ciBuilderEventsChannel chan CiBuilderEvent
 }
 
 // NewEstafetteDispatcher returns a new estafette.EventWorker to handle events channeled by estafette.EventDispatcher
-func NewEstafetteDispatcher(stopChannel <-chan struct{}, waitGroup *sync.WaitGroup, maxWorkers int, ciBuilderClient CiBuilderClient, cockroachDBClient cockroach.DBClient, ciBuilderEventsChannel chan CiBuilderEvent) EventDispatcher {
+func NewEstafetteDispatcher(stopChannel <-chan struct{}, waitGroup *sync.WaitGroup, maxWorkers int, ciBuilderClient CiBuilderClient, cockroachDBClient cockroach.DBClient, pipelineTriggerHelper PipelineTriggerHelper, buildStatusHelper BuildStatusHelper, ciBuilderEventsChannel chan CiBuilderEvent) EventDispatcher {
 	return &eventDispatcherImpl{
 		waitGroup:              waitGroup,
 		stopChannel:            stopChannel,
@@ -31,6 +33,8 @@ func NewEstafetteDispatcher(stopChannel <-chan struct{}, waitGroup *sync.WaitGro
 		maxWorkers:             maxWorkers,
 		ciBuilderClient:        ciBuilderClient,
 		cockroachDBClient:      cockroachDBClient,
+		pipelineTriggerHelper:  pipelineTriggerHelper,
+		buildStatusHelper:      buildStatusHelper,
 		ciBuilderEventsChannel: ciBuilderEventsChannel,
 	}
 }
@@ -39,7 +43,7 @@ func NewEstafetteDispatcher(stopChannel <-chan struct{}, waitGroup *sync.WaitGro
 func (d *eventDispatcherImpl) Run() {
 	// starting n number of workers
 	for i := 0; i < d.maxWorkers; i++ {
-		worker := NewEstafetteEventWorker(d.stopChannel, d.waitGroup, d.ciBuilderWorkerPool, d.ciBuilderClient, d.cockroachDBClient)
+		worker := NewEstafetteEventWorker(d.stopChannel, d.waitGroup, d.ciBuilderWorkerPool, d.ciBuilderClient, d.cockroachDBClient, d.pipelineTriggerHelper, d.buildStatusHelper)
 		worker.ListenToCiBuilderEventChannels()
 	}
 
@@ -22,17 +22,21 @@ type eventWorkerImpl struct {
 	ciBuilderWorkerPool    chan chan CiBuilderEvent
 	ciBuilderClient        CiBuilderClient
 	cockroachDBClient      cockroach.DBClient
+	pipelineTriggerHelper  PipelineTriggerHelper
+	buildStatusHelper      BuildStatusHelper
 	ciBuilderEventsChannel chan CiBuilderEvent
 }
 
 // NewEstafetteEventWorker returns a new estafette.EventWorker
-func NewEstafetteEventWorker(stopChannel <-chan struct{}, waitGroup *sync.WaitGroup, ciBuilderWorkerPool chan chan CiBuilderEvent, ciBuilderClient CiBuilderClient, cockroachDBClient cockroach.DBClient) EventWorker {
+func NewEstafetteEventWorker(stopChannel <-chan struct{}, waitGroup *sync.WaitGroup, ciBuilderWorkerPool chan chan CiBuilderEvent, ciBuilderClient CiBuilderClient, cockroachDBClient cockroach.DBClient, pipelineTriggerHelper PipelineTriggerHelper, buildStatusHelper BuildStatusHelper) EventWorker {
 	return &eventWorkerImpl{
 		waitGroup:              waitGroup,
 		stopChannel:            stopChannel,
 		ciBuilderWorkerPool:    ciBuilderWorkerPool,
 		ciBuilderClient:        ciBuilderClient,
 		cockroachDBClient:      cockroachDBClient,
+		pipelineTriggerHelper:  pipelineTriggerHelper,
+		buildStatusHelper:      buildStatusHelper,
 		ciBuilderEventsChannel: make(chan CiBuilderEvent),
 	}
 }
@@ -93,7 +97,16 @@ func (w *eventWorkerImpl) UpdateBuildStatus(ciBuilderEvent CiBuilderEvent) (err
 
 		log.Debug().Msgf("Updated release status for job %v to %v", ciBuilderEvent.JobName, ciBuilderEvent.BuildStatus)
 
-		return err
+		// start builds or releases of pipelines subscribed to this release
+		release, err := w.cockroachDBClient.GetPipelineRelease(ciBuilderEvent.RepoSource, ciBuilderEvent.RepoOwner, ciBuilderEvent.RepoName, releaseID)
+		if err != nil {
+			log.Error().Err(err).Msgf("Failed retrieving release %v for firing pipeline triggers", releaseID)
+		} else if release != nil {
+			w.goTracked(func() { w.pipelineTriggerHelper.FireReleaseTriggers(*release) })
+			w.goTracked(func() { w.buildStatusHelper.ReportReleaseStatus(*release, nil) })
+		}
+
+		return nil
 
 	} else if ciBuilderEvent.BuildStatus != "" && ciBuilderEvent.BuildID != "" {
 
@@ -111,8 +124,27 @@ func (w *eventWorkerImpl) UpdateBuildStatus(ciBuilderEvent CiBuilderEvent) (err
 
 		log.Debug().Msgf("Updated build status for job %v to %v", ciBuilderEvent.JobName, ciBuilderEvent.BuildStatus)
 
-		return err
+		// start builds or releases of pipelines subscribed to this build and releases requested in its commit messages
+		build, err := w.cockroachDBClient.GetPipelineBuildByID(ciBuilderEvent.RepoSource, ciBuilderEvent.RepoOwner, ciBuilderEvent.RepoName, buildID, true)
+		if err != nil {
+			log.Error().Err(err).Msgf("Failed retrieving build %v for firing pipeline triggers", buildID)
+		} else if build != nil {
+			w.goTracked(func() { w.pipelineTriggerHelper.FireBuildTriggers(*build) })
+			w.goTracked(func() { w.pipelineTriggerHelper.FireReleaseDirectives(*build) })
+			w.goTracked(func() { w.buildStatusHelper.ReportBuildStatus(*build, nil) })
+		}
+
+		return nil
 	}
 
 	return fmt.Errorf("CiBuilderEvent has invalid state, not updating build status")
 }
+
+// goTracked runs the function in a goroutine the wait group waits for on shutdown, so triggers and status reports aren't cut off halfway
+func (w *eventWorkerImpl) goTracked(f func()) {
+	w.waitGroup.Add(1)
+	go func() {
+		defer w.waitGroup.Done()
+		f()
+	}()
+}
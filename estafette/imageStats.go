@@ -0,0 +1,152 @@
+package estafette
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/estafette/estafette-ci-api/cockroach"
+)
+
+// ImageStats lists the pipelines whose latest manifest uses an image with a specific tag in a build or release stage
+type ImageStats struct {
+	Image     string   `json:"image"`
+	Tag       string   `json:"tag"`
+	Pinned    bool     `json:"pinned"`
+	Count     int      `json:"count"`
+	Pipelines []string `json:"pipelines"`
+}
+
+// floatingImageTags are tags that move to another image over time, so stages using them aren't pinned
+var floatingImageTags = []string{"", "latest", "stable", "beta", "dev"}
+
+// imageStatsCacheDuration is how long the images used by the pipelines are kept, so the stats don't read every manifest on each request
+const imageStatsCacheDuration = 5 * time.Minute
+
+// pipelineImageUsage holds the images used by the build and release stages of a pipeline's latest manifest, without the manifest itself
+type pipelineImageUsage struct {
+	pipeline string
+	images   []imagePathAndTag
+}
+
+type imagePathAndTag struct {
+	path string
+	tag  string
+}
+
+type imageStatsCacheEntry struct {
+	pipelineImages []pipelineImageUsage
+	expiresAt      time.Time
+}
+
+// getPipelineImages returns the images used by the stages of each pipeline's latest manifest; pipelines without a readable manifest are left out
+func getPipelineImages(pipelines []*cockroach.Pipeline) []pipelineImageUsage {
+
+	result := []pipelineImageUsage{}
+	for _, pipeline := range pipelines {
+		if pipeline.ManifestObject == nil {
+			continue
+		}
+
+		pi := pipelineImageUsage{pipeline: fmt.Sprintf("%v/%v/%v", pipeline.RepoSource, pipeline.RepoOwner, pipeline.RepoName)}
+		for _, s := range (manifestRuleContext{manifest: pipeline.ManifestObject}).getStages() {
+			path, tag := getImagePathAndTag(s.stage.ContainerImage)
+			pi.images = append(pi.images, imagePathAndTag{path: path, tag: tag})
+		}
+		result = append(result, pi)
+	}
+
+	return result
+}
+
+// getImageStats aggregates the images of the pipelines per image and tag and counts the pipelines using any matching image; images and tags can be filtered on, where a tag filter like 1.10 also matches 1.10.3 and 1.10-alpine3.8, and on whether they're pinned ("true" or "false", empty for both)
+func getImageStats(pipelines []pipelineImageUsage, imageFilter, tagFilter []string, pinnedFilter string) (stats []*ImageStats, pipelinesCount int) {
+
+	stats = []*ImageStats{}
+	statsByImage := map[string]*ImageStats{}
+
+	for _, pipeline := range pipelines {
+
+		matches := false
+		for _, image := range pipeline.images {
+
+			pinned := !stringArrayContains(floatingImageTags, image.tag)
+			if !matchesImageFilter(image.path, imageFilter) || !matchesTagFilter(image.tag, tagFilter) || (pinnedFilter != "" && pinnedFilter != fmt.Sprint(pinned)) {
+				continue
+			}
+			matches = true
+
+			key := fmt.Sprintf("%v:%v", image.path, image.tag)
+			imageStats, ok := statsByImage[key]
+			if !ok {
+				imageStats = &ImageStats{Image: image.path, Tag: image.tag, Pinned: pinned, Pipelines: []string{}}
+				statsByImage[key] = imageStats
+				stats = append(stats, imageStats)
+			}
+
+			// a pipeline using the same image in multiple stages counts once
+			if !stringArrayContains(imageStats.Pipelines, pipeline.pipeline) {
+				imageStats.Pipelines = append(imageStats.Pipelines, pipeline.pipeline)
+				imageStats.Count++
+			}
+		}
+		if matches {
+			pipelinesCount++
+		}
+	}
+
+	// most used first
+	sort.SliceStable(stats, func(i, j int) bool {
+		if stats[i].Count != stats[j].Count {
+			return stats[i].Count > stats[j].Count
+		}
+		if stats[i].Image != stats[j].Image {
+			return stats[i].Image < stats[j].Image
+		}
+		return stats[i].Tag < stats[j].Tag
+	})
+
+	return
+}
+
+// getImagePathAndTag splits a container image like gcr.io/my-project/my-image:1.0.0 into its path and tag; the colon of a registry port isn't mistaken for the start of the tag and a digest like @sha256:... is kept with the tag, or is the tag if there's no other
+func getImagePathAndTag(containerImage string) (path, tag string) {
+
+	path = containerImage
+	digest := ""
+	if index := strings.Index(path, "@"); index >= 0 {
+		path, digest = path[:index], path[index+1:]
+	}
+	if index := strings.LastIndex(path, ":"); index > strings.LastIndex(path, "/") {
+		path, tag = path[:index], path[index+1:]
+	}
+
+	switch {
+	case digest != "" && tag != "":
+		tag = tag + "@" + digest
+	case digest != "":
+		tag = digest
+	}
+
+	return
+}
+
+func matchesImageFilter(image string, imageFilter []string) bool {
+	if len(imageFilter) == 0 {
+		return true
+	}
+	return stringArrayContains(imageFilter, image)
+}
+
+func matchesTagFilter(tag string, tagFilter []string) bool {
+	if len(tagFilter) == 0 {
+		return true
+	}
+	for _, f := range tagFilter {
+		if tag == f || strings.HasPrefix(tag, f+".") || strings.HasPrefix(tag, f+"-") {
+			return true
+		}
+	}
+	return false
+}
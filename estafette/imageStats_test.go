@@ -0,0 +1,153 @@
+package estafette
+
+import (
+	"testing"
+
+	"github.com/estafette/estafette-ci-api/cockroach"
+	"github.com/estafette/estafette-ci-contracts"
+	"github.com/estafette/estafette-ci-manifest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetImageStats(t *testing.T) {
+
+	pipelines := []*cockroach.Pipeline{
+		&cockroach.Pipeline{
+			Pipeline: contracts.Pipeline{
+				RepoSource: "github.com",
+				RepoOwner:  "estafette",
+				RepoName:   "estafette-ci-api",
+				ManifestObject: &manifest.EstafetteManifest{
+					Stages: []*manifest.EstafetteStage{
+						&manifest.EstafetteStage{Name: "build", ContainerImage: "golang:1.10.3-alpine3.8"},
+						&manifest.EstafetteStage{Name: "test", ContainerImage: "golang:1.10.3-alpine3.8"},
+					},
+					Releases: []*manifest.EstafetteRelease{
+						&manifest.EstafetteRelease{
+							Name: "production",
+							Stages: []*manifest.EstafetteStage{
+								&manifest.EstafetteStage{Name: "deploy", ContainerImage: "extensions/gke:dev"},
+							},
+						},
+					},
+				},
+			},
+		},
+		&cockroach.Pipeline{
+			Pipeline: contracts.Pipeline{
+				RepoSource: "github.com",
+				RepoOwner:  "estafette",
+				RepoName:   "estafette-ci-web",
+				ManifestObject: &manifest.EstafetteManifest{
+					Stages: []*manifest.EstafetteStage{
+						&manifest.EstafetteStage{Name: "build", ContainerImage: "golang:1.11.2-alpine3.8"},
+						&manifest.EstafetteStage{Name: "bake", ContainerImage: "extensions/docker:stable"},
+					},
+					Releases: []*manifest.EstafetteRelease{
+						&manifest.EstafetteRelease{
+							Name: "production",
+							Stages: []*manifest.EstafetteStage{
+								&manifest.EstafetteStage{Name: "deploy", ContainerImage: "extensions/gke:dev"},
+							},
+						},
+					},
+				},
+			},
+		},
+		&cockroach.Pipeline{
+			Pipeline: contracts.Pipeline{
+				RepoSource: "github.com",
+				RepoOwner:  "estafette",
+				RepoName:   "estafette-ci-broken",
+			},
+		},
+	}
+
+	t.Run("ReturnsStatsPerImageAndTagMostUsedFirstCountingPipelinesOnce", func(t *testing.T) {
+
+		// act
+		stats, pipelinesCount := getImageStats(getPipelineImages(pipelines), []string{}, []string{}, "")
+
+		assert.Equal(t, 2, pipelinesCount)
+		assert.Equal(t, 4, len(stats))
+		assert.Equal(t, "extensions/gke", stats[0].Image)
+		assert.Equal(t, "dev", stats[0].Tag)
+		assert.False(t, stats[0].Pinned)
+		assert.Equal(t, 2, stats[0].Count)
+		assert.Equal(t, []string{"github.com/estafette/estafette-ci-api", "github.com/estafette/estafette-ci-web"}, stats[0].Pipelines)
+		assert.Equal(t, "extensions/docker", stats[1].Image)
+		assert.Equal(t, "golang", stats[2].Image)
+		assert.Equal(t, "1.10.3-alpine3.8", stats[2].Tag)
+		assert.True(t, stats[2].Pinned)
+		assert.Equal(t, 1, stats[2].Count)
+	})
+
+	t.Run("ReturnsStatsForImageAndTagPrefix", func(t *testing.T) {
+
+		// act
+		stats, pipelinesCount := getImageStats(getPipelineImages(pipelines), []string{"golang"}, []string{"1.10"}, "")
+
+		assert.Equal(t, 1, pipelinesCount)
+		assert.Equal(t, 1, len(stats))
+		assert.Equal(t, "1.10.3-alpine3.8", stats[0].Tag)
+		assert.Equal(t, []string{"github.com/estafette/estafette-ci-api"}, stats[0].Pipelines)
+	})
+
+	t.Run("ReturnsOnlyUnpinnedImages", func(t *testing.T) {
+
+		// act
+		stats, _ := getImageStats(getPipelineImages(pipelines), []string{}, []string{}, "false")
+
+		assert.Equal(t, 2, len(stats))
+		assert.Equal(t, "extensions/gke", stats[0].Image)
+		assert.Equal(t, "extensions/docker", stats[1].Image)
+	})
+}
+
+func TestGetImagePathAndTag(t *testing.T) {
+
+	t.Run("ReturnsPathAndTag", func(t *testing.T) {
+
+		// act
+		path, tag := getImagePathAndTag("extensions/gke:dev")
+
+		assert.Equal(t, "extensions/gke", path)
+		assert.Equal(t, "dev", tag)
+	})
+
+	t.Run("ReturnsEmptyTagForImageWithoutTag", func(t *testing.T) {
+
+		// act
+		path, tag := getImagePathAndTag("golang")
+
+		assert.Equal(t, "golang", path)
+		assert.Equal(t, "", tag)
+	})
+
+	t.Run("ReturnsDigestAsTagForImageWithoutTag", func(t *testing.T) {
+
+		// act
+		path, tag := getImagePathAndTag("gcr.io/my-project/my-image@sha256:5b2ef5c8a7e1")
+
+		assert.Equal(t, "gcr.io/my-project/my-image", path)
+		assert.Equal(t, "sha256:5b2ef5c8a7e1", tag)
+	})
+
+	t.Run("ReturnsTagWithDigestForImageWithTagAndDigest", func(t *testing.T) {
+
+		// act
+		path, tag := getImagePathAndTag("registry.example.com:5000/my-image:1.0.0@sha256:5b2ef5c8a7e1")
+
+		assert.Equal(t, "registry.example.com:5000/my-image", path)
+		assert.Equal(t, "1.0.0@sha256:5b2ef5c8a7e1", tag)
+	})
+
+	t.Run("DoesNotMistakeRegistryPortForTag", func(t *testing.T) {
+
+		// act
+		path, tag := getImagePathAndTag("registry.example.com:5000/my-image")
+
+		assert.Equal(t, "registry.example.com:5000/my-image", path)
+		assert.Equal(t, "", tag)
+	})
+}
@@ -0,0 +1,333 @@
+package estafette
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/estafette/estafette-ci-contracts"
+	manifest "github.com/estafette/estafette-ci-manifest"
+)
+
+// ManifestRule is a lint rule for manifests; rules can be enabled and disabled per repository owner in the manifestRules config
+type ManifestRule struct {
+	ID               string `json:"id"`
+	Severity         string `json:"severity"`
+	DocsURL          string `json:"docsURL"`
+	Description      string `json:"description"`
+	EnabledByDefault bool   `json:"enabledByDefault"`
+
+	// check returns each violation of the rule
+	check func(manifestRuleContext) []manifestRuleViolation
+}
+
+// ManifestLintResult is a violation of a manifest rule
+type ManifestLintResult struct {
+	RuleID   string   `json:"ruleId"`
+	Severity string   `json:"severity"`
+	DocsURL  string   `json:"docsURL"`
+	Message  string   `json:"message"`
+	Path     []string `json:"path,omitempty"`
+}
+
+// manifestRuleViolation is a message with the yaml path of the part of the manifest it's about
+type manifestRuleViolation struct {
+	path    []string
+	message string
+}
+
+type manifestRuleContext struct {
+	manifest      *manifest.EstafetteManifest
+	gitOwner      string
+	trustedImages []*contracts.TrustedImageConfig
+}
+
+// manifestStage is a build stage or a stage of a release, with the name it's reported by and its yaml path
+type manifestStage struct {
+	name    string
+	path    []string
+	release *manifest.EstafetteRelease
+	stage   *manifest.EstafetteStage
+}
+
+// pathTo returns the yaml path of a property of the stage
+func (s manifestStage) pathTo(keys ...string) []string {
+	return append(append([]string{}, s.path...), keys...)
+}
+
+func (c manifestRuleContext) getStages() (stages []manifestStage) {
+	for _, s := range c.manifest.Stages {
+		stages = append(stages, manifestStage{name: s.Name, path: []string{"stages", s.Name}, stage: s})
+	}
+	for _, r := range c.manifest.Releases {
+		for _, s := range r.Stages {
+			stages = append(stages, manifestStage{name: fmt.Sprintf("%v/%v", r.Name, s.Name), path: []string{"releases", r.Name, "stages", s.Name}, release: r, stage: s})
+		}
+	}
+	return
+}
+
+var (
+	notificationExtensions = []string{"extensions/slack-build-status", "extensions/github-status", "extensions/bitbucket-status", "extensions/email-build-status"}
+	plaintextSecretRegex   = regexp.MustCompile(`(?i)(password|passwd|secret|token|apikey|api_key|privatekey|private_key)`)
+	maxRetries             = 3
+
+	// dockerUsageRegex matches docker anywhere in a command line - also after cd, sudo or && - and use of the docker socket
+	dockerUsageRegex = regexp.MustCompile(`(^|[\s;&|(\x60])(sudo\s+)?(docker|docker-compose|dockerd)(\s|$)|docker\.sock|DOCKER_HOST`)
+	// privilegedUsageRegex matches commands that only work in a privileged container
+	privilegedUsageRegex = regexp.MustCompile(`(^|[\s;&|(\x60])(sudo\s+)?(mount|umount|modprobe|insmod|iptables|ip6tables|losetup|sysctl\s+-w)(\s|$)`)
+)
+
+// manifestRules is the registry of all lint rules; add a rule here to have it checked for every manifest
+var manifestRules = []ManifestRule{
+	ManifestRule{
+		ID:               "image-tag-latest",
+		Severity:         "warning",
+		DocsURL:          "https://estafette.io/usage/best-practices/#pin-image-versions",
+		Description:      "Stage images should be pinned to a version instead of using the latest tag or no tag",
+		EnabledByDefault: true,
+		check: func(c manifestRuleContext) []manifestRuleViolation {
+			stagesUsingLatestTag := []string{}
+			var path []string
+			for _, s := range c.getStages() {
+				if _, _, tag := getContainerImageParts(s.stage.ContainerImage); tag == "latest" {
+					stagesUsingLatestTag = append(stagesUsingLatestTag, s.name)
+					if path == nil {
+						path = s.pathTo("image")
+					}
+				}
+			}
+			if len(stagesUsingLatestTag) == 0 {
+				return nil
+			}
+			return []manifestRuleViolation{{path, fmt.Sprintf("This pipeline has one or more stages that use **latest** or no tag for its container image: `%v`; it is [best practice](https://estafette.io/usage/best-practices/#pin-image-versions) to pin stage images to specific versions so you don't spend hours tracking down build failures because the used image has changed.", strings.Join(stagesUsingLatestTag, ", "))}}
+		},
+	},
+	ManifestRule{
+		ID:               "image-tag-dev",
+		Severity:         "warning",
+		DocsURL:          "https://estafette.io/usage/best-practices/#avoid-using-estafette-s-dev-or-beta-tags",
+		Description:      "Extensions shouldn't use the dev tag, except in estafette's own pipelines",
+		EnabledByDefault: true,
+		check: func(c manifestRuleContext) []manifestRuleViolation {
+			if c.gitOwner == "estafette" {
+				return nil
+			}
+			stagesUsingDevTag := []string{}
+			var path []string
+			for _, s := range c.getStages() {
+				if repo, _, tag := getContainerImageParts(s.stage.ContainerImage); tag == "dev" && repo == "extensions" {
+					stagesUsingDevTag = append(stagesUsingDevTag, s.name)
+					if path == nil {
+						path = s.pathTo("image")
+					}
+				}
+			}
+			if len(stagesUsingDevTag) == 0 {
+				return nil
+			}
+			return []manifestRuleViolation{{path, fmt.Sprintf("This pipeline has one or more stages that use the **dev** tag for its container image: `%v`; it is [best practice](https://estafette.io/usage/best-practices/#avoid-using-estafette-s-dev-or-beta-tags) to avoid the dev tag alltogether, since it can be broken at any time.", strings.Join(stagesUsingDevTag, ", "))}}
+		},
+	},
+	ManifestRule{
+		ID:               "notification-without-when",
+		Severity:         "warning",
+		DocsURL:          "https://estafette.io/usage/manifest/#when",
+		Description:      "Notification stages should have a when condition, otherwise they only run for succeeded builds and releases",
+		EnabledByDefault: true,
+		check: func(c manifestRuleContext) (violations []manifestRuleViolation) {
+			for _, s := range c.getStages() {
+				if !stringArrayContains(notificationExtensions, getImagePath(s.stage.ContainerImage)) {
+					continue
+				}
+				if s.stage.When == "" || s.stage.When == "status == 'succeeded'" {
+					violations = append(violations, manifestRuleViolation{s.path, fmt.Sprintf("Stage `%v` sends notifications but has no when condition, so it's skipped when the pipeline fails; add `when: status == 'succeeded' || status == 'failed'` to be notified of failures as well.", s.name)})
+				}
+			}
+			return
+		},
+	},
+	ManifestRule{
+		ID:               "release-without-clone",
+		Severity:         "warning",
+		DocsURL:          "https://estafette.io/usage/manifest/#releases",
+		Description:      "Releases with stages that need files from the repository should set clone: true",
+		EnabledByDefault: true,
+		check: func(c manifestRuleContext) (violations []manifestRuleViolation) {
+			for _, s := range c.getStages() {
+				if s.release == nil || s.release.CloneRepository || !isGitDependentStage(s.stage) {
+					continue
+				}
+				violations = append(violations, manifestRuleViolation{[]string{"releases", s.release.Name}, fmt.Sprintf("Stage `%v` needs files from the repository, but release `%v` doesn't clone it; set `clone: true` on the release.", s.name, s.release.Name)})
+			}
+			return
+		},
+	},
+	ManifestRule{
+		ID:               "untrusted-image-runs-docker",
+		Severity:         "error",
+		DocsURL:          "https://estafette.io/usage/manifest/#build-stages",
+		Description:      "Only trusted images with runDocker get access to docker and only ones with runPrivileged run privileged, so stages needing either fail in other images",
+		EnabledByDefault: true,
+		check: func(c manifestRuleContext) (violations []manifestRuleViolation) {
+			for _, s := range c.getStages() {
+				trustedImage := contracts.GetTrustedImage(c.trustedImages, s.stage.ContainerImage)
+				if usesDocker(s.stage) && (trustedImage == nil || !trustedImage.RunDocker) {
+					violations = append(violations, manifestRuleViolation{s.pathTo("commands"), fmt.Sprintf("Stage `%v` uses docker in image `%v`, which isn't a trusted image allowed to run docker; use the extensions/docker extension instead.", s.name, s.stage.ContainerImage)})
+				}
+				if usesPrivilegedMode(s.stage) && (trustedImage == nil || !trustedImage.RunPrivileged) {
+					violations = append(violations, manifestRuleViolation{s.pathTo("commands"), fmt.Sprintf("Stage `%v` runs commands that need a privileged container in image `%v`, which isn't a trusted image allowed to run privileged.", s.name, s.stage.ContainerImage)})
+				}
+			}
+			return
+		},
+	},
+	ManifestRule{
+		ID:               "missing-labels",
+		Severity:         "info",
+		DocsURL:          "https://estafette.io/usage/manifest/#labels",
+		Description:      "Manifests should have team and app labels, to filter pipelines by",
+		EnabledByDefault: false,
+		check: func(c manifestRuleContext) (violations []manifestRuleViolation) {
+			for _, label := range []string{"team", "app"} {
+				if c.manifest.Labels[label] == "" {
+					violations = append(violations, manifestRuleViolation{[]string{"labels"}, fmt.Sprintf("This pipeline has no `%v` label.", label)})
+				}
+			}
+			return
+		},
+	},
+	ManifestRule{
+		ID:               "too-many-retries",
+		Severity:         "warning",
+		DocsURL:          "https://estafette.io/usage/manifest/#retries",
+		Description:      fmt.Sprintf("Stages shouldn't be retried more than %v times, since that mostly hides flaky steps and keeps builders busy", maxRetries),
+		EnabledByDefault: true,
+		check: func(c manifestRuleContext) (violations []manifestRuleViolation) {
+			for _, s := range c.getStages() {
+				if s.stage.Retries > maxRetries {
+					violations = append(violations, manifestRuleViolation{s.pathTo("retries"), fmt.Sprintf("Stage `%v` is retried %v times; retry it at most %v times and fix what makes it flaky.", s.name, s.stage.Retries, maxRetries)})
+				}
+			}
+			return
+		},
+	},
+	ManifestRule{
+		ID:               "plaintext-secret-env",
+		Severity:         "error",
+		DocsURL:          "https://estafette.io/usage/manifest/#secrets",
+		Description:      "Environment variables that look like secrets should hold an encrypted estafette.secret(...) value",
+		EnabledByDefault: true,
+		check: func(c manifestRuleContext) (violations []manifestRuleViolation) {
+			for _, name := range getPlaintextSecretEnvVars(c.manifest.GlobalEnvVars) {
+				violations = append(violations, manifestRuleViolation{[]string{"env", name}, fmt.Sprintf("Global environment variable `%v` looks like a secret, but isn't encrypted; encrypt its value so it doesn't end up in the repository in plain text.", name)})
+			}
+			for _, s := range c.getStages() {
+				for _, name := range getPlaintextSecretEnvVars(s.stage.EnvVars) {
+					violations = append(violations, manifestRuleViolation{s.pathTo("env", name), fmt.Sprintf("Environment variable `%v` of stage `%v` looks like a secret, but isn't encrypted; encrypt its value so it doesn't end up in the repository in plain text.", name, s.name)})
+				}
+			}
+			return
+		},
+	},
+}
+
+// lintManifest returns the violations of all rules enabled for the owner, in the order of the registry
+func lintManifest(rules []ManifestRule, isEnabled func(ManifestRule) bool, c manifestRuleContext) (results []ManifestLintResult) {
+
+	results = []ManifestLintResult{}
+	if c.manifest == nil {
+		return
+	}
+
+	for _, rule := range rules {
+		if !isEnabled(rule) {
+			continue
+		}
+		for _, violation := range rule.check(c) {
+			results = append(results, ManifestLintResult{
+				RuleID:   rule.ID,
+				Severity: rule.Severity,
+				DocsURL:  rule.DocsURL,
+				Message:  violation.message,
+				Path:     violation.path,
+			})
+		}
+	}
+
+	return
+}
+
+func getContainerImageParts(containerImage string) (repo, name, tag string) {
+	containerImageArray := strings.Split(containerImage, ":")
+	tag = "latest"
+	if len(containerImageArray) > 1 {
+		tag = containerImageArray[1]
+		containerImageArray = strings.Split(containerImageArray[0], "/")
+		if len(containerImageArray) > 0 {
+			name = containerImageArray[len(containerImageArray)-1]
+			repo = strings.Join(containerImageArray[:len(containerImageArray)-1], "/")
+		}
+	}
+
+	return
+}
+
+func getImagePath(containerImage string) string {
+	return strings.Split(containerImage, ":")[0]
+}
+
+// isGitDependentStage returns true for extensions that read files from the repository
+func isGitDependentStage(stage *manifest.EstafetteStage) bool {
+	switch getImagePath(stage.ContainerImage) {
+	case "extensions/docker":
+		return stage.CustomProperties["action"] == "build"
+	case "extensions/helm":
+		return true
+	case "extensions/gke":
+		_, hasManifests := stage.CustomProperties["manifests"]
+		return hasManifests
+	}
+	return false
+}
+
+// usesDocker returns true if any command of the stage uses docker or its socket, or an environment variable points at a docker daemon
+func usesDocker(stage *manifest.EstafetteStage) bool {
+	for _, command := range stage.Commands {
+		if dockerUsageRegex.MatchString(command) {
+			return true
+		}
+	}
+	for name, value := range stage.EnvVars {
+		if name == "DOCKER_HOST" || strings.Contains(value, "docker.sock") {
+			return true
+		}
+	}
+	return false
+}
+
+// usesPrivilegedMode returns true if any command of the stage needs a privileged container
+func usesPrivilegedMode(stage *manifest.EstafetteStage) bool {
+	for _, command := range stage.Commands {
+		if privilegedUsageRegex.MatchString(command) {
+			return true
+		}
+	}
+	return false
+}
+
+func getPlaintextSecretEnvVars(envVars map[string]string) (names []string) {
+	for name, value := range envVars {
+		if !plaintextSecretRegex.MatchString(name) || value == "" {
+			continue
+		}
+		// encrypted values and references to other variables are fine
+		if strings.Contains(value, "estafette.secret(") || strings.HasPrefix(value, "$") {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
@@ -0,0 +1,244 @@
+package estafette
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/estafette/estafette-ci-api/cockroach"
+	manifest "github.com/estafette/estafette-ci-manifest"
+	"github.com/rs/zerolog/log"
+)
+
+// manifestTemplateSummary is how GET /api/manifest/templates has always listed a template, by name with the names of its placeholders
+type manifestTemplateSummary struct {
+	Template     string   `json:"template"`
+	Placeholders []string `json:"placeholders"`
+}
+
+var (
+	manifestTemplateFileRegex        = regexp.MustCompile(`^manifest-(.+)\.tmpl$`)
+	manifestTemplatePlaceholderRegex = regexp.MustCompile(`{{\.([a-zA-Z0-9]+)}}`)
+	manifestTemplateNameRegex        = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+	manifestTemplatePlaceholderTypes = []string{"string", "int", "bool"}
+)
+
+// validateManifestTemplate returns an error if the template doesn't parse, uses placeholders it doesn't declare or declares placeholders with an unknown type, an invalid regex or a default that isn't valid itself
+func validateManifestTemplate(manifestTemplate cockroach.ManifestTemplate) error {
+
+	if !manifestTemplateNameRegex.MatchString(manifestTemplate.Name) {
+		return fmt.Errorf("Template name %v should only contain lowercase letters, digits and dashes", manifestTemplate.Name)
+	}
+
+	if _, err := template.New(".estafette.yaml").Parse(manifestTemplate.Template); err != nil {
+		return fmt.Errorf("Template %v doesn't parse: %v", manifestTemplate.Name, err)
+	}
+
+	declared := map[string]bool{}
+	for _, placeholder := range manifestTemplate.Placeholders {
+		if placeholder.Name == "" {
+			return fmt.Errorf("Template %v has a placeholder without name", manifestTemplate.Name)
+		}
+		if declared[placeholder.Name] {
+			return fmt.Errorf("Template %v declares placeholder %v more than once", manifestTemplate.Name, placeholder.Name)
+		}
+		declared[placeholder.Name] = true
+
+		if !stringArrayContains(manifestTemplatePlaceholderTypes, placeholder.Type) {
+			return fmt.Errorf("Placeholder %v has type %v, it should be one of %v", placeholder.Name, placeholder.Type, strings.Join(manifestTemplatePlaceholderTypes, ", "))
+		}
+		if placeholder.ValidationRegex != "" {
+			if _, err := regexp.Compile(placeholder.ValidationRegex); err != nil {
+				return fmt.Errorf("Placeholder %v has an invalid validation regex: %v", placeholder.Name, err)
+			}
+		}
+		if placeholder.Default != "" {
+			if err := validatePlaceholderValue(placeholder, placeholder.Default); err != nil {
+				return fmt.Errorf("Default of placeholder %v is invalid: %v", placeholder.Name, err)
+			}
+		}
+	}
+
+	for _, name := range getTemplatePlaceholderNames(manifestTemplate.Template) {
+		if !declared[name] {
+			return fmt.Errorf("Template %v uses placeholder %v without declaring it", manifestTemplate.Name, name)
+		}
+	}
+
+	return nil
+}
+
+// renderManifestTemplate fills in the placeholders of the template - with their defaults where no value is given - and validates the result as a manifest
+func renderManifestTemplate(manifestTemplate cockroach.ManifestTemplate, values map[string]string) (string, error) {
+
+	data := map[string]interface{}{}
+	for _, placeholder := range manifestTemplate.Placeholders {
+
+		value, ok := values[placeholder.Name]
+		if !ok || value == "" {
+			value = placeholder.Default
+		}
+		if value == "" {
+			return "", fmt.Errorf("Placeholder %v needs a value", placeholder.Name)
+		}
+		if err := validatePlaceholderValue(placeholder, value); err != nil {
+			return "", err
+		}
+
+		data[placeholder.Name] = value
+	}
+
+	tmpl, err := template.New(".estafette.yaml").Option("missingkey=error").Parse(manifestTemplate.Template)
+	if err != nil {
+		return "", err
+	}
+
+	var renderedTemplate bytes.Buffer
+	err = tmpl.Execute(&renderedTemplate, data)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = manifest.ReadManifest(renderedTemplate.String())
+	if err != nil {
+		return "", fmt.Errorf("Template %v version %v doesn't render into a valid manifest: %v", manifestTemplate.Name, manifestTemplate.Version, err)
+	}
+
+	return renderedTemplate.String(), nil
+}
+
+func validatePlaceholderValue(placeholder cockroach.ManifestTemplatePlaceholder, value string) error {
+
+	switch placeholder.Type {
+	case "int":
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Errorf("Placeholder %v should be a whole number, not %v", placeholder.Name, value)
+		}
+	case "bool":
+		if value != "true" && value != "false" {
+			return fmt.Errorf("Placeholder %v should be true or false, not %v", placeholder.Name, value)
+		}
+	}
+
+	if placeholder.ValidationRegex != "" {
+		// anchor the regex so it has to match the whole value
+		validationRegex, err := regexp.Compile(fmt.Sprintf("^(?:%v)$", placeholder.ValidationRegex))
+		if err != nil {
+			return err
+		}
+		if !validationRegex.MatchString(value) {
+			return fmt.Errorf("Placeholder %v should match %v, %v doesn't", placeholder.Name, placeholder.ValidationRegex, value)
+		}
+	}
+
+	return nil
+}
+
+// getTemplatePlaceholderNames returns the deduplicated names of all {{.Name}} placeholders in the order they first appear
+func getTemplatePlaceholderNames(templateText string) (names []string) {
+
+	// reduce and deduplicate [["{{.Application}}","Application"],["{{.Team}}","Team"],["{{.ProjectName}}","ProjectName"],["{{.ProjectName}}","ProjectName"]] to ["Application","Team","ProjectName"]
+	names = []string{}
+	for _, m := range manifestTemplatePlaceholderRegex.FindAllStringSubmatch(templateText, -1) {
+		if len(m) == 2 && !stringArrayContains(names, m[1]) {
+			names = append(names, m[1])
+		}
+	}
+
+	return
+}
+
+// readManifestTemplateFiles turns the manifest-<name>.tmpl files in a directory - like the ones in gke/templates - into templates with a string placeholder for each {{.Name}} they use, to seed the database with
+func readManifestTemplateFiles(directory string) (manifestTemplates []cockroach.ManifestTemplate, err error) {
+
+	files, err := ioutil.ReadDir(directory)
+	if err != nil {
+		return
+	}
+
+	for _, f := range files {
+
+		match := manifestTemplateFileRegex.FindStringSubmatch(f.Name())
+		if len(match) != 2 {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(directory, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		manifestTemplate := cockroach.ManifestTemplate{
+			Name:         match[1],
+			Description:  fmt.Sprintf("Imported from %v", f.Name()),
+			Template:     string(data),
+			Placeholders: []cockroach.ManifestTemplatePlaceholder{},
+		}
+		for _, name := range getTemplatePlaceholderNames(manifestTemplate.Template) {
+			manifestTemplate.Placeholders = append(manifestTemplate.Placeholders, cockroach.ManifestTemplatePlaceholder{
+				Name: name,
+				Type: "string",
+			})
+		}
+
+		manifestTemplates = append(manifestTemplates, manifestTemplate)
+	}
+
+	return
+}
+
+// getManifestTemplateSummaries lists templates the way clients of GET /api/manifest/templates expect them
+func getManifestTemplateSummaries(manifestTemplates []*cockroach.ManifestTemplate) []manifestTemplateSummary {
+
+	summaries := []manifestTemplateSummary{}
+	for _, mt := range manifestTemplates {
+		summary := manifestTemplateSummary{Template: mt.Name, Placeholders: []string{}}
+		for _, p := range mt.Placeholders {
+			summary.Placeholders = append(summary.Placeholders, p.Name)
+		}
+		summaries = append(summaries, summary)
+	}
+
+	return summaries
+}
+
+// ImportManifestTemplateFiles stores the manifest-<name>.tmpl files in a directory as templates, skipping templates that already exist or are invalid; it runs at startup and from POST /api/manifest/templates/import
+func ImportManifestTemplateFiles(cockroachDBClient cockroach.DBClient, directory, insertedBy string) (imported []string, err error) {
+
+	manifestTemplates, err := readManifestTemplateFiles(directory)
+	if err != nil {
+		return
+	}
+
+	imported = []string{}
+	for _, manifestTemplate := range manifestTemplates {
+
+		existingManifestTemplate, err := cockroachDBClient.GetManifestTemplate(manifestTemplate.Name, 0)
+		if err != nil {
+			return imported, err
+		}
+		if existingManifestTemplate != nil {
+			continue
+		}
+
+		err = validateManifestTemplate(manifestTemplate)
+		if err != nil {
+			log.Warn().Err(err).Msgf("Skipping import of invalid manifest template %v", manifestTemplate.Name)
+			continue
+		}
+
+		manifestTemplate.InsertedBy = insertedBy
+		_, err = cockroachDBClient.InsertManifestTemplate(manifestTemplate)
+		if err != nil {
+			return imported, err
+		}
+		imported = append(imported, manifestTemplate.Name)
+	}
+
+	return
+}
@@ -0,0 +1,173 @@
+package estafette
+
+import (
+	"testing"
+
+	"github.com/estafette/estafette-ci-api/cockroach"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateManifestTemplate(t *testing.T) {
+
+	t.Run("ReturnsNilForTemplateWithDeclaredPlaceholders", func(t *testing.T) {
+
+		manifestTemplate := cockroach.ManifestTemplate{
+			Name:     "golang",
+			Template: "labels:\n  app: {{.Application}}\n  team: {{.Team}}\n",
+			Placeholders: []cockroach.ManifestTemplatePlaceholder{
+				cockroach.ManifestTemplatePlaceholder{Name: "Application", Type: "string", ValidationRegex: "[a-z0-9-]+"},
+				cockroach.ManifestTemplatePlaceholder{Name: "Team", Type: "string", Default: "estafette"},
+			},
+		}
+
+		// act
+		err := validateManifestTemplate(manifestTemplate)
+
+		assert.Nil(t, err)
+	})
+
+	t.Run("ReturnsErrorForUndeclaredPlaceholder", func(t *testing.T) {
+
+		manifestTemplate := cockroach.ManifestTemplate{
+			Name:     "golang",
+			Template: "labels:\n  app: {{.Application}}\n  team: {{.Team}}\n",
+			Placeholders: []cockroach.ManifestTemplatePlaceholder{
+				cockroach.ManifestTemplatePlaceholder{Name: "Application", Type: "string"},
+			},
+		}
+
+		// act
+		err := validateManifestTemplate(manifestTemplate)
+
+		assert.NotNil(t, err)
+		assert.Equal(t, "Template golang uses placeholder Team without declaring it", err.Error())
+	})
+
+	t.Run("ReturnsErrorForUnknownPlaceholderType", func(t *testing.T) {
+
+		manifestTemplate := cockroach.ManifestTemplate{
+			Name:     "golang",
+			Template: "labels:\n  app: {{.Application}}\n",
+			Placeholders: []cockroach.ManifestTemplatePlaceholder{
+				cockroach.ManifestTemplatePlaceholder{Name: "Application", Type: "float"},
+			},
+		}
+
+		// act
+		err := validateManifestTemplate(manifestTemplate)
+
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ReturnsErrorForDefaultNotMatchingValidationRegex", func(t *testing.T) {
+
+		manifestTemplate := cockroach.ManifestTemplate{
+			Name:     "golang",
+			Template: "labels:\n  app: {{.Application}}\n",
+			Placeholders: []cockroach.ManifestTemplatePlaceholder{
+				cockroach.ManifestTemplatePlaceholder{Name: "Application", Type: "string", Default: "My App", ValidationRegex: "[a-z0-9-]+"},
+			},
+		}
+
+		// act
+		err := validateManifestTemplate(manifestTemplate)
+
+		assert.NotNil(t, err)
+	})
+}
+
+func TestRenderManifestTemplate(t *testing.T) {
+
+	manifestTemplate := cockroach.ManifestTemplate{
+		Name:     "golang",
+		Version:  2,
+		Template: "labels:\n  app: {{.Application}}\n  team: {{.Team}}\n\nstages:\n  build:\n    image: golang:1.11.2-alpine3.8\n    commands:\n    - go build -o ./publish/{{.Application}} .\n",
+		Placeholders: []cockroach.ManifestTemplatePlaceholder{
+			cockroach.ManifestTemplatePlaceholder{Name: "Application", Type: "string", ValidationRegex: "[a-z0-9-]+"},
+			cockroach.ManifestTemplatePlaceholder{Name: "Team", Type: "string", Default: "estafette"},
+		},
+	}
+
+	t.Run("ReturnsManifestWithValuesAndDefaults", func(t *testing.T) {
+
+		// act
+		renderedTemplate, err := renderManifestTemplate(manifestTemplate, map[string]string{"Application": "estafette-ci-api"})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "labels:\n  app: estafette-ci-api\n  team: estafette\n\nstages:\n  build:\n    image: golang:1.11.2-alpine3.8\n    commands:\n    - go build -o ./publish/estafette-ci-api .\n", renderedTemplate)
+	})
+
+	t.Run("ReturnsErrorForMissingValueWithoutDefault", func(t *testing.T) {
+
+		// act
+		_, err := renderManifestTemplate(manifestTemplate, map[string]string{})
+
+		assert.NotNil(t, err)
+		assert.Equal(t, "Placeholder Application needs a value", err.Error())
+	})
+
+	t.Run("ReturnsErrorForValueNotMatchingValidationRegex", func(t *testing.T) {
+
+		// act
+		_, err := renderManifestTemplate(manifestTemplate, map[string]string{"Application": "Estafette CI API"})
+
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ReturnsErrorForValueOfWrongType", func(t *testing.T) {
+
+		intTemplate := cockroach.ManifestTemplate{
+			Name:     "golang",
+			Template: "version:\n  semver:\n    major: {{.Major}}\n",
+			Placeholders: []cockroach.ManifestTemplatePlaceholder{
+				cockroach.ManifestTemplatePlaceholder{Name: "Major", Type: "int"},
+			},
+		}
+
+		// act
+		_, err := renderManifestTemplate(intTemplate, map[string]string{"Major": "one"})
+
+		assert.NotNil(t, err)
+		assert.Equal(t, "Placeholder Major should be a whole number, not one", err.Error())
+	})
+}
+
+func TestReadManifestTemplateFiles(t *testing.T) {
+
+	t.Run("ReturnsTemplateWithStringPlaceholdersForEachFile", func(t *testing.T) {
+
+		// act
+		manifestTemplates, err := readManifestTemplateFiles("../gke/templates")
+
+		assert.Nil(t, err)
+		assert.Equal(t, 9, len(manifestTemplates))
+		assert.Equal(t, "dotnet-extension", manifestTemplates[0].Name)
+		for _, manifestTemplate := range manifestTemplates {
+			assert.Nil(t, validateManifestTemplate(manifestTemplate))
+		}
+	})
+}
+
+func TestGetManifestTemplateSummaries(t *testing.T) {
+
+	t.Run("ReturnsNameAndPlaceholderNamesPerTemplate", func(t *testing.T) {
+
+		manifestTemplates := []*cockroach.ManifestTemplate{
+			&cockroach.ManifestTemplate{
+				Name:     "golang",
+				Template: "labels:\n  app: {{.Application}}\n  team: {{.Team}}\n",
+				Placeholders: []cockroach.ManifestTemplatePlaceholder{
+					cockroach.ManifestTemplatePlaceholder{Name: "Application", Type: "string"},
+					cockroach.ManifestTemplatePlaceholder{Name: "Team", Type: "string", Default: "estafette"},
+				},
+			},
+		}
+
+		// act
+		summaries := getManifestTemplateSummaries(manifestTemplates)
+
+		assert.Equal(t, 1, len(summaries))
+		assert.Equal(t, "golang", summaries[0].Template)
+		assert.Equal(t, []string{"Application", "Team"}, summaries[0].Placeholders)
+	})
+}
@@ -0,0 +1,385 @@
+package estafette
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/estafette/estafette-ci-contracts"
+	manifest "github.com/estafette/estafette-ci-manifest"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// ManifestValidationResult is an error or warning for a manifest, with the yaml path, line and column of the part it's about so an editor can highlight it; line and column are 0 if they're unknown
+type ManifestValidationResult struct {
+	RuleID   string   `json:"ruleId"`
+	Severity string   `json:"severity"`
+	Path     []string `json:"path"`
+	Line     int      `json:"line"`
+	Column   int      `json:"column"`
+	Message  string   `json:"message"`
+	DocsURL  string   `json:"docsURL,omitempty"`
+}
+
+var (
+	yamlLineErrorRegex = regexp.MustCompile(`line (\d+): (.+)`)
+
+	manifestKeys         = []string{"builder", "labels", "version", "env", "triggers", "paths", "pipelines", "stages", "releases"}
+	pathsKeys            = []string{"include", "exclude"}
+	builderKeys          = []string{"track"}
+	versionKeys          = []string{"semver", "custom"}
+	semverVersionKeys    = []string{"major", "minor", "patch", "labelTemplate", "releaseBranch"}
+	customVersionKeys    = []string{"labelTemplate"}
+	releaseKeys          = []string{"clone", "actions", "stages"}
+	stageKeys            = []string{"image", "shell", "workDir", "commands", "when", "env", "autoInjected", "retries"}
+	builderTracks        = []string{"stable", "beta", "dev"}
+	versionTemplateFuncs = (&manifest.EstafetteVersionParams{}).GetFuncMap()
+)
+
+// validateManifest returns the errors in a manifest - invalid yaml, unknown keys and invalid versions - with their location and warnings for when expressions that don't parse; if there are no errors it returns the manifest as well
+func validateManifest(manifestString string) (*manifest.EstafetteManifest, []ManifestValidationResult, []ManifestValidationResult) {
+
+	lines := strings.Split(manifestString, "\n")
+	warnings := []ManifestValidationResult{}
+
+	var document yaml.MapSlice
+	if err := yaml.Unmarshal([]byte(manifestString), &document); err != nil {
+		return nil, getYamlErrors(err, "yaml-syntax"), warnings
+	}
+
+	errors := []ManifestValidationResult{}
+	addError := func(ruleID string, path []string, message string) {
+		errors = append(errors, newManifestValidationResult(lines, ruleID, "error", path, message, ""))
+	}
+	addWarning := func(ruleID string, path []string, message string) {
+		warnings = append(warnings, newManifestValidationResult(lines, ruleID, "warning", path, message, ""))
+	}
+
+	checkKeys(document, nil, manifestKeys, addError)
+
+	for _, item := range document {
+		key := fmt.Sprint(item.Key)
+		path := []string{key}
+		switch key {
+		case "builder":
+			builder := asMapSlice(item.Value)
+			checkKeys(builder, path, builderKeys, addError)
+			if track, ok := getMapSliceValue(builder, "track"); ok && !stringArrayContains(builderTracks, fmt.Sprint(track)) {
+				addError("invalid-builder-track", append(path, "track"), fmt.Sprintf("Builder track %v is unknown, it should be one of %v", track, strings.Join(builderTracks, ", ")))
+			}
+
+		case "version":
+			validateVersion(asMapSlice(item.Value), path, addError)
+
+		case "paths":
+			checkKeys(asMapSlice(item.Value), path, pathsKeys, addError)
+
+		case "triggers":
+			if triggers, ok := item.Value.([]interface{}); ok {
+				for _, trigger := range triggers {
+					if cron, ok := getMapSliceValue(asMapSlice(trigger), "cron"); ok {
+						if _, err := parseCronSchedule(fmt.Sprint(cron)); err != nil {
+							addError("invalid-trigger", path, err.Error())
+						}
+					}
+				}
+			}
+
+		case "stages", "pipelines":
+			for _, stage := range asMapSlice(item.Value) {
+				validateStage(asMapSlice(stage.Value), append(path, fmt.Sprint(stage.Key)), addError, addWarning)
+			}
+
+		case "releases":
+			for _, release := range asMapSlice(item.Value) {
+				releasePath := append(path, fmt.Sprint(release.Key))
+				releaseItems := asMapSlice(release.Value)
+				checkKeys(releaseItems, releasePath, releaseKeys, addError)
+				if stages, ok := getMapSliceValue(releaseItems, "stages"); ok {
+					for _, stage := range asMapSlice(stages) {
+						validateStage(asMapSlice(stage.Value), append(copyPath(releasePath), "stages", fmt.Sprint(stage.Key)), addError, addWarning)
+					}
+				}
+			}
+		}
+	}
+
+	if len(errors) > 0 {
+		return nil, errors, warnings
+	}
+
+	// the yaml is fine, any remaining error is about values of the wrong type
+	mft, err := manifest.ReadManifest(manifestString)
+	if err != nil {
+		return nil, getYamlErrors(err, "invalid-manifest"), warnings
+	}
+
+	return &mft, errors, warnings
+}
+
+// validateManifestForPipeline returns errors for stages the builder would refuse to run for the pipeline, because they use a trusted image in a way it doesn't allow, credentials that don't exist or secrets restricted to another pipeline
+func validateManifestForPipeline(manifestString string, mft *manifest.EstafetteManifest, trustedImages []*contracts.TrustedImageConfig, credentials []*contracts.CredentialConfig, validateSecrets func(string) error) []ManifestValidationResult {
+
+	lines := strings.Split(manifestString, "\n")
+	errors := []ManifestValidationResult{}
+
+	for _, s := range (manifestRuleContext{manifest: mft}).getStages() {
+
+		trustedImage := contracts.GetTrustedImage(trustedImages, s.stage.ContainerImage)
+		if trustedImage != nil && !trustedImage.AllowCommands && len(s.stage.Commands) > 0 {
+			errors = append(errors, newManifestValidationResult(lines, "trusted-image-commands", "error", s.pathTo("commands"), fmt.Sprintf("Stage `%v` uses trusted image `%v`, which isn't allowed to run commands", s.name, s.stage.ContainerImage), ""))
+		}
+
+		for _, name := range getCredentialNames(s.stage.CustomProperties["credentials"]) {
+			if getCredentialByName(credentials, name) == nil {
+				errors = append(errors, newManifestValidationResult(lines, "unknown-credential", "error", s.pathTo("credentials"), fmt.Sprintf("Stage `%v` uses credential `%v`, which doesn't exist", s.name, name), ""))
+			}
+		}
+	}
+
+	// check secrets line by line, so each one that can't be used gets its own location
+	for i, line := range lines {
+		index := strings.Index(line, "estafette.secret(")
+		if index < 0 {
+			continue
+		}
+		if err := validateSecrets(line); err != nil {
+			errors = append(errors, ManifestValidationResult{
+				RuleID:   "restricted-secret",
+				Severity: "error",
+				Path:     []string{},
+				Line:     i + 1,
+				Column:   index + 1,
+				Message:  err.Error(),
+			})
+		}
+	}
+
+	return errors
+}
+
+// getManifestValidationWarnings turns lint results into validation results with the line and column of their path
+func getManifestValidationWarnings(manifestString string, results []ManifestLintResult) []ManifestValidationResult {
+
+	lines := strings.Split(manifestString, "\n")
+	warnings := []ManifestValidationResult{}
+	for _, r := range results {
+		warnings = append(warnings, newManifestValidationResult(lines, r.RuleID, r.Severity, r.Path, r.Message, r.DocsURL))
+	}
+
+	return warnings
+}
+
+func validateVersion(version yaml.MapSlice, path []string, addError func(string, []string, string)) {
+
+	checkKeys(version, path, versionKeys, addError)
+
+	semver, hasSemver := getMapSliceValue(version, "semver")
+	custom, hasCustom := getMapSliceValue(version, "custom")
+	if hasSemver && hasCustom {
+		addError("invalid-version", path, "Version should be either semver or custom, not both")
+	}
+
+	if hasSemver {
+		semverPath := append(copyPath(path), "semver")
+		semverItems := asMapSlice(semver)
+		checkKeys(semverItems, semverPath, semverVersionKeys, addError)
+		for _, key := range []string{"major", "minor"} {
+			if value, ok := getMapSliceValue(semverItems, key); ok {
+				if _, err := strconv.Atoi(fmt.Sprint(value)); err != nil {
+					addError("invalid-version", append(copyPath(semverPath), key), fmt.Sprintf("Version %v should be a whole number, not %v", key, value))
+				}
+			}
+		}
+		for _, key := range []string{"patch", "labelTemplate"} {
+			validateVersionTemplate(semverItems, append(copyPath(semverPath), key), addError)
+		}
+	}
+
+	if hasCustom {
+		customPath := append(copyPath(path), "custom")
+		customItems := asMapSlice(custom)
+		checkKeys(customItems, customPath, customVersionKeys, addError)
+		validateVersionTemplate(customItems, append(copyPath(customPath), "labelTemplate"), addError)
+	}
+}
+
+// validateVersionTemplate checks that a version template only uses the {{auto}}, {{branch}} and {{revision}} functions of the manifest library and the {{tag}} function this api adds
+func validateVersionTemplate(items yaml.MapSlice, path []string, addError func(string, []string, string)) {
+
+	value, ok := getMapSliceValue(items, path[len(path)-1])
+	if !ok {
+		return
+	}
+
+	if _, err := template.New("version").Funcs(versionTemplateFuncs).Parse(versionTagRegex.ReplaceAllLiteralString(fmt.Sprint(value), "")); err != nil {
+		addError("invalid-version", path, fmt.Sprintf("Version template %v is invalid: %v", value, err))
+	}
+}
+
+func validateStage(stage yaml.MapSlice, path []string, addError, addWarning func(string, []string, string)) {
+
+	image, _ := getMapSliceValue(stage, "image")
+	if image == nil || fmt.Sprint(image) == "" {
+		addError("missing-image", path, fmt.Sprintf("Stage `%v` has no image", path[len(path)-1]))
+	}
+
+	// extensions receive any other keys as custom properties; private and third-party images can read them the same way, so for images other than extensions they're only reported as warnings
+	if !strings.HasPrefix(fmt.Sprint(image), "extensions/") {
+		checkKeys(stage, path, stageKeys, addWarning)
+	}
+
+	// the builder evaluates when expressions as javascript, so one that doesn't parse as the common subset might still work
+	if when, ok := getMapSliceValue(stage, "when"); ok {
+		if err := validateWhenExpression(fmt.Sprint(when)); err != nil {
+			addWarning("invalid-when", append(copyPath(path), "when"), err.Error())
+		}
+	}
+}
+
+func checkKeys(items yaml.MapSlice, path []string, knownKeys []string, addResult func(string, []string, string)) {
+	for _, item := range items {
+		key := fmt.Sprint(item.Key)
+		if !stringArrayContains(knownKeys, key) {
+			addResult("unknown-key", append(copyPath(path), key), fmt.Sprintf("Key %v is unknown here, it should be one of %v", key, strings.Join(knownKeys, ", ")))
+		}
+	}
+}
+
+func newManifestValidationResult(lines []string, ruleID, severity string, path []string, message, docsURL string) ManifestValidationResult {
+
+	if path == nil {
+		path = []string{}
+	}
+	line, column := getYamlPosition(lines, path)
+
+	return ManifestValidationResult{
+		RuleID:   ruleID,
+		Severity: severity,
+		Path:     path,
+		Line:     line,
+		Column:   column,
+		Message:  message,
+		DocsURL:  docsURL,
+	}
+}
+
+// getYamlErrors turns a yaml error - which can hold multiple lines like 'line 3: cannot unmarshal !!str `abc` into int' - into a result per line
+func getYamlErrors(err error, ruleID string) (errors []ManifestValidationResult) {
+
+	for _, match := range yamlLineErrorRegex.FindAllStringSubmatch(err.Error(), -1) {
+		line, _ := strconv.Atoi(match[1])
+		errors = append(errors, ManifestValidationResult{
+			RuleID:   ruleID,
+			Severity: "error",
+			Path:     []string{},
+			Line:     line,
+			Message:  match[2],
+		})
+	}
+
+	if len(errors) == 0 {
+		errors = append(errors, ManifestValidationResult{
+			RuleID:   ruleID,
+			Severity: "error",
+			Path:     []string{},
+			Message:  strings.TrimPrefix(err.Error(), "yaml: "),
+		})
+	}
+
+	return
+}
+
+// getYamlPosition returns the 1-based line and column of the key at the end of the path, by following the indentation of the yaml; if the full path can't be found it returns the position of the deepest key that can
+func getYamlPosition(lines []string, path []string) (line, column int) {
+
+	start := 0
+	parentIndentation := -1
+
+	for _, key := range path {
+		found := false
+		childIndentation := -1
+
+		for i := start; i < len(lines); i++ {
+			trimmed := strings.TrimLeft(lines[i], " ")
+			if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+				continue
+			}
+			indentation := len(lines[i]) - len(trimmed)
+			if indentation <= parentIndentation {
+				// left the block of the parent key
+				break
+			}
+			if childIndentation == -1 {
+				childIndentation = indentation
+			}
+			if indentation != childIndentation || !isYamlKey(trimmed, key) {
+				continue
+			}
+
+			line, column = i+1, indentation+1
+			start, parentIndentation = i+1, indentation
+			found = true
+			break
+		}
+
+		if !found {
+			return
+		}
+	}
+
+	return
+}
+
+func isYamlKey(trimmedLine, key string) bool {
+	for _, prefix := range []string{key + ":", `"` + key + `":`, "'" + key + "':"} {
+		if strings.HasPrefix(trimmedLine, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func asMapSlice(value interface{}) yaml.MapSlice {
+	if items, ok := value.(yaml.MapSlice); ok {
+		return items
+	}
+	return nil
+}
+
+func getMapSliceValue(items yaml.MapSlice, key string) (interface{}, bool) {
+	for _, item := range items {
+		if fmt.Sprint(item.Key) == key {
+			return item.Value, true
+		}
+	}
+	return nil, false
+}
+
+func copyPath(path []string) []string {
+	return append([]string{}, path...)
+}
+
+// getCredentialNames returns the names of the credentials a stage refers to with a credentials property, either a single name or a list
+func getCredentialNames(value interface{}) (names []string) {
+	switch v := value.(type) {
+	case string:
+		names = append(names, v)
+	case []interface{}:
+		for _, name := range v {
+			names = append(names, fmt.Sprint(name))
+		}
+	}
+	return
+}
+
+func getCredentialByName(credentials []*contracts.CredentialConfig, name string) *contracts.CredentialConfig {
+	for _, c := range credentials {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
@@ -0,0 +1,398 @@
+package estafette
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/estafette/estafette-ci-contracts"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateManifest(t *testing.T) {
+
+	t.Run("ReturnsManifestAndNoErrorsForValidManifest", func(t *testing.T) {
+
+		manifestString := `labels:
+  app: estafette-ci-api
+
+version:
+  semver:
+    major: 0
+    minor: 1
+    patch: '{{auto}}'
+
+stages:
+  build:
+    image: golang:1.11.2-alpine3.8
+    commands:
+    - go build ./...
+
+  notify:
+    image: extensions/slack-build-status:stable
+    workspace: estafette
+    when: status == 'succeeded' || (status == 'failed' && branch.startsWith('release'))
+`
+
+		// act
+		mft, errors, _ := validateManifest(manifestString)
+
+		assert.NotNil(t, mft)
+		assert.Equal(t, 0, len(errors))
+		assert.Equal(t, 2, len(mft.Stages))
+	})
+
+	t.Run("ReturnsNoErrorsForManifestWithTriggersAndPaths", func(t *testing.T) {
+
+		manifestString := `labels:
+  app: estafette-ci-api
+
+triggers:
+- cron: '0 3 * * *'
+  branch: master
+- tag: 'v*'
+- pipeline: github.com/estafette/estafette-ci-contracts
+  event: build
+  status: succeeded
+  branch: master
+
+paths:
+  include:
+  - 'cmd/**'
+  exclude:
+  - 'docs/**'
+
+stages:
+  build:
+    image: golang:1.11.2-alpine3.8
+    commands:
+    - go build ./...
+`
+
+		// act
+		mft, errors, _ := validateManifest(manifestString)
+
+		assert.NotNil(t, mft)
+		assert.Equal(t, 0, len(errors))
+	})
+
+	t.Run("ReturnsErrorForInvalidCronTrigger", func(t *testing.T) {
+
+		manifestString := "triggers:\n- cron: '0 3 * *'\n"
+
+		// act
+		_, errors, _ := validateManifest(manifestString)
+
+		assert.Equal(t, 1, len(errors))
+		assert.Equal(t, "invalid-trigger", errors[0].RuleID)
+		assert.Equal(t, []string{"triggers"}, errors[0].Path)
+		assert.Equal(t, 1, errors[0].Line)
+	})
+
+	t.Run("ReturnsErrorWithLineForYamlSyntaxError", func(t *testing.T) {
+
+		manifestString := "stages:\n  build:\n    image: golang\n   commands: [\n"
+
+		// act
+		mft, errors, _ := validateManifest(manifestString)
+
+		assert.Nil(t, mft)
+		assert.Equal(t, 1, len(errors))
+		assert.Equal(t, "yaml-syntax", errors[0].RuleID)
+		assert.Equal(t, "error", errors[0].Severity)
+		assert.True(t, errors[0].Line > 0)
+	})
+
+	t.Run("ReturnsWarningWithPathLineAndColumnForUnknownStageKey", func(t *testing.T) {
+
+		manifestString := `labels:
+  app: estafette-ci-api
+
+stages:
+  build:
+    image: golang:1.11.2-alpine3.8
+    comands:
+    - go build ./...
+`
+
+		// act
+		mft, errors, warnings := validateManifest(manifestString)
+
+		assert.NotNil(t, mft)
+		assert.Equal(t, 0, len(errors))
+		assert.Equal(t, 1, len(warnings))
+		assert.Equal(t, "unknown-key", warnings[0].RuleID)
+		assert.Equal(t, "warning", warnings[0].Severity)
+		assert.Equal(t, []string{"stages", "build", "comands"}, warnings[0].Path)
+		assert.Equal(t, 7, warnings[0].Line)
+		assert.Equal(t, 5, warnings[0].Column)
+	})
+
+	t.Run("ReturnsNoErrorForCustomPropertiesOfPrivateImage", func(t *testing.T) {
+
+		manifestString := "stages:\n  deploy:\n    image: my-registry.io/my-team/deployer:1.0.0\n    cluster: production\n"
+
+		// act
+		mft, errors, warnings := validateManifest(manifestString)
+
+		assert.NotNil(t, mft)
+		assert.Equal(t, 0, len(errors))
+		assert.Equal(t, 1, len(warnings))
+		assert.Equal(t, []string{"stages", "deploy", "cluster"}, warnings[0].Path)
+	})
+
+	t.Run("ReturnsNoErrorForCustomPropertiesOfExtension", func(t *testing.T) {
+
+		manifestString := "stages:\n  deploy:\n    image: extensions/gke:stable\n    visibility: private\n"
+
+		// act
+		mft, errors, _ := validateManifest(manifestString)
+
+		assert.NotNil(t, mft)
+		assert.Equal(t, 0, len(errors))
+	})
+
+	t.Run("ReturnsErrorForUnknownTopLevelKey", func(t *testing.T) {
+
+		manifestString := "lables:\n  app: estafette-ci-api\n"
+
+		// act
+		_, errors, _ := validateManifest(manifestString)
+
+		assert.Equal(t, 1, len(errors))
+		assert.Equal(t, []string{"lables"}, errors[0].Path)
+		assert.Equal(t, 1, errors[0].Line)
+		assert.Equal(t, 1, errors[0].Column)
+	})
+
+	t.Run("ReturnsErrorsForInvalidVersion", func(t *testing.T) {
+
+		manifestString := `version:
+  semver:
+    major: one
+    minor: 1
+    patch: '{{autoincrement}}'
+  custom:
+    labelTemplate: '{{revision}}'
+`
+
+		// act
+		_, errors, _ := validateManifest(manifestString)
+
+		assert.Equal(t, 3, len(errors))
+		assert.Equal(t, "invalid-version", errors[0].RuleID)
+		assert.Equal(t, []string{"version"}, errors[0].Path)
+		assert.Equal(t, []string{"version", "semver", "major"}, errors[1].Path)
+		assert.Equal(t, 3, errors[1].Line)
+		assert.Equal(t, 5, errors[1].Column)
+		assert.Equal(t, []string{"version", "semver", "patch"}, errors[2].Path)
+		assert.Equal(t, 5, errors[2].Line)
+	})
+
+	t.Run("ReturnsWarningForInvalidWhenExpressionInReleaseStage", func(t *testing.T) {
+
+		manifestString := `stages:
+  build:
+    image: golang:1.11.2-alpine3.8
+    when: status == 'succeeded'
+
+releases:
+  production:
+    stages:
+      build:
+        image: extensions/gke:stable
+        when: status == 'succeeded' &&
+`
+
+		// act
+		mft, errors, warnings := validateManifest(manifestString)
+
+		assert.NotNil(t, mft)
+		assert.Equal(t, 0, len(errors))
+		assert.Equal(t, 1, len(warnings))
+		assert.Equal(t, "invalid-when", warnings[0].RuleID)
+		assert.Equal(t, "warning", warnings[0].Severity)
+		assert.Equal(t, []string{"releases", "production", "stages", "build", "when"}, warnings[0].Path)
+		assert.Equal(t, 11, warnings[0].Line)
+		assert.Equal(t, 9, warnings[0].Column)
+	})
+
+	t.Run("ReturnsErrorWithLineForValueOfWrongType", func(t *testing.T) {
+
+		manifestString := "labels:\n  app: estafette-ci-api\nenv:\n  VALUES:\n  - a\n"
+
+		// act
+		mft, errors, _ := validateManifest(manifestString)
+
+		assert.Nil(t, mft)
+		assert.Equal(t, 1, len(errors))
+		assert.Equal(t, "invalid-manifest", errors[0].RuleID)
+		assert.Equal(t, 5, errors[0].Line)
+	})
+}
+
+func TestValidateManifestForPipeline(t *testing.T) {
+
+	manifestString := `stages:
+  build:
+    image: golang:1.11.2-alpine3.8
+    commands:
+    - go build ./...
+    env:
+      API_KEY: estafette.secret(abc.def)
+
+  deploy:
+    image: extensions/gke:stable
+    credentials: gke-production
+`
+
+	mft, _, _ := validateManifest(manifestString)
+	trustedImages := []*contracts.TrustedImageConfig{
+		&contracts.TrustedImageConfig{ImagePath: "golang", AllowCommands: false},
+	}
+	credentials := []*contracts.CredentialConfig{
+		&contracts.CredentialConfig{Name: "gke-development", Type: "kubernetes-engine"},
+	}
+
+	t.Run("ReturnsErrorsForCommandsInTrustedImageUnknownCredentialAndRestrictedSecret", func(t *testing.T) {
+
+		// act
+		errors := validateManifestForPipeline(manifestString, mft, trustedImages, credentials, func(text string) error {
+			if strings.Contains(text, "estafette.secret(abc.def)") {
+				return fmt.Errorf("The secret is restricted to pipeline github.com/estafette/other")
+			}
+			return nil
+		})
+
+		assert.Equal(t, 3, len(errors))
+		assert.Equal(t, "trusted-image-commands", errors[0].RuleID)
+		assert.Equal(t, 4, errors[0].Line)
+		assert.Equal(t, "unknown-credential", errors[1].RuleID)
+		assert.Equal(t, []string{"stages", "deploy", "credentials"}, errors[1].Path)
+		assert.Equal(t, 11, errors[1].Line)
+		assert.Equal(t, "restricted-secret", errors[2].RuleID)
+		assert.Equal(t, 7, errors[2].Line)
+		assert.Equal(t, 16, errors[2].Column)
+	})
+
+	t.Run("ReturnsNoErrorsIfImageAllowsCommandsAndCredentialExists", func(t *testing.T) {
+
+		trustedImages := []*contracts.TrustedImageConfig{
+			&contracts.TrustedImageConfig{ImagePath: "golang", AllowCommands: true},
+		}
+		credentials := []*contracts.CredentialConfig{
+			&contracts.CredentialConfig{Name: "gke-production", Type: "kubernetes-engine"},
+		}
+
+		// act
+		errors := validateManifestForPipeline(manifestString, mft, trustedImages, credentials, func(string) error { return nil })
+
+		assert.Equal(t, 0, len(errors))
+	})
+}
+
+func TestGetYamlPosition(t *testing.T) {
+
+	lines := strings.Split(`labels:
+  app: estafette-ci-api
+
+stages:
+  # builds the app
+  build:
+    image: golang:1.11.2-alpine3.8
+
+releases:
+  production:
+    stages:
+      build:
+        image: extensions/gke:stable
+`, "\n")
+
+	t.Run("ReturnsPositionOfNestedKey", func(t *testing.T) {
+
+		// act
+		line, column := getYamlPosition(lines, []string{"releases", "production", "stages", "build", "image"})
+
+		assert.Equal(t, 13, line)
+		assert.Equal(t, 9, column)
+	})
+
+	t.Run("ReturnsPositionOfDeepestKeyFound", func(t *testing.T) {
+
+		// act
+		line, column := getYamlPosition(lines, []string{"stages", "build", "commands"})
+
+		assert.Equal(t, 6, line)
+		assert.Equal(t, 3, column)
+	})
+
+	t.Run("ReturnsZeroForUnknownTopLevelKey", func(t *testing.T) {
+
+		// act
+		line, column := getYamlPosition(lines, []string{"version"})
+
+		assert.Equal(t, 0, line)
+		assert.Equal(t, 0, column)
+	})
+}
+
+func TestValidateWhenExpression(t *testing.T) {
+
+	t.Run("ReturnsNilForValidExpressions", func(t *testing.T) {
+
+		expressions := []string{
+			"status == 'succeeded'",
+			"status == 'succeeded' || status == 'failed'",
+			"branch == 'master' && (trigger == 'git' || trigger == 'manual')",
+			"!(branch.startsWith('feature')) && status === \"succeeded\"",
+			"server == 'gocd' && action != 'rollback'",
+			"branch.indexOf('release') > -1",
+			"status == 'succeeded' && ['master', 'release'].indexOf(branch) >= 0",
+			"(branch + '-suffix').length > 10 ? true : false",
+			"branch.split('/')[0] == 'feature'",
+		}
+
+		for _, expression := range expressions {
+
+			// act
+			err := validateWhenExpression(expression)
+
+			assert.Nil(t, err, expression)
+		}
+	})
+
+	t.Run("ReturnsErrorForUnclosedString", func(t *testing.T) {
+
+		// act
+		err := validateWhenExpression("status == 'succeeded")
+
+		assert.NotNil(t, err)
+		assert.Equal(t, "When expression status == 'succeeded is invalid: string at position 11 isn't closed", err.Error())
+	})
+
+	t.Run("ReturnsErrorForUnbalancedParentheses", func(t *testing.T) {
+
+		// act
+		err := validateWhenExpression("(status == 'succeeded'")
+
+		assert.NotNil(t, err)
+		assert.Equal(t, "When expression (status == 'succeeded' is invalid: unexpected end at position 23", err.Error())
+	})
+
+	t.Run("ReturnsErrorForMissingOperator", func(t *testing.T) {
+
+		// act
+		err := validateWhenExpression("status 'succeeded'")
+
+		assert.NotNil(t, err)
+		assert.Equal(t, "When expression status 'succeeded' is invalid: unexpected 'succeeded' at position 8", err.Error())
+	})
+
+	t.Run("ReturnsErrorForSingleEqualsSign", func(t *testing.T) {
+
+		// act
+		err := validateWhenExpression("status = 'succeeded'")
+
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,91 @@
+package estafette
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// GetPathFilter reads the path filter from the paths section of a manifest
+func GetPathFilter(manifestString string) (filter PathFilter, err error) {
+
+	var aux struct {
+		Paths PathFilter `yaml:"paths"`
+	}
+
+	if err = yaml.Unmarshal([]byte(manifestString), &aux); err != nil {
+		return
+	}
+
+	return aux.Paths, nil
+}
+
+// GetSkipReason returns why a build can be skipped for the changed files, or an empty string if it has to run; without known changed files it always has to run
+func (f *PathFilter) GetSkipReason(changedFiles []string) string {
+
+	if changedFiles == nil || (len(f.Include) == 0 && len(f.Exclude) == 0) {
+		return ""
+	}
+
+	for _, file := range changedFiles {
+		if f.matches(file) {
+			return ""
+		}
+	}
+
+	return fmt.Sprintf("None of the %v changed files match the paths filter of the manifest", len(changedFiles))
+}
+
+func (f *PathFilter) matches(file string) bool {
+
+	included := len(f.Include) == 0
+	for _, pattern := range f.Include {
+		if matchesPathGlob(pattern, file) {
+			included = true
+			break
+		}
+	}
+	if !included {
+		return false
+	}
+
+	for _, pattern := range f.Exclude {
+		if matchesPathGlob(pattern, file) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchesPathGlob matches a file against a glob where ** matches any number of directories; a pattern matching a directory matches all files below it
+func matchesPathGlob(pattern, file string) bool {
+	return matchesPathSegments(strings.Split(strings.Trim(pattern, "/"), "/"), strings.Split(strings.Trim(file, "/"), "/"))
+}
+
+func matchesPathSegments(patternSegments, fileSegments []string) bool {
+
+	if len(patternSegments) == 0 {
+		return true
+	}
+
+	if patternSegments[0] == "**" {
+		for i := 0; i <= len(fileSegments); i++ {
+			if matchesPathSegments(patternSegments[1:], fileSegments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(fileSegments) == 0 {
+		return false
+	}
+	if matched, err := path.Match(patternSegments[0], fileSegments[0]); err != nil || !matched {
+		return false
+	}
+
+	return matchesPathSegments(patternSegments[1:], fileSegments[1:])
+}
@@ -0,0 +1,131 @@
+package estafette
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetPathFilter(t *testing.T) {
+
+	t.Run("ReturnsIncludeAndExcludeGlobsFromManifest", func(t *testing.T) {
+
+		manifestString := `
+builder:
+  track: stable
+
+paths:
+  include:
+  - services/api/**
+  - go.mod
+  exclude:
+  - '**/*.md'
+
+stages:
+  build:
+    image: golang:1.11.2-alpine3.8
+`
+
+		// act
+		filter, err := GetPathFilter(manifestString)
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"services/api/**", "go.mod"}, filter.Include)
+		assert.Equal(t, []string{"**/*.md"}, filter.Exclude)
+	})
+
+	t.Run("ReturnsEmptyFilterIfManifestHasNoPathsSection", func(t *testing.T) {
+
+		// act
+		filter, err := GetPathFilter("builder:\n  track: stable\n")
+
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(filter.Include))
+		assert.Equal(t, 0, len(filter.Exclude))
+	})
+}
+
+func TestPathFilterGetSkipReason(t *testing.T) {
+
+	filter := PathFilter{
+		Include: []string{"services/api/**", "go.mod"},
+		Exclude: []string{"**/*.md"},
+	}
+
+	t.Run("ReturnsEmptyReasonIfChangedFilesAreUnknown", func(t *testing.T) {
+
+		// act
+		skipReason := filter.GetSkipReason(nil)
+
+		assert.Equal(t, "", skipReason)
+	})
+
+	t.Run("ReturnsEmptyReasonIfFilterIsEmpty", func(t *testing.T) {
+
+		emptyFilter := PathFilter{}
+
+		// act
+		skipReason := emptyFilter.GetSkipReason([]string{"services/web/main.go"})
+
+		assert.Equal(t, "", skipReason)
+	})
+
+	t.Run("ReturnsEmptyReasonIfAnIncludedFileChanged", func(t *testing.T) {
+
+		// act
+		skipReason := filter.GetSkipReason([]string{"services/web/main.go", "services/api/handlers/health.go"})
+
+		assert.Equal(t, "", skipReason)
+	})
+
+	t.Run("ReturnsEmptyReasonIfIncludedFileInRootChanged", func(t *testing.T) {
+
+		// act
+		skipReason := filter.GetSkipReason([]string{"go.mod"})
+
+		assert.Equal(t, "", skipReason)
+	})
+
+	t.Run("ReturnsReasonIfNoIncludedFileChanged", func(t *testing.T) {
+
+		// act
+		skipReason := filter.GetSkipReason([]string{"services/web/main.go", "README.md"})
+
+		assert.Equal(t, "None of the 2 changed files match the paths filter of the manifest", skipReason)
+	})
+
+	t.Run("ReturnsReasonIfOnlyExcludedFilesChanged", func(t *testing.T) {
+
+		// act
+		skipReason := filter.GetSkipReason([]string{"services/api/README.md", "services/api/docs/usage.md"})
+
+		assert.NotEqual(t, "", skipReason)
+	})
+}
+
+func TestMatchesPathGlob(t *testing.T) {
+
+	t.Run("ReturnsTrueForFilesBelowMatchingDirectory", func(t *testing.T) {
+
+		// act
+		matches := matchesPathGlob("services/api", "services/api/main.go")
+
+		assert.True(t, matches)
+	})
+
+	t.Run("ReturnsTrueIfDoubleStarMatchesNoDirectories", func(t *testing.T) {
+
+		// act
+		matches := matchesPathGlob("**/*.md", "README.md")
+
+		assert.True(t, matches)
+	})
+
+	t.Run("ReturnsFalseIfSingleStarWouldHaveToMatchSlash", func(t *testing.T) {
+
+		// act
+		matches := matchesPathGlob("services/*.go", "services/api/main.go")
+
+		assert.False(t, matches)
+	})
+}
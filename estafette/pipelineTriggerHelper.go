@@ -0,0 +1,356 @@
+package estafette
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/estafette/estafette-ci-api/cockroach"
+	contracts "github.com/estafette/estafette-ci-contracts"
+	"github.com/rs/zerolog/log"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// PipelineTriggerHelper starts builds and releases of pipelines that subscribed to builds or releases of another pipeline
+type PipelineTriggerHelper interface {
+	FireBuildTriggers(cockroach.Build)
+	FireReleaseTriggers(cockroach.Release)
+	FireReleaseDirectives(cockroach.Build)
+	GetTriggeredPipelines(*cockroach.Pipeline) ([]TriggeredPipeline, error)
+}
+
+type pipelineTriggerHelperImpl struct {
+	cockroachDBClient    cockroach.DBClient
+	releaseHelper        ReleaseHelper
+	githubTriggerFunc    func(TriggerEvent) (*cockroach.Build, error)
+	bitbucketTriggerFunc func(TriggerEvent) (*cockroach.Build, error)
+}
+
+// NewPipelineTriggerHelper returns a new estafette.PipelineTriggerHelper
+func NewPipelineTriggerHelper(cockroachDBClient cockroach.DBClient, releaseHelper ReleaseHelper, githubTriggerFunc func(TriggerEvent) (*cockroach.Build, error), bitbucketTriggerFunc func(TriggerEvent) (*cockroach.Build, error)) PipelineTriggerHelper {
+	return &pipelineTriggerHelperImpl{
+		cockroachDBClient:    cockroachDBClient,
+		releaseHelper:        releaseHelper,
+		githubTriggerFunc:    githubTriggerFunc,
+		bitbucketTriggerFunc: bitbucketTriggerFunc,
+	}
+}
+
+// maxTriggerChainLength limits how many pipelines can trigger each other in a row
+const maxTriggerChainLength = 10
+
+// triggerChainSeparator joins the pipelines a trigger went through, in the trigger reason of builds and the triggered by of releases
+const triggerChainSeparator = " > "
+
+var triggerChainRegex = regexp.MustCompile(`\(trigger chain: ([^)]+)\)$`)
+
+// FireBuildTriggers fires the triggers of all pipelines subscribed to a finished build
+func (th *pipelineTriggerHelperImpl) FireBuildTriggers(build cockroach.Build) {
+
+	if !isFinishedStatus(build.BuildStatus) {
+		return
+	}
+
+	fullName := fmt.Sprintf("%v/%v/%v", build.RepoSource, build.RepoOwner, build.RepoName)
+	reason := fmt.Sprintf("build %v of %v %v", build.BuildVersion, fullName, build.BuildStatus)
+	chain := getTriggerChainFromReason(build.TriggerReason)
+
+	th.fireTriggers(fullName, reason, chain, func(t PipelineTrigger) bool {
+		return t.Event == "build" && t.Status == build.BuildStatus && t.Branch == build.RepoBranch
+	})
+}
+
+// FireReleaseTriggers fires the triggers of all pipelines subscribed to a finished release
+func (th *pipelineTriggerHelperImpl) FireReleaseTriggers(release cockroach.Release) {
+
+	if !isFinishedStatus(release.ReleaseStatus) {
+		return
+	}
+
+	fullName := fmt.Sprintf("%v/%v/%v", release.RepoSource, release.RepoOwner, release.RepoName)
+	reason := fmt.Sprintf("release %v of %v to %v %v", release.ReleaseVersion, fullName, release.Name, release.ReleaseStatus)
+	chain := getTriggerChainFromTriggeredBy(release.TriggeredBy)
+
+	th.fireTriggers(fullName, reason, chain, func(t PipelineTrigger) bool {
+		return t.Event == "release" && t.Status == release.ReleaseStatus && t.Target == release.Name
+	})
+}
+
+// FireReleaseDirectives releases a succeeded build to the targets of [ci release:<target>] directives in its commit messages; only builds of the manifest's release branch honour directives, so a commit on a feature branch can't release to production
+func (th *pipelineTriggerHelperImpl) FireReleaseDirectives(build cockroach.Build) {
+
+	if build.BuildStatus != "succeeded" {
+		return
+	}
+
+	hasDirectives := false
+	for _, c := range build.Commits {
+		if len(GetReleaseDirectives(c.Message)) > 0 {
+			hasDirectives = true
+			break
+		}
+	}
+	if !hasDirectives {
+		return
+	}
+
+	// releasing and checking the release branch need the manifest, which isn't part of the build passed in
+	buildID, err := strconv.Atoi(build.ID)
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed to convert build id %v to int for release directive", build.ID)
+		return
+	}
+	fullBuild, err := th.cockroachDBClient.GetPipelineBuildByID(build.RepoSource, build.RepoOwner, build.RepoName, buildID, false)
+	if err != nil || fullBuild == nil {
+		log.Error().Err(err).Msgf("Failed retrieving build %v of %v/%v/%v for release directive", build.ID, build.RepoSource, build.RepoOwner, build.RepoName)
+		return
+	}
+
+	if !isReleaseBranch(fullBuild.ManifestObject, build.RepoBranch) {
+		log.Warn().Msgf("Ignoring release directives of build %v of %v/%v/%v, branch %v isn't a release branch", build.BuildVersion, build.RepoSource, build.RepoOwner, build.RepoName, build.RepoBranch)
+		return
+	}
+
+	releasedTargets := map[string]bool{}
+	for _, c := range build.Commits {
+		for _, target := range GetReleaseDirectives(c.Message) {
+			if releasedTargets[target] {
+				continue
+			}
+			releasedTargets[target] = true
+
+			if !hasReleaseTarget(build, target) {
+				log.Warn().Msgf("Release directive of build %v of %v/%v/%v refers to unknown release target %v", build.BuildVersion, build.RepoSource, build.RepoOwner, build.RepoName, target)
+				continue
+			}
+
+			log.Info().Msgf("Releasing build %v of %v/%v/%v to %v for release directive", build.BuildVersion, build.RepoSource, build.RepoOwner, build.RepoName, target)
+
+			_, err = th.releaseHelper.StartRelease(cockroach.Release{
+				Release: contracts.Release{
+					Name:           target,
+					RepoSource:     build.RepoSource,
+					RepoOwner:      build.RepoOwner,
+					RepoName:       build.RepoName,
+					ReleaseVersion: build.BuildVersion,
+					ReleaseStatus:  "running",
+					TriggeredBy:    c.Author.Email,
+				},
+			}, *fullBuild)
+			if err != nil {
+				log.Error().Err(err).Msgf("Failed releasing build %v of %v/%v/%v to %v for release directive", build.BuildVersion, build.RepoSource, build.RepoOwner, build.RepoName, target)
+			}
+		}
+	}
+}
+
+// GetTriggeredPipelines returns all pipelines with a trigger subscribed to the pipeline
+func (th *pipelineTriggerHelperImpl) GetTriggeredPipelines(pipeline *cockroach.Pipeline) (triggeredPipelines []TriggeredPipeline, err error) {
+
+	fullName := fmt.Sprintf("%v/%v/%v", pipeline.RepoSource, pipeline.RepoOwner, pipeline.RepoName)
+
+	subscribers, err := th.cockroachDBClient.GetPipelinesWithManifestContaining(fullName)
+	if err != nil {
+		return
+	}
+
+	triggeredPipelines = make([]TriggeredPipeline, 0)
+	for _, s := range subscribers {
+		triggers, err := GetPipelineTriggers(s.Manifest)
+		if err != nil {
+			log.Warn().Err(err).Msgf("Failed reading pipeline triggers from manifest for pipeline %v/%v/%v", s.RepoSource, s.RepoOwner, s.RepoName)
+			continue
+		}
+		for _, t := range triggers {
+			if t.Pipeline == fullName {
+				triggeredPipelines = append(triggeredPipelines, TriggeredPipeline{
+					Pipeline: fmt.Sprintf("%v/%v/%v", s.RepoSource, s.RepoOwner, s.RepoName),
+					Trigger:  t,
+				})
+			}
+		}
+	}
+
+	return
+}
+
+// fireTriggers fires the matching triggers of the subscribed pipelines; the chain holds the pipelines that triggered each other up to this one, so a pipeline already in it - including this one itself - isn't triggered again and loops like a > b > a stop
+func (th *pipelineTriggerHelperImpl) fireTriggers(fullName, reason string, chain []string, matches func(PipelineTrigger) bool) {
+
+	chain = append(chain, fullName)
+	if len(chain) > maxTriggerChainLength {
+		log.Warn().Msgf("Not firing triggers for %v, trigger chain %v is longer than %v pipelines", reason, strings.Join(chain, triggerChainSeparator), maxTriggerChainLength)
+		return
+	}
+
+	subscribers, err := th.cockroachDBClient.GetPipelinesWithManifestContaining(fullName)
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed retrieving pipelines subscribed to %v", fullName)
+		return
+	}
+
+	for _, s := range subscribers {
+		subscriberName := fmt.Sprintf("%v/%v/%v", s.RepoSource, s.RepoOwner, s.RepoName)
+		if stringArrayContains(chain, subscriberName) {
+			log.Warn().Msgf("Not firing trigger of pipeline %v for %v, it's already in trigger chain %v", subscriberName, reason, strings.Join(chain, triggerChainSeparator))
+			continue
+		}
+
+		triggers, err := GetPipelineTriggers(s.Manifest)
+		if err != nil {
+			log.Warn().Err(err).Msgf("Failed reading pipeline triggers from manifest for pipeline %v/%v/%v", s.RepoSource, s.RepoOwner, s.RepoName)
+			continue
+		}
+
+		for _, t := range triggers {
+			if t.Pipeline != fullName || !matches(t) {
+				continue
+			}
+
+			log.Info().Msgf("Firing trigger of pipeline %v/%v/%v for %v", s.RepoSource, s.RepoOwner, s.RepoName, reason)
+
+			err = th.fireTrigger(s, t, reason, chain)
+			if err != nil {
+				log.Error().Err(err).Msgf("Failed firing trigger of pipeline %v/%v/%v for %v", s.RepoSource, s.RepoOwner, s.RepoName, reason)
+			}
+		}
+	}
+}
+
+func (th *pipelineTriggerHelperImpl) fireTrigger(pipeline *cockroach.Pipeline, trigger PipelineTrigger, reason string, chain []string) (err error) {
+
+	branch := getTriggeredBranch(pipeline, trigger)
+
+	if trigger.Release != "" {
+		// get the last succeeded build of the triggered branch of the subscribed pipeline
+		filters := map[string][]string{
+			"branch": []string{branch},
+			"status": []string{"succeeded"},
+		}
+		var builds []*cockroach.Build
+		builds, err = th.cockroachDBClient.GetPipelineBuilds(pipeline.RepoSource, pipeline.RepoOwner, pipeline.RepoName, 1, 1, filters, false)
+		if err != nil {
+			return
+		}
+		if len(builds) == 0 {
+			return fmt.Errorf("Pipeline %v/%v/%v has no succeeded builds for branch %v", pipeline.RepoSource, pipeline.RepoOwner, pipeline.RepoName, branch)
+		}
+		build := builds[0]
+
+		_, err = th.releaseHelper.StartRelease(cockroach.Release{
+			Release: contracts.Release{
+				Name:           trigger.Release,
+				Action:         trigger.ReleaseAction,
+				RepoSource:     build.RepoSource,
+				RepoOwner:      build.RepoOwner,
+				RepoName:       build.RepoName,
+				ReleaseVersion: build.BuildVersion,
+				ReleaseStatus:  "running",
+				TriggeredBy:    strings.Join(chain, triggerChainSeparator),
+			},
+		}, *build)
+
+		return
+	}
+
+	// leave the revision empty so the head of the branch gets built, instead of rebuilding whatever build - failed or skipped - happens to be the last one
+	triggerEvent := TriggerEvent{
+		RepoSource: pipeline.RepoSource,
+		RepoOwner:  pipeline.RepoOwner,
+		RepoName:   pipeline.RepoName,
+		RepoBranch: branch,
+		Reason:     fmt.Sprintf("%v (trigger chain: %v)", reason, strings.Join(chain, triggerChainSeparator)),
+	}
+
+	switch pipeline.RepoSource {
+	case "bitbucket.org":
+		_, err = th.bitbucketTriggerFunc(triggerEvent)
+	default:
+		// github.com and github enterprise hosts
+		_, err = th.githubTriggerFunc(triggerEvent)
+	}
+
+	return
+}
+
+// getTriggeredBranch returns the branch of the subscribed pipeline a trigger builds or releases; the trigger's buildBranch if set, otherwise the first release branch of the subscriber's manifest, falling back to master
+func getTriggeredBranch(pipeline *cockroach.Pipeline, trigger PipelineTrigger) string {
+
+	if trigger.BuildBranch != "" {
+		return trigger.BuildBranch
+	}
+
+	mft := pipeline.ManifestObject
+	if mft != nil && mft.Version.SemVer != nil && len(mft.Version.SemVer.ReleaseBranch.Values) > 0 {
+		return mft.Version.SemVer.ReleaseBranch.Values[0]
+	}
+
+	return "master"
+}
+
+// getTriggerChainFromReason returns the pipelines that triggered each other up to a build from its trigger reason, empty if it wasn't started by a pipeline trigger
+func getTriggerChainFromReason(triggerReason string) []string {
+	matches := triggerChainRegex.FindStringSubmatch(triggerReason)
+	if len(matches) != 2 {
+		return []string{}
+	}
+	return strings.Split(matches[1], triggerChainSeparator)
+}
+
+// getTriggerChainFromTriggeredBy returns the pipelines that triggered each other up to a release, empty if it was started by a user
+func getTriggerChainFromTriggeredBy(triggeredBy string) []string {
+	chain := []string{}
+	for _, pipeline := range strings.Split(triggeredBy, triggerChainSeparator) {
+		// pipelines look like github.com/owner/name, users are identified by email address
+		if strings.Count(pipeline, "/") != 2 || strings.Contains(pipeline, "@") {
+			return []string{}
+		}
+		chain = append(chain, pipeline)
+	}
+	return chain
+}
+
+func isFinishedStatus(status string) bool {
+	return status == "succeeded" || status == "failed" || status == "canceled"
+}
+
+func hasReleaseTarget(build cockroach.Build, target string) bool {
+	for _, rt := range build.ReleaseTargets {
+		if rt.Name == target {
+			return true
+		}
+	}
+	return false
+}
+
+// GetPipelineTriggers reads the pipeline triggers from the triggers section of a manifest; event defaults to build, status to succeeded and branch to master for build events
+func GetPipelineTriggers(manifestString string) (triggers []PipelineTrigger, err error) {
+
+	var aux struct {
+		Triggers []PipelineTrigger `yaml:"triggers"`
+	}
+
+	if err = yaml.Unmarshal([]byte(manifestString), &aux); err != nil {
+		return
+	}
+
+	triggers = make([]PipelineTrigger, 0)
+	for _, t := range aux.Triggers {
+		if t.Pipeline == "" {
+			continue
+		}
+		if t.Event == "" {
+			t.Event = "build"
+		}
+		if t.Status == "" {
+			t.Status = "succeeded"
+		}
+		if t.Event == "build" && t.Branch == "" {
+			t.Branch = "master"
+		}
+		triggers = append(triggers, t)
+	}
+
+	return
+}
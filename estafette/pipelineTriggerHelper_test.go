@@ -0,0 +1,135 @@
+package estafette
+
+import (
+	"testing"
+
+	"github.com/estafette/estafette-ci-api/cockroach"
+	manifest "github.com/estafette/estafette-ci-manifest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetPipelineTriggers(t *testing.T) {
+
+	t.Run("ReturnsPipelineTriggersFromManifestWithDefaults", func(t *testing.T) {
+
+		manifestString := `
+builder:
+  track: stable
+
+triggers:
+- cron: '0 3 * * *'
+- pipeline: github.com/estafette/estafette-ci-contracts
+- pipeline: github.com/estafette/estafette-ci-api
+  event: release
+  target: staging
+  release: e2e
+
+stages:
+  build:
+    image: golang:1.11.2-alpine3.8
+`
+
+		// act
+		triggers, err := GetPipelineTriggers(manifestString)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(triggers))
+		assert.Equal(t, "github.com/estafette/estafette-ci-contracts", triggers[0].Pipeline)
+		assert.Equal(t, "build", triggers[0].Event)
+		assert.Equal(t, "succeeded", triggers[0].Status)
+		assert.Equal(t, "master", triggers[0].Branch)
+		assert.Equal(t, "github.com/estafette/estafette-ci-api", triggers[1].Pipeline)
+		assert.Equal(t, "release", triggers[1].Event)
+		assert.Equal(t, "succeeded", triggers[1].Status)
+		assert.Equal(t, "", triggers[1].Branch)
+		assert.Equal(t, "staging", triggers[1].Target)
+		assert.Equal(t, "e2e", triggers[1].Release)
+	})
+}
+
+func TestGetTriggerChainFromReason(t *testing.T) {
+
+	t.Run("ReturnsPipelinesOfTriggerChain", func(t *testing.T) {
+
+		// act
+		chain := getTriggerChainFromReason("build 1.0.3 of github.com/estafette/estafette-ci-contracts succeeded (trigger chain: github.com/estafette/estafette-ci-manifest > github.com/estafette/estafette-ci-contracts)")
+
+		assert.Equal(t, []string{"github.com/estafette/estafette-ci-manifest", "github.com/estafette/estafette-ci-contracts"}, chain)
+	})
+
+	t.Run("ReturnsEmptyChainForOtherReasons", func(t *testing.T) {
+
+		// act
+		chain := getTriggerChainFromReason("cron 0 3 * * *")
+
+		assert.Equal(t, []string{}, chain)
+	})
+}
+
+func TestGetTriggerChainFromTriggeredBy(t *testing.T) {
+
+	t.Run("ReturnsPipelinesOfTriggerChain", func(t *testing.T) {
+
+		// act
+		chain := getTriggerChainFromTriggeredBy("github.com/estafette/estafette-ci-api > github.com/estafette/estafette-ci-web")
+
+		assert.Equal(t, []string{"github.com/estafette/estafette-ci-api", "github.com/estafette/estafette-ci-web"}, chain)
+	})
+
+	t.Run("ReturnsEmptyChainForUser", func(t *testing.T) {
+
+		// act
+		chain := getTriggerChainFromTriggeredBy("me@estafette.io")
+
+		assert.Equal(t, []string{}, chain)
+	})
+}
+
+func TestGetTriggeredBranch(t *testing.T) {
+
+	t.Run("ReturnsReleaseBranchOfSubscriberManifest", func(t *testing.T) {
+
+		pipeline := &cockroach.Pipeline{
+			ManifestObject: &manifest.EstafetteManifest{
+				Version: manifest.EstafetteVersion{
+					SemVer: &manifest.EstafetteSemverVersion{
+						ReleaseBranch: manifest.StringOrStringArray{Values: []string{"main"}},
+					},
+				},
+			},
+		}
+
+		// act
+		branch := getTriggeredBranch(pipeline, PipelineTrigger{Pipeline: "github.com/estafette/estafette-ci-contracts"})
+
+		assert.Equal(t, "main", branch)
+	})
+
+	t.Run("ReturnsBuildBranchOfTriggerOverManifest", func(t *testing.T) {
+
+		pipeline := &cockroach.Pipeline{
+			ManifestObject: &manifest.EstafetteManifest{
+				Version: manifest.EstafetteVersion{
+					SemVer: &manifest.EstafetteSemverVersion{
+						ReleaseBranch: manifest.StringOrStringArray{Values: []string{"main"}},
+					},
+				},
+			},
+		}
+
+		// act
+		branch := getTriggeredBranch(pipeline, PipelineTrigger{Pipeline: "github.com/estafette/estafette-ci-contracts", BuildBranch: "develop"})
+
+		assert.Equal(t, "develop", branch)
+	})
+
+	t.Run("ReturnsMasterWithoutReleaseBranch", func(t *testing.T) {
+
+		pipeline := &cockroach.Pipeline{}
+
+		// act
+		branch := getTriggeredBranch(pipeline, PipelineTrigger{Pipeline: "github.com/estafette/estafette-ci-contracts"})
+
+		assert.Equal(t, "master", branch)
+	})
+}
@@ -0,0 +1,258 @@
+package estafette
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/estafette/estafette-ci-api/cockroach"
+	"github.com/estafette/estafette-ci-contracts"
+	manifest "github.com/estafette/estafette-ci-manifest"
+	"github.com/rs/zerolog/log"
+)
+
+// ReleaseHelper starts releases and looks up release history, shared by the api and slack handlers
+type ReleaseHelper interface {
+	StartRelease(cockroach.Release, cockroach.Build) (cockroach.Release, error)
+	StartReleaseForRevision(cockroach.Release, string) (cockroach.Release, error)
+	GetRollbackRelease(*cockroach.Pipeline, string, string) (*cockroach.Release, *cockroach.Build, error)
+}
+
+type releaseHelperImpl struct {
+	cockroachDBClient    cockroach.DBClient
+	ciBuilderClient      CiBuilderClient
+	githubJobVarsFunc    func(string, string, string) (string, string, error)
+	bitbucketJobVarsFunc func(string, string, string) (string, string, error)
+	buildStatusHelper    BuildStatusHelper
+}
+
+// NewReleaseHelper returns a new estafette.ReleaseHelper
+func NewReleaseHelper(cockroachDBClient cockroach.DBClient, ciBuilderClient CiBuilderClient, githubJobVarsFunc func(string, string, string) (string, string, error), bitbucketJobVarsFunc func(string, string, string) (string, string, error), buildStatusHelper BuildStatusHelper) (releaseHelper ReleaseHelper) {
+
+	releaseHelper = &releaseHelperImpl{
+		cockroachDBClient:    cockroachDBClient,
+		ciBuilderClient:      ciBuilderClient,
+		githubJobVarsFunc:    githubJobVarsFunc,
+		bitbucketJobVarsFunc: bitbucketJobVarsFunc,
+		buildStatusHelper:    buildStatusHelper,
+	}
+
+	return
+}
+
+// StartRelease inserts the release into the database and creates a release job for the (succeeded) build it releases
+func (rh *releaseHelperImpl) StartRelease(release cockroach.Release, build cockroach.Build) (insertedRelease cockroach.Release, err error) {
+
+	insertedRelease, err = rh.cockroachDBClient.InsertRelease(release)
+	if err != nil {
+		return
+	}
+
+	go rh.buildStatusHelper.ReportReleaseStatus(insertedRelease, &build)
+
+	// get authenticated url
+	var authenticatedRepositoryURL string
+	var environmentVariableWithToken map[string]string
+	var gitSource string
+	switch build.RepoSource {
+	case "bitbucket.org":
+		var accessToken string
+		accessToken, authenticatedRepositoryURL, err = rh.bitbucketJobVarsFunc(build.RepoSource, build.RepoOwner, build.RepoName)
+		if err != nil {
+			return
+		}
+		environmentVariableWithToken = map[string]string{"ESTAFETTE_BITBUCKET_API_TOKEN": accessToken}
+		gitSource = "bitbucket"
+
+	default:
+		// github.com and github enterprise hosts
+		var accessToken string
+		accessToken, authenticatedRepositoryURL, err = rh.githubJobVarsFunc(build.RepoSource, build.RepoOwner, build.RepoName)
+		if err != nil {
+			return
+		}
+		environmentVariableWithToken = map[string]string{"ESTAFETTE_GITHUB_API_TOKEN": accessToken}
+		gitSource = "github"
+	}
+
+	mft, err := manifest.ReadManifest(build.Manifest)
+	if err != nil {
+		return
+	}
+
+	// inject steps
+	mft, err = InjectSteps(mft, mft.Builder.Track, gitSource)
+	if err != nil {
+		return
+	}
+
+	insertedReleaseID, err := strconv.Atoi(insertedRelease.ID)
+	if err != nil {
+		return
+	}
+
+	// start release job
+	ciBuilderParams := CiBuilderParams{
+		JobType:              "release",
+		RepoSource:           build.RepoSource,
+		RepoOwner:            build.RepoOwner,
+		RepoName:             build.RepoName,
+		RepoURL:              authenticatedRepositoryURL,
+		RepoBranch:           build.RepoBranch,
+		RepoRevision:         build.RepoRevision,
+		EnvironmentVariables: environmentVariableWithToken,
+		Track:                mft.Builder.Track,
+		VersionNumber:        release.ReleaseVersion,
+		Manifest:             mft,
+		ReleaseID:            insertedReleaseID,
+		ReleaseName:          release.Name,
+		ReleaseAction:        release.Action,
+	}
+
+	go func(ciBuilderParams CiBuilderParams) {
+		_, err := rh.ciBuilderClient.CreateCiBuilderJob(ciBuilderParams)
+		if err != nil {
+			log.Error().Err(err).Msgf("Failed creating release job for %v/%v/%v/%v/%v version %v", ciBuilderParams.RepoSource, ciBuilderParams.RepoOwner, ciBuilderParams.RepoName, ciBuilderParams.RepoBranch, ciBuilderParams.RepoRevision, ciBuilderParams.VersionNumber)
+		}
+	}(ciBuilderParams)
+
+	return
+}
+
+// StartReleaseForRevision starts a release of the succeeded build of a revision, if it's built from a release branch and has the requested release target and action
+func (rh *releaseHelperImpl) StartReleaseForRevision(release cockroach.Release, revision string) (insertedRelease cockroach.Release, err error) {
+
+	build, err := rh.cockroachDBClient.GetPipelineBuild(release.RepoSource, release.RepoOwner, release.RepoName, revision, false)
+	if err != nil {
+		return
+	}
+	if build == nil || build.BuildStatus != "succeeded" {
+		return insertedRelease, fmt.Errorf("Pipeline %v/%v/%v has no succeeded build for revision %v", release.RepoSource, release.RepoOwner, release.RepoName, revision)
+	}
+	if !isReleaseBranch(build.ManifestObject, build.RepoBranch) {
+		return insertedRelease, fmt.Errorf("Build %v of pipeline %v/%v/%v is from branch %v, which isn't a release branch", build.BuildVersion, release.RepoSource, release.RepoOwner, release.RepoName, build.RepoBranch)
+	}
+
+	// check if release target and action exist
+	var releaseTarget *contracts.ReleaseTarget
+	for _, rt := range build.ReleaseTargets {
+		if rt.Name == release.Name {
+			releaseTarget = &rt
+			break
+		}
+	}
+	if releaseTarget == nil {
+		return insertedRelease, fmt.Errorf("Build %v of pipeline %v/%v/%v has no release %v", build.BuildVersion, release.RepoSource, release.RepoOwner, release.RepoName, release.Name)
+	}
+	actions := []string{}
+	for _, a := range releaseTarget.Actions {
+		actions = append(actions, a.Name)
+	}
+	if (release.Action == "" && len(actions) > 0) || (release.Action != "" && !stringArrayContains(actions, release.Action)) {
+		return insertedRelease, fmt.Errorf("Release %v for build %v of pipeline %v/%v/%v has no action '%v'", release.Name, build.BuildVersion, release.RepoSource, release.RepoOwner, release.RepoName, release.Action)
+	}
+
+	release.ReleaseVersion = build.BuildVersion
+	release.ReleaseStatus = "running"
+
+	return rh.StartRelease(release, *build)
+}
+
+// GetRollbackRelease returns a rollback release for the last succeeded version released to a target before the currently released one, together with the build to release
+func (rh *releaseHelperImpl) GetRollbackRelease(pipeline *cockroach.Pipeline, releaseName, releaseAction string) (rollbackRelease *cockroach.Release, build *cockroach.Build, err error) {
+
+	// check if release target exists and collect its actions
+	var releaseTarget *contracts.ReleaseTarget
+	for _, rt := range pipeline.ReleaseTargets {
+		if rt.Name == releaseName {
+			releaseTarget = &rt
+			break
+		}
+	}
+	if releaseTarget == nil {
+		return nil, nil, fmt.Errorf("Pipeline %v/%v/%v has no release %v", pipeline.RepoSource, pipeline.RepoOwner, pipeline.RepoName, releaseName)
+	}
+
+	actions := []string{}
+	for _, a := range releaseTarget.Actions {
+		actions = append(actions, a.Name)
+	}
+	if releaseAction != "" && !stringArrayContains(actions, releaseAction) {
+		return nil, nil, fmt.Errorf("Release %v for pipeline %v/%v/%v has no action %v", releaseName, pipeline.RepoSource, pipeline.RepoOwner, pipeline.RepoName, releaseAction)
+	}
+
+	// get the currently released version; without an explicit action the most recently used one is rolled back
+	lastReleases, err := rh.cockroachDBClient.GetPipelineLastReleasesByName(pipeline.RepoSource, pipeline.RepoOwner, pipeline.RepoName, releaseName, actions)
+	if err != nil {
+		return
+	}
+	var currentRelease *contracts.Release
+	for i, r := range lastReleases {
+		if releaseAction == "" || r.Action == releaseAction {
+			currentRelease = &lastReleases[i]
+			break
+		}
+	}
+	if currentRelease == nil {
+		return nil, nil, fmt.Errorf("Release %v for pipeline %v/%v/%v has never been released", releaseName, pipeline.RepoSource, pipeline.RepoOwner, pipeline.RepoName)
+	}
+	if currentRelease.ReleaseStatus == "running" {
+		return nil, nil, fmt.Errorf("Release %v for pipeline %v/%v/%v is still running version %v", releaseName, pipeline.RepoSource, pipeline.RepoOwner, pipeline.RepoName, currentRelease.ReleaseVersion)
+	}
+
+	// walk the succeeded releases history until hitting another version than the current one
+	filters := map[string][]string{
+		"status":  []string{"succeeded"},
+		"since":   []string{"eternity"},
+		"release": []string{releaseName},
+		"action":  []string{currentRelease.Action},
+	}
+	pageSize := 20
+	for pageNumber := 1; rollbackRelease == nil; pageNumber++ {
+		releases, err := rh.cockroachDBClient.GetPipelineReleases(pipeline.RepoSource, pipeline.RepoOwner, pipeline.RepoName, pageNumber, pageSize, filters)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, r := range releases {
+			if r.ReleaseVersion != currentRelease.ReleaseVersion && r.InsertedAt != nil && currentRelease.InsertedAt != nil && r.InsertedAt.Before(*currentRelease.InsertedAt) {
+				rollbackRelease = r
+				break
+			}
+		}
+		if len(releases) < pageSize {
+			break
+		}
+	}
+	if rollbackRelease == nil {
+		return nil, nil, fmt.Errorf("Release %v for pipeline %v/%v/%v has no succeeded version before %v", releaseName, pipeline.RepoSource, pipeline.RepoOwner, pipeline.RepoName, currentRelease.ReleaseVersion)
+	}
+
+	// get succeeded build for the version to roll back to
+	builds, err := rh.cockroachDBClient.GetPipelineBuildsByVersion(pipeline.RepoSource, pipeline.RepoOwner, pipeline.RepoName, rollbackRelease.ReleaseVersion, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, b := range builds {
+		if b.BuildStatus == "succeeded" {
+			build = b
+			break
+		}
+	}
+	if build == nil {
+		return nil, nil, fmt.Errorf("Pipeline %v/%v/%v has no succeeded build for version %v", pipeline.RepoSource, pipeline.RepoOwner, pipeline.RepoName, rollbackRelease.ReleaseVersion)
+	}
+
+	rollbackRelease = &cockroach.Release{
+		Release: contracts.Release{
+			Name:           releaseName,
+			Action:         currentRelease.Action,
+			RepoSource:     pipeline.RepoSource,
+			RepoOwner:      pipeline.RepoOwner,
+			RepoName:       pipeline.RepoName,
+			ReleaseVersion: rollbackRelease.ReleaseVersion,
+			ReleaseStatus:  "running",
+		},
+		IsRollback: true,
+	}
+
+	return
+}
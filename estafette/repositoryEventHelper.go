@@ -0,0 +1,120 @@
+package estafette
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/estafette/estafette-ci-api/cockroach"
+	"github.com/rs/zerolog/log"
+)
+
+// RepositoryEventHelper keeps pipelines in sync with branches and repositories being deleted, renamed or transferred
+type RepositoryEventHelper interface {
+	BranchDeleted(string, string, string, string) error
+	RepositoryRenamed(string, string, string, string, string) error
+	RepositoryDeleted(string, string, string) error
+}
+
+type repositoryEventHelperImpl struct {
+	cockroachDBClient cockroach.DBClient
+	ciBuilderClient   CiBuilderClient
+	buildStatusHelper BuildStatusHelper
+}
+
+// NewRepositoryEventHelper returns a new estafette.RepositoryEventHelper
+func NewRepositoryEventHelper(cockroachDBClient cockroach.DBClient, ciBuilderClient CiBuilderClient, buildStatusHelper BuildStatusHelper) RepositoryEventHelper {
+	return &repositoryEventHelperImpl{
+		cockroachDBClient: cockroachDBClient,
+		ciBuilderClient:   ciBuilderClient,
+		buildStatusHelper: buildStatusHelper,
+	}
+}
+
+// BranchDeleted cancels all running builds for the deleted branch
+func (rh *repositoryEventHelperImpl) BranchDeleted(repoSource, repoOwner, repoName, repoBranch string) (err error) {
+
+	filters := map[string][]string{
+		"branch": []string{repoBranch},
+		"status": []string{"running"},
+	}
+
+	// collect the running builds of all pages first, canceling them while paging would shift the later pages
+	builds := make([]*cockroach.Build, 0)
+	pageSize := 100
+	for pageNumber := 1; ; pageNumber++ {
+		pageBuilds, err := rh.cockroachDBClient.GetPipelineBuilds(repoSource, repoOwner, repoName, pageNumber, pageSize, filters, true)
+		if err != nil {
+			return err
+		}
+		builds = append(builds, pageBuilds...)
+
+		if len(pageBuilds) < pageSize {
+			break
+		}
+	}
+
+	for _, build := range builds {
+		buildID, err := strconv.Atoi(build.ID)
+		if err != nil {
+			log.Error().Err(err).Msgf("Failed to convert build id %v to int for canceling build of deleted branch %v", build.ID, repoBranch)
+			continue
+		}
+
+		log.Info().Msgf("Canceling build %v of %v/%v/%v because branch %v has been deleted", build.BuildVersion, repoSource, repoOwner, repoName, repoBranch)
+
+		jobName := rh.ciBuilderClient.GetJobName("build", build.RepoOwner, build.RepoName, build.ID)
+		buildStatus := "canceling"
+		if err = rh.ciBuilderClient.CancelCiBuilderJob(jobName); err != nil {
+			// job might not have created a builder yet, so set status to canceled straightaway
+			buildStatus = "canceled"
+		}
+
+		err = rh.cockroachDBClient.UpdateBuildStatus(build.RepoSource, build.RepoOwner, build.RepoName, buildID, buildStatus)
+		if err != nil {
+			log.Error().Err(err).Msgf("Failed updating status of build %v of %v/%v/%v to %v", build.BuildVersion, repoSource, repoOwner, repoName, buildStatus)
+			continue
+		}
+
+		build.BuildStatus = buildStatus
+		go rh.buildStatusHelper.ReportBuildStatus(*build, nil)
+	}
+
+	return nil
+}
+
+// RepositoryRenamed moves the builds, releases and computed rows of a renamed or transferred repository to its new owner and name, so its history doesn't get orphaned
+func (rh *repositoryEventHelperImpl) RepositoryRenamed(repoSource, fromRepoOwner, fromRepoName, toRepoOwner, toRepoName string) (err error) {
+
+	if fromRepoOwner == toRepoOwner && fromRepoName == toRepoName {
+		return
+	}
+
+	pipeline, err := rh.cockroachDBClient.GetPipeline(repoSource, fromRepoOwner, fromRepoName, true)
+	if err != nil {
+		return
+	}
+	if pipeline == nil {
+		// the repository has never been built, nothing to move
+		return
+	}
+
+	existingPipeline, err := rh.cockroachDBClient.GetPipeline(repoSource, toRepoOwner, toRepoName, true)
+	if err != nil {
+		return
+	}
+	if existingPipeline != nil {
+		return fmt.Errorf("Pipeline %v/%v/%v can't be renamed to %v/%v/%v because that pipeline already exists", repoSource, fromRepoOwner, fromRepoName, repoSource, toRepoOwner, toRepoName)
+	}
+
+	log.Info().Msgf("Renaming pipeline %v/%v/%v to %v/%v/%v", repoSource, fromRepoOwner, fromRepoName, repoSource, toRepoOwner, toRepoName)
+
+	return rh.cockroachDBClient.RenamePipeline(repoSource, fromRepoOwner, fromRepoName, toRepoOwner, toRepoName)
+}
+
+// RepositoryDeleted archives the pipeline of a deleted repository
+func (rh *repositoryEventHelperImpl) RepositoryDeleted(repoSource, repoOwner, repoName string) (err error) {
+
+	log.Info().Msgf("Archiving pipeline %v/%v/%v because its repository has been deleted", repoSource, repoOwner, repoName)
+
+	return rh.cockroachDBClient.ArchiveComputedPipeline(repoSource, repoOwner, repoName)
+}
@@ -0,0 +1,74 @@
+package estafette
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+
+	manifest "github.com/estafette/estafette-ci-manifest"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// versionTagRegex matches the {{tag}} function in version templates
+var versionTagRegex = regexp.MustCompile(`\{\{\s*tag\s*\}\}`)
+
+// GetTagTriggers reads the tag triggers from the triggers section of a manifest
+func GetTagTriggers(manifestString string) (triggers []TagTrigger, err error) {
+
+	var aux struct {
+		Triggers []TagTrigger `yaml:"triggers"`
+	}
+
+	if err = yaml.Unmarshal([]byte(manifestString), &aux); err != nil {
+		return
+	}
+
+	triggers = make([]TagTrigger, 0)
+	for _, t := range aux.Triggers {
+		if t.Tag != "" {
+			triggers = append(triggers, t)
+		}
+	}
+
+	return
+}
+
+// IsTagTriggered returns true if the manifest has a tag trigger matching the pushed tag
+func IsTagTriggered(manifestString, tag string) (bool, error) {
+
+	triggers, err := GetTagTriggers(manifestString)
+	if err != nil {
+		return false, err
+	}
+
+	for _, t := range triggers {
+		if matched, err := path.Match(t.Tag, tag); err == nil && matched {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// WithVersionTag returns a copy of the version with {{tag}} in its templates replaced by the pushed git tag, empty for branch pushes; the manifest library only provides auto, branch and revision to version templates
+func WithVersionTag(version manifest.EstafetteVersion, tag string) manifest.EstafetteVersion {
+
+	// a quoted string is a valid template action, so the tag can't break the template
+	replaceTag := func(templateText string) string {
+		return versionTagRegex.ReplaceAllLiteralString(templateText, fmt.Sprintf("{{%q}}", tag))
+	}
+
+	if version.SemVer != nil {
+		semver := *version.SemVer
+		semver.Patch = replaceTag(semver.Patch)
+		semver.LabelTemplate = replaceTag(semver.LabelTemplate)
+		version.SemVer = &semver
+	}
+	if version.Custom != nil {
+		custom := *version.Custom
+		custom.LabelTemplate = replaceTag(custom.LabelTemplate)
+		version.Custom = &custom
+	}
+
+	return version
+}
@@ -0,0 +1,79 @@
+package estafette
+
+import (
+	"testing"
+
+	manifest "github.com/estafette/estafette-ci-manifest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsTagTriggered(t *testing.T) {
+
+	manifestString := `
+builder:
+  track: stable
+
+triggers:
+- cron: '0 3 * * *'
+- tag: 'v*'
+
+stages:
+  build:
+    image: golang:1.11.2-alpine3.8
+`
+
+	t.Run("ReturnsTrueIfTagMatchesTagTrigger", func(t *testing.T) {
+
+		// act
+		triggered, err := IsTagTriggered(manifestString, "v1.2.0")
+
+		assert.Nil(t, err)
+		assert.True(t, triggered)
+	})
+
+	t.Run("ReturnsFalseIfTagDoesNotMatchTagTrigger", func(t *testing.T) {
+
+		// act
+		triggered, err := IsTagTriggered(manifestString, "release-1.2.0")
+
+		assert.Nil(t, err)
+		assert.False(t, triggered)
+	})
+
+	t.Run("ReturnsFalseIfManifestHasNoTagTriggers", func(t *testing.T) {
+
+		// act
+		triggered, err := IsTagTriggered("builder:\n  track: stable\n", "v1.2.0")
+
+		assert.Nil(t, err)
+		assert.False(t, triggered)
+	})
+}
+
+func TestWithVersionTag(t *testing.T) {
+
+	t.Run("ReplacesTagInVersionTemplatesOfCopy", func(t *testing.T) {
+
+		version := manifest.EstafetteVersion{
+			SemVer: &manifest.EstafetteSemverVersion{Major: 1, Minor: 2, Patch: "{{auto}}", LabelTemplate: "{{ tag }}", ReleaseBranch: manifest.StringOrStringArray{Values: []string{"master"}}},
+		}
+
+		// act
+		result := WithVersionTag(version, "v1.2.0")
+
+		assert.Equal(t, "1.2.5-v1.2.0", result.Version(manifest.EstafetteVersionParams{AutoIncrement: 5, Branch: "feature"}))
+		assert.Equal(t, "{{ tag }}", version.SemVer.LabelTemplate)
+	})
+
+	t.Run("ReplacesTagInCustomVersion", func(t *testing.T) {
+
+		version := manifest.EstafetteVersion{
+			Custom: &manifest.EstafetteCustomVersion{LabelTemplate: "{{tag}}-{{auto}}"},
+		}
+
+		// act
+		result := WithVersionTag(version, "v1.2.0")
+
+		assert.Equal(t, "v1.2.0-5", result.Version(manifest.EstafetteVersionParams{AutoIncrement: 5}))
+	})
+}
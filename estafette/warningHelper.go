@@ -1,9 +1,9 @@
 package estafette
 
 import (
-	"fmt"
-	"strings"
+	"sync"
 
+	"github.com/estafette/estafette-ci-api/config"
 	"github.com/estafette/estafette-ci-contracts"
 	manifest "github.com/estafette/estafette-ci-manifest"
 )
@@ -11,16 +11,22 @@ import (
 // WarningHelper checks whether any warnings should be issued
 type WarningHelper interface {
 	GetManifestWarnings(*manifest.EstafetteManifest, string) ([]contracts.Warning, error)
+	LintManifest(*manifest.EstafetteManifest, string) []ManifestLintResult
 	GetContainerImageParts(string) (string, string, string)
+	UpdateConfig(config.APIConfig)
 }
 
 type warningHelperImpl struct {
+	config      config.APIConfig
+	configMutex sync.RWMutex
 }
 
-// NewWarningHelper returns a new estafette.WarningHelper
-func NewWarningHelper() (warningHelper WarningHelper) {
+// NewWarningHelper returns a new estafette.WarningHelper; the config determines which manifest rules are enabled for which owner and which images are trusted
+func NewWarningHelper(config config.APIConfig) (warningHelper WarningHelper) {
 
-	warningHelper = &warningHelperImpl{}
+	warningHelper = &warningHelperImpl{
+		config: config,
+	}
 
 	return
 }
@@ -28,61 +34,46 @@ func NewWarningHelper() (warningHelper WarningHelper) {
 func (w *warningHelperImpl) GetManifestWarnings(manifest *manifest.EstafetteManifest, gitOwner string) (warnings []contracts.Warning, err error) {
 	warnings = []contracts.Warning{}
 
-	// unmarshal then marshal manifest to include defaults
-	if manifest != nil {
-		// check all build and release stages to have a pinned version
-		stagesUsingLatestTag := []string{}
-		stagesUsingDevTag := []string{}
-		for _, s := range manifest.Stages {
-			containerImageRepo, _, containerImageTag := w.GetContainerImageParts(s.ContainerImage)
-			if containerImageTag == "latest" {
-				stagesUsingLatestTag = append(stagesUsingLatestTag, s.Name)
-			}
-			if containerImageTag == "dev" && containerImageRepo == "extensions" && gitOwner != "estafette" {
-				stagesUsingDevTag = append(stagesUsingDevTag, s.Name)
-			}
-		}
-
-		for _, r := range manifest.Releases {
-			for _, s := range r.Stages {
-				containerImageRepo, _, containerImageTag := w.GetContainerImageParts(s.ContainerImage)
-				if containerImageTag == "latest" {
-					stagesUsingLatestTag = append(stagesUsingLatestTag, fmt.Sprintf("%v/%v", r.Name, s.Name))
-				}
-				if containerImageTag == "dev" && containerImageRepo == "extensions" && gitOwner != "estafette" {
-					stagesUsingDevTag = append(stagesUsingDevTag, s.Name)
-				}
-			}
-		}
-
-		if len(stagesUsingLatestTag) > 0 {
-			warnings = append(warnings, contracts.Warning{
-				Status:  "warning",
-				Message: fmt.Sprintf("This pipeline has one or more stages that use **latest** or no tag for its container image: `%v`; it is [best practice](https://estafette.io/usage/best-practices/#pin-image-versions) to pin stage images to specific versions so you don't spend hours tracking down build failures because the used image has changed.", strings.Join(stagesUsingLatestTag, ", ")),
-			})
-		}
-		if len(stagesUsingDevTag) > 0 {
-			warnings = append(warnings, contracts.Warning{
-				Status:  "warning",
-				Message: fmt.Sprintf("This pipeline has one or more stages that use the **dev** tag for its container image: `%v`; it is [best practice](https://estafette.io/usage/best-practices/#avoid-using-estafette-s-dev-or-beta-tags) to avoid the dev tag alltogether, since it can be broken at any time.", strings.Join(stagesUsingDevTag, ", ")),
-			})
-		}
+	// consumers of pipeline and build warnings only know the warning status; the severity of each rule is reported by POST /api/manifest/validate
+	for _, result := range w.LintManifest(manifest, gitOwner) {
+		warnings = append(warnings, contracts.Warning{
+			Status:  "warning",
+			Message: result.Message,
+		})
 	}
 
 	return
 }
 
+// LintManifest checks the manifest against all rules enabled for the owner
+func (w *warningHelperImpl) LintManifest(manifest *manifest.EstafetteManifest, gitOwner string) []ManifestLintResult {
+
+	config := w.getConfig()
+
+	return lintManifest(manifestRules, func(rule ManifestRule) bool {
+		return config.ManifestRules.IsEnabled(rule.ID, gitOwner, rule.EnabledByDefault)
+	}, manifestRuleContext{
+		manifest:      manifest,
+		gitOwner:      gitOwner,
+		trustedImages: config.TrustedImages,
+	})
+}
+
 func (w *warningHelperImpl) GetContainerImageParts(containerImage string) (repo, name, tag string) {
-	containerImageArray := strings.Split(containerImage, ":")
-	tag = "latest"
-	if len(containerImageArray) > 1 {
-		tag = containerImageArray[1]
-		containerImageArray = strings.Split(containerImageArray[0], "/")
-		if len(containerImageArray) > 0 {
-			name = containerImageArray[len(containerImageArray)-1]
-			repo = strings.Join(containerImageArray[:len(containerImageArray)-1], "/")
-		}
-	}
+	return getContainerImageParts(containerImage)
+}
 
-	return
+// UpdateConfig swaps in a reloaded config; manifests checked from then on use its manifest rules and trusted images
+func (w *warningHelperImpl) UpdateConfig(config config.APIConfig) {
+	w.configMutex.Lock()
+	defer w.configMutex.Unlock()
+
+	w.config = config
+}
+
+func (w *warningHelperImpl) getConfig() config.APIConfig {
+	w.configMutex.RLock()
+	defer w.configMutex.RUnlock()
+
+	return w.config
 }
@@ -3,13 +3,15 @@ package estafette
 import (
 	"testing"
 
+	"github.com/estafette/estafette-ci-api/config"
+	"github.com/estafette/estafette-ci-contracts"
 	"github.com/estafette/estafette-ci-manifest"
 
 	"github.com/stretchr/testify/assert"
 )
 
 var (
-	helper = NewWarningHelper()
+	helper = NewWarningHelper(config.APIConfig{})
 )
 
 func TestGetManifestWarnings(t *testing.T) {
@@ -92,6 +94,31 @@ func TestGetManifestWarnings(t *testing.T) {
 		assert.Equal(t, "This pipeline has one or more stages that use the **dev** tag for its container image: `build`; it is [best practice](https://estafette.io/usage/best-practices/#avoid-using-estafette-s-dev-or-beta-tags) to avoid the dev tag alltogether, since it can be broken at any time.", warnings[0].Message)
 	})
 
+	t.Run("ReturnsWarningWithReleaseNameIfReleaseStageContainerImageUsesExtensionWithDevTag", func(t *testing.T) {
+
+		mft := &manifest.EstafetteManifest{
+			Releases: []*manifest.EstafetteRelease{
+				&manifest.EstafetteRelease{
+					Name:            "production",
+					CloneRepository: true,
+					Stages: []*manifest.EstafetteStage{
+						&manifest.EstafetteStage{
+							Name:           "deploy",
+							ContainerImage: "extensions/gke:dev",
+						},
+					},
+				},
+			},
+		}
+
+		// act
+		warnings, err := helper.GetManifestWarnings(mft, "extensions")
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(warnings))
+		assert.Equal(t, "This pipeline has one or more stages that use the **dev** tag for its container image: `production/deploy`; it is [best practice](https://estafette.io/usage/best-practices/#avoid-using-estafette-s-dev-or-beta-tags) to avoid the dev tag alltogether, since it can be broken at any time.", warnings[0].Message)
+	})
+
 	t.Run("ReturnsNoWarningIfStageContainerImageUsesDevTagForNonExtensionImage", func(t *testing.T) {
 
 		mft := &manifest.EstafetteManifest{
@@ -129,6 +156,29 @@ func TestGetManifestWarnings(t *testing.T) {
 	})
 }
 
+func TestGetManifestWarningsStatus(t *testing.T) {
+
+	t.Run("ReturnsWarningStatusForRulesWithOtherSeverities", func(t *testing.T) {
+
+		mft := &manifest.EstafetteManifest{
+			Stages: []*manifest.EstafetteStage{
+				&manifest.EstafetteStage{
+					Name:           "bake",
+					ContainerImage: "golang:1.11.2-alpine3.8",
+					Commands:       []string{"docker build ."},
+				},
+			},
+		}
+
+		// act
+		warnings, err := helper.GetManifestWarnings(mft, "estafette")
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(warnings))
+		assert.Equal(t, "warning", warnings[0].Status)
+	})
+}
+
 func TestGetContainerImageParts(t *testing.T) {
 
 	t.Run("ReturnsEmptyRepoIfOfficialDockerHubImage", func(t *testing.T) {
@@ -251,3 +301,226 @@ func TestGetContainerImageParts(t *testing.T) {
 		assert.Equal(t, "latest", tag)
 	})
 }
+
+func TestLintManifest(t *testing.T) {
+
+	t.Run("ReturnsResultWithRuleIDSeverityAndDocsURLForNotificationStageWithoutWhen", func(t *testing.T) {
+
+		mft := &manifest.EstafetteManifest{
+			Stages: []*manifest.EstafetteStage{
+				&manifest.EstafetteStage{
+					Name:           "slack-notify",
+					ContainerImage: "extensions/slack-build-status:stable",
+					When:           "status == 'succeeded'",
+				},
+			},
+		}
+
+		// act
+		results := helper.LintManifest(mft, "estafette")
+
+		assert.Equal(t, 1, len(results))
+		assert.Equal(t, "notification-without-when", results[0].RuleID)
+		assert.Equal(t, "warning", results[0].Severity)
+		assert.Equal(t, "https://estafette.io/usage/manifest/#when", results[0].DocsURL)
+	})
+
+	t.Run("ReturnsResultForReleaseWithoutCloneUsingHelm", func(t *testing.T) {
+
+		mft := &manifest.EstafetteManifest{
+			Releases: []*manifest.EstafetteRelease{
+				&manifest.EstafetteRelease{
+					Name: "production",
+					Stages: []*manifest.EstafetteStage{
+						&manifest.EstafetteStage{
+							Name:           "deploy",
+							ContainerImage: "extensions/helm:stable",
+						},
+					},
+				},
+			},
+		}
+
+		// act
+		results := helper.LintManifest(mft, "estafette")
+
+		assert.Equal(t, 1, len(results))
+		assert.Equal(t, "release-without-clone", results[0].RuleID)
+		assert.Equal(t, "Stage `production/deploy` needs files from the repository, but release `production` doesn't clone it; set `clone: true` on the release.", results[0].Message)
+	})
+
+	t.Run("ReturnsResultForUntrustedImageRunningDocker", func(t *testing.T) {
+
+		mft := &manifest.EstafetteManifest{
+			Stages: []*manifest.EstafetteStage{
+				&manifest.EstafetteStage{
+					Name:           "bake",
+					ContainerImage: "golang:1.11.2-alpine3.8",
+					Commands:       []string{"docker build ."},
+				},
+			},
+		}
+
+		// act
+		results := helper.LintManifest(mft, "estafette")
+
+		assert.Equal(t, 1, len(results))
+		assert.Equal(t, "untrusted-image-runs-docker", results[0].RuleID)
+		assert.Equal(t, "error", results[0].Severity)
+	})
+
+	t.Run("ReturnsResultForUntrustedImageUsingDockerLaterInCommandOrThroughItsSocket", func(t *testing.T) {
+
+		mft := &manifest.EstafetteManifest{
+			Stages: []*manifest.EstafetteStage{
+				&manifest.EstafetteStage{
+					Name:           "bake",
+					ContainerImage: "golang:1.11.2-alpine3.8",
+					Commands:       []string{"cd app && sudo docker build ."},
+				},
+				&manifest.EstafetteStage{
+					Name:           "push",
+					ContainerImage: "golang:1.11.2-alpine3.8",
+					Commands:       []string{"curl --unix-socket /var/run/docker.sock http://localhost/images/json"},
+				},
+				&manifest.EstafetteStage{
+					Name:           "build",
+					ContainerImage: "golang:1.11.2-alpine3.8",
+					Commands:       []string{"go build -o dockerize ./..."},
+				},
+			},
+		}
+
+		// act
+		results := helper.LintManifest(mft, "estafette")
+
+		assert.Equal(t, 2, len(results))
+		assert.Equal(t, "untrusted-image-runs-docker", results[0].RuleID)
+		assert.Equal(t, []string{"stages", "bake", "commands"}, results[0].Path)
+		assert.Equal(t, []string{"stages", "push", "commands"}, results[1].Path)
+	})
+
+	t.Run("ReturnsResultForImageNotTrustedToRunPrivilegedRunningPrivilegedCommands", func(t *testing.T) {
+
+		trustingHelper := NewWarningHelper(config.APIConfig{
+			TrustedImages: []*contracts.TrustedImageConfig{
+				&contracts.TrustedImageConfig{ImagePath: "docker", RunDocker: true},
+			},
+		})
+		mft := &manifest.EstafetteManifest{
+			Stages: []*manifest.EstafetteStage{
+				&manifest.EstafetteStage{
+					Name:           "bake",
+					ContainerImage: "docker:18.09.0",
+					Commands:       []string{"docker build .", "mount -t tmpfs tmpfs /tmp/cache"},
+				},
+			},
+		}
+
+		// act
+		results := trustingHelper.LintManifest(mft, "estafette")
+
+		assert.Equal(t, 1, len(results))
+		assert.Equal(t, "untrusted-image-runs-docker", results[0].RuleID)
+		assert.Equal(t, "Stage `bake` runs commands that need a privileged container in image `docker:18.09.0`, which isn't a trusted image allowed to run privileged.", results[0].Message)
+	})
+
+	t.Run("ReturnsNoResultForTrustedImageRunningDocker", func(t *testing.T) {
+
+		trustingHelper := NewWarningHelper(config.APIConfig{
+			TrustedImages: []*contracts.TrustedImageConfig{
+				&contracts.TrustedImageConfig{ImagePath: "docker", RunDocker: true},
+			},
+		})
+		mft := &manifest.EstafetteManifest{
+			Stages: []*manifest.EstafetteStage{
+				&manifest.EstafetteStage{
+					Name:           "bake",
+					ContainerImage: "docker:18.09.0",
+					Commands:       []string{"docker build ."},
+				},
+			},
+		}
+
+		// act
+		results := trustingHelper.LintManifest(mft, "estafette")
+
+		assert.Equal(t, 0, len(results))
+	})
+
+	t.Run("ReturnsResultForStageWithTooManyRetries", func(t *testing.T) {
+
+		mft := &manifest.EstafetteManifest{
+			Stages: []*manifest.EstafetteStage{
+				&manifest.EstafetteStage{
+					Name:           "test",
+					ContainerImage: "golang:1.11.2-alpine3.8",
+					Retries:        5,
+				},
+			},
+		}
+
+		// act
+		results := helper.LintManifest(mft, "estafette")
+
+		assert.Equal(t, 1, len(results))
+		assert.Equal(t, "too-many-retries", results[0].RuleID)
+	})
+
+	t.Run("ReturnsResultForEachPlaintextSecretEnvVar", func(t *testing.T) {
+
+		mft := &manifest.EstafetteManifest{
+			GlobalEnvVars: map[string]string{
+				"NPM_TOKEN":  "abc123",
+				"LOG_LEVEL":  "debug",
+				"API_KEY":    "estafette.secret(deFTz5Bdjg6SUe29.oPIkXbze5G9PNEWS2-ZnArl8BCqHnx4MdTdxHg37th9u)",
+				"GIT_SECRET": "${GIT_SECRET_FROM_CI}",
+			},
+			Stages: []*manifest.EstafetteStage{
+				&manifest.EstafetteStage{
+					Name:           "test",
+					ContainerImage: "golang:1.11.2-alpine3.8",
+					EnvVars:        map[string]string{"DB_PASSWORD": "hunter2"},
+				},
+			},
+		}
+
+		// act
+		results := helper.LintManifest(mft, "estafette")
+
+		assert.Equal(t, 2, len(results))
+		assert.Equal(t, "plaintext-secret-env", results[0].RuleID)
+		assert.Equal(t, "Global environment variable `NPM_TOKEN` looks like a secret, but isn't encrypted; encrypt its value so it doesn't end up in the repository in plain text.", results[0].Message)
+		assert.Equal(t, "Environment variable `DB_PASSWORD` of stage `test` looks like a secret, but isn't encrypted; encrypt its value so it doesn't end up in the repository in plain text.", results[1].Message)
+	})
+
+	t.Run("ReturnsResultForMissingLabelsOnlyForOwnersItsEnabledFor", func(t *testing.T) {
+
+		configuredHelper := NewWarningHelper(config.APIConfig{
+			ManifestRules: &config.ManifestRulesConfig{
+				Disabled: []string{"image-tag-latest"},
+				Owners: []*config.ManifestRulesOwnerConfig{
+					&config.ManifestRulesOwnerConfig{Owner: "estafette", Enabled: []string{"missing-labels"}},
+				},
+			},
+		})
+		mft := &manifest.EstafetteManifest{
+			Labels: map[string]string{"app": "estafette-ci-api"},
+			Stages: []*manifest.EstafetteStage{
+				&manifest.EstafetteStage{
+					Name:           "build",
+					ContainerImage: "golang:latest",
+				},
+			},
+		}
+
+		// act
+		resultsForOwner := configuredHelper.LintManifest(mft, "estafette")
+		resultsForOthers := configuredHelper.LintManifest(mft, "other")
+
+		assert.Equal(t, 1, len(resultsForOwner))
+		assert.Equal(t, "missing-labels", resultsForOwner[0].RuleID)
+		assert.Equal(t, "This pipeline has no `team` label.", resultsForOwner[0].Message)
+		assert.Equal(t, 0, len(resultsForOthers))
+	})
+}
@@ -0,0 +1,225 @@
+package estafette
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// whenToken is a token of a when expression, with its 1-based position in the expression
+type whenToken struct {
+	kind     string
+	value    string
+	position int
+}
+
+var (
+	whenOperators       = []string{"===", "!==", "==", "!=", "<=", ">=", "&&", "||", "<", ">", "!", "(", ")", "[", "]", ".", ",", "?", ":", "+", "-", "*", "/", "%"}
+	whenBinaryOperators = []string{"||", "&&", "===", "!==", "==", "!=", "<=", ">=", "<", ">", "+", "-", "*", "/", "%"}
+)
+
+// validateWhenExpression checks that a when expression - like status == 'succeeded' && branch.startsWith('release') - parses as the javascript subset most manifests use; the builder evaluates full javascript, so an error only means the expression is likely to be wrong
+func validateWhenExpression(expression string) error {
+
+	tokens, err := tokenizeWhenExpression(expression)
+	if err != nil {
+		return fmt.Errorf("When expression %v is invalid: %v", expression, err)
+	}
+
+	parser := &whenParser{tokens: tokens}
+	err = parser.parseExpression()
+	if err == nil && parser.peek().kind != "end" {
+		err = parser.unexpected()
+	}
+	if err != nil {
+		return fmt.Errorf("When expression %v is invalid: %v", expression, err)
+	}
+
+	return nil
+}
+
+func tokenizeWhenExpression(expression string) (tokens []whenToken, err error) {
+
+	runes := []rune(expression)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case r == '\'' || r == '"':
+			start := i
+			i++
+			for i < len(runes) && runes[i] != r {
+				if runes[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("string at position %v isn't closed", start+1)
+			}
+			i++
+			tokens = append(tokens, whenToken{kind: "string", value: string(runes[start:i]), position: start + 1})
+
+		case unicode.IsDigit(r):
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, whenToken{kind: "number", value: string(runes[start:i]), position: start + 1})
+
+		case unicode.IsLetter(r) || r == '_' || r == '$':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_' || runes[i] == '$') {
+				i++
+			}
+			tokens = append(tokens, whenToken{kind: "identifier", value: string(runes[start:i]), position: start + 1})
+
+		default:
+			operator := ""
+			for _, o := range whenOperators {
+				if strings.HasPrefix(string(runes[i:]), o) {
+					operator = o
+					break
+				}
+			}
+			if operator == "" {
+				return nil, fmt.Errorf("unexpected character %v at position %v", string(r), i+1)
+			}
+			tokens = append(tokens, whenToken{kind: "operator", value: operator, position: i + 1})
+			i += len([]rune(operator))
+		}
+	}
+
+	return append(tokens, whenToken{kind: "end", position: len(runes) + 1}), nil
+}
+
+// whenParser is a recursive descent parser for when expressions; it only checks the syntax, evaluating is up to the builder
+type whenParser struct {
+	tokens []whenToken
+	index  int
+}
+
+func (p *whenParser) peek() whenToken {
+	return p.tokens[p.index]
+}
+
+func (p *whenParser) next() whenToken {
+	token := p.tokens[p.index]
+	if token.kind != "end" {
+		p.index++
+	}
+	return token
+}
+
+func (p *whenParser) accept(operators ...string) bool {
+	token := p.peek()
+	if token.kind == "operator" && stringArrayContains(operators, token.value) {
+		p.index++
+		return true
+	}
+	return false
+}
+
+func (p *whenParser) unexpected() error {
+	token := p.peek()
+	if token.kind == "end" {
+		return fmt.Errorf("unexpected end at position %v", token.position)
+	}
+	return fmt.Errorf("unexpected %v at position %v", token.value, token.position)
+}
+
+// parseExpression parses operands joined by binary operators, optionally followed by a ternary ? :
+func (p *whenParser) parseExpression() error {
+	for {
+		if err := p.parseUnary(); err != nil {
+			return err
+		}
+		if !p.accept(whenBinaryOperators...) {
+			break
+		}
+	}
+
+	if p.accept("?") {
+		if err := p.parseExpression(); err != nil {
+			return err
+		}
+		if !p.accept(":") {
+			return p.unexpected()
+		}
+		return p.parseExpression()
+	}
+
+	return nil
+}
+
+func (p *whenParser) parseUnary() error {
+	if p.accept("!", "-", "+") {
+		return p.parseUnary()
+	}
+	return p.parseOperand()
+}
+
+// parseList parses comma separated expressions up to the closing operator, for arguments and array literals
+func (p *whenParser) parseList(closing string) error {
+	if p.accept(closing) {
+		return nil
+	}
+	for {
+		if err := p.parseExpression(); err != nil {
+			return err
+		}
+		if p.accept(closing) {
+			return nil
+		}
+		if !p.accept(",") {
+			return p.unexpected()
+		}
+	}
+}
+
+// parseOperand parses a literal, array, parenthesized expression or variable, followed by optional property access, indexing and method calls, like branch.startsWith('release')
+func (p *whenParser) parseOperand() error {
+
+	switch {
+	case p.accept("("):
+		if err := p.parseExpression(); err != nil {
+			return err
+		}
+		if !p.accept(")") {
+			return p.unexpected()
+		}
+	case p.accept("["):
+		if err := p.parseList("]"); err != nil {
+			return err
+		}
+	case p.peek().kind == "string" || p.peek().kind == "number" || p.peek().kind == "identifier":
+		p.next()
+	default:
+		return p.unexpected()
+	}
+
+	for {
+		switch {
+		case p.accept("."):
+			if p.peek().kind != "identifier" {
+				return p.unexpected()
+			}
+			p.next()
+		case p.accept("("):
+			if err := p.parseList(")"); err != nil {
+				return err
+			}
+		case p.accept("["):
+			if err := p.parseExpression(); err != nil {
+				return err
+			}
+			if !p.accept("]") {
+				return p.unexpected()
+			}
+		default:
+			return nil
+		}
+	}
+}
@@ -1,6 +1,11 @@
 package contracts
 
-import "strings"
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"time"
+)
 
 // PushEvent represents a Github webhook push event
 type PushEvent struct {
@@ -11,18 +16,23 @@ type PushEvent struct {
 	Repository   Repository   `json:"repository"`
 	Installation Installation `json:"installation"`
 	Ref          string       `json:"ref"`
+	Deleted      bool         `json:"deleted"`
 }
 
 // Installation represents an installation of a Github app
 type Installation struct {
-	ID int `json:"id"`
+	ID      int    `json:"id"`
+	Account *Owner `json:"account,omitempty"`
 }
 
 // Commit represents a Github commit
 type Commit struct {
-	Author  Author `json:"author"`
-	Message string `json:"message"`
-	ID      string `json:"id"`
+	Author   Author   `json:"author"`
+	Message  string   `json:"message"`
+	ID       string   `json:"id"`
+	Added    []string `json:"added"`
+	Modified []string `json:"modified"`
+	Removed  []string `json:"removed"`
 }
 
 // Author represents a Github author
@@ -52,6 +62,15 @@ type AccessToken struct {
 	Token     string `json:"token"`
 }
 
+// ExpiresWithin returns true if the token expires within the duration, or if its expiry time is unknown
+func (at *AccessToken) ExpiresWithin(duration time.Duration) bool {
+	expiresAt, err := time.Parse(time.RFC3339, at.ExpiresAt)
+	if err != nil {
+		return true
+	}
+	return time.Now().Add(duration).After(expiresAt)
+}
+
 // RepositoryContent represents a file retrieved via the Github api
 type RepositoryContent struct {
 	Type     string `json:"type"`
@@ -65,7 +84,16 @@ type RepositoryContent struct {
 
 // GetRepoSource returns the repository source
 func (pe *PushEvent) GetRepoSource() string {
-	return "github.com"
+	return pe.Repository.GetRepoSource()
+}
+
+// GetRepoSource returns the host of the repository's html url, which is github.com unless the repository lives on a github enterprise host
+func (r *Repository) GetRepoSource() string {
+	htmlURL, err := url.Parse(r.HTMLURL)
+	if err != nil || htmlURL.Host == "" {
+		return "github.com"
+	}
+	return htmlURL.Host
 }
 
 // GetRepoOwner returns the repository owner
@@ -83,12 +111,256 @@ func (pe *PushEvent) GetRepoFullName() string {
 	return pe.Repository.FullName
 }
 
-// GetRepoBranch returns the branch of the push event
+// GetRepoBranch returns the branch of the push event; for tag pushes it returns the tag
 func (pe *PushEvent) GetRepoBranch() string {
+	if strings.HasPrefix(pe.Ref, "refs/tags/") {
+		return strings.Replace(pe.Ref, "refs/tags/", "", 1)
+	}
 	return strings.Replace(pe.Ref, "refs/heads/", "", 1)
 }
 
+// GetRepoTag returns the tag of the push event, or an empty string if a branch was pushed
+func (pe *PushEvent) GetRepoTag() string {
+	if strings.HasPrefix(pe.Ref, "refs/tags/") {
+		return strings.Replace(pe.Ref, "refs/tags/", "", 1)
+	}
+	return ""
+}
+
 // GetRepoRevision returns the revision of the push event
 func (pe *PushEvent) GetRepoRevision() string {
 	return pe.After
 }
+
+// GetChangedFiles returns the files added, modified or removed by the commits of the push event; it returns nil if they can't be determined from the payload, which holds at most 20 commits
+func (pe *PushEvent) GetChangedFiles() []string {
+
+	if len(pe.Commits) == 0 || len(pe.Commits) >= 20 {
+		return nil
+	}
+
+	changedFiles := make([]string, 0)
+	seen := map[string]bool{}
+	for _, c := range pe.Commits {
+		for _, files := range [][]string{c.Added, c.Modified, c.Removed} {
+			for _, f := range files {
+				if !seen[f] {
+					seen[f] = true
+					changedFiles = append(changedFiles, f)
+				}
+			}
+		}
+	}
+
+	return changedFiles
+}
+
+// DeleteEvent represents a Github webhook delete event, sent when a branch or tag is deleted
+type DeleteEvent struct {
+	Ref          string       `json:"ref"`
+	RefType      string       `json:"ref_type"`
+	Repository   Repository   `json:"repository"`
+	Installation Installation `json:"installation"`
+}
+
+// RepositoryEvent represents a Github webhook repository event
+type RepositoryEvent struct {
+	Action       string            `json:"action"`
+	Repository   Repository        `json:"repository"`
+	Changes      RepositoryChanges `json:"changes"`
+	Installation Installation      `json:"installation"`
+}
+
+// RepositoryChanges represents the previous name or owner of a renamed or transferred Github repository
+type RepositoryChanges struct {
+	Repository struct {
+		Name struct {
+			From string `json:"from"`
+		} `json:"name"`
+	} `json:"repository"`
+	Owner struct {
+		From struct {
+			User         *Owner `json:"user,omitempty"`
+			Organization *Owner `json:"organization,omitempty"`
+		} `json:"from"`
+	} `json:"owner"`
+}
+
+// Owner represents the user or organization owning a Github repository
+type Owner struct {
+	Login string `json:"login"`
+}
+
+// GetRepoOwner returns the repository owner
+func (de *DeleteEvent) GetRepoOwner() string {
+	return strings.Split(de.Repository.FullName, "/")[0]
+}
+
+// GetRepoName returns the repository name
+func (de *DeleteEvent) GetRepoName() string {
+	return de.Repository.Name
+}
+
+// GetRepoOwner returns the repository owner
+func (re *RepositoryEvent) GetRepoOwner() string {
+	return strings.Split(re.Repository.FullName, "/")[0]
+}
+
+// GetRepoName returns the repository name
+func (re *RepositoryEvent) GetRepoName() string {
+	return re.Repository.Name
+}
+
+// GetPreviousRepoOwner returns the owner before the repository got transferred; for other actions it returns the current owner
+func (re *RepositoryEvent) GetPreviousRepoOwner() string {
+	if re.Action == "transferred" {
+		if re.Changes.Owner.From.Organization != nil && re.Changes.Owner.From.Organization.Login != "" {
+			return re.Changes.Owner.From.Organization.Login
+		}
+		if re.Changes.Owner.From.User != nil && re.Changes.Owner.From.User.Login != "" {
+			return re.Changes.Owner.From.User.Login
+		}
+	}
+	return re.GetRepoOwner()
+}
+
+// GetPreviousRepoName returns the name before the repository got renamed; for other actions it returns the current name
+func (re *RepositoryEvent) GetPreviousRepoName() string {
+	if re.Action == "renamed" && re.Changes.Repository.Name.From != "" {
+		return re.Changes.Repository.Name.From
+	}
+	return re.GetRepoName()
+}
+
+// CheckRun represents a Github check run, reporting the status of a single stage of a build
+type CheckRun struct {
+	ID          int             `json:"id,omitempty"`
+	Name        string          `json:"name"`
+	HeadSHA     string          `json:"head_sha,omitempty"`
+	Status      string          `json:"status,omitempty"`
+	Conclusion  string          `json:"conclusion,omitempty"`
+	DetailsURL  string          `json:"details_url,omitempty"`
+	ExternalID  string          `json:"external_id,omitempty"`
+	CompletedAt *time.Time      `json:"completed_at,omitempty"`
+	Output      *CheckRunOutput `json:"output,omitempty"`
+	CheckSuite  *CheckSuite     `json:"check_suite,omitempty"`
+}
+
+// CheckRunOutput represents the title, summary and annotations shown for a Github check run
+type CheckRunOutput struct {
+	Title       string               `json:"title"`
+	Summary     string               `json:"summary"`
+	Text        string               `json:"text,omitempty"`
+	Annotations []CheckRunAnnotation `json:"annotations,omitempty"`
+}
+
+// CheckRunAnnotation represents a message attached to a line of a file in a Github check run
+type CheckRunAnnotation struct {
+	Path            string `json:"path"`
+	StartLine       int    `json:"start_line"`
+	EndLine         int    `json:"end_line"`
+	AnnotationLevel string `json:"annotation_level"`
+	Message         string `json:"message"`
+}
+
+// CheckSuite represents the Github check suite a check run belongs to
+type CheckSuite struct {
+	HeadBranch string `json:"head_branch"`
+	HeadSHA    string `json:"head_sha"`
+}
+
+// CheckRunsResponse represents the check runs for a commit as returned by the Github api
+type CheckRunsResponse struct {
+	TotalCount int        `json:"total_count"`
+	CheckRuns  []CheckRun `json:"check_runs"`
+}
+
+// CheckRunEvent represents a Github webhook check_run event
+type CheckRunEvent struct {
+	Action       string       `json:"action"`
+	CheckRun     CheckRun     `json:"check_run"`
+	Repository   Repository   `json:"repository"`
+	Sender       Owner        `json:"sender"`
+	Installation Installation `json:"installation"`
+}
+
+// GetRepoOwner returns the repository owner
+func (ce *CheckRunEvent) GetRepoOwner() string {
+	return strings.Split(ce.Repository.FullName, "/")[0]
+}
+
+// GetRepoBranch returns the branch the check run was created for
+func (ce *CheckRunEvent) GetRepoBranch() string {
+	if ce.CheckRun.CheckSuite == nil {
+		return ""
+	}
+	return ce.CheckRun.CheckSuite.HeadBranch
+}
+
+// Deployment represents a Github deployment of a commit to an environment
+type Deployment struct {
+	ID               int             `json:"id,omitempty"`
+	SHA              string          `json:"sha,omitempty"`
+	Ref              string          `json:"ref"`
+	Task             string          `json:"task,omitempty"`
+	Environment      string          `json:"environment"`
+	Description      string          `json:"description,omitempty"`
+	Payload          json.RawMessage `json:"payload,omitempty"`
+	AutoMerge        bool            `json:"auto_merge"`
+	RequiredContexts []string        `json:"required_contexts"`
+	Creator          *Owner          `json:"creator,omitempty"`
+}
+
+// DeploymentPayload represents the extra information Estafette stores in and reads from the payload of a Github deployment
+type DeploymentPayload struct {
+	ReleaseID string `json:"estafetteReleaseID,omitempty"`
+	Action    string `json:"action,omitempty"`
+}
+
+// DeploymentStatus represents the status of a Github deployment
+type DeploymentStatus struct {
+	ID          int    `json:"id,omitempty"`
+	State       string `json:"state"`
+	TargetURL   string `json:"target_url,omitempty"`
+	LogURL      string `json:"log_url,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// CollaboratorPermission represents the permission of a user on a Github repository
+type CollaboratorPermission struct {
+	Permission string `json:"permission"`
+	User       Owner  `json:"user"`
+}
+
+// DeploymentEvent represents a Github webhook deployment event
+type DeploymentEvent struct {
+	Deployment   Deployment   `json:"deployment"`
+	Repository   Repository   `json:"repository"`
+	Sender       Owner        `json:"sender"`
+	Installation Installation `json:"installation"`
+}
+
+// GetPayload returns the payload of the deployment; a payload that isn't a json object, for example a plain string, results in an empty payload
+func (d *Deployment) GetPayload() (payload DeploymentPayload) {
+	if len(d.Payload) > 0 {
+		json.Unmarshal(d.Payload, &payload)
+	}
+	return
+}
+
+// GetRepoOwner returns the repository owner
+func (de *DeploymentEvent) GetRepoOwner() string {
+	return strings.Split(de.Repository.FullName, "/")[0]
+}
+
+// GetRepoName returns the repository name
+func (de *DeploymentEvent) GetRepoName() string {
+	return de.Repository.Name
+}
+
+// InstallationEvent represents a Github webhook installation or installation_repositories event
+type InstallationEvent struct {
+	Action       string       `json:"action"`
+	Installation Installation `json:"installation"`
+	Sender       Owner        `json:"sender"`
+}
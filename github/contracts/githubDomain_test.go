@@ -0,0 +1,231 @@
+package contracts
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPushEventGetChangedFiles(t *testing.T) {
+
+	t.Run("ReturnsDistinctAddedModifiedAndRemovedFilesOfAllCommits", func(t *testing.T) {
+
+		pe := PushEvent{
+			Commits: []Commit{
+				Commit{Added: []string{"services/api/main.go"}, Modified: []string{"go.mod"}},
+				Commit{Modified: []string{"services/api/main.go"}, Removed: []string{"README.md"}},
+			},
+		}
+
+		// act
+		changedFiles := pe.GetChangedFiles()
+
+		assert.Equal(t, []string{"services/api/main.go", "go.mod", "README.md"}, changedFiles)
+	})
+
+	t.Run("ReturnsNilIfPushEventHasNoCommits", func(t *testing.T) {
+
+		pe := PushEvent{}
+
+		// act
+		changedFiles := pe.GetChangedFiles()
+
+		assert.Nil(t, changedFiles)
+	})
+
+	t.Run("ReturnsNilIfPushEventCommitsMightBeTruncated", func(t *testing.T) {
+
+		pe := PushEvent{
+			Commits: make([]Commit, 20),
+		}
+
+		// act
+		changedFiles := pe.GetChangedFiles()
+
+		assert.Nil(t, changedFiles)
+	})
+}
+
+func TestRepositoryGetRepoSource(t *testing.T) {
+
+	t.Run("ReturnsGithubComForRepositoryOnGithubCom", func(t *testing.T) {
+
+		r := Repository{HTMLURL: "https://github.com/estafette/estafette-ci-api"}
+
+		// act
+		repoSource := r.GetRepoSource()
+
+		assert.Equal(t, "github.com", repoSource)
+	})
+
+	t.Run("ReturnsEnterpriseHostForRepositoryOnGithubEnterprise", func(t *testing.T) {
+
+		r := Repository{HTMLURL: "https://github.estafette.io/estafette/estafette-ci-api"}
+
+		// act
+		repoSource := r.GetRepoSource()
+
+		assert.Equal(t, "github.estafette.io", repoSource)
+	})
+
+	t.Run("ReturnsGithubComIfRepositoryHasNoHtmlURL", func(t *testing.T) {
+
+		r := Repository{}
+
+		// act
+		repoSource := r.GetRepoSource()
+
+		assert.Equal(t, "github.com", repoSource)
+	})
+}
+
+func TestPushEventGetRepoTag(t *testing.T) {
+
+	t.Run("ReturnsTagForTagPush", func(t *testing.T) {
+
+		pe := PushEvent{
+			Ref: "refs/tags/v1.2.0",
+		}
+
+		// act
+		tag := pe.GetRepoTag()
+
+		assert.Equal(t, "v1.2.0", tag)
+		assert.Equal(t, "v1.2.0", pe.GetRepoBranch())
+	})
+
+	t.Run("ReturnsEmptyStringForBranchPush", func(t *testing.T) {
+
+		pe := PushEvent{
+			Ref: "refs/heads/master",
+		}
+
+		// act
+		tag := pe.GetRepoTag()
+
+		assert.Equal(t, "", tag)
+		assert.Equal(t, "master", pe.GetRepoBranch())
+	})
+}
+
+func TestRepositoryEventGetPreviousRepoOwnerAndName(t *testing.T) {
+
+	t.Run("ReturnsNameFromChangesForRenamedRepository", func(t *testing.T) {
+
+		re := RepositoryEvent{
+			Action:     "renamed",
+			Repository: Repository{Name: "estafette-ci-api", FullName: "estafette/estafette-ci-api"},
+		}
+		re.Changes.Repository.Name.From = "estafette-api"
+
+		// act
+		owner := re.GetPreviousRepoOwner()
+		name := re.GetPreviousRepoName()
+
+		assert.Equal(t, "estafette", owner)
+		assert.Equal(t, "estafette-api", name)
+	})
+
+	t.Run("ReturnsOrganizationFromChangesForTransferredRepository", func(t *testing.T) {
+
+		re := RepositoryEvent{
+			Action:     "transferred",
+			Repository: Repository{Name: "estafette-ci-api", FullName: "estafette/estafette-ci-api"},
+		}
+		re.Changes.Owner.From.Organization = &Owner{Login: "jorrit"}
+
+		// act
+		owner := re.GetPreviousRepoOwner()
+		name := re.GetPreviousRepoName()
+
+		assert.Equal(t, "jorrit", owner)
+		assert.Equal(t, "estafette-ci-api", name)
+	})
+
+	t.Run("ReturnsUserFromChangesForRepositoryTransferredFromUser", func(t *testing.T) {
+
+		re := RepositoryEvent{
+			Action:     "transferred",
+			Repository: Repository{Name: "estafette-ci-api", FullName: "estafette/estafette-ci-api"},
+		}
+		re.Changes.Owner.From.User = &Owner{Login: "jorrit"}
+
+		// act
+		owner := re.GetPreviousRepoOwner()
+
+		assert.Equal(t, "jorrit", owner)
+	})
+}
+
+func TestDeploymentGetPayload(t *testing.T) {
+
+	t.Run("ReturnsReleaseIDAndActionFromObjectPayload", func(t *testing.T) {
+
+		d := Deployment{
+			Payload: []byte(`{"estafetteReleaseID":"390605593734184965","action":"deploy-canary"}`),
+		}
+
+		// act
+		payload := d.GetPayload()
+
+		assert.Equal(t, "390605593734184965", payload.ReleaseID)
+		assert.Equal(t, "deploy-canary", payload.Action)
+	})
+
+	t.Run("ReturnsEmptyPayloadForStringPayload", func(t *testing.T) {
+
+		d := Deployment{
+			Payload: []byte(`"deploy everything"`),
+		}
+
+		// act
+		payload := d.GetPayload()
+
+		assert.Equal(t, "", payload.ReleaseID)
+		assert.Equal(t, "", payload.Action)
+	})
+
+	t.Run("ReturnsEmptyPayloadIfDeploymentHasNoPayload", func(t *testing.T) {
+
+		d := Deployment{}
+
+		// act
+		payload := d.GetPayload()
+
+		assert.Equal(t, "", payload.ReleaseID)
+	})
+}
+
+func TestAccessTokenExpiresWithin(t *testing.T) {
+
+	t.Run("ReturnsFalseIfTokenExpiresAfterDuration", func(t *testing.T) {
+
+		at := AccessToken{ExpiresAt: time.Now().Add(time.Hour).UTC().Format(time.RFC3339)}
+
+		// act
+		expires := at.ExpiresWithin(5 * time.Minute)
+
+		assert.False(t, expires)
+	})
+
+	t.Run("ReturnsTrueIfTokenExpiresWithinDuration", func(t *testing.T) {
+
+		at := AccessToken{ExpiresAt: time.Now().Add(2 * time.Minute).UTC().Format(time.RFC3339)}
+
+		// act
+		expires := at.ExpiresWithin(5 * time.Minute)
+
+		assert.True(t, expires)
+	})
+
+	t.Run("ReturnsTrueIfExpiryTimeCanNotBeParsed", func(t *testing.T) {
+
+		at := AccessToken{}
+
+		// act
+		expires := at.ExpiresWithin(5 * time.Minute)
+
+		assert.True(t, expires)
+	})
+}
@@ -2,18 +2,22 @@ package github
 
 import (
 	"bytes"
+	"crypto/rsa"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
-	"strings"
+	"net/url"
+	"sync"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
+	"github.com/estafette/estafette-ci-api/cockroach"
 	"github.com/estafette/estafette-ci-api/config"
 	ghcontracts "github.com/estafette/estafette-ci-api/github/contracts"
+	contracts "github.com/estafette/estafette-ci-contracts"
 	"github.com/rs/zerolog/log"
 	"github.com/sethgrid/pester"
 
@@ -22,40 +26,61 @@ import (
 
 // APIClient is the interface for running kubernetes commands specific to this application
 type APIClient interface {
-	GetGithubAppToken() (string, error)
-	GetInstallationID(string) (int, error)
-	GetInstallationToken(int) (ghcontracts.AccessToken, error)
+	GetGithubAppToken(*config.GithubAppConfig) (string, error)
+	GetAppInstallations(*config.GithubAppConfig) ([]ghcontracts.Installation, error)
+	GetInstallationID(string, string) (int, error)
+	GetInstallationToken(string, int) (ghcontracts.AccessToken, error)
 	GetAuthenticatedRepositoryURL(ghcontracts.AccessToken, string) (string, error)
 	GetEstafetteManifest(ghcontracts.AccessToken, ghcontracts.PushEvent) (bool, string, error)
+	GetBranchRevision(ghcontracts.AccessToken, string, string, string) (string, error)
+	GetCheckRuns(ghcontracts.AccessToken, string, string, string) ([]ghcontracts.CheckRun, error)
+	CreateCheckRun(ghcontracts.AccessToken, string, string, ghcontracts.CheckRun) error
+	UpdateCheckRun(ghcontracts.AccessToken, string, string, ghcontracts.CheckRun) error
+	GetDeployments(ghcontracts.AccessToken, string, string, string, string) ([]ghcontracts.Deployment, error)
+	CreateDeployment(ghcontracts.AccessToken, string, string, ghcontracts.Deployment) (ghcontracts.Deployment, error)
+	CreateDeploymentStatus(ghcontracts.AccessToken, string, string, int, ghcontracts.DeploymentStatus) error
+	GetCollaboratorPermission(ghcontracts.AccessToken, string, string, string) (string, error)
 	callGithubAPI(string, string, interface{}, string, string) (int, []byte, error)
 
 	JobVarsFunc() func(string, string, string) (string, string, error)
+	CheckRunsFunc() func(contracts.Build, *contracts.BuildLog, string) error
+	DeploymentStatusFunc() func(cockroach.Release, string, string) error
+
+	InvalidateInstallation(*config.GithubAppConfig, int)
+	UpdateConfig(config.GithubConfig)
 }
 
 type apiClientImpl struct {
 	config                          config.GithubConfig
 	prometheusOutboundAPICallTotals *prometheus.CounterVec
+
+	// parsed private keys by path, installations by app id and installation tokens by repo source and installation id
+	cacheMutex         sync.RWMutex
+	privateKeys        map[string]*rsa.PrivateKey
+	installations      map[string][]ghcontracts.Installation
+	installationTokens map[string]ghcontracts.AccessToken
 }
 
-// NewGithubAPIClient creates an github.APIClient to communicate with the Github api
+// installation tokens are valid for an hour; renewing them a while before that keeps tokens handed to builder jobs usable for the start of the job
+const installationTokenRenewBeforeExpiry = 10 * time.Minute
+
+// NewGithubAPIClient creates an github.APIClient to communicate with the Github api of github.com and the configured github enterprise hosts
 func NewGithubAPIClient(config config.GithubConfig, prometheusOutboundAPICallTotals *prometheus.CounterVec) APIClient {
 	return &apiClientImpl{
 		config:                          config,
 		prometheusOutboundAPICallTotals: prometheusOutboundAPICallTotals,
+		privateKeys:                     map[string]*rsa.PrivateKey{},
+		installations:                   map[string][]ghcontracts.Installation{},
+		installationTokens:              map[string]ghcontracts.AccessToken{},
 	}
 }
 
 // GetGithubAppToken returns a Github app token with which to retrieve an installation token
-func (gh *apiClientImpl) GetGithubAppToken() (githubAppToken string, err error) {
+func (gh *apiClientImpl) GetGithubAppToken(app *config.GithubAppConfig) (githubAppToken string, err error) {
 
 	// https://developer.github.com/apps/building-integrations/setting-up-and-registering-github-apps/about-authentication-options-for-github-apps/
 
-	// load private key from pem file
-	pemFileByteArray, err := ioutil.ReadFile(gh.config.PrivateKeyPath)
-	if err != nil {
-		return
-	}
-	privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(pemFileByteArray)
+	privateKey, err := gh.getPrivateKey(app)
 	if err != nil {
 		return
 	}
@@ -68,7 +93,7 @@ func (gh *apiClientImpl) GetGithubAppToken() (githubAppToken string, err error)
 		// JWT expiration time (10 minute maximum)
 		"exp": epoch + 500,
 		// GitHub App's identifier
-		"iss": gh.config.AppID,
+		"iss": app.AppID,
 	})
 
 	// sign and get the complete encoded token as a string using the private key
@@ -80,26 +105,31 @@ func (gh *apiClientImpl) GetGithubAppToken() (githubAppToken string, err error)
 	return
 }
 
-// GetInstallationID returns the id for an installation of a Github app
-func (gh *apiClientImpl) GetInstallationID(repoOwner string) (installationID int, err error) {
+// GetAppInstallations returns the installations of a Github app; they're cached until an installation webhook invalidates them
+func (gh *apiClientImpl) GetAppInstallations(app *config.GithubAppConfig) (installations []ghcontracts.Installation, err error) {
 
-	githubAppToken, err := gh.GetGithubAppToken()
-	if err != nil {
+	gh.cacheMutex.RLock()
+	installations, ok := gh.installations[app.AppID]
+	gh.cacheMutex.RUnlock()
+	if ok {
+		gh.trackCache("installations", true)
 		return
 	}
+	gh.trackCache("installations", false)
 
-	type InstallationAccount struct {
-		Login string `json:"login"`
+	githubAppToken, err := gh.GetGithubAppToken(app)
+	if err != nil {
+		return
 	}
 
-	type InstallationResponse struct {
-		ID      int                 `json:"id"`
-		Account InstallationAccount `json:"account"`
+	statusCode, body, err := gh.callGithubAPI("GET", fmt.Sprintf("%v/app/installations", app.GetAPIBaseURL()), nil, "Bearer", githubAppToken)
+	if err != nil {
+		return
 	}
 
-	_, body, err := gh.callGithubAPI("GET", "https://api.github.com/app/installations", nil, "Bearer", githubAppToken)
-
-	var installations []InstallationResponse
+	if statusCode != http.StatusOK {
+		return installations, fmt.Errorf("Retrieving installations of Github app %v failed with status code %v", app.AppID, statusCode)
+	}
 
 	// unmarshal json body
 	err = json.Unmarshal(body, &installations)
@@ -107,26 +137,74 @@ func (gh *apiClientImpl) GetInstallationID(repoOwner string) (installationID int
 		return
 	}
 
-	// find installation matching repoOwner
-	for _, installation := range installations {
-		if installation.Account.Login == repoOwner {
-			installationID = installation.ID
-			return
+	gh.cacheMutex.Lock()
+	gh.installations[app.AppID] = installations
+	gh.cacheMutex.Unlock()
+
+	return
+}
+
+// GetInstallationID returns the id for the installation of a Github app on the host of the repository source for a repository owner
+func (gh *apiClientImpl) GetInstallationID(repoSource, repoOwner string) (installationID int, err error) {
+
+	apps := gh.getConfig().GetAppsByRepoSource(repoSource)
+
+	// find installation matching repoOwner in any of the apps for the host; if it's not in the cached installations, the app might have been installed since they got cached
+	for attempt := 0; attempt < 2; attempt++ {
+		for _, app := range apps {
+			installations, err := gh.GetAppInstallations(app)
+			if err != nil {
+				return 0, err
+			}
+			for _, installation := range installations {
+				if installation.Account != nil && installation.Account.Login == repoOwner {
+					return installation.ID, nil
+				}
+			}
+		}
+
+		gh.cacheMutex.Lock()
+		for _, app := range apps {
+			delete(gh.installations, app.AppID)
 		}
+		gh.cacheMutex.Unlock()
 	}
 
-	return installationID, fmt.Errorf("Github installation of app %v with account login %v can't be found", gh.config.AppID, repoOwner)
+	return installationID, fmt.Errorf("Github installation with account login %v can't be found in any of the %v apps for %v", repoOwner, len(apps), repoSource)
 }
 
-// GetInstallationToken returns an access token for an installation of a Github app
-func (gh *apiClientImpl) GetInstallationToken(installationID int) (accessToken ghcontracts.AccessToken, err error) {
+// GetInstallationToken returns an access token for an installation of a Github app; a token is reused until shortly before it expires
+func (gh *apiClientImpl) GetInstallationToken(repoSource string, installationID int) (accessToken ghcontracts.AccessToken, err error) {
+
+	cacheKey := fmt.Sprintf("%v/%v", repoSource, installationID)
+
+	gh.cacheMutex.RLock()
+	accessToken, ok := gh.installationTokens[cacheKey]
+	gh.cacheMutex.RUnlock()
+	if ok && !accessToken.ExpiresWithin(installationTokenRenewBeforeExpiry) {
+		gh.trackCache("token", true)
+		return
+	}
+	gh.trackCache("token", false)
+
+	app, err := gh.getAppForInstallation(repoSource, installationID)
+	if err != nil {
+		return
+	}
+
+	githubAppToken, err := gh.GetGithubAppToken(app)
+	if err != nil {
+		return
+	}
 
-	githubAppToken, err := gh.GetGithubAppToken()
+	statusCode, body, err := gh.callGithubAPI("POST", fmt.Sprintf("%v/installations/%v/access_tokens", app.GetAPIBaseURL(), installationID), nil, "Bearer", githubAppToken)
 	if err != nil {
 		return
 	}
 
-	_, body, err := gh.callGithubAPI("POST", fmt.Sprintf("https://api.github.com/installations/%v/access_tokens", installationID), nil, "Bearer", githubAppToken)
+	if statusCode != http.StatusCreated {
+		return accessToken, fmt.Errorf("Creating access token for installation %v of Github app %v failed with status code %v", installationID, app.AppID, statusCode)
+	}
 
 	// unmarshal json body
 	err = json.Unmarshal(body, &accessToken)
@@ -134,22 +212,40 @@ func (gh *apiClientImpl) GetInstallationToken(installationID int) (accessToken g
 		return
 	}
 
+	gh.cacheMutex.Lock()
+	gh.installationTokens[cacheKey] = accessToken
+	gh.cacheMutex.Unlock()
+
 	return
 }
 
+// InvalidateInstallation drops the cached installations of an app and the cached token of one of its installations, so they get retrieved again on next use
+func (gh *apiClientImpl) InvalidateInstallation(app *config.GithubAppConfig, installationID int) {
+
+	gh.cacheMutex.Lock()
+	defer gh.cacheMutex.Unlock()
+
+	delete(gh.installations, app.AppID)
+	delete(gh.installationTokens, fmt.Sprintf("%v/%v", app.GetRepoSource(), installationID))
+}
+
 // GetAuthenticatedRepositoryURL returns a repository url with a time-limited access token embedded
-func (gh *apiClientImpl) GetAuthenticatedRepositoryURL(accessToken ghcontracts.AccessToken, htmlURL string) (url string, err error) {
+func (gh *apiClientImpl) GetAuthenticatedRepositoryURL(accessToken ghcontracts.AccessToken, htmlURL string) (authenticatedURL string, err error) {
 
-	url = strings.Replace(htmlURL, "https://github.com", fmt.Sprintf("https://x-access-token:%v@github.com", accessToken.Token), -1)
+	repositoryURL, err := url.Parse(htmlURL)
+	if err != nil {
+		return
+	}
+	repositoryURL.User = url.UserPassword("x-access-token", accessToken.Token)
 
-	return
+	return repositoryURL.String(), nil
 }
 
 func (gh *apiClientImpl) GetEstafetteManifest(accessToken ghcontracts.AccessToken, pushEvent ghcontracts.PushEvent) (exists bool, manifest string, err error) {
 
 	// https://developer.github.com/v3/repos/contents/
 
-	statusCode, body, err := gh.callGithubAPI("GET", fmt.Sprintf("https://api.github.com/repos/%v/contents/.estafette.yaml?ref=%v", pushEvent.Repository.FullName, pushEvent.After), nil, "token", accessToken.Token)
+	statusCode, body, err := gh.callGithubAPI("GET", fmt.Sprintf("%v/repos/%v/contents/.estafette.yaml?ref=%v", gh.getAPIBaseURL(pushEvent.GetRepoSource()), pushEvent.Repository.FullName, pushEvent.After), nil, "token", accessToken.Token)
 	if err != nil {
 		return
 	}
@@ -180,17 +276,192 @@ func (gh *apiClientImpl) GetEstafetteManifest(accessToken ghcontracts.AccessToke
 	return
 }
 
+// GetBranchRevision returns the sha of the last commit of a branch
+func (gh *apiClientImpl) GetBranchRevision(accessToken ghcontracts.AccessToken, repoSource, fullRepoName, branch string) (revision string, err error) {
+
+	// https://developer.github.com/v3/repos/branches/#get-branch
+
+	statusCode, body, err := gh.callGithubAPI("GET", fmt.Sprintf("%v/repos/%v/branches/%v", gh.getAPIBaseURL(repoSource), fullRepoName, branch), nil, "token", accessToken.Token)
+	if err != nil {
+		return
+	}
+
+	if statusCode != http.StatusOK {
+		return "", fmt.Errorf("Retrieving branch %v for Github repository %v failed with status code %v", branch, fullRepoName, statusCode)
+	}
+
+	var branchResponse struct {
+		Commit struct {
+			SHA string `json:"sha"`
+		} `json:"commit"`
+	}
+
+	// unmarshal json body
+	err = json.Unmarshal(body, &branchResponse)
+	if err != nil {
+		return
+	}
+
+	return branchResponse.Commit.SHA, nil
+}
+
+// GetCheckRuns returns the check runs for a commit
+func (gh *apiClientImpl) GetCheckRuns(accessToken ghcontracts.AccessToken, repoSource, fullRepoName, revision string) (checkRuns []ghcontracts.CheckRun, err error) {
+
+	// https://developer.github.com/v3/checks/runs/#list-check-runs-for-a-specific-ref
+
+	statusCode, body, err := gh.callGithubAPI("GET", fmt.Sprintf("%v/repos/%v/commits/%v/check-runs?per_page=100", gh.getAPIBaseURL(repoSource), fullRepoName, revision), nil, "token", accessToken.Token)
+	if err != nil {
+		return
+	}
+
+	if statusCode != http.StatusOK {
+		return checkRuns, fmt.Errorf("Retrieving check runs for revision %v of Github repository %v failed with status code %v", revision, fullRepoName, statusCode)
+	}
+
+	var checkRunsResponse ghcontracts.CheckRunsResponse
+
+	// unmarshal json body
+	err = json.Unmarshal(body, &checkRunsResponse)
+	if err != nil {
+		return
+	}
+
+	return checkRunsResponse.CheckRuns, nil
+}
+
+// CreateCheckRun creates a check run for a commit
+func (gh *apiClientImpl) CreateCheckRun(accessToken ghcontracts.AccessToken, repoSource, fullRepoName string, checkRun ghcontracts.CheckRun) (err error) {
+
+	// https://developer.github.com/v3/checks/runs/#create-a-check-run
+
+	statusCode, _, err := gh.callGithubAPI("POST", fmt.Sprintf("%v/repos/%v/check-runs", gh.getAPIBaseURL(repoSource), fullRepoName), checkRun, "token", accessToken.Token)
+	if err != nil {
+		return
+	}
+
+	if statusCode != http.StatusCreated {
+		return fmt.Errorf("Creating check run %v for revision %v of Github repository %v failed with status code %v", checkRun.Name, checkRun.HeadSHA, fullRepoName, statusCode)
+	}
+
+	return
+}
+
+// UpdateCheckRun updates the status, conclusion and output of an existing check run
+func (gh *apiClientImpl) UpdateCheckRun(accessToken ghcontracts.AccessToken, repoSource, fullRepoName string, checkRun ghcontracts.CheckRun) (err error) {
+
+	// https://developer.github.com/v3/checks/runs/#update-a-check-run
+
+	statusCode, _, err := gh.callGithubAPI("PATCH", fmt.Sprintf("%v/repos/%v/check-runs/%v", gh.getAPIBaseURL(repoSource), fullRepoName, checkRun.ID), checkRun, "token", accessToken.Token)
+	if err != nil {
+		return
+	}
+
+	if statusCode != http.StatusOK {
+		return fmt.Errorf("Updating check run %v for revision %v of Github repository %v failed with status code %v", checkRun.Name, checkRun.HeadSHA, fullRepoName, statusCode)
+	}
+
+	return
+}
+
+// GetDeployments returns the deployments of a commit to an environment, most recent first
+func (gh *apiClientImpl) GetDeployments(accessToken ghcontracts.AccessToken, repoSource, fullRepoName, revision, environment string) (deployments []ghcontracts.Deployment, err error) {
+
+	// https://developer.github.com/v3/repos/deployments/#list-deployments
+
+	statusCode, body, err := gh.callGithubAPI("GET", fmt.Sprintf("%v/repos/%v/deployments?sha=%v&environment=%v", gh.getAPIBaseURL(repoSource), fullRepoName, revision, url.QueryEscape(environment)), nil, "token", accessToken.Token)
+	if err != nil {
+		return
+	}
+
+	if statusCode != http.StatusOK {
+		return deployments, fmt.Errorf("Retrieving deployments to %v for revision %v of Github repository %v failed with status code %v", environment, revision, fullRepoName, statusCode)
+	}
+
+	// unmarshal json body
+	err = json.Unmarshal(body, &deployments)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// CreateDeployment creates a deployment of a commit to an environment
+func (gh *apiClientImpl) CreateDeployment(accessToken ghcontracts.AccessToken, repoSource, fullRepoName string, deployment ghcontracts.Deployment) (createdDeployment ghcontracts.Deployment, err error) {
+
+	// https://developer.github.com/v3/repos/deployments/#create-a-deployment
+
+	statusCode, body, err := gh.callGithubAPI("POST", fmt.Sprintf("%v/repos/%v/deployments", gh.getAPIBaseURL(repoSource), fullRepoName), deployment, "token", accessToken.Token)
+	if err != nil {
+		return
+	}
+
+	if statusCode != http.StatusCreated {
+		return createdDeployment, fmt.Errorf("Creating deployment to %v for revision %v of Github repository %v failed with status code %v", deployment.Environment, deployment.Ref, fullRepoName, statusCode)
+	}
+
+	// unmarshal json body
+	err = json.Unmarshal(body, &createdDeployment)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// CreateDeploymentStatus adds a status to a deployment
+func (gh *apiClientImpl) CreateDeploymentStatus(accessToken ghcontracts.AccessToken, repoSource, fullRepoName string, deploymentID int, deploymentStatus ghcontracts.DeploymentStatus) (err error) {
+
+	// https://developer.github.com/v3/repos/deployments/#create-a-deployment-status
+
+	statusCode, _, err := gh.callGithubAPI("POST", fmt.Sprintf("%v/repos/%v/deployments/%v/statuses", gh.getAPIBaseURL(repoSource), fullRepoName, deploymentID), deploymentStatus, "token", accessToken.Token)
+	if err != nil {
+		return
+	}
+
+	if statusCode != http.StatusCreated {
+		return fmt.Errorf("Creating status %v for deployment %v of Github repository %v failed with status code %v", deploymentStatus.State, deploymentID, fullRepoName, statusCode)
+	}
+
+	return
+}
+
+// GetCollaboratorPermission returns the permission of a user on a repository, being admin, write, read or none
+func (gh *apiClientImpl) GetCollaboratorPermission(accessToken ghcontracts.AccessToken, repoSource, fullRepoName, user string) (permission string, err error) {
+
+	// https://developer.github.com/v3/repos/collaborators/#review-a-users-permission-level
+
+	statusCode, body, err := gh.callGithubAPI("GET", fmt.Sprintf("%v/repos/%v/collaborators/%v/permission", gh.getAPIBaseURL(repoSource), fullRepoName, url.PathEscape(user)), nil, "token", accessToken.Token)
+	if err != nil {
+		return
+	}
+
+	if statusCode != http.StatusOK {
+		return permission, fmt.Errorf("Retrieving permission of %v on Github repository %v failed with status code %v", user, fullRepoName, statusCode)
+	}
+
+	// unmarshal json body
+	var collaboratorPermission ghcontracts.CollaboratorPermission
+	err = json.Unmarshal(body, &collaboratorPermission)
+	if err != nil {
+		return
+	}
+
+	return collaboratorPermission.Permission, nil
+}
+
 // JobVarsFunc returns a function that can get an access token and authenticated url for a repository
 func (gh *apiClientImpl) JobVarsFunc() func(string, string, string) (string, string, error) {
 	return func(repoSource, repoOwner, repoName string) (token string, url string, err error) {
 		// get installation id with just the repo owner
-		installationID, err := gh.GetInstallationID(repoOwner)
+		installationID, err := gh.GetInstallationID(repoSource, repoOwner)
 		if err != nil {
 			return "", "", err
 		}
 
 		// get access token
-		accessToken, err := gh.GetInstallationToken(installationID)
+		accessToken, err := gh.GetInstallationToken(repoSource, installationID)
 		if err != nil {
 			return "", "", err
 		}
@@ -205,6 +476,194 @@ func (gh *apiClientImpl) JobVarsFunc() func(string, string, string) (string, str
 	}
 }
 
+// CheckRunsFunc returns a function that creates or updates a check run for each stage of a build
+func (gh *apiClientImpl) CheckRunsFunc() func(contracts.Build, *contracts.BuildLog, string) error {
+	return func(build contracts.Build, buildLog *contracts.BuildLog, detailsURL string) error {
+
+		checkRuns := getCheckRuns(build, buildLog, detailsURL)
+		if len(checkRuns) == 0 {
+			return nil
+		}
+
+		// get installation id with just the repo owner
+		installationID, err := gh.GetInstallationID(build.RepoSource, build.RepoOwner)
+		if err != nil {
+			return err
+		}
+
+		// get access token
+		accessToken, err := gh.GetInstallationToken(build.RepoSource, installationID)
+		if err != nil {
+			return err
+		}
+
+		fullRepoName := fmt.Sprintf("%v/%v", build.RepoOwner, build.RepoName)
+
+		// check runs of this build get updated, so each stage only has one check run per build
+		existingCheckRuns, err := gh.GetCheckRuns(accessToken, build.RepoSource, fullRepoName, build.RepoRevision)
+		if err != nil {
+			return err
+		}
+
+		completedAt := time.Now().UTC()
+		for _, checkRun := range checkRuns {
+			if checkRun.Conclusion != "" {
+				checkRun.CompletedAt = &completedAt
+			}
+
+			for _, e := range existingCheckRuns {
+				if e.Name == checkRun.Name && e.ExternalID == checkRun.ExternalID {
+					checkRun.ID = e.ID
+					break
+				}
+			}
+
+			if checkRun.ID > 0 {
+				err = gh.UpdateCheckRun(accessToken, build.RepoSource, fullRepoName, checkRun)
+			} else {
+				err = gh.CreateCheckRun(accessToken, build.RepoSource, fullRepoName, checkRun)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+// DeploymentStatusFunc returns a function that adds the status of a release to its Github deployment; a release started from a deployment event reports to that deployment, any other release to a deployment created for it
+func (gh *apiClientImpl) DeploymentStatusFunc() func(cockroach.Release, string, string) error {
+	return func(release cockroach.Release, revision, logURL string) error {
+
+		// get installation id with just the repo owner
+		installationID, err := gh.GetInstallationID(release.RepoSource, release.RepoOwner)
+		if err != nil {
+			return err
+		}
+
+		// get access token
+		accessToken, err := gh.GetInstallationToken(release.RepoSource, installationID)
+		if err != nil {
+			return err
+		}
+
+		fullRepoName := fmt.Sprintf("%v/%v", release.RepoOwner, release.RepoName)
+
+		deploymentID, err := gh.getReleaseDeploymentID(accessToken, fullRepoName, release, revision)
+		if err != nil {
+			return err
+		}
+
+		if deploymentID == 0 {
+			deployment, err := getDeployment(release.Release, revision)
+			if err != nil {
+				return err
+			}
+			deployment, err = gh.CreateDeployment(accessToken, release.RepoSource, fullRepoName, deployment)
+			if err != nil {
+				return err
+			}
+			deploymentID = deployment.ID
+		}
+
+		return gh.CreateDeploymentStatus(accessToken, release.RepoSource, fullRepoName, deploymentID, getDeploymentStatus(release.Release, logURL))
+	}
+}
+
+// getReleaseDeploymentID returns the id of the deployment the release was started for, or of the deployment created earlier for the release; it returns 0 if there's no such deployment
+func (gh *apiClientImpl) getReleaseDeploymentID(accessToken ghcontracts.AccessToken, fullRepoName string, release cockroach.Release, revision string) (deploymentID int, err error) {
+
+	if release.DeploymentID > 0 {
+		return release.DeploymentID, nil
+	}
+
+	deployments, err := gh.GetDeployments(accessToken, release.RepoSource, fullRepoName, revision, release.Name)
+	if err != nil {
+		return
+	}
+
+	for _, d := range deployments {
+		if d.GetPayload().ReleaseID == release.ID {
+			return d.ID, nil
+		}
+	}
+
+	return 0, nil
+}
+
+// getPrivateKey returns the parsed private key of an app, reading it from its pem file only once
+func (gh *apiClientImpl) getPrivateKey(app *config.GithubAppConfig) (privateKey *rsa.PrivateKey, err error) {
+
+	gh.cacheMutex.RLock()
+	privateKey, ok := gh.privateKeys[app.PrivateKeyPath]
+	gh.cacheMutex.RUnlock()
+	if ok {
+		gh.trackCache("privatekey", true)
+		return
+	}
+	gh.trackCache("privatekey", false)
+
+	// load private key from pem file
+	pemFileByteArray, err := ioutil.ReadFile(app.PrivateKeyPath)
+	if err != nil {
+		return
+	}
+	privateKey, err = jwt.ParseRSAPrivateKeyFromPEM(pemFileByteArray)
+	if err != nil {
+		return
+	}
+
+	gh.cacheMutex.Lock()
+	gh.privateKeys[app.PrivateKeyPath] = privateKey
+	gh.cacheMutex.Unlock()
+
+	return
+}
+
+// trackCache counts cache hits and misses as github-<cache>-cache-hit or github-<cache>-cache-miss targets of the outbound api call counter
+func (gh *apiClientImpl) trackCache(cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	gh.prometheusOutboundAPICallTotals.With(prometheus.Labels{"target": fmt.Sprintf("github-%v-cache-%v", cache, result)}).Inc()
+}
+
+// getAppForInstallation returns the app on the host of the repository source the installation belongs to
+func (gh *apiClientImpl) getAppForInstallation(repoSource string, installationID int) (*config.GithubAppConfig, error) {
+
+	apps := gh.getConfig().GetAppsByRepoSource(repoSource)
+	if len(apps) == 1 {
+		return apps[0], nil
+	}
+
+	for _, app := range apps {
+		installations, err := gh.GetAppInstallations(app)
+		if err != nil {
+			return nil, err
+		}
+		for _, installation := range installations {
+			if installation.ID == installationID {
+				return app, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("Github installation %v can't be found in any of the %v apps for %v", installationID, len(apps), repoSource)
+}
+
+// getAPIBaseURL returns the api base url of the github host of the repository source
+func (gh *apiClientImpl) getAPIBaseURL(repoSource string) string {
+
+	apps := gh.getConfig().GetAppsByRepoSource(repoSource)
+	if len(apps) > 0 {
+		return apps[0].GetAPIBaseURL()
+	}
+
+	return "https://api.github.com"
+}
+
 func (gh *apiClientImpl) callGithubAPI(method, url string, params interface{}, authorizationType, token string) (statusCode int, body []byte, err error) {
 
 	// track call via prometheus
@@ -232,7 +691,8 @@ func (gh *apiClientImpl) callGithubAPI(method, url string, params interface{}, a
 
 	// add headers
 	request.Header.Add("Authorization", fmt.Sprintf("%v %v", authorizationType, token))
-	request.Header.Add("Accept", "application/vnd.github.machine-man-preview+json")
+	// the checks api and the in_progress state and log_url of deployment statuses are only available as preview
+	request.Header.Add("Accept", "application/vnd.github.machine-man-preview+json, application/vnd.github.antiope-preview+json, application/vnd.github.ant-man-preview+json, application/vnd.github.flash-preview+json")
 
 	// perform actual request
 	response, err := client.Do(request)
@@ -267,3 +727,22 @@ func (gh *apiClientImpl) callGithubAPI(method, url string, params interface{}, a
 
 	return
 }
+
+// UpdateConfig swaps in a reloaded config and drops all cached keys, installations and tokens, since apps might have been added, removed or had their private key replaced
+func (gh *apiClientImpl) UpdateConfig(config config.GithubConfig) {
+	gh.cacheMutex.Lock()
+	defer gh.cacheMutex.Unlock()
+
+	gh.config = config
+	gh.privateKeys = map[string]*rsa.PrivateKey{}
+	gh.installations = map[string][]ghcontracts.Installation{}
+	gh.installationTokens = map[string]ghcontracts.AccessToken{}
+}
+
+func (gh *apiClientImpl) getConfig() *config.GithubConfig {
+	gh.cacheMutex.RLock()
+	defer gh.cacheMutex.RUnlock()
+
+	config := gh.config
+	return &config
+}
@@ -0,0 +1,161 @@
+package github
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	ghcontracts "github.com/estafette/estafette-ci-api/github/contracts"
+	contracts "github.com/estafette/estafette-ci-contracts"
+)
+
+const (
+	// github accepts at most 50 annotations per request
+	maxCheckRunAnnotations = 50
+	// number of log lines of a failed stage shown in the check run details
+	maxCheckRunLogLines = 25
+)
+
+// matches lines like 'main.go:12:5: undefined: x' or './src/app.js:3: error' as printed by most compilers and linters
+var annotationRegex = regexp.MustCompile(`^\s*(?:\./)?([a-zA-Z0-9_\-./]+\.[a-zA-Z0-9]+):(\d+)(?::\d+)?:?\s+(.+)$`)
+
+// getCheckRuns maps the stages of a build log to one check run per stage; the automatically injected stages are left out
+func getCheckRuns(build contracts.Build, buildLog *contracts.BuildLog, detailsURL string) (checkRuns []ghcontracts.CheckRun) {
+
+	checkRuns = make([]ghcontracts.CheckRun, 0)
+	if buildLog == nil {
+		return
+	}
+
+	// retried stages show up multiple times, the last run counts
+	indexByName := map[string]int{}
+	for _, step := range buildLog.Steps {
+		if step.AutoInjected {
+			continue
+		}
+
+		checkRun := getCheckRun(build, step, detailsURL)
+
+		if i, ok := indexByName[step.Step]; ok {
+			checkRuns[i] = checkRun
+			continue
+		}
+		indexByName[step.Step] = len(checkRuns)
+		checkRuns = append(checkRuns, checkRun)
+	}
+
+	return
+}
+
+func getCheckRun(build contracts.Build, step contracts.BuildLogStep, detailsURL string) ghcontracts.CheckRun {
+
+	checkRun := ghcontracts.CheckRun{
+		Name:       step.Step,
+		HeadSHA:    build.RepoRevision,
+		DetailsURL: detailsURL,
+		ExternalID: build.ID,
+	}
+
+	stepStatus := strings.ToLower(step.Status)
+	output := &ghcontracts.CheckRunOutput{}
+
+	switch stepStatus {
+	case "succeeded":
+		checkRun.Status = "completed"
+		checkRun.Conclusion = "success"
+		output.Title = "Succeeded"
+		output.Summary = fmt.Sprintf("Stage %v succeeded in %v", step.Step, step.Duration)
+
+	case "failed":
+		checkRun.Status = "completed"
+		checkRun.Conclusion = "failure"
+		output.Title = "Failed"
+		output.Summary = fmt.Sprintf("Stage %v failed with exit code %v after %v", step.Step, step.ExitCode, step.Duration)
+		output.Text = getCheckRunLogTail(step)
+		output.Annotations = getCheckRunAnnotations(step)
+
+	case "skipped":
+		checkRun.Status = "completed"
+		checkRun.Conclusion = "neutral"
+		output.Title = "Skipped"
+		output.Summary = fmt.Sprintf("Stage %v was skipped", step.Step)
+
+	case "canceled":
+		checkRun.Status = "completed"
+		checkRun.Conclusion = "cancelled"
+		output.Title = "Canceled"
+		output.Summary = fmt.Sprintf("Stage %v was canceled", step.Step)
+
+	default:
+		// a canceled build leaves its running stage unfinished
+		if build.BuildStatus == "canceled" || build.BuildStatus == "canceling" {
+			checkRun.Status = "completed"
+			checkRun.Conclusion = "cancelled"
+			output.Title = "Canceled"
+			output.Summary = fmt.Sprintf("Stage %v was canceled", step.Step)
+			break
+		}
+		checkRun.Status = "in_progress"
+		output.Title = "Running"
+		output.Summary = fmt.Sprintf("Stage %v is running", step.Step)
+	}
+
+	if step.Image != nil && step.Image.Error != "" {
+		output.Summary = fmt.Sprintf("%v\n\nImage %v:%v failed: %v", output.Summary, step.Image.Name, step.Image.Tag, step.Image.Error)
+	}
+
+	checkRun.Output = output
+
+	return checkRun
+}
+
+// getCheckRunAnnotations turns log lines referring to a file and line number into annotations
+func getCheckRunAnnotations(step contracts.BuildLogStep) []ghcontracts.CheckRunAnnotation {
+
+	annotations := make([]ghcontracts.CheckRunAnnotation, 0)
+	for _, l := range step.LogLines {
+		match := annotationRegex.FindStringSubmatch(l.Text)
+		if len(match) != 4 {
+			continue
+		}
+
+		line, err := strconv.Atoi(match[2])
+		if err != nil || line < 1 {
+			continue
+		}
+
+		annotations = append(annotations, ghcontracts.CheckRunAnnotation{
+			Path:            match[1],
+			StartLine:       line,
+			EndLine:         line,
+			AnnotationLevel: "failure",
+			Message:         strings.TrimSpace(match[3]),
+		})
+
+		if len(annotations) == maxCheckRunAnnotations {
+			break
+		}
+	}
+
+	return annotations
+}
+
+func getCheckRunLogTail(step contracts.BuildLogStep) string {
+
+	if len(step.LogLines) == 0 {
+		return ""
+	}
+
+	logLines := step.LogLines
+	if len(logLines) > maxCheckRunLogLines {
+		logLines = logLines[len(logLines)-maxCheckRunLogLines:]
+	}
+
+	lines := make([]string, len(logLines))
+	for i, l := range logLines {
+		lines[i] = l.Text
+	}
+
+	return fmt.Sprintf("```\n%v\n```", strings.Join(lines, "\n"))
+}
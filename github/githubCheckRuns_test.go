@@ -0,0 +1,132 @@
+package github
+
+import (
+	"testing"
+	"time"
+
+	contracts "github.com/estafette/estafette-ci-contracts"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetCheckRuns(t *testing.T) {
+
+	build := contracts.Build{
+		ID:           "390605593734184965",
+		RepoRevision: "a3b5c7d9e1f3a5b7c9d1e3f5a7b9c1d3e5f7a9b1",
+		BuildStatus:  "failed",
+	}
+
+	t.Run("ReturnsNoCheckRunsIfThereIsNoBuildLog", func(t *testing.T) {
+
+		// act
+		checkRuns := getCheckRuns(build, nil, "https://ci.estafette.io/pipelines/github.com/estafette/estafette-ci-api/builds/390605593734184965/logs")
+
+		assert.Equal(t, 0, len(checkRuns))
+	})
+
+	t.Run("ReturnsOneCheckRunPerStageSkippingInjectedStages", func(t *testing.T) {
+
+		buildLog := &contracts.BuildLog{
+			Steps: []contracts.BuildLogStep{
+				contracts.BuildLogStep{Step: "git-clone", Status: "SUCCEEDED", AutoInjected: true},
+				contracts.BuildLogStep{Step: "build", Status: "SUCCEEDED", Duration: 12 * time.Second},
+				contracts.BuildLogStep{Step: "test", Status: "FAILED", ExitCode: 1},
+				contracts.BuildLogStep{Step: "push", Status: "SKIPPED"},
+			},
+		}
+
+		// act
+		checkRuns := getCheckRuns(build, buildLog, "https://ci.estafette.io/pipelines/github.com/estafette/estafette-ci-api/builds/390605593734184965/logs")
+
+		assert.Equal(t, 3, len(checkRuns))
+		assert.Equal(t, "build", checkRuns[0].Name)
+		assert.Equal(t, "completed", checkRuns[0].Status)
+		assert.Equal(t, "success", checkRuns[0].Conclusion)
+		assert.Equal(t, "Stage build succeeded in 12s", checkRuns[0].Output.Summary)
+		assert.Equal(t, "a3b5c7d9e1f3a5b7c9d1e3f5a7b9c1d3e5f7a9b1", checkRuns[0].HeadSHA)
+		assert.Equal(t, "390605593734184965", checkRuns[0].ExternalID)
+		assert.Equal(t, "https://ci.estafette.io/pipelines/github.com/estafette/estafette-ci-api/builds/390605593734184965/logs", checkRuns[0].DetailsURL)
+		assert.Equal(t, "test", checkRuns[1].Name)
+		assert.Equal(t, "failure", checkRuns[1].Conclusion)
+		assert.Equal(t, "push", checkRuns[2].Name)
+		assert.Equal(t, "neutral", checkRuns[2].Conclusion)
+	})
+
+	t.Run("ReturnsLastRunOfRetriedStage", func(t *testing.T) {
+
+		buildLog := &contracts.BuildLog{
+			Steps: []contracts.BuildLogStep{
+				contracts.BuildLogStep{Step: "deploy", Status: "FAILED"},
+				contracts.BuildLogStep{Step: "deploy", Status: "SUCCEEDED", RunIndex: 1},
+			},
+		}
+
+		// act
+		checkRuns := getCheckRuns(build, buildLog, "")
+
+		assert.Equal(t, 1, len(checkRuns))
+		assert.Equal(t, "success", checkRuns[0].Conclusion)
+	})
+
+	t.Run("ReturnsCancelledCheckRunForRunningStageOfCanceledBuild", func(t *testing.T) {
+
+		canceledBuild := build
+		canceledBuild.BuildStatus = "canceled"
+		buildLog := &contracts.BuildLog{
+			Steps: []contracts.BuildLogStep{
+				contracts.BuildLogStep{Step: "build", Status: "RUNNING"},
+			},
+		}
+
+		// act
+		checkRuns := getCheckRuns(canceledBuild, buildLog, "")
+
+		assert.Equal(t, "completed", checkRuns[0].Status)
+		assert.Equal(t, "cancelled", checkRuns[0].Conclusion)
+	})
+}
+
+func TestGetCheckRunAnnotations(t *testing.T) {
+
+	t.Run("ReturnsAnnotationsForLogLinesReferringToFileAndLine", func(t *testing.T) {
+
+		step := contracts.BuildLogStep{
+			Step: "build",
+			LogLines: []contracts.BuildLogLine{
+				contracts.BuildLogLine{Text: "# github.com/estafette/estafette-ci-api/github"},
+				contracts.BuildLogLine{Text: "./githubApiClient.go:42:2: undefined: accessToken"},
+				contracts.BuildLogLine{Text: "src/app.js:7 Unexpected token"},
+				contracts.BuildLogLine{Text: "exit status 2"},
+			},
+		}
+
+		// act
+		annotations := getCheckRunAnnotations(step)
+
+		assert.Equal(t, 2, len(annotations))
+		assert.Equal(t, "githubApiClient.go", annotations[0].Path)
+		assert.Equal(t, 42, annotations[0].StartLine)
+		assert.Equal(t, 42, annotations[0].EndLine)
+		assert.Equal(t, "failure", annotations[0].AnnotationLevel)
+		assert.Equal(t, "undefined: accessToken", annotations[0].Message)
+		assert.Equal(t, "src/app.js", annotations[1].Path)
+		assert.Equal(t, 7, annotations[1].StartLine)
+		assert.Equal(t, "Unexpected token", annotations[1].Message)
+	})
+
+	t.Run("ReturnsAtMostFiftyAnnotations", func(t *testing.T) {
+
+		step := contracts.BuildLogStep{
+			Step:     "lint",
+			LogLines: make([]contracts.BuildLogLine, 60),
+		}
+		for i := range step.LogLines {
+			step.LogLines[i].Text = "main.go:1: line is too long"
+		}
+
+		// act
+		annotations := getCheckRunAnnotations(step)
+
+		assert.Equal(t, 50, len(annotations))
+	})
+}
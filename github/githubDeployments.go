@@ -0,0 +1,63 @@
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+
+	ghcontracts "github.com/estafette/estafette-ci-api/github/contracts"
+	contracts "github.com/estafette/estafette-ci-contracts"
+)
+
+// getDeployment returns the Github deployment for a release of a revision; the payload links the deployment back to the release
+func getDeployment(release contracts.Release, revision string) (deployment ghcontracts.Deployment, err error) {
+
+	payload, err := json.Marshal(ghcontracts.DeploymentPayload{
+		ReleaseID: release.ID,
+		Action:    release.Action,
+	})
+	if err != nil {
+		return
+	}
+
+	description := fmt.Sprintf("Release %v to %v", release.ReleaseVersion, release.Name)
+	if release.Action != "" {
+		description = fmt.Sprintf("Release %v to %v with action %v", release.ReleaseVersion, release.Name, release.Action)
+	}
+
+	return ghcontracts.Deployment{
+		Ref:         revision,
+		Task:        "deploy",
+		Environment: release.Name,
+		Description: description,
+		Payload:     payload,
+		// the build has succeeded already; don't let github merge the default branch or wait for commit statuses
+		AutoMerge:        false,
+		RequiredContexts: []string{},
+	}, nil
+}
+
+// getDeploymentStatus maps the status of a release to a Github deployment status
+func getDeploymentStatus(release contracts.Release, logURL string) ghcontracts.DeploymentStatus {
+
+	deploymentStatus := ghcontracts.DeploymentStatus{
+		TargetURL: logURL,
+		LogURL:    logURL,
+	}
+
+	switch release.ReleaseStatus {
+	case "succeeded":
+		deploymentStatus.State = "success"
+		deploymentStatus.Description = fmt.Sprintf("Released %v to %v", release.ReleaseVersion, release.Name)
+	case "failed":
+		deploymentStatus.State = "failure"
+		deploymentStatus.Description = fmt.Sprintf("Releasing %v to %v failed", release.ReleaseVersion, release.Name)
+	case "canceling", "canceled":
+		deploymentStatus.State = "error"
+		deploymentStatus.Description = fmt.Sprintf("Releasing %v to %v was canceled", release.ReleaseVersion, release.Name)
+	default:
+		deploymentStatus.State = "in_progress"
+		deploymentStatus.Description = fmt.Sprintf("Releasing %v to %v", release.ReleaseVersion, release.Name)
+	}
+
+	return deploymentStatus
+}
@@ -0,0 +1,81 @@
+package github
+
+import (
+	"testing"
+
+	contracts "github.com/estafette/estafette-ci-contracts"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetDeployment(t *testing.T) {
+
+	t.Run("ReturnsDeploymentForReleaseTargetWithReleaseIDInPayload", func(t *testing.T) {
+
+		release := contracts.Release{
+			ID:             "390605593734184965",
+			Name:           "production",
+			Action:         "deploy-canary",
+			ReleaseVersion: "1.0.5",
+		}
+
+		// act
+		deployment, err := getDeployment(release, "a3b5c7d9e1f3a5b7c9d1e3f5a7b9c1d3e5f7a9b1")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "a3b5c7d9e1f3a5b7c9d1e3f5a7b9c1d3e5f7a9b1", deployment.Ref)
+		assert.Equal(t, "production", deployment.Environment)
+		assert.Equal(t, "Release 1.0.5 to production with action deploy-canary", deployment.Description)
+		assert.False(t, deployment.AutoMerge)
+		assert.Equal(t, 0, len(deployment.RequiredContexts))
+		assert.Equal(t, "390605593734184965", deployment.GetPayload().ReleaseID)
+		assert.Equal(t, "deploy-canary", deployment.GetPayload().Action)
+	})
+}
+
+func TestGetDeploymentStatus(t *testing.T) {
+
+	logURL := "https://ci.estafette.io/pipelines/github.com/estafette/estafette-ci-api/releases/390605593734184965/logs"
+
+	t.Run("ReturnsInProgressForRunningRelease", func(t *testing.T) {
+
+		release := contracts.Release{Name: "production", ReleaseVersion: "1.0.5", ReleaseStatus: "running"}
+
+		// act
+		deploymentStatus := getDeploymentStatus(release, logURL)
+
+		assert.Equal(t, "in_progress", deploymentStatus.State)
+		assert.Equal(t, logURL, deploymentStatus.LogURL)
+		assert.Equal(t, logURL, deploymentStatus.TargetURL)
+		assert.Equal(t, "Releasing 1.0.5 to production", deploymentStatus.Description)
+	})
+
+	t.Run("ReturnsSuccessForSucceededRelease", func(t *testing.T) {
+
+		release := contracts.Release{Name: "production", ReleaseVersion: "1.0.5", ReleaseStatus: "succeeded"}
+
+		// act
+		deploymentStatus := getDeploymentStatus(release, logURL)
+
+		assert.Equal(t, "success", deploymentStatus.State)
+	})
+
+	t.Run("ReturnsFailureForFailedRelease", func(t *testing.T) {
+
+		release := contracts.Release{Name: "production", ReleaseVersion: "1.0.5", ReleaseStatus: "failed"}
+
+		// act
+		deploymentStatus := getDeploymentStatus(release, logURL)
+
+		assert.Equal(t, "failure", deploymentStatus.State)
+	})
+
+	t.Run("ReturnsErrorForCanceledRelease", func(t *testing.T) {
+
+		release := contracts.Release{Name: "production", ReleaseVersion: "1.0.5", ReleaseStatus: "canceled"}
+
+		// act
+		deploymentStatus := getDeploymentStatus(release, logURL)
+
+		assert.Equal(t, "error", deploymentStatus.State)
+	})
+}
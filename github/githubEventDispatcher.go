@@ -4,6 +4,7 @@ import (
 	"sync"
 
 	"github.com/estafette/estafette-ci-api/cockroach"
+	"github.com/estafette/estafette-ci-api/config"
 	"github.com/estafette/estafette-ci-api/estafette"
 	ghcontracts "github.com/estafette/estafette-ci-api/github/contracts"
 )
@@ -23,10 +24,11 @@ type eventDispatcherImpl struct {
 	apiClient         APIClient
 	ciBuilderClient   estafette.CiBuilderClient
 	cockroachDBClient cockroach.DBClient
+	apiServerConfig   config.APIServerConfig
 }
 
 // NewGithubDispatcher returns a new github.EventWorker to handle events channeled by github.EventDispatcher
-func NewGithubDispatcher(stopChannel <-chan struct{}, waitGroup *sync.WaitGroup, maxWorkers int, apiClient APIClient, ciBuilderClient estafette.CiBuilderClient, cockroachDBClient cockroach.DBClient, eventsChannel chan ghcontracts.PushEvent) EventDispatcher {
+func NewGithubDispatcher(stopChannel <-chan struct{}, waitGroup *sync.WaitGroup, maxWorkers int, apiClient APIClient, ciBuilderClient estafette.CiBuilderClient, cockroachDBClient cockroach.DBClient, apiServerConfig config.APIServerConfig, eventsChannel chan ghcontracts.PushEvent) EventDispatcher {
 	return &eventDispatcherImpl{
 		waitGroup:         waitGroup,
 		stopChannel:       stopChannel,
@@ -36,6 +38,7 @@ func NewGithubDispatcher(stopChannel <-chan struct{}, waitGroup *sync.WaitGroup,
 		apiClient:         apiClient,
 		ciBuilderClient:   ciBuilderClient,
 		cockroachDBClient: cockroachDBClient,
+		apiServerConfig:   apiServerConfig,
 	}
 }
 
@@ -43,7 +46,7 @@ func NewGithubDispatcher(stopChannel <-chan struct{}, waitGroup *sync.WaitGroup,
 func (d *eventDispatcherImpl) Run() {
 	// starting n number of workers
 	for i := 0; i < d.maxWorkers; i++ {
-		worker := NewGithubEventWorker(d.stopChannel, d.waitGroup, d.workerPool, d.apiClient, d.ciBuilderClient, d.cockroachDBClient)
+		worker := NewGithubEventWorker(d.stopChannel, d.waitGroup, d.workerPool, d.apiClient, d.ciBuilderClient, d.cockroachDBClient, d.apiServerConfig)
 		worker.ListenToEventChannels()
 	}
 
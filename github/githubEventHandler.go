@@ -6,12 +6,17 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"strings"
+	"sync"
 
+	"github.com/estafette/estafette-ci-api/cockroach"
 	"github.com/estafette/estafette-ci-api/config"
+	"github.com/estafette/estafette-ci-api/estafette"
 	ghcontracts "github.com/estafette/estafette-ci-api/github/contracts"
+	contracts "github.com/estafette/estafette-ci-contracts"
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog/log"
@@ -21,20 +26,35 @@ import (
 type EventHandler interface {
 	Handle(*gin.Context)
 	HandlePushEvent(ghcontracts.PushEvent)
-	HasValidSignature([]byte, string) (bool, error)
+	HandleDeleteEvent(ghcontracts.DeleteEvent)
+	HandleRepositoryEvent(ghcontracts.RepositoryEvent)
+	HandleCheckRunEvent(ghcontracts.CheckRunEvent)
+	HandleDeploymentEvent(ghcontracts.DeploymentEvent)
+	HandleInstallationEvent(*config.GithubAppConfig, ghcontracts.InstallationEvent)
+	HasValidSignature([]byte, string, string) (bool, error)
+	UpdateConfig(config.GithubConfig)
 }
 
 type eventHandlerImpl struct {
 	eventsChannel                chan ghcontracts.PushEvent
 	config                       config.GithubConfig
+	configMutex                  sync.RWMutex
+	apiClient                    APIClient
+	repositoryEventHelper        estafette.RepositoryEventHelper
+	releaseHelper                estafette.ReleaseHelper
+	triggerFunc                  func(estafette.TriggerEvent) (*cockroach.Build, error)
 	prometheusInboundEventTotals *prometheus.CounterVec
 }
 
 // NewGithubEventHandler returns a github.EventHandler to handle incoming webhook events
-func NewGithubEventHandler(eventsChannel chan ghcontracts.PushEvent, config config.GithubConfig, prometheusInboundEventTotals *prometheus.CounterVec) EventHandler {
+func NewGithubEventHandler(eventsChannel chan ghcontracts.PushEvent, config config.GithubConfig, apiClient APIClient, repositoryEventHelper estafette.RepositoryEventHelper, releaseHelper estafette.ReleaseHelper, triggerFunc func(estafette.TriggerEvent) (*cockroach.Build, error), prometheusInboundEventTotals *prometheus.CounterVec) EventHandler {
 	return &eventHandlerImpl{
 		eventsChannel:                eventsChannel,
 		config:                       config,
+		apiClient:                    apiClient,
+		repositoryEventHelper:        repositoryEventHelper,
+		releaseHelper:                releaseHelper,
+		triggerFunc:                  triggerFunc,
 		prometheusInboundEventTotals: prometheusInboundEventTotals,
 	}
 }
@@ -52,12 +72,19 @@ func (h *eventHandlerImpl) Handle(c *gin.Context) {
 		return
 	}
 
-	// verify hmac signature
-	hasValidSignature, err := h.HasValidSignature(body, c.GetHeader("X-Hub-Signature"))
-	if err != nil {
-		log.Error().Err(err).Msg("Verifying signature from Github webhook failed")
-		c.String(http.StatusInternalServerError, "Verifying signature from Github webhook failed")
-		return
+	// verify hmac signature with the webhook secret of the app that sent the webhook
+	hasValidSignature := false
+	var app *config.GithubAppConfig
+	for _, app = range h.getWebhookApps(c) {
+		hasValidSignature, err = h.HasValidSignature(body, c.GetHeader("X-Hub-Signature"), app.WebhookSecret)
+		if err != nil {
+			log.Error().Err(err).Msg("Verifying signature from Github webhook failed")
+			c.String(http.StatusInternalServerError, "Verifying signature from Github webhook failed")
+			return
+		}
+		if hasValidSignature {
+			break
+		}
 	}
 	if !hasValidSignature {
 		log.Warn().Msg("Signature from Github webhook is invalid")
@@ -78,16 +105,75 @@ func (h *eventHandlerImpl) Handle(c *gin.Context) {
 
 		h.HandlePushEvent(pushEvent)
 
+	case "delete": // Any time a Branch or Tag is deleted.
+
+		// unmarshal json body
+		var deleteEvent ghcontracts.DeleteEvent
+		err := json.Unmarshal(body, &deleteEvent)
+		if err != nil {
+			log.Error().Err(err).Str("body", string(body)).Msg("Deserializing body to GithubDeleteEvent failed")
+			return
+		}
+
+		h.HandleDeleteEvent(deleteEvent)
+
+	case "repository": // Any time a Repository is created, deleted (organization hooks only), archived, unarchived, made public, made private, renamed or transferred.
+
+		// unmarshal json body
+		var repositoryEvent ghcontracts.RepositoryEvent
+		err := json.Unmarshal(body, &repositoryEvent)
+		if err != nil {
+			log.Error().Err(err).Str("body", string(body)).Msg("Deserializing body to GithubRepositoryEvent failed")
+			return
+		}
+
+		h.HandleRepositoryEvent(repositoryEvent)
+
+	case "check_run": // Any time a check run is created, requested, rerequested, or completed.
+
+		// unmarshal json body
+		var checkRunEvent ghcontracts.CheckRunEvent
+		err := json.Unmarshal(body, &checkRunEvent)
+		if err != nil {
+			log.Error().Err(err).Str("body", string(body)).Msg("Deserializing body to GithubCheckRunEvent failed")
+			return
+		}
+
+		h.HandleCheckRunEvent(checkRunEvent)
+
+	case "deployment": // Any time a Repository has a new deployment created from the API.
+
+		// unmarshal json body
+		var deploymentEvent ghcontracts.DeploymentEvent
+		err := json.Unmarshal(body, &deploymentEvent)
+		if err != nil {
+			log.Error().Err(err).Str("body", string(body)).Msg("Deserializing body to GithubDeploymentEvent failed")
+			return
+		}
+
+		h.HandleDeploymentEvent(deploymentEvent)
+
 	case
+		"installation",              // Any time a GitHub App is installed or uninstalled.
+		"installation_repositories": // Any time a repository is added or removed from an installation.
+
+		// unmarshal json body
+		var installationEvent ghcontracts.InstallationEvent
+		err := json.Unmarshal(body, &installationEvent)
+		if err != nil {
+			log.Error().Err(err).Str("body", string(body)).Msg("Deserializing body to GithubInstallationEvent failed")
+			return
+		}
+
+		h.HandleInstallationEvent(app, installationEvent)
+
+	case
+		"check_suite",                           // Any time a check suite is requested, rerequested, or completed.
 		"commit_comment",                        // Any time a Commit is commented on.
 		"create",                                // Any time a Branch or Tag is created.
-		"delete",                                // Any time a Branch or Tag is deleted.
-		"deployment",                            // Any time a Repository has a new deployment created from the API.
 		"deployment_status",                     // Any time a deployment for a Repository has a status update from the API.
 		"fork",                                  // Any time a Repository is forked.
 		"gollum",                                // Any time a Wiki page is updated.
-		"installation",                          // Any time a GitHub App is installed or uninstalled.
-		"installation_repositories",             // Any time a repository is added or removed from an installation.
 		"issue_comment",                         // Any time a comment on an issue is created, edited, or deleted.
 		"issues",                                // Any time an Issue is assigned, unassigned, labeled, unlabeled, opened, edited, milestoned, demilestoned, closed, or reopened.
 		"label",                                 // Any time a Label is created, edited, or deleted.
@@ -105,7 +191,6 @@ func (h *eventHandlerImpl) Handle(c *gin.Context) {
 		"pull_request_review_comment",           // Any time a comment on a pull request's unified diff is created, edited, or deleted (in the Files Changed tab).
 		"pull_request_review",                   // Any time a pull request review is submitted, edited, or dismissed.
 		"pull_request",                          // Any time a pull request is assigned, unassigned, labeled, unlabeled, opened, edited, closed, reopened, or synchronized (updated due to a new push in the branch that the pull request is tracking). Also any time a pull request review is requested, or a review request is removed.
-		"repository",                            // Any time a Repository is created, deleted (organization hooks only), made public, or made private.
 		"release",                               // Any time a Release is published in a Repository.
 		"status",                                // Any time a Repository has a status update from the API
 		"team",                                  // Any time a team is created, deleted, modified, or added to or removed from a repository. Organization hooks only
@@ -125,7 +210,140 @@ func (h *eventHandlerImpl) HandlePushEvent(pushEvent ghcontracts.PushEvent) {
 	h.eventsChannel <- pushEvent
 }
 
-func (h *eventHandlerImpl) HasValidSignature(body []byte, signatureHeader string) (bool, error) {
+func (h *eventHandlerImpl) HandleDeleteEvent(deleteEvent ghcontracts.DeleteEvent) {
+
+	// deleted tags don't have running builds of their own
+	if deleteEvent.RefType != "branch" {
+		return
+	}
+
+	err := h.repositoryEventHelper.BranchDeleted(deleteEvent.Repository.GetRepoSource(), deleteEvent.GetRepoOwner(), deleteEvent.GetRepoName(), deleteEvent.Ref)
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed canceling builds for deleted branch %v of %v", deleteEvent.Ref, deleteEvent.Repository.FullName)
+	}
+}
+
+func (h *eventHandlerImpl) HandleRepositoryEvent(repositoryEvent ghcontracts.RepositoryEvent) {
+
+	switch repositoryEvent.Action {
+	case "renamed", "transferred":
+		err := h.repositoryEventHelper.RepositoryRenamed(repositoryEvent.Repository.GetRepoSource(), repositoryEvent.GetPreviousRepoOwner(), repositoryEvent.GetPreviousRepoName(), repositoryEvent.GetRepoOwner(), repositoryEvent.GetRepoName())
+		if err != nil {
+			log.Error().Err(err).Msgf("Failed moving pipeline for %v repository %v", repositoryEvent.Action, repositoryEvent.Repository.FullName)
+		}
+
+	case "deleted":
+		err := h.repositoryEventHelper.RepositoryDeleted(repositoryEvent.Repository.GetRepoSource(), repositoryEvent.GetRepoOwner(), repositoryEvent.GetRepoName())
+		if err != nil {
+			log.Error().Err(err).Msgf("Failed archiving pipeline for deleted repository %v", repositoryEvent.Repository.FullName)
+		}
+	}
+}
+
+func (h *eventHandlerImpl) HandleCheckRunEvent(checkRunEvent ghcontracts.CheckRunEvent) {
+
+	// only a re-run from the github ui starts a new build
+	if checkRunEvent.Action != "rerequested" {
+		return
+	}
+
+	if checkRunEvent.GetRepoBranch() == "" {
+		log.Warn().Msgf("Re-run of check run %v for %v has no branch, not starting a build", checkRunEvent.CheckRun.Name, checkRunEvent.Repository.FullName)
+		return
+	}
+
+	triggerEvent := estafette.TriggerEvent{
+		RepoSource:   checkRunEvent.Repository.GetRepoSource(),
+		RepoOwner:    checkRunEvent.GetRepoOwner(),
+		RepoName:     checkRunEvent.Repository.Name,
+		RepoBranch:   checkRunEvent.GetRepoBranch(),
+		RepoRevision: checkRunEvent.CheckRun.HeadSHA,
+		Reason:       fmt.Sprintf("re-run of check %v requested by %v", checkRunEvent.CheckRun.Name, checkRunEvent.Sender.Login),
+	}
+
+	// building takes longer than github waits for a webhook response
+	go func(triggerEvent estafette.TriggerEvent) {
+		_, err := h.triggerFunc(triggerEvent)
+		if err != nil {
+			log.Error().Err(err).Msgf("Failed starting build for re-run of check run %v for %v", checkRunEvent.CheckRun.Name, checkRunEvent.Repository.FullName)
+		}
+	}(triggerEvent)
+}
+
+func (h *eventHandlerImpl) HandleDeploymentEvent(deploymentEvent ghcontracts.DeploymentEvent) {
+
+	payload := deploymentEvent.Deployment.GetPayload()
+
+	// deployments created by estafette itself for a release don't start another release
+	if payload.ReleaseID != "" {
+		return
+	}
+
+	release := cockroach.Release{
+		Release: contracts.Release{
+			Name:        deploymentEvent.Deployment.Environment,
+			Action:      payload.Action,
+			RepoSource:  deploymentEvent.Repository.GetRepoSource(),
+			RepoOwner:   deploymentEvent.GetRepoOwner(),
+			RepoName:    deploymentEvent.GetRepoName(),
+			TriggeredBy: deploymentEvent.Sender.Login,
+		},
+		DeploymentID: deploymentEvent.Deployment.ID,
+	}
+
+	// releasing takes longer than github waits for a webhook response
+	go func(release cockroach.Release, revision string) {
+
+		// anyone with read access can create a deployment through the api, but only those allowed to push get to release
+		allowed, err := h.senderCanRelease(release)
+		if err != nil {
+			log.Error().Err(err).Msgf("Failed checking permission of %v to release to %v for deployment %v of %v", release.TriggeredBy, release.Name, release.DeploymentID, deploymentEvent.Repository.FullName)
+			return
+		}
+		if !allowed {
+			log.Warn().Msgf("Ignoring deployment %v of %v to %v, %v has no write permission on the repository", release.DeploymentID, deploymentEvent.Repository.FullName, release.Name, release.TriggeredBy)
+			return
+		}
+
+		insertedRelease, err := h.releaseHelper.StartReleaseForRevision(release, revision)
+		if err != nil {
+			log.Error().Err(err).Msgf("Failed starting release to %v for deployment %v of %v revision %v", release.Name, release.DeploymentID, deploymentEvent.Repository.FullName, revision)
+			return
+		}
+
+		log.Info().Msgf("Started release %v of version %v to %v for deployment %v of %v", insertedRelease.ID, insertedRelease.ReleaseVersion, insertedRelease.Name, release.DeploymentID, deploymentEvent.Repository.FullName)
+	}(release, deploymentEvent.Deployment.SHA)
+}
+
+// senderCanRelease checks whether whoever created a deployment has admin or write permission on the repository
+func (h *eventHandlerImpl) senderCanRelease(release cockroach.Release) (bool, error) {
+
+	installationID, err := h.apiClient.GetInstallationID(release.RepoSource, release.RepoOwner)
+	if err != nil {
+		return false, err
+	}
+
+	accessToken, err := h.apiClient.GetInstallationToken(release.RepoSource, installationID)
+	if err != nil {
+		return false, err
+	}
+
+	permission, err := h.apiClient.GetCollaboratorPermission(accessToken, release.RepoSource, fmt.Sprintf("%v/%v", release.RepoOwner, release.RepoName), release.TriggeredBy)
+	if err != nil {
+		return false, err
+	}
+
+	return permission == "admin" || permission == "write", nil
+}
+
+func (h *eventHandlerImpl) HandleInstallationEvent(app *config.GithubAppConfig, installationEvent ghcontracts.InstallationEvent) {
+
+	log.Info().Msgf("Installation %v of Github app %v got %v by %v, refreshing its installations and token", installationEvent.Installation.ID, app.AppID, installationEvent.Action, installationEvent.Sender.Login)
+
+	h.apiClient.InvalidateInstallation(app, installationEvent.Installation.ID)
+}
+
+func (h *eventHandlerImpl) HasValidSignature(body []byte, signatureHeader, webhookSecret string) (bool, error) {
 
 	// https://developer.github.com/webhooks/securing/
 	signature := strings.Replace(signatureHeader, "sha1=", "", 1)
@@ -135,7 +353,7 @@ func (h *eventHandlerImpl) HasValidSignature(body []byte, signatureHeader string
 	}
 
 	// calculate expected MAC
-	mac := hmac.New(sha1.New, []byte(h.config.WebhookSecret))
+	mac := hmac.New(sha1.New, []byte(webhookSecret))
 	mac.Write(body)
 	expectedMAC := mac.Sum(nil)
 
@@ -151,3 +369,35 @@ func (h *eventHandlerImpl) HasValidSignature(body []byte, signatureHeader string
 
 	return false, nil
 }
+
+// getWebhookApps returns the apps that can have sent a webhook; github identifies the app in the installation target header, otherwise it's any of the apps for the github enterprise host in the enterprise host header or for github.com
+func (h *eventHandlerImpl) getWebhookApps(c *gin.Context) []*config.GithubAppConfig {
+
+	if c.GetHeader("X-Github-Hook-Installation-Target-Type") == "integration" {
+		if app := h.getConfig().GetAppByID(c.GetHeader("X-Github-Hook-Installation-Target-ID")); app != nil {
+			return []*config.GithubAppConfig{app}
+		}
+	}
+
+	if enterpriseHost := c.GetHeader("X-Github-Enterprise-Host"); enterpriseHost != "" {
+		return h.getConfig().GetAppsByRepoSource(enterpriseHost)
+	}
+
+	return h.getConfig().GetAppsByRepoSource("github.com")
+}
+
+// UpdateConfig swaps in a reloaded config; webhooks received from then on get verified against its apps
+func (h *eventHandlerImpl) UpdateConfig(config config.GithubConfig) {
+	h.configMutex.Lock()
+	defer h.configMutex.Unlock()
+
+	h.config = config
+}
+
+func (h *eventHandlerImpl) getConfig() *config.GithubConfig {
+	h.configMutex.RLock()
+	defer h.configMutex.RUnlock()
+
+	config := h.config
+	return &config
+}
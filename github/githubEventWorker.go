@@ -1,11 +1,13 @@
 package github
 
 import (
+	"fmt"
 	"strconv"
 	"strings"
 	"sync"
 
 	"github.com/estafette/estafette-ci-api/cockroach"
+	"github.com/estafette/estafette-ci-api/config"
 	"github.com/estafette/estafette-ci-api/estafette"
 	ghcontracts "github.com/estafette/estafette-ci-api/github/contracts"
 	"github.com/estafette/estafette-ci-contracts"
@@ -17,6 +19,7 @@ import (
 type EventWorker interface {
 	ListenToEventChannels()
 	CreateJobForGithubPush(ghcontracts.PushEvent)
+	CreateJobForTrigger(estafette.TriggerEvent) (*cockroach.Build, error)
 }
 
 type eventWorkerImpl struct {
@@ -27,10 +30,11 @@ type eventWorkerImpl struct {
 	apiClient         APIClient
 	ciBuilderClient   estafette.CiBuilderClient
 	cockroachDBClient cockroach.DBClient
+	apiServerConfig   config.APIServerConfig
 }
 
 // NewGithubEventWorker returns a new github.EventWorker to handle events channeled by github.EventHandler
-func NewGithubEventWorker(stopChannel <-chan struct{}, waitGroup *sync.WaitGroup, workerPool chan chan ghcontracts.PushEvent, apiClient APIClient, ciBuilderClient estafette.CiBuilderClient, cockroachDBClient cockroach.DBClient) EventWorker {
+func NewGithubEventWorker(stopChannel <-chan struct{}, waitGroup *sync.WaitGroup, workerPool chan chan ghcontracts.PushEvent, apiClient APIClient, ciBuilderClient estafette.CiBuilderClient, cockroachDBClient cockroach.DBClient, apiServerConfig config.APIServerConfig) EventWorker {
 	return &eventWorkerImpl{
 		waitGroup:         waitGroup,
 		stopChannel:       stopChannel,
@@ -39,6 +43,7 @@ func NewGithubEventWorker(stopChannel <-chan struct{}, waitGroup *sync.WaitGroup
 		apiClient:         apiClient,
 		ciBuilderClient:   ciBuilderClient,
 		cockroachDBClient: cockroachDBClient,
+		apiServerConfig:   apiServerConfig,
 	}
 }
 
@@ -65,14 +70,76 @@ func (w *eventWorkerImpl) ListenToEventChannels() {
 }
 
 func (w *eventWorkerImpl) CreateJobForGithubPush(pushEvent ghcontracts.PushEvent) {
+	w.createJobForGithubPush(pushEvent, "", nil)
+}
+
+// CreateJobForTrigger rebuilds a branch the same way a push would, with the firing trigger stored as the reason for the build;
+// without a revision the last commit of the branch gets built
+func (w *eventWorkerImpl) CreateJobForTrigger(triggerEvent estafette.TriggerEvent) (*cockroach.Build, error) {
+
+	// get installation id with just the repo owner
+	installationID, err := w.apiClient.GetInstallationID(triggerEvent.RepoSource, triggerEvent.RepoOwner)
+	if err != nil {
+		log.Error().Err(err).
+			Msgf("Retrieving installation id for trigger for Github repository %v/%v failed", triggerEvent.RepoOwner, triggerEvent.RepoName)
+		return nil, err
+	}
+
+	if triggerEvent.RepoRevision == "" {
+		accessToken, err := w.apiClient.GetInstallationToken(triggerEvent.RepoSource, installationID)
+		if err != nil {
+			log.Error().Err(err).
+				Msg("Retrieving access token failed")
+			return nil, err
+		}
+
+		triggerEvent.RepoRevision, err = w.apiClient.GetBranchRevision(accessToken, triggerEvent.RepoSource, fmt.Sprintf("%v/%v", triggerEvent.RepoOwner, triggerEvent.RepoName), triggerEvent.RepoBranch)
+		if err != nil {
+			log.Error().Err(err).
+				Msgf("Retrieving last commit of branch %v for Github repository %v/%v failed", triggerEvent.RepoBranch, triggerEvent.RepoOwner, triggerEvent.RepoName)
+			return nil, err
+		}
+	}
+
+	pushEvent := ghcontracts.PushEvent{
+		After: triggerEvent.RepoRevision,
+		Ref:   fmt.Sprintf("refs/heads/%v", triggerEvent.RepoBranch),
+		Repository: ghcontracts.Repository{
+			HTMLURL:  fmt.Sprintf("https://%v/%v/%v", triggerEvent.RepoSource, triggerEvent.RepoOwner, triggerEvent.RepoName),
+			Name:     triggerEvent.RepoName,
+			FullName: fmt.Sprintf("%v/%v", triggerEvent.RepoOwner, triggerEvent.RepoName),
+		},
+		Installation: ghcontracts.Installation{
+			ID: installationID,
+		},
+	}
+
+	return w.createJobForGithubPush(pushEvent, triggerEvent.Reason, triggerEvent.EnvironmentVariables)
+}
+
+func (w *eventWorkerImpl) createJobForGithubPush(pushEvent ghcontracts.PushEvent, triggerReason string, environmentVariables map[string]string) (build *cockroach.Build, err error) {
 
 	// check to see that it's a cloneable event
-	if !strings.HasPrefix(pushEvent.Ref, "refs/heads/") {
+	if pushEvent.Deleted || (!strings.HasPrefix(pushEvent.Ref, "refs/heads/") && !strings.HasPrefix(pushEvent.Ref, "refs/tags/")) {
 		return
 	}
 
+	// pushes to archived pipelines don't get built until the pipeline is unarchived
+	if triggerReason == "" {
+		var pipeline *cockroach.Pipeline
+		pipeline, err = w.cockroachDBClient.GetPipeline(pushEvent.GetRepoSource(), pushEvent.GetRepoOwner(), pushEvent.GetRepoName(), true)
+		if err != nil {
+			log.Error().Err(err).Msgf("Failed retrieving pipeline %v/%v/%v to check whether it's archived", pushEvent.GetRepoSource(), pushEvent.GetRepoOwner(), pushEvent.GetRepoName())
+			return
+		}
+		if pipeline != nil && pipeline.Archived {
+			log.Info().Msgf("Pipeline %v/%v/%v is archived, rejecting push", pushEvent.GetRepoSource(), pushEvent.GetRepoOwner(), pushEvent.GetRepoName())
+			return
+		}
+	}
+
 	// get access token
-	accessToken, err := w.apiClient.GetInstallationToken(pushEvent.Installation.ID)
+	accessToken, err := w.apiClient.GetInstallationToken(pushEvent.GetRepoSource(), pushEvent.Installation.ID)
 	if err != nil {
 		log.Error().Err(err).
 			Msg("Retrieving access token failed")
@@ -91,6 +158,20 @@ func (w *eventWorkerImpl) CreateJobForGithubPush(pushEvent ghcontracts.PushEvent
 		return
 	}
 
+	// tags only get built if the manifest opts in with a matching tag trigger
+	if pushEvent.GetRepoTag() != "" {
+		var tagTriggered bool
+		tagTriggered, err = estafette.IsTagTriggered(manifestString, pushEvent.GetRepoTag())
+		if err != nil {
+			log.Error().Err(err).
+				Msgf("Reading tag triggers from manifest for repo %v and tag %v failed", pushEvent.Repository.FullName, pushEvent.GetRepoTag())
+			return
+		}
+		if !tagTriggered {
+			return nil, nil
+		}
+	}
+
 	mft, err := manifest.ReadManifest(manifestString)
 	builderTrack := "stable"
 	hasValidManifest := false
@@ -111,6 +192,24 @@ func (w *eventWorkerImpl) CreateJobForGithubPush(pushEvent ghcontracts.PushEvent
 		}
 	}
 
+	// skip the build if the head commit asks to skip ci or the push didn't change any files matching the paths filter of the manifest;
+	// the skipped build is stored for the build history, but the pipeline keeps showing its last build that did run
+	skipReason := ""
+	if triggerReason == "" {
+		if skipDirective := estafette.GetSkipDirective(pushEvent.HeadCommit.Message, w.apiServerConfig.SkipDirectives); skipDirective != "" {
+			skipReason = fmt.Sprintf("Commit message contains %v", skipDirective)
+		}
+	}
+	if skipReason == "" && hasValidManifest && triggerReason == "" {
+		var pathFilter estafette.PathFilter
+		pathFilter, err = estafette.GetPathFilter(manifestString)
+		if err != nil {
+			log.Warn().Err(err).Msgf("Reading paths filter from manifest for repo %v and revision %v failed, building anyway", pushEvent.Repository.FullName, pushEvent.After)
+		} else {
+			skipReason = pathFilter.GetSkipReason(pushEvent.GetChangedFiles())
+		}
+	}
+
 	// get authenticated url for the repository
 	authenticatedRepositoryURL, err := w.apiClient.GetAuthenticatedRepositoryURL(accessToken, pushEvent.Repository.HTMLURL)
 	if err != nil {
@@ -119,18 +218,24 @@ func (w *eventWorkerImpl) CreateJobForGithubPush(pushEvent ghcontracts.PushEvent
 		return
 	}
 
-	// get autoincrement number
-	autoincrement, err := w.cockroachDBClient.GetAutoIncrement("github", pushEvent.Repository.FullName)
-	if err != nil {
-		log.Warn().Err(err).
-			Msgf("Failed generating autoincrement for Github repository %v", pushEvent.Repository.FullName)
+	// get autoincrement number; skipped builds don't get a version
+	autoincrement := 0
+	if skipReason == "" {
+		autoincrement, err = w.cockroachDBClient.GetAutoIncrement("github", pushEvent.Repository.FullName)
+		if err != nil {
+			log.Warn().Err(err).
+				Msgf("Failed generating autoincrement for Github repository %v", pushEvent.Repository.FullName)
+		}
 	}
 
 	// set build version number
 	buildVersion := ""
 	buildStatus := "failed"
-	if hasValidManifest {
-		buildVersion = mft.Version.Version(manifest.EstafetteVersionParams{
+	if skipReason != "" {
+		buildStatus = "skipped"
+	} else if hasValidManifest {
+		version := estafette.WithVersionTag(mft.Version, pushEvent.GetRepoTag())
+		buildVersion = version.Version(manifest.EstafetteVersionParams{
 			AutoIncrement: autoincrement,
 			Branch:        pushEvent.GetRepoBranch(),
 			Revision:      pushEvent.GetRepoRevision(),
@@ -179,18 +284,23 @@ func (w *eventWorkerImpl) CreateJobForGithubPush(pushEvent ghcontracts.PushEvent
 	}
 
 	// store build in db
-	insertedBuild, err := w.cockroachDBClient.InsertBuild(contracts.Build{
-		RepoSource:     pushEvent.GetRepoSource(),
-		RepoOwner:      pushEvent.GetRepoOwner(),
-		RepoName:       pushEvent.GetRepoName(),
-		RepoBranch:     pushEvent.GetRepoBranch(),
-		RepoRevision:   pushEvent.GetRepoRevision(),
-		BuildVersion:   buildVersion,
-		BuildStatus:    buildStatus,
-		Labels:         labels,
-		ReleaseTargets: releaseTargets,
-		Manifest:       manifestString,
-		Commits:        commits,
+	insertedBuild, err := w.cockroachDBClient.InsertBuild(cockroach.Build{
+		Build: contracts.Build{
+			RepoSource:     pushEvent.GetRepoSource(),
+			RepoOwner:      pushEvent.GetRepoOwner(),
+			RepoName:       pushEvent.GetRepoName(),
+			RepoBranch:     pushEvent.GetRepoBranch(),
+			RepoRevision:   pushEvent.GetRepoRevision(),
+			BuildVersion:   buildVersion,
+			BuildStatus:    buildStatus,
+			Labels:         labels,
+			ReleaseTargets: releaseTargets,
+			Manifest:       manifestString,
+			Commits:        commits,
+		},
+		RepoTag:       pushEvent.GetRepoTag(),
+		TriggerReason: triggerReason,
+		SkipReason:    skipReason,
 	})
 	if err != nil {
 		log.Error().Err(err).
@@ -198,6 +308,11 @@ func (w *eventWorkerImpl) CreateJobForGithubPush(pushEvent ghcontracts.PushEvent
 		return
 	}
 
+	if skipReason != "" {
+		log.Info().Msgf("Skipped build for Github repository %v revision %v: %v", pushEvent.Repository.FullName, pushEvent.GetRepoRevision(), skipReason)
+		return &insertedBuild, nil
+	}
+
 	buildID, err := strconv.Atoi(insertedBuild.ID)
 	if err != nil {
 		log.Warn().Err(err).Msgf("Failed to convert build id %v to int", insertedBuild.ID)
@@ -205,19 +320,21 @@ func (w *eventWorkerImpl) CreateJobForGithubPush(pushEvent ghcontracts.PushEvent
 
 	// define ci builder params
 	ciBuilderParams := estafette.CiBuilderParams{
-		JobType:              "build",
-		RepoSource:           pushEvent.GetRepoSource(),
-		RepoOwner:            pushEvent.GetRepoOwner(),
-		RepoName:             pushEvent.GetRepoName(),
-		RepoURL:              authenticatedRepositoryURL,
-		RepoBranch:           pushEvent.GetRepoBranch(),
-		RepoRevision:         pushEvent.GetRepoRevision(),
-		EnvironmentVariables: map[string]string{"ESTAFETTE_GITHUB_API_TOKEN": accessToken.Token},
-		Track:                builderTrack,
-		AutoIncrement:        autoincrement,
-		VersionNumber:        buildVersion,
-		Manifest:             mft,
-		BuildID:              buildID,
+		JobType:                   "build",
+		RepoSource:                pushEvent.GetRepoSource(),
+		RepoOwner:                 pushEvent.GetRepoOwner(),
+		RepoName:                  pushEvent.GetRepoName(),
+		RepoURL:                   authenticatedRepositoryURL,
+		RepoBranch:                pushEvent.GetRepoBranch(),
+		RepoRevision:              pushEvent.GetRepoRevision(),
+		RepoTag:                   pushEvent.GetRepoTag(),
+		EnvironmentVariables:      map[string]string{"ESTAFETTE_GITHUB_API_TOKEN": accessToken.Token},
+		Track:                     builderTrack,
+		AutoIncrement:             autoincrement,
+		VersionNumber:             buildVersion,
+		Manifest:                  mft,
+		BuildID:                   buildID,
+		ExtraEnvironmentVariables: environmentVariables,
 	}
 
 	// create ci builder job
@@ -229,7 +346,9 @@ func (w *eventWorkerImpl) CreateJobForGithubPush(pushEvent ghcontracts.PushEvent
 				Interface("params", ciBuilderParams).
 				Msgf("Creating estafette-ci-builder job for Github repository %v/%v revision %v failed", ciBuilderParams.RepoOwner, ciBuilderParams.RepoName, ciBuilderParams.RepoRevision)
 
-			return
+			return &insertedBuild, err
 		}
 	}
+
+	return &insertedBuild, nil
 }
@@ -2,10 +2,13 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"io/ioutil"
 	stdlog "log"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"runtime"
 	"sync"
 	"syscall"
@@ -20,8 +23,8 @@ import (
 	"github.com/estafette/estafette-ci-api/estafette"
 	"github.com/estafette/estafette-ci-api/github"
 	ghcontracts "github.com/estafette/estafette-ci-api/github/contracts"
+	"github.com/estafette/estafette-ci-api/secrets"
 	"github.com/estafette/estafette-ci-api/slack"
-	"github.com/estafette/estafette-ci-crypt"
 	"github.com/gin-contrib/gzip"
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus"
@@ -45,6 +48,15 @@ var (
 	apiAddress               = kingpin.Flag("api-listen-address", "The address to listen on for api HTTP requests.").Default(":5000").String()
 	configFilePath           = kingpin.Flag("config-file-path", "The path to yaml config file configuring this application.").Default("/configs/config.yaml").String()
 	secretDecryptionKey      = kingpin.Flag("secret-decryption-key", "The AES-256 key used to decrypt secrets that have been encrypted with it.").Envar("SECRET_DECRYPTION_KEY").String()
+	legacyDecryptionKeys     = kingpin.Flag("secret-decryption-legacy-key", "An AES-256 key that used to be the secret decryption key; it's only used to decrypt secrets that have been encrypted with it. Can be repeated.").Envar("SECRET_DECRYPTION_LEGACY_KEYS").Strings()
+	configOverlayFilePaths   = kingpin.Flag("config-overlay-file-path", "The path to a yaml config file merged over the base config file, for example with per-environment settings; can be repeated and gets applied in order.").Strings()
+	configReloadInterval     = kingpin.Flag("config-reload-interval", "The interval at which the yaml config file gets checked for changes to reload it; a SIGHUP reloads it immediately.").Default("30s").Duration()
+
+	// commands
+	serveCommand          = kingpin.Command("serve", "Runs the api server.").Default()
+	validateConfigCommand = kingpin.Command("validate-config", "Validates the yaml config file and its secrets, listing all problems and exiting with a non-zero code if there are any.")
+	reencryptCommand      = kingpin.Command("reencrypt-secrets", "Re-encrypts all secrets in a config or manifest file with the primary secret decryption key and writes the result to stdout.")
+	reencryptFilePath     = reencryptCommand.Arg("file", "The config or manifest file to re-encrypt the secrets of.").Required().ExistingFile()
 
 	// prometheusInboundEventTotals is the prometheus timeline serie that keeps track of inbound events
 	prometheusInboundEventTotals = prometheus.NewCounterVec(
@@ -63,18 +75,47 @@ var (
 		},
 		[]string{"target"},
 	)
+
+	// prometheusConfigReloadTotals is the prometheus timeline serie that keeps track of config reloads
+	prometheusConfigReloadTotals = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "estafette_ci_api_config_reload_totals",
+			Help: "Total of config reloads.",
+		},
+		[]string{"trigger", "result"},
+	)
+
+	// prometheusSecretKeyUsageTotals is the prometheus timeline serie that keeps track of encryptions and decryptions per secret decryption key
+	prometheusSecretKeyUsageTotals = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "estafette_ci_api_secret_key_usage_totals",
+			Help: "Total of secrets encrypted or decrypted per key.",
+		},
+		[]string{"key", "operation"},
+	)
 )
 
 func init() {
 	// Metrics have to be registered to be exposed:
 	prometheus.MustRegister(prometheusInboundEventTotals)
 	prometheus.MustRegister(prometheusOutboundAPICallTotals)
+	prometheus.MustRegister(prometheusConfigReloadTotals)
+	prometheus.MustRegister(prometheusSecretKeyUsageTotals)
 }
 
 func main() {
 
 	// parse command line parameters
-	kingpin.Parse()
+	command := kingpin.Parse()
+
+	switch command {
+	case validateConfigCommand.FullCommand():
+		validateConfig()
+		return
+	case reencryptCommand.FullCommand():
+		reencryptSecrets()
+		return
+	}
 
 	// configure json logging
 	initLogging()
@@ -111,6 +152,46 @@ func main() {
 	log.Info().Msg("Server gracefully stopped")
 }
 
+func validateConfig() {
+
+	configReader := config.NewConfigReader(secrets.NewSecretHelper(*secretDecryptionKey, *legacyDecryptionKeys, prometheusSecretKeyUsageTotals))
+
+	err := configReader.ValidateConfigFile(*configFilePath, *configOverlayFilePaths)
+	if err == nil {
+		fmt.Printf("%v is valid\n", *configFilePath)
+		return
+	}
+
+	if validationErrors, ok := err.(config.ValidationErrors); ok {
+		for _, validationError := range validationErrors {
+			fmt.Printf("%v: %v\n", *configFilePath, validationError)
+		}
+	} else {
+		fmt.Printf("%v: %v\n", *configFilePath, err)
+	}
+
+	os.Exit(1)
+}
+
+func reencryptSecrets() {
+
+	secretHelper := secrets.NewSecretHelper(*secretDecryptionKey, *legacyDecryptionKeys, prometheusSecretKeyUsageTotals)
+
+	data, err := ioutil.ReadFile(*reencryptFilePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v: %v\n", *reencryptFilePath, err)
+		os.Exit(1)
+	}
+
+	reencryptedText, err := secretHelper.ReencryptAllEnvelopes(string(data))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v: %v\n", *reencryptFilePath, err)
+		os.Exit(1)
+	}
+
+	fmt.Print(reencryptedText)
+}
+
 func startPrometheus() {
 	http.Handle(*prometheusMetricsPath, promhttp.Handler())
 
@@ -173,45 +254,68 @@ func createRouter() *gin.Engine {
 
 func handleRequests(stopChannel <-chan struct{}, waitGroup *sync.WaitGroup) *http.Server {
 
-	secretHelper := crypt.NewSecretHelper(*secretDecryptionKey)
+	secretHelper := secrets.NewSecretHelper(*secretDecryptionKey, *legacyDecryptionKeys, prometheusSecretKeyUsageTotals)
 	configReader := config.NewConfigReader(secretHelper)
 
-	config, err := configReader.ReadConfigFromFile(*configFilePath, true)
+	apiConfig, _, err := configReader.ReadLayeredConfig(*configFilePath, *configOverlayFilePaths, true)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed reading configuration")
 	}
 
-	encryptedConfig, err := configReader.ReadConfigFromFile(*configFilePath, false)
+	err = apiConfig.Validate()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Configuration is invalid")
+	}
+
+	encryptedConfig, configSources, err := configReader.ReadLayeredConfig(*configFilePath, *configOverlayFilePaths, false)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed reading configuration without decrypting")
 	}
 
-	githubAPIClient := github.NewGithubAPIClient(*config.Integrations.Github, prometheusOutboundAPICallTotals)
-	bitbucketAPIClient := bitbucket.NewBitbucketAPIClient(*config.Integrations.Bitbucket, prometheusOutboundAPICallTotals)
-	slackAPIClient := slack.NewSlackAPIClient(*config.Integrations.Slack, prometheusOutboundAPICallTotals)
-	cockroachDBClient := cockroach.NewCockroachDBClient(*config.Database, prometheusOutboundAPICallTotals)
-	ciBuilderClient, err := estafette.NewCiBuilderClient(*config, *encryptedConfig, *secretDecryptionKey, prometheusOutboundAPICallTotals)
+	githubAPIClient := github.NewGithubAPIClient(*apiConfig.Integrations.Github, prometheusOutboundAPICallTotals)
+	bitbucketAPIClient := bitbucket.NewBitbucketAPIClient(*apiConfig.Integrations.Bitbucket, prometheusOutboundAPICallTotals)
+	slackAPIClient := slack.NewSlackAPIClient(*apiConfig.Integrations.Slack, prometheusOutboundAPICallTotals)
+	cockroachDBClient := cockroach.NewCockroachDBClient(*apiConfig.Database, prometheusOutboundAPICallTotals)
+	ciBuilderClient, err := estafette.NewCiBuilderClient(*apiConfig, *encryptedConfig, *secretDecryptionKey, secretHelper, cockroachDBClient, prometheusOutboundAPICallTotals)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Creating new CiBuilderClient has failed")
 	}
 
+	buildStatusHelper := estafette.NewBuildStatusHelper(*apiConfig.APIServer, cockroachDBClient, githubAPIClient.CheckRunsFunc(), bitbucketAPIClient.BuildStatusFunc(), githubAPIClient.DeploymentStatusFunc())
+	releaseHelper := estafette.NewReleaseHelper(cockroachDBClient, ciBuilderClient, githubAPIClient.JobVarsFunc(), bitbucketAPIClient.JobVarsFunc(), buildStatusHelper)
+
 	// set up database
 	err = cockroachDBClient.Connect()
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed connecting to CockroachDB")
 	}
 
+	// seed the manifest templates from the manifest-*.tmpl files next to the config file; templates that already exist are left alone
+	importedManifestTemplates, err := estafette.ImportManifestTemplateFiles(cockroachDBClient, filepath.Dir(*configFilePath), "estafette-ci-api")
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed importing manifest template files")
+	} else if len(importedManifestTemplates) > 0 {
+		log.Info().Msgf("Imported manifest templates %v", importedManifestTemplates)
+	}
+
 	// listen to channels for push events
-	githubPushEvents := make(chan ghcontracts.PushEvent, config.Integrations.Github.EventChannelBufferSize)
-	githubDispatcher := github.NewGithubDispatcher(stopChannel, waitGroup, config.Integrations.Github.MaxWorkers, githubAPIClient, ciBuilderClient, cockroachDBClient, githubPushEvents)
+	githubPushEvents := make(chan ghcontracts.PushEvent, apiConfig.Integrations.Github.EventChannelBufferSize)
+	githubDispatcher := github.NewGithubDispatcher(stopChannel, waitGroup, apiConfig.Integrations.Github.MaxWorkers, githubAPIClient, ciBuilderClient, cockroachDBClient, *apiConfig.APIServer, githubPushEvents)
 	githubDispatcher.Run()
 
-	bitbucketPushEvents := make(chan bbcontracts.RepositoryPushEvent, config.Integrations.Bitbucket.EventChannelBufferSize)
-	bitbucketDispatcher := bitbucket.NewBitbucketDispatcher(stopChannel, waitGroup, config.Integrations.Bitbucket.MaxWorkers, bitbucketAPIClient, ciBuilderClient, cockroachDBClient, bitbucketPushEvents)
+	bitbucketPushEvents := make(chan bbcontracts.RepositoryPushEvent, apiConfig.Integrations.Bitbucket.EventChannelBufferSize)
+	bitbucketDispatcher := bitbucket.NewBitbucketDispatcher(stopChannel, waitGroup, apiConfig.Integrations.Bitbucket.MaxWorkers, bitbucketAPIClient, ciBuilderClient, cockroachDBClient, *apiConfig.APIServer, bitbucketPushEvents)
 	bitbucketDispatcher.Run()
 
-	estafetteCiBuilderEvents := make(chan estafette.CiBuilderEvent, config.APIServer.MaxWorkers)
-	estafetteDispatcher := estafette.NewEstafetteDispatcher(stopChannel, waitGroup, config.APIServer.MaxWorkers, ciBuilderClient, cockroachDBClient, estafetteCiBuilderEvents)
+	// fire builds for cron and pipeline triggers defined in the manifests
+	githubTriggerWorker := github.NewGithubEventWorker(stopChannel, waitGroup, nil, githubAPIClient, ciBuilderClient, cockroachDBClient, *apiConfig.APIServer)
+	bitbucketTriggerWorker := bitbucket.NewBitbucketEventWorker(stopChannel, waitGroup, nil, bitbucketAPIClient, ciBuilderClient, cockroachDBClient, *apiConfig.APIServer)
+	cronTriggerScheduler := estafette.NewCronTriggerScheduler(stopChannel, waitGroup, cockroachDBClient, githubTriggerWorker.CreateJobForTrigger, bitbucketTriggerWorker.CreateJobForTrigger)
+	cronTriggerScheduler.Run()
+	pipelineTriggerHelper := estafette.NewPipelineTriggerHelper(cockroachDBClient, releaseHelper, githubTriggerWorker.CreateJobForTrigger, bitbucketTriggerWorker.CreateJobForTrigger)
+
+	estafetteCiBuilderEvents := make(chan estafette.CiBuilderEvent, apiConfig.APIServer.MaxWorkers)
+	estafetteDispatcher := estafette.NewEstafetteDispatcher(stopChannel, waitGroup, apiConfig.APIServer.MaxWorkers, ciBuilderClient, cockroachDBClient, pipelineTriggerHelper, buildStatusHelper, estafetteCiBuilderEvents)
 	estafetteDispatcher.Run()
 
 	// create and init router
@@ -221,22 +325,24 @@ func handleRequests(stopChannel <-chan struct{}, waitGroup *sync.WaitGroup) *htt
 	gzippedRoutes := router.Group("/", gzip.Gzip(gzip.DefaultCompression))
 
 	// middleware to handle auth for different endpoints
-	authMiddleware := auth.NewAuthMiddleware(*config.Auth)
+	authMiddleware := auth.NewAuthMiddleware(*apiConfig.Auth)
 
-	githubEventHandler := github.NewGithubEventHandler(githubPushEvents, *config.Integrations.Github, prometheusInboundEventTotals)
+	repositoryEventHelper := estafette.NewRepositoryEventHelper(cockroachDBClient, ciBuilderClient, buildStatusHelper)
+
+	githubEventHandler := github.NewGithubEventHandler(githubPushEvents, *apiConfig.Integrations.Github, githubAPIClient, repositoryEventHelper, releaseHelper, githubTriggerWorker.CreateJobForTrigger, prometheusInboundEventTotals)
 	gzippedRoutes.POST("/api/integrations/github/events", githubEventHandler.Handle)
 
-	bitbucketEventHandler := bitbucket.NewBitbucketEventHandler(bitbucketPushEvents, prometheusInboundEventTotals)
+	bitbucketEventHandler := bitbucket.NewBitbucketEventHandler(bitbucketPushEvents, repositoryEventHelper, prometheusInboundEventTotals)
 	gzippedRoutes.POST("/api/integrations/bitbucket/events", bitbucketEventHandler.Handle)
 
-	slackEventHandler := slack.NewSlackEventHandler(secretHelper, *config.Integrations.Slack, slackAPIClient, cockroachDBClient, *config.APIServer, ciBuilderClient, githubAPIClient.JobVarsFunc(), bitbucketAPIClient.JobVarsFunc(), prometheusInboundEventTotals)
+	slackEventHandler := slack.NewSlackEventHandler(secretHelper, *apiConfig.Integrations.Slack, slackAPIClient, cockroachDBClient, *apiConfig.APIServer, releaseHelper, prometheusInboundEventTotals)
 	gzippedRoutes.POST("/api/integrations/slack/slash", slackEventHandler.Handle)
 
-	estafetteEventHandler := estafette.NewEstafetteEventHandler(*config.APIServer, estafetteCiBuilderEvents, prometheusInboundEventTotals)
+	estafetteEventHandler := estafette.NewEstafetteEventHandler(*apiConfig.APIServer, estafetteCiBuilderEvents, prometheusInboundEventTotals)
 
-	warningHelper := estafette.NewWarningHelper()
+	warningHelper := estafette.NewWarningHelper(*encryptedConfig)
 
-	estafetteAPIHandler := estafette.NewAPIHandler(*configFilePath, *config.APIServer, *config.Auth, *encryptedConfig, cockroachDBClient, ciBuilderClient, warningHelper, secretHelper, githubAPIClient.JobVarsFunc(), bitbucketAPIClient.JobVarsFunc())
+	estafetteAPIHandler := estafette.NewAPIHandler(*configFilePath, *apiConfig.APIServer, *apiConfig.Auth, *encryptedConfig, configSources, cockroachDBClient, ciBuilderClient, warningHelper, secretHelper, releaseHelper, pipelineTriggerHelper, buildStatusHelper, githubAPIClient.JobVarsFunc(), bitbucketAPIClient.JobVarsFunc(), githubTriggerWorker.CreateJobForTrigger, bitbucketTriggerWorker.CreateJobForTrigger)
 	gzippedRoutes.GET("/api/pipelines", estafetteAPIHandler.GetPipelines)
 	gzippedRoutes.GET("/api/pipelines/:source/:owner/:repo", estafetteAPIHandler.GetPipeline)
 	gzippedRoutes.GET("/api/pipelines/:source/:owner/:repo/builds", estafetteAPIHandler.GetPipelineBuilds)
@@ -251,13 +357,18 @@ func handleRequests(stopChannel <-chan struct{}, waitGroup *sync.WaitGroup) *htt
 	gzippedRoutes.GET("/api/pipelines/:source/:owner/:repo/stats/buildsdurations", estafetteAPIHandler.GetPipelineStatsBuildsDurations)
 	gzippedRoutes.GET("/api/pipelines/:source/:owner/:repo/stats/releasesdurations", estafetteAPIHandler.GetPipelineStatsReleasesDurations)
 	gzippedRoutes.GET("/api/pipelines/:source/:owner/:repo/warnings", estafetteAPIHandler.GetPipelineWarnings)
+	gzippedRoutes.GET("/api/pipelines/:source/:owner/:repo/triggers", estafetteAPIHandler.GetPipelineTriggers)
 	gzippedRoutes.GET("/api/stats/pipelinescount", estafetteAPIHandler.GetStatsPipelinesCount)
 	gzippedRoutes.GET("/api/stats/buildscount", estafetteAPIHandler.GetStatsBuildsCount)
 	gzippedRoutes.GET("/api/stats/releasescount", estafetteAPIHandler.GetStatsReleasesCount)
 	gzippedRoutes.GET("/api/stats/buildsduration", estafetteAPIHandler.GetStatsBuildsDuration)
 	gzippedRoutes.GET("/api/stats/buildsadoption", estafetteAPIHandler.GetStatsBuildsAdoption)
 	gzippedRoutes.GET("/api/stats/releasesadoption", estafetteAPIHandler.GetStatsReleasesAdoption)
+	gzippedRoutes.GET("/api/stats/images", estafetteAPIHandler.GetStatsImages)
 	gzippedRoutes.GET("/api/manifest/templates", estafetteAPIHandler.GetManifestTemplates)
+	gzippedRoutes.GET("/api/v2/manifest/templates", estafetteAPIHandler.GetManifestTemplatesV2)
+	gzippedRoutes.GET("/api/manifest/templates/:name", estafetteAPIHandler.GetManifestTemplate)
+	gzippedRoutes.GET("/api/manifest/templates/:name/versions", estafetteAPIHandler.GetManifestTemplateVersions)
 	gzippedRoutes.POST("/api/manifest/generate", estafetteAPIHandler.GenerateManifest)
 	gzippedRoutes.POST("/api/manifest/validate", estafetteAPIHandler.ValidateManifest)
 	gzippedRoutes.POST("/api/manifest/encrypt", estafetteAPIHandler.EncryptSecret)
@@ -275,15 +386,44 @@ func handleRequests(stopChannel <-chan struct{}, waitGroup *sync.WaitGroup) *htt
 	{
 		iapAuthorizedRoutes.POST("/api/pipelines/:source/:owner/:repo/builds", estafetteAPIHandler.CreatePipelineBuild)
 		iapAuthorizedRoutes.POST("/api/pipelines/:source/:owner/:repo/releases", estafetteAPIHandler.CreatePipelineRelease)
+		iapAuthorizedRoutes.POST("/api/pipelines/:source/:owner/:repo/releases/:id/rollback", estafetteAPIHandler.CreatePipelineRollback)
 		iapAuthorizedRoutes.DELETE("/api/pipelines/:source/:owner/:repo/builds/:revisionOrId", estafetteAPIHandler.CancelPipelineBuild)
 		iapAuthorizedRoutes.DELETE("/api/pipelines/:source/:owner/:repo/releases/:id", estafetteAPIHandler.CancelPipelineRelease)
+		iapAuthorizedRoutes.POST("/api/pipelines/:source/:owner/:repo/archive", estafetteAPIHandler.ArchivePipeline)
+		iapAuthorizedRoutes.POST("/api/pipelines/:source/:owner/:repo/unarchive", estafetteAPIHandler.UnarchivePipeline)
 		iapAuthorizedRoutes.GET("/api/users/me", estafetteAPIHandler.GetLoggedInUser)
 		iapAuthorizedRoutes.GET("/api/config", estafetteAPIHandler.GetConfig)
 		iapAuthorizedRoutes.GET("/api/config/credentials", estafetteAPIHandler.GetConfigCredentials)
 		iapAuthorizedRoutes.GET("/api/config/trustedimages", estafetteAPIHandler.GetConfigTrustedImages)
+		iapAuthorizedRoutes.POST("/api/config/credentials", estafetteAPIHandler.CreateManagedCredential)
+		iapAuthorizedRoutes.PUT("/api/config/credentials/:name", estafetteAPIHandler.UpdateManagedCredential)
+		iapAuthorizedRoutes.DELETE("/api/config/credentials/:name", estafetteAPIHandler.DeleteManagedCredential)
+		iapAuthorizedRoutes.POST("/api/config/trustedimages", estafetteAPIHandler.CreateManagedTrustedImage)
+		iapAuthorizedRoutes.PUT("/api/config/trustedimages/*path", estafetteAPIHandler.UpdateManagedTrustedImage)
+		iapAuthorizedRoutes.DELETE("/api/config/trustedimages/*path", estafetteAPIHandler.DeleteManagedTrustedImage)
+		iapAuthorizedRoutes.GET("/api/config/audit", estafetteAPIHandler.GetManagedConfigAuditLog)
+		iapAuthorizedRoutes.POST("/api/manifest/templates", estafetteAPIHandler.CreateManifestTemplate)
+		iapAuthorizedRoutes.PUT("/api/manifest/templates/:name", estafetteAPIHandler.UpdateManifestTemplate)
+		iapAuthorizedRoutes.DELETE("/api/manifest/templates/:name", estafetteAPIHandler.DeleteManifestTemplate)
+		iapAuthorizedRoutes.POST("/api/manifest/templates/import", estafetteAPIHandler.ImportManifestTemplates)
 		iapAuthorizedRoutes.GET("/api/update-computed-tables", estafetteAPIHandler.UpdateComputedTables)
+		iapAuthorizedRoutes.POST("/api/secrets/reencrypt", estafetteAPIHandler.ReencryptSecrets)
 	}
 
+	// reload the config when the configmap gets updated or on SIGHUP; database, listen address and worker settings only get picked up on restart
+	configWatcher := config.NewConfigWatcher(stopChannel, waitGroup, configReader, *configFilePath, *configOverlayFilePaths, *configReloadInterval, func(reloadedConfig, reloadedEncryptedConfig *config.APIConfig, reloadedConfigSources []config.ConfigSource) {
+		ciBuilderClient.UpdateConfig(*reloadedConfig, *reloadedEncryptedConfig)
+		warningHelper.UpdateConfig(*reloadedEncryptedConfig)
+		estafetteAPIHandler.UpdateConfig(*reloadedConfig.APIServer, *reloadedConfig.Auth, *reloadedEncryptedConfig, reloadedConfigSources)
+		authMiddleware.UpdateConfig(*reloadedConfig.Auth)
+		githubAPIClient.UpdateConfig(*reloadedConfig.Integrations.Github)
+		githubEventHandler.UpdateConfig(*reloadedConfig.Integrations.Github)
+		bitbucketAPIClient.UpdateConfig(*reloadedConfig.Integrations.Bitbucket)
+		slackAPIClient.UpdateConfig(*reloadedConfig.Integrations.Slack)
+		slackEventHandler.UpdateConfig(*reloadedConfig.Integrations.Slack, *reloadedConfig.APIServer)
+	}, prometheusConfigReloadTotals)
+	configWatcher.Run()
+
 	router.NoRoute(func(c *gin.Context) {
 		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"code": http.StatusText(http.StatusNotFound), "message": "Page not found"})
 	})
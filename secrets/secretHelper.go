@@ -0,0 +1,265 @@
+package secrets
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+
+	crypt "github.com/estafette/estafette-ci-crypt"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// SecretHelper encrypts secrets with the primary key and decrypts them with whichever key they were encrypted with, so the secret decryption key can be rotated without re-encrypting all secrets at once; secrets can be restricted to a single pipeline
+type SecretHelper interface {
+	crypt.SecretHelper
+	EncryptEnvelopeForPipeline(string, string) (string, error)
+	ReencryptAllEnvelopes(string) (string, error)
+	ValidateAllEnvelopesForPipeline(string, string) error
+	DowngradeAllEnvelopes(string, string) (string, error)
+}
+
+type secretHelperImpl struct {
+	primaryKeyID                   string
+	keyIDs                         []string
+	keys                           map[string]crypt.SecretHelper
+	prometheusSecretKeyUsageTotals *prometheus.CounterVec
+}
+
+var (
+	envelopeRegex        = regexp.MustCompile(`estafette\.secret\([a-zA-Z0-9.=_-]+\)`)
+	singleEnvelopeRegex  = regexp.MustCompile(`^estafette\.secret\(([a-zA-Z0-9.=_-]+)\)$`)
+	pipelineBindingRegex = regexp.MustCompile(`^estafette\.pipeline\(([^)]+)\)`)
+	pipelineRegex        = regexp.MustCompile(`^[^/()]+/[^/()]+/[^/()]+$`)
+)
+
+// NewSecretHelper returns a new secrets.SecretHelper; new envelopes get encrypted with the primary key and carry its id, legacy keys are only used for decrypting
+func NewSecretHelper(primaryKey string, legacyKeys []string, prometheusSecretKeyUsageTotals *prometheus.CounterVec) SecretHelper {
+
+	secretHelper := &secretHelperImpl{
+		primaryKeyID:                   GetKeyID(primaryKey),
+		keys:                           map[string]crypt.SecretHelper{},
+		prometheusSecretKeyUsageTotals: prometheusSecretKeyUsageTotals,
+	}
+
+	for _, key := range append([]string{primaryKey}, legacyKeys...) {
+		keyID := GetKeyID(key)
+		if _, ok := secretHelper.keys[keyID]; ok {
+			continue
+		}
+		secretHelper.keyIDs = append(secretHelper.keyIDs, keyID)
+		secretHelper.keys[keyID] = crypt.NewSecretHelper(key)
+	}
+
+	return secretHelper
+}
+
+// GetKeyID returns the id identifying a key in the envelopes encrypted with it; it's derived from the key itself so it doesn't need configuring and doesn't reveal the key
+func GetKeyID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:4])
+}
+
+// Encrypt returns the text encrypted with the primary key, prefixed with the key id
+func (sh *secretHelperImpl) Encrypt(unencryptedText string) (encryptedTextPlusNonce string, err error) {
+
+	encryptedTextPlusNonce, err = sh.keys[sh.primaryKeyID].Encrypt(unencryptedText)
+	if err != nil {
+		return
+	}
+
+	sh.trackUsage(sh.primaryKeyID, "encrypt")
+
+	return fmt.Sprintf("%v.%v", sh.primaryKeyID, encryptedTextPlusNonce), nil
+}
+
+// Decrypt decrypts text encrypted with the key its id refers to; text without key id predates key rotation and gets decrypted with the first key that fits, starting with the primary key. Secrets restricted to a pipeline can only be decrypted for that pipeline, so they return an error
+func (sh *secretHelperImpl) Decrypt(encryptedText string) (decryptedText string, err error) {
+
+	decryptedText, _, err = sh.decrypt(encryptedText)
+	if err != nil {
+		return
+	}
+
+	decryptedText, pipeline := getPipelineBinding(decryptedText)
+	if pipeline != "" {
+		return "", fmt.Errorf("The secret is restricted to pipeline %v", pipeline)
+	}
+
+	return
+}
+
+// EncryptEnvelope returns the text encrypted with the primary key in an estafette.secret(...) envelope
+func (sh *secretHelperImpl) EncryptEnvelope(unencryptedText string) (encryptedTextInEnvelope string, err error) {
+
+	encryptedText, err := sh.Encrypt(unencryptedText)
+	if err != nil {
+		return
+	}
+
+	return fmt.Sprintf("estafette.secret(%v)", encryptedText), nil
+}
+
+// EncryptEnvelopeForPipeline returns the text encrypted with the primary key in an estafette.secret(...) envelope that only the pipeline - in the form source/owner/repo - can use; the binding is part of the encrypted text, so it can't be tampered with
+func (sh *secretHelperImpl) EncryptEnvelopeForPipeline(unencryptedText, pipeline string) (encryptedTextInEnvelope string, err error) {
+
+	if !pipelineRegex.MatchString(pipeline) {
+		return "", fmt.Errorf("Pipeline %v is not in the form source/owner/repo", pipeline)
+	}
+
+	return sh.EncryptEnvelope(fmt.Sprintf("estafette.pipeline(%v)%v", pipeline, unencryptedText))
+}
+
+// DecryptEnvelope decrypts the text in an estafette.secret(...) envelope; text that isn't an envelope gets returned as is, and so do envelopes restricted to a pipeline, so credentials in the config file can be restricted as well and only get decrypted by the builder of that pipeline
+func (sh *secretHelperImpl) DecryptEnvelope(encryptedTextInEnvelope string) (decryptedText string, err error) {
+
+	matches := singleEnvelopeRegex.FindStringSubmatch(encryptedTextInEnvelope)
+	if matches == nil {
+		return encryptedTextInEnvelope, nil
+	}
+
+	decryptedText, _, err = sh.decrypt(matches[1])
+	if err != nil {
+		return
+	}
+
+	decryptedText, pipeline := getPipelineBinding(decryptedText)
+	if pipeline != "" {
+		return encryptedTextInEnvelope, nil
+	}
+
+	return
+}
+
+// DecryptAllEnvelopes decrypts all estafette.secret(...) envelopes in the text, except the ones restricted to a pipeline
+func (sh *secretHelperImpl) DecryptAllEnvelopes(encryptedTextWithEnvelopes string) (string, error) {
+	return sh.replaceAllEnvelopes(encryptedTextWithEnvelopes, func(envelope string) (string, error) {
+		return sh.DecryptEnvelope(envelope)
+	})
+}
+
+// ReencryptAllEnvelopes re-encrypts all estafette.secret(...) envelopes in the text - a config file or manifest - with the primary key, so the legacy keys they were encrypted with can be retired
+func (sh *secretHelperImpl) ReencryptAllEnvelopes(encryptedTextWithEnvelopes string) (string, error) {
+	return sh.replaceAllEnvelopes(encryptedTextWithEnvelopes, func(envelope string) (string, error) {
+
+		// keep the pipeline binding as is
+		decryptedText, _, err := sh.decrypt(singleEnvelopeRegex.FindStringSubmatch(envelope)[1])
+		if err != nil {
+			return envelope, err
+		}
+
+		return sh.EncryptEnvelope(decryptedText)
+	})
+}
+
+// ValidateAllEnvelopesForPipeline returns an error if any of the estafette.secret(...) envelopes in the text can't be decrypted or is restricted to another pipeline than the one in the form source/owner/repo
+func (sh *secretHelperImpl) ValidateAllEnvelopesForPipeline(encryptedTextWithEnvelopes, pipeline string) error {
+	_, err := sh.replaceAllEnvelopes(encryptedTextWithEnvelopes, func(envelope string) (string, error) {
+		_, err := sh.decryptForPipeline(singleEnvelopeRegex.FindStringSubmatch(envelope)[1], pipeline)
+		return envelope, err
+	})
+	return err
+}
+
+// DowngradeAllEnvelopes turns all estafette.secret(...) envelopes in the text into envelopes without key id or pipeline binding encrypted with the primary key, the only form builder jobs - which only receive the primary key - can decrypt; it fails for secrets restricted to another pipeline than the one in the form source/owner/repo, so the builder never gets to decrypt those. The builder doesn't know about pipeline bindings and can decrypt any downgraded envelope, so this is the only place the binding is enforced; anything handed to a builder job has to go through it
+func (sh *secretHelperImpl) DowngradeAllEnvelopes(encryptedTextWithEnvelopes, pipeline string) (string, error) {
+	return sh.replaceAllEnvelopes(encryptedTextWithEnvelopes, func(envelope string) (string, error) {
+
+		decryptedText, err := sh.decryptForPipeline(singleEnvelopeRegex.FindStringSubmatch(envelope)[1], pipeline)
+		if err != nil {
+			return envelope, err
+		}
+
+		reencryptedText, err := sh.keys[sh.primaryKeyID].Encrypt(decryptedText)
+		if err != nil {
+			return envelope, err
+		}
+		sh.trackUsage(sh.primaryKeyID, "encrypt")
+
+		return fmt.Sprintf("estafette.secret(%v)", reencryptedText), nil
+	})
+}
+
+// decryptForPipeline returns the decrypted text without pipeline binding if the secret isn't restricted or restricted to the pipeline
+func (sh *secretHelperImpl) decryptForPipeline(encryptedText, pipeline string) (decryptedText string, err error) {
+
+	decryptedText, _, err = sh.decrypt(encryptedText)
+	if err != nil {
+		return
+	}
+
+	decryptedText, boundPipeline := getPipelineBinding(decryptedText)
+	if boundPipeline != "" && !strings.EqualFold(boundPipeline, pipeline) {
+		return "", fmt.Errorf("The secret is restricted to pipeline %v and can't be used by pipeline %v", boundPipeline, pipeline)
+	}
+
+	return
+}
+
+// getPipelineBinding splits decrypted text into the secret and the pipeline it's restricted to, if any
+func getPipelineBinding(decryptedText string) (secret, pipeline string) {
+	matches := pipelineBindingRegex.FindStringSubmatch(decryptedText)
+	if matches == nil {
+		return decryptedText, ""
+	}
+	return strings.TrimPrefix(decryptedText, matches[0]), matches[1]
+}
+
+// decrypt returns the decrypted text and the id of the key that decrypted it
+func (sh *secretHelperImpl) decrypt(encryptedText string) (decryptedText, keyID string, err error) {
+
+	parts := strings.Split(encryptedText, ".")
+
+	switch len(parts) {
+	case 3:
+		keyID = parts[0]
+		key, ok := sh.keys[keyID]
+		if !ok {
+			return "", keyID, fmt.Errorf("The secret has been encrypted with unknown key %v", keyID)
+		}
+		decryptedText, err = key.Decrypt(strings.Join(parts[1:], "."))
+		if err != nil {
+			return "", keyID, err
+		}
+		sh.trackUsage(keyID, "decrypt")
+		return
+
+	case 2:
+		for _, keyID = range sh.keyIDs {
+			decryptedText, err = sh.keys[keyID].Decrypt(encryptedText)
+			if err == nil {
+				sh.trackUsage(keyID, "decrypt")
+				return
+			}
+		}
+		return "", "", fmt.Errorf("The secret can't be decrypted with any of the %v keys", len(sh.keyIDs))
+	}
+
+	return "", "", fmt.Errorf("The encrypted text plus nonce doesn't split correctly")
+}
+
+func (sh *secretHelperImpl) replaceAllEnvelopes(text string, replaceFunc func(string) (string, error)) (string, error) {
+
+	var replaceErr error
+	replacedText := envelopeRegex.ReplaceAllStringFunc(text, func(envelope string) string {
+		replacedEnvelope, err := replaceFunc(envelope)
+		if err != nil && replaceErr == nil {
+			replaceErr = err
+		}
+		return replacedEnvelope
+	})
+	if replaceErr != nil {
+		return text, replaceErr
+	}
+
+	return replacedText, nil
+}
+
+// trackUsage counts encryptions and decryptions per key id; a legacy key that hasn't decrypted anything for a while can be retired
+func (sh *secretHelperImpl) trackUsage(keyID, operation string) {
+	if sh.prometheusSecretKeyUsageTotals == nil {
+		return
+	}
+	sh.prometheusSecretKeyUsageTotals.With(prometheus.Labels{"key": keyID, "operation": operation}).Inc()
+}
@@ -0,0 +1,210 @@
+package secrets
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	crypt "github.com/estafette/estafette-ci-crypt"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	primaryKey = "SazbwMf3NZxVVbBqQHebPcXCqrVn3DDp"
+	legacyKey  = "AazbwMf3NZxVVbBqQHebPcXCqrVn3DDp"
+)
+
+func TestGetKeyID(t *testing.T) {
+
+	t.Run("ReturnsSameIDForSameKey", func(t *testing.T) {
+
+		// act
+		keyID := GetKeyID(primaryKey)
+
+		assert.Equal(t, 8, len(keyID))
+		assert.Equal(t, keyID, GetKeyID(primaryKey))
+		assert.NotEqual(t, keyID, GetKeyID(legacyKey))
+	})
+}
+
+func TestEncryptEnvelope(t *testing.T) {
+
+	t.Run("ReturnsEnvelopeWithPrimaryKeyID", func(t *testing.T) {
+
+		secretHelper := NewSecretHelper(primaryKey, []string{legacyKey}, nil)
+
+		// act
+		envelope, err := secretHelper.EncryptEnvelope("this is my secret")
+
+		assert.Nil(t, err)
+		assert.True(t, strings.HasPrefix(envelope, fmt.Sprintf("estafette.secret(%v.", GetKeyID(primaryKey))))
+	})
+}
+
+func TestDecryptEnvelope(t *testing.T) {
+
+	t.Run("DecryptsEnvelopeWithKeyID", func(t *testing.T) {
+
+		secretHelper := NewSecretHelper(primaryKey, []string{legacyKey}, nil)
+		envelope, _ := secretHelper.EncryptEnvelope("this is my secret")
+
+		// act
+		decryptedText, err := secretHelper.DecryptEnvelope(envelope)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "this is my secret", decryptedText)
+	})
+
+	t.Run("DecryptsEnvelopeWithoutKeyIDEncryptedWithLegacyKey", func(t *testing.T) {
+
+		secretHelper := NewSecretHelper(primaryKey, []string{legacyKey}, nil)
+		envelope, _ := crypt.NewSecretHelper(legacyKey).EncryptEnvelope("this is my secret")
+
+		// act
+		decryptedText, err := secretHelper.DecryptEnvelope(envelope)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "this is my secret", decryptedText)
+	})
+
+	t.Run("DecryptsEnvelopeWithKeyIDOfLegacyKeyAfterRotation", func(t *testing.T) {
+
+		envelope, _ := NewSecretHelper(legacyKey, nil, nil).EncryptEnvelope("this is my secret")
+		secretHelper := NewSecretHelper(primaryKey, []string{legacyKey}, nil)
+
+		// act
+		decryptedText, err := secretHelper.DecryptEnvelope(envelope)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "this is my secret", decryptedText)
+	})
+
+	t.Run("ReturnsErrorForEnvelopeWithUnknownKeyID", func(t *testing.T) {
+
+		envelope, _ := NewSecretHelper(legacyKey, nil, nil).EncryptEnvelope("this is my secret")
+		secretHelper := NewSecretHelper(primaryKey, nil, nil)
+
+		// act
+		_, err := secretHelper.DecryptEnvelope(envelope)
+
+		assert.NotNil(t, err)
+	})
+}
+
+func TestReencryptAllEnvelopes(t *testing.T) {
+
+	t.Run("ReencryptsAllEnvelopesWithPrimaryKey", func(t *testing.T) {
+
+		legacyEnvelope, _ := crypt.NewSecretHelper(legacyKey).EncryptEnvelope("first secret")
+		legacyEnvelopeWithKeyID, _ := NewSecretHelper(legacyKey, nil, nil).EncryptEnvelope("second secret")
+		text := fmt.Sprintf("first: %v\nsecond: %v\n", legacyEnvelope, legacyEnvelopeWithKeyID)
+
+		secretHelper := NewSecretHelper(primaryKey, []string{legacyKey}, nil)
+
+		// act
+		reencryptedText, err := secretHelper.ReencryptAllEnvelopes(text)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 2, strings.Count(reencryptedText, fmt.Sprintf("estafette.secret(%v.", GetKeyID(primaryKey))))
+		decryptedText, err := NewSecretHelper(primaryKey, nil, nil).DecryptAllEnvelopes(reencryptedText)
+		assert.Nil(t, err)
+		assert.Equal(t, "first: first secret\nsecond: second secret\n", decryptedText)
+	})
+}
+
+func TestDowngradeAllEnvelopes(t *testing.T) {
+
+	t.Run("ReturnsEnvelopesWithoutKeyIDThatCanBeDecryptedWithPrimaryKeyOnly", func(t *testing.T) {
+
+		secretHelper := NewSecretHelper(primaryKey, []string{legacyKey}, nil)
+		primaryEnvelope, _ := secretHelper.EncryptEnvelope("first secret")
+		legacyEnvelope, _ := crypt.NewSecretHelper(legacyKey).EncryptEnvelope("second secret")
+		text := fmt.Sprintf(`{"first":"%v","second":"%v"}`, primaryEnvelope, legacyEnvelope)
+
+		// act
+		downgradedText, err := secretHelper.DowngradeAllEnvelopes(text, "github.com/estafette/estafette-ci-api")
+
+		assert.Nil(t, err)
+		decryptedText, err := crypt.NewSecretHelper(primaryKey).DecryptAllEnvelopes(downgradedText)
+		assert.Nil(t, err)
+		assert.Equal(t, `{"first":"first secret","second":"second secret"}`, decryptedText)
+	})
+
+	t.Run("StripsPipelineBindingForOwningPipeline", func(t *testing.T) {
+
+		secretHelper := NewSecretHelper(primaryKey, nil, nil)
+		envelope, _ := secretHelper.EncryptEnvelopeForPipeline("my secret", "github.com/estafette/estafette-ci-api")
+
+		// act
+		downgradedText, err := secretHelper.DowngradeAllEnvelopes(envelope, "github.com/estafette/estafette-ci-api")
+
+		assert.Nil(t, err)
+		decryptedText, err := crypt.NewSecretHelper(primaryKey).DecryptEnvelope(downgradedText)
+		assert.Nil(t, err)
+		assert.Equal(t, "my secret", decryptedText)
+	})
+
+	t.Run("ReturnsErrorForSecretRestrictedToOtherPipeline", func(t *testing.T) {
+
+		secretHelper := NewSecretHelper(primaryKey, nil, nil)
+		envelope, _ := secretHelper.EncryptEnvelopeForPipeline("my secret", "github.com/estafette/estafette-ci-api")
+
+		// act
+		downgradedText, err := secretHelper.DowngradeAllEnvelopes(envelope, "github.com/estafette/estafette-ci-builder")
+
+		assert.NotNil(t, err)
+		assert.Equal(t, "The secret is restricted to pipeline github.com/estafette/estafette-ci-api and can't be used by pipeline github.com/estafette/estafette-ci-builder", err.Error())
+		assert.Equal(t, envelope, downgradedText)
+	})
+}
+
+func TestEncryptEnvelopeForPipeline(t *testing.T) {
+
+	t.Run("ReturnsEnvelopeThatCantBeDecryptedWithoutPipeline", func(t *testing.T) {
+
+		secretHelper := NewSecretHelper(primaryKey, nil, nil)
+
+		// act
+		envelope, err := secretHelper.EncryptEnvelopeForPipeline("my secret", "github.com/estafette/estafette-ci-api")
+
+		assert.Nil(t, err)
+		_, err = secretHelper.Decrypt(singleEnvelopeRegex.FindStringSubmatch(envelope)[1])
+		assert.NotNil(t, err)
+		assert.Equal(t, "The secret is restricted to pipeline github.com/estafette/estafette-ci-api", err.Error())
+	})
+
+	t.Run("ReturnsEnvelopeThatStaysEncryptedWhenDecryptingAllEnvelopes", func(t *testing.T) {
+
+		secretHelper := NewSecretHelper(primaryKey, nil, nil)
+		envelope, _ := secretHelper.EncryptEnvelopeForPipeline("my secret", "github.com/estafette/estafette-ci-api")
+
+		// act
+		decryptedText, err := secretHelper.DecryptAllEnvelopes("secret: " + envelope)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "secret: "+envelope, decryptedText)
+	})
+
+	t.Run("ReturnsErrorForPipelineWithoutSourceOwnerAndRepo", func(t *testing.T) {
+
+		secretHelper := NewSecretHelper(primaryKey, nil, nil)
+
+		// act
+		_, err := secretHelper.EncryptEnvelopeForPipeline("my secret", "estafette-ci-api")
+
+		assert.NotNil(t, err)
+	})
+
+	t.Run("KeepsPipelineBindingWhenReencrypting", func(t *testing.T) {
+
+		envelope, _ := NewSecretHelper(legacyKey, nil, nil).EncryptEnvelopeForPipeline("my secret", "github.com/estafette/estafette-ci-api")
+		secretHelper := NewSecretHelper(primaryKey, []string{legacyKey}, nil)
+
+		// act
+		reencryptedEnvelope, err := secretHelper.ReencryptAllEnvelopes(envelope)
+
+		assert.Nil(t, err)
+		assert.Nil(t, secretHelper.ValidateAllEnvelopesForPipeline(reencryptedEnvelope, "github.com/estafette/estafette-ci-api"))
+		assert.NotNil(t, secretHelper.ValidateAllEnvelopesForPipeline(reencryptedEnvelope, "github.com/estafette/estafette-ci-builder"))
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"sync"
 
 	"github.com/estafette/estafette-ci-api/config"
 	slcontracts "github.com/estafette/estafette-ci-api/slack/contracts"
@@ -16,10 +17,12 @@ import (
 // APIClient is the interface for communicating with the Slack api
 type APIClient interface {
 	GetUserProfile(string) (*slcontracts.UserProfile, error)
+	UpdateConfig(config.SlackConfig)
 }
 
 type apiClientImpl struct {
 	config                          config.SlackConfig
+	configMutex                     sync.RWMutex
 	prometheusOutboundAPICallTotals *prometheus.CounterVec
 }
 
@@ -50,7 +53,7 @@ func (sl *apiClientImpl) GetUserProfile(userID string) (profile *slcontracts.Use
 	}
 
 	// add headers
-	request.Header.Add("Authorization", fmt.Sprintf("%v %v", "Bearer", sl.config.AppOAuthAccessToken))
+	request.Header.Add("Authorization", fmt.Sprintf("%v %v", "Bearer", sl.getConfig().AppOAuthAccessToken))
 
 	// perform actual request
 	response, err := client.Do(request)
@@ -74,3 +77,18 @@ func (sl *apiClientImpl) GetUserProfile(userID string) (profile *slcontracts.Use
 
 	return profileResponse.Profile, nil
 }
+
+// UpdateConfig swaps in a reloaded config
+func (sl *apiClientImpl) UpdateConfig(config config.SlackConfig) {
+	sl.configMutex.Lock()
+	defer sl.configMutex.Unlock()
+
+	sl.config = config
+}
+
+func (sl *apiClientImpl) getConfig() config.SlackConfig {
+	sl.configMutex.RLock()
+	defer sl.configMutex.RUnlock()
+
+	return sl.config
+}
@@ -3,18 +3,17 @@ package slack
 import (
 	"fmt"
 	"net/http"
-	"strconv"
 	"strings"
+	"sync"
 
 	slcontracts "github.com/estafette/estafette-ci-api/slack/contracts"
-	"github.com/estafette/estafette-ci-manifest"
 
 	"github.com/estafette/estafette-ci-contracts"
 
 	"github.com/estafette/estafette-ci-api/cockroach"
 	"github.com/estafette/estafette-ci-api/config"
 	"github.com/estafette/estafette-ci-api/estafette"
-	crypt "github.com/estafette/estafette-ci-crypt"
+	"github.com/estafette/estafette-ci-api/secrets"
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog/log"
@@ -24,31 +23,29 @@ import (
 type EventHandler interface {
 	Handle(*gin.Context)
 	HasValidVerificationToken(slcontracts.SlashCommand) bool
+	UpdateConfig(config.SlackConfig, config.APIServerConfig)
 }
 
 type eventHandlerImpl struct {
-	secretHelper                 crypt.SecretHelper
+	secretHelper                 secrets.SecretHelper
 	config                       config.SlackConfig
 	slackAPIClient               APIClient
 	cockroachDBClient            cockroach.DBClient
 	apiConfig                    config.APIServerConfig
-	ciBuilderClient              estafette.CiBuilderClient
-	githubJobVarsFunc            func(string, string, string) (string, string, error)
-	bitbucketJobVarsFunc         func(string, string, string) (string, string, error)
+	configMutex                  sync.RWMutex
+	releaseHelper                estafette.ReleaseHelper
 	prometheusInboundEventTotals *prometheus.CounterVec
 }
 
 // NewSlackEventHandler returns a new slack.EventHandler
-func NewSlackEventHandler(secretHelper crypt.SecretHelper, config config.SlackConfig, slackAPIClient APIClient, cockroachDBClient cockroach.DBClient, apiConfig config.APIServerConfig, ciBuilderClient estafette.CiBuilderClient, githubJobVarsFunc func(string, string, string) (string, string, error), bitbucketJobVarsFunc func(string, string, string) (string, string, error), prometheusInboundEventTotals *prometheus.CounterVec) EventHandler {
+func NewSlackEventHandler(secretHelper secrets.SecretHelper, config config.SlackConfig, slackAPIClient APIClient, cockroachDBClient cockroach.DBClient, apiConfig config.APIServerConfig, releaseHelper estafette.ReleaseHelper, prometheusInboundEventTotals *prometheus.CounterVec) EventHandler {
 	return &eventHandlerImpl{
 		secretHelper:                 secretHelper,
 		config:                       config,
 		slackAPIClient:               slackAPIClient,
 		cockroachDBClient:            cockroachDBClient,
 		apiConfig:                    apiConfig,
-		ciBuilderClient:              ciBuilderClient,
-		githubJobVarsFunc:            githubJobVarsFunc,
-		bitbucketJobVarsFunc:         bitbucketJobVarsFunc,
+		releaseHelper:                releaseHelper,
 		prometheusInboundEventTotals: prometheusInboundEventTotals,
 	}
 }
@@ -70,7 +67,7 @@ func (h *eventHandlerImpl) Handle(c *gin.Context) {
 
 	hasValidVerificationToken := h.HasValidVerificationToken(slashCommand)
 	if !hasValidVerificationToken {
-		log.Warn().Str("expectedToken", h.config.AppVerificationToken).Str("actualToken", slashCommand.Token).Msg("Verification token for Slack command is invalid")
+		log.Warn().Str("expectedToken", h.getConfig().AppVerificationToken).Str("actualToken", slashCommand.Token).Msg("Verification token for Slack command is invalid")
 		c.String(http.StatusBadRequest, "Verification token for Slack command is invalid")
 		return
 	}
@@ -84,6 +81,21 @@ func (h *eventHandlerImpl) Handle(c *gin.Context) {
 				switch command {
 				case "encrypt":
 
+					// # restrict the secret to a single pipeline
+					// /estafette encrypt --pipeline github.com/estafette/estafette-ci-api mysecret
+
+					if len(arguments) > 1 && arguments[0] == "--pipeline" {
+						encryptedString, err := h.secretHelper.EncryptEnvelopeForPipeline(strings.Join(arguments[2:], " "), arguments[1])
+						if err != nil {
+							log.Error().Err(err).Interface("slashCommand", slashCommand).Msg("Failed to encrypt secret for pipeline")
+							c.String(http.StatusOK, fmt.Sprintf("Incorrect usage of /estafette encrypt --pipeline: %v", err))
+							return
+						}
+
+						c.String(http.StatusOK, encryptedString)
+						return
+					}
+
 					encryptedString, err := h.secretHelper.Encrypt(strings.Join(arguments, " "))
 					if err != nil {
 						log.Error().Err(err).Interface("slashCommand", slashCommand).Msg("Failed to encrypt secret")
@@ -96,7 +108,7 @@ func (h *eventHandlerImpl) Handle(c *gin.Context) {
 
 				case "release":
 
-					// # release latest green version (for release branch?)
+					// # release latest green version
 					// /estafette release github.com/estafette/estafette-ci-builder beta
 					// /estafette release github.com/estafette/estafette-ci-api tooling
 
@@ -107,96 +119,65 @@ func (h *eventHandlerImpl) Handle(c *gin.Context) {
 					// /estafette release github.com/estafette/estafette-ci-builder beta 0.0.47
 					// /estafette release github.com/estafette/estafette-ci-api beta 0.0.130
 
-					if len(arguments) < 3 {
-						c.String(http.StatusOK, "You have to few arguments, the command has to be of type /estafette release <repo> <release> <version>")
-						return
-					}
+					// # release with a release action
+					// /estafette release estafette-ci-api production:deploy-canary 0.0.130
 
-					// retrieve pipeline with first argument
-					fullRepoName := arguments[0]
-					releaseName := arguments[1]
-					buildVersion := arguments[2]
-
-					fullRepoNameArray := strings.Split(fullRepoName, "/")
-					if len(fullRepoNameArray) != 1 && len(fullRepoNameArray) != 3 {
-						c.String(http.StatusOK, "Your repository needs to be of the form <repo name> or <repo source>/<repo owner>/<repo name>")
+					if len(arguments) < 2 {
+						c.String(http.StatusOK, "You have to few arguments, the command has to be of type /estafette release <repo> <release>[:<action>] [<version>|<branch>]")
 						return
 					}
 
-					var pipeline *contracts.Pipeline
-					if len(fullRepoNameArray) == 1 {
-						pipelines, err := h.cockroachDBClient.GetPipelinesByRepoName(fullRepoName, false)
-						if err != nil {
-							log.Error().Err(err).Msgf("Failed retrieving pipelines for repo name %v by name", fullRepoName)
-							c.String(http.StatusOK, fmt.Sprintf("Retrieving the pipeline for repository %v from the database failed: %v", fullRepoName, err))
-							return
-						}
-						if len(pipelines) <= 0 {
-							c.String(http.StatusOK, fmt.Sprintf("The repo %v in your command does not have any estafette builds", fullRepoName))
-							return
-						}
-						if len(pipelines) > 1 {
-							commandsExample := ""
-							for _, p := range pipelines {
-								commandsExample += fmt.Sprintf("/estafette release %v/%v/%v %v %v\n", p.RepoSource, p.RepoOwner, p.RepoName, releaseName, buildVersion)
-							}
-							c.String(http.StatusOK, fmt.Sprintf("There are multiple pipelines with name %v, use the full name instead:\n%v", fullRepoName, commandsExample))
-							return
-						}
-						pipeline = pipelines[0]
-					} else {
-						pipeline, err := h.cockroachDBClient.GetPipeline(fullRepoNameArray[0], fullRepoNameArray[1], fullRepoNameArray[2], false)
-						if err != nil {
-							c.String(http.StatusOK, fmt.Sprintf("Retrieving the pipeline for repository %v from the database failed: %v", fullRepoName, err))
-							return
-						}
-						if pipeline == nil {
-							c.String(http.StatusOK, fmt.Sprintf("The repo %v in your command does not have any estafette builds", fullRepoName))
-							return
-						}
+					fullRepoName := arguments[0]
+					releaseName, releaseAction := h.getReleaseNameAndAction(arguments[1])
+					versionOrBranch := ""
+					if len(arguments) > 2 {
+						versionOrBranch = arguments[2]
 					}
 
-					// check if version exists
-					builds, err := h.cockroachDBClient.GetPipelineBuildsByVersion(pipeline.RepoSource, pipeline.RepoOwner, pipeline.RepoName, buildVersion, false)
-
-					if err != nil {
-						c.String(http.StatusOK, fmt.Sprintf("Retrieving the build for repository %v and version %v from the database failed: %v", fullRepoName, buildVersion, err))
+					pipeline, message := h.getPipelineForCommand(fullRepoName, func(p *cockroach.Pipeline) string {
+						return fmt.Sprintf("/estafette release %v/%v/%v %v", p.RepoSource, p.RepoOwner, p.RepoName, strings.Join(arguments[1:], " "))
+					})
+					if pipeline == nil {
+						c.String(http.StatusOK, message)
 						return
 					}
 
-					var build *contracts.Build
-					// get succeeded build
-					for _, b := range builds {
-						if b.BuildStatus == "succeeded" {
-							build = b
-							break
-						}
-					}
-
+					build, message := h.getBuildForReleaseCommand(pipeline, versionOrBranch)
 					if build == nil {
-						c.String(http.StatusOK, fmt.Sprintf("The version %v in your command does not exist", buildVersion))
+						c.String(http.StatusOK, message)
 						return
 					}
-					if build.BuildStatus != "succeeded" {
-						c.String(http.StatusOK, fmt.Sprintf("The build for version %v is not successful and cannot be used", buildVersion))
-						return
-					}
-
-					// check if release target exists
-					releaseExists := false
-					for _, releaseTarget := range build.ReleaseTargets {
-						if releaseTarget.Name == releaseName {
-							// todo support release action
 
-							releaseExists = true
+					// check if release target and action exist
+					var releaseTarget *contracts.ReleaseTarget
+					for _, rt := range build.ReleaseTargets {
+						if rt.Name == releaseName {
+							releaseTarget = &rt
 							break
 						}
 					}
-
-					if !releaseExists {
+					if releaseTarget == nil {
 						c.String(http.StatusOK, fmt.Sprintf("The release %v in your command is not defined in the manifest", releaseName))
 						return
 					}
+					if len(releaseTarget.Actions) > 0 || releaseAction != "" {
+						actionExists := false
+						actionNames := []string{}
+						for _, a := range releaseTarget.Actions {
+							actionNames = append(actionNames, a.Name)
+							if a.Name == releaseAction {
+								actionExists = true
+							}
+						}
+						if !actionExists {
+							if len(actionNames) == 0 {
+								c.String(http.StatusOK, fmt.Sprintf("The release %v in your command has no actions, use /estafette release %v %v %v", releaseName, fullRepoName, releaseName, build.BuildVersion))
+								return
+							}
+							c.String(http.StatusOK, fmt.Sprintf("The release %v in your command requires one of the actions %v, use /estafette release %v %v:<action> %v", releaseName, strings.Join(actionNames, ", "), fullRepoName, releaseName, build.BuildVersion))
+							return
+						}
+					}
 
 					// get user profile from api to set email address for TriggeredBy
 					profile, err := h.slackAPIClient.GetUserProfile(slashCommand.UserID)
@@ -205,91 +186,71 @@ func (h *eventHandlerImpl) Handle(c *gin.Context) {
 						return
 					}
 
-					// create release in database
-					release := contracts.Release{
-						Name:           releaseName,
-						RepoSource:     build.RepoSource,
-						RepoOwner:      build.RepoOwner,
-						RepoName:       build.RepoName,
-						ReleaseVersion: buildVersion,
-						ReleaseStatus:  "running",
-						TriggeredBy:    profile.Email,
+					// create release in database and start release job
+					release := cockroach.Release{
+						Release: contracts.Release{
+							Name:           releaseName,
+							Action:         releaseAction,
+							RepoSource:     build.RepoSource,
+							RepoOwner:      build.RepoOwner,
+							RepoName:       build.RepoName,
+							ReleaseVersion: build.BuildVersion,
+							ReleaseStatus:  "running",
+							TriggeredBy:    profile.Email,
+						},
 					}
-					insertedRelease, err := h.cockroachDBClient.InsertRelease(release)
+					insertedRelease, err := h.releaseHelper.StartRelease(release, *build)
 					if err != nil {
-						c.String(http.StatusOK, fmt.Sprintf("Inserting the release into the database failed: %v", err))
+						c.String(http.StatusOK, fmt.Sprintf("Starting the release failed: %v", err))
 						return
 					}
 
-					// get authenticated url
-					var authenticatedRepositoryURL string
-					var environmentVariableWithToken map[string]string
-					var gitSource string
-					switch build.RepoSource {
-					case "github.com":
-						var accessToken string
-						accessToken, authenticatedRepositoryURL, err = h.githubJobVarsFunc(build.RepoSource, build.RepoOwner, build.RepoName)
-						if err != nil {
-							c.String(http.StatusOK, fmt.Sprintf("Retrieving access token and authenticated github url for repository %v/%v/%v failed: %v", build.RepoSource, build.RepoOwner, build.RepoName, err))
-							return
-						}
-						environmentVariableWithToken = map[string]string{"ESTAFETTE_GITHUB_API_TOKEN": accessToken}
-						gitSource = "github"
+					c.String(http.StatusOK, fmt.Sprintf("Started releasing version %v to %v: %vpipelines/%v/%v/%v/releases/%v/logs", build.BuildVersion, arguments[1], h.getAPIConfig().BaseURL, build.RepoSource, build.RepoOwner, build.RepoName, insertedRelease.ID))
+					return
 
-					case "bitbucket.org":
-						var accessToken string
-						accessToken, authenticatedRepositoryURL, err = h.bitbucketJobVarsFunc(build.RepoSource, build.RepoOwner, build.RepoName)
-						if err != nil {
-							c.String(http.StatusOK, fmt.Sprintf("Retrieving access token and authenticated bitbucket url for repository %v/%v/%v failed: %v", build.RepoSource, build.RepoOwner, build.RepoName, err))
-							return
-						}
-						environmentVariableWithToken = map[string]string{"ESTAFETTE_BITBUCKET_API_TOKEN": accessToken}
-						gitSource = "bitbucket"
+				case "rollback":
+
+					// # release the last succeeded version before the currently released one
+					// /estafette rollback github.com/estafette/estafette-ci-api production
+					// /estafette rollback estafette-ci-api production:deploy-stable
+
+					if len(arguments) < 2 {
+						c.String(http.StatusOK, "You have to few arguments, the command has to be of type /estafette rollback <repo> <release>[:<action>]")
+						return
 					}
 
-					manifest, err := manifest.ReadManifest(build.Manifest)
-					if err != nil {
-						c.String(http.StatusOK, fmt.Sprintf("Reading the manifest from the build failed: %v", err))
+					fullRepoName := arguments[0]
+					releaseName, releaseAction := h.getReleaseNameAndAction(arguments[1])
+
+					pipeline, message := h.getPipelineForCommand(fullRepoName, func(p *cockroach.Pipeline) string {
+						return fmt.Sprintf("/estafette rollback %v/%v/%v %v", p.RepoSource, p.RepoOwner, p.RepoName, arguments[1])
+					})
+					if pipeline == nil {
+						c.String(http.StatusOK, message)
 						return
 					}
 
-					// inject steps
-					manifest, err = estafette.InjectSteps(manifest, manifest.Builder.Track, gitSource)
+					rollbackRelease, build, err := h.releaseHelper.GetRollbackRelease(pipeline, releaseName, releaseAction)
 					if err != nil {
-						c.String(http.StatusOK, fmt.Sprintf("Failed injecting steps into manifest for %v/%v/%v version %v: %v", build.RepoSource, build.RepoOwner, build.RepoName, buildVersion, err))
+						c.String(http.StatusOK, fmt.Sprintf("Determining the version to roll back to failed: %v", err))
+						return
 					}
 
-					insertedReleaseID, err := strconv.Atoi(insertedRelease.ID)
+					// get user profile from api to set email address for TriggeredBy
+					profile, err := h.slackAPIClient.GetUserProfile(slashCommand.UserID)
 					if err != nil {
-						c.String(http.StatusOK, fmt.Sprintf("Converting the release id to a string failed: %v", err))
+						c.String(http.StatusOK, fmt.Sprintf("Failed retrieving Slack user profile for user id %v: %v", slashCommand.UserID, err))
 						return
 					}
+					rollbackRelease.TriggeredBy = profile.Email
 
-					// start release job
-					ciBuilderParams := estafette.CiBuilderParams{
-						JobType:              "release",
-						RepoSource:           build.RepoSource,
-						RepoOwner:            build.RepoOwner,
-						RepoName:             build.RepoName,
-						RepoURL:              authenticatedRepositoryURL,
-						RepoBranch:           build.RepoBranch,
-						RepoRevision:         build.RepoRevision,
-						EnvironmentVariables: environmentVariableWithToken,
-						Track:                manifest.Builder.Track,
-						VersionNumber:        buildVersion,
-						Manifest:             manifest,
-						ReleaseID:            insertedReleaseID,
-						ReleaseName:          releaseName,
+					insertedRelease, err := h.releaseHelper.StartRelease(*rollbackRelease, *build)
+					if err != nil {
+						c.String(http.StatusOK, fmt.Sprintf("Starting the rollback failed: %v", err))
+						return
 					}
 
-					go func(ciBuilderParams estafette.CiBuilderParams) {
-						_, err := h.ciBuilderClient.CreateCiBuilderJob(ciBuilderParams)
-						if err != nil {
-							log.Error().Err(err).Msgf("Failed creating release job for %v/%v/%v/%v/%v version %v", ciBuilderParams.RepoSource, ciBuilderParams.RepoOwner, ciBuilderParams.RepoName, ciBuilderParams.RepoBranch, ciBuilderParams.RepoRevision, ciBuilderParams.VersionNumber)
-						}
-					}(ciBuilderParams)
-
-					c.String(http.StatusOK, fmt.Sprintf("Started releasing version %v to %v: %vpipelines/%v/%v/%v/releases/%v/logs", buildVersion, releaseName, h.apiConfig.BaseURL, build.RepoSource, build.RepoOwner, build.RepoName, insertedRelease.ID))
+					c.String(http.StatusOK, fmt.Sprintf("Started rolling back %v to version %v: %vpipelines/%v/%v/%v/releases/%v/logs", arguments[1], insertedRelease.ReleaseVersion, h.getAPIConfig().BaseURL, insertedRelease.RepoSource, insertedRelease.RepoOwner, insertedRelease.RepoName, insertedRelease.ID))
 					return
 				}
 			}
@@ -299,6 +260,121 @@ func (h *eventHandlerImpl) Handle(c *gin.Context) {
 	c.String(http.StatusOK, "Aye aye!")
 }
 
+// getReleaseNameAndAction splits a <release>[:<action>] argument
+func (h *eventHandlerImpl) getReleaseNameAndAction(argument string) (releaseName, releaseAction string) {
+	releaseNameAndAction := strings.SplitN(argument, ":", 2)
+	releaseName = releaseNameAndAction[0]
+	if len(releaseNameAndAction) > 1 {
+		releaseAction = releaseNameAndAction[1]
+	}
+
+	return
+}
+
+// getPipelineForCommand looks up a pipeline by <repo name> or <repo source>/<repo owner>/<repo name>; if it returns no pipeline the message explains why
+func (h *eventHandlerImpl) getPipelineForCommand(fullRepoName string, commandExampleFunc func(*cockroach.Pipeline) string) (pipeline *cockroach.Pipeline, message string) {
+
+	fullRepoNameArray := strings.Split(fullRepoName, "/")
+	if len(fullRepoNameArray) != 1 && len(fullRepoNameArray) != 3 {
+		return nil, "Your repository needs to be of the form <repo name> or <repo source>/<repo owner>/<repo name>"
+	}
+
+	if len(fullRepoNameArray) == 1 {
+		pipelines, err := h.cockroachDBClient.GetPipelinesByRepoName(fullRepoName, false)
+		if err != nil {
+			log.Error().Err(err).Msgf("Failed retrieving pipelines for repo name %v by name", fullRepoName)
+			return nil, fmt.Sprintf("Retrieving the pipeline for repository %v from the database failed: %v", fullRepoName, err)
+		}
+		if len(pipelines) <= 0 {
+			return nil, fmt.Sprintf("The repo %v in your command does not have any estafette builds", fullRepoName)
+		}
+		if len(pipelines) > 1 {
+			commandsExample := ""
+			for _, p := range pipelines {
+				commandsExample += commandExampleFunc(p) + "\n"
+			}
+			return nil, fmt.Sprintf("There are multiple pipelines with name %v, use the full name instead:\n%v", fullRepoName, commandsExample)
+		}
+
+		return pipelines[0], ""
+	}
+
+	pipeline, err := h.cockroachDBClient.GetPipeline(fullRepoNameArray[0], fullRepoNameArray[1], fullRepoNameArray[2], false)
+	if err != nil {
+		return nil, fmt.Sprintf("Retrieving the pipeline for repository %v from the database failed: %v", fullRepoName, err)
+	}
+	if pipeline == nil {
+		return nil, fmt.Sprintf("The repo %v in your command does not have any estafette builds", fullRepoName)
+	}
+
+	return pipeline, ""
+}
+
+// getBuildForReleaseCommand returns the succeeded build for a version, the latest succeeded build for a branch or - if versionOrBranch is empty - the latest succeeded build; if it returns no build the message explains why
+func (h *eventHandlerImpl) getBuildForReleaseCommand(pipeline *cockroach.Pipeline, versionOrBranch string) (build *cockroach.Build, message string) {
+
+	filters := map[string][]string{
+		"status": []string{"succeeded"},
+		"since":  []string{"eternity"},
+	}
+
+	if versionOrBranch != "" {
+		// check if version exists
+		builds, err := h.cockroachDBClient.GetPipelineBuildsByVersion(pipeline.RepoSource, pipeline.RepoOwner, pipeline.RepoName, versionOrBranch, false)
+		if err != nil {
+			return nil, fmt.Sprintf("Retrieving the build for repository %v/%v/%v and version %v from the database failed: %v", pipeline.RepoSource, pipeline.RepoOwner, pipeline.RepoName, versionOrBranch, err)
+		}
+		if len(builds) > 0 {
+			// get succeeded build
+			for _, b := range builds {
+				if b.BuildStatus == "succeeded" {
+					return b, ""
+				}
+			}
+			return nil, fmt.Sprintf("The build for version %v is not successful and cannot be used", versionOrBranch)
+		}
+
+		// no build with this version, so try it as a branch instead
+		filters["branch"] = []string{versionOrBranch}
+	}
+
+	builds, err := h.cockroachDBClient.GetPipelineBuilds(pipeline.RepoSource, pipeline.RepoOwner, pipeline.RepoName, 1, 1, filters, false)
+	if err != nil {
+		return nil, fmt.Sprintf("Retrieving the latest succeeded build for repository %v/%v/%v from the database failed: %v", pipeline.RepoSource, pipeline.RepoOwner, pipeline.RepoName, err)
+	}
+	if len(builds) == 0 {
+		if versionOrBranch != "" {
+			return nil, fmt.Sprintf("The version or branch %v in your command does not have any succeeded builds", versionOrBranch)
+		}
+		return nil, fmt.Sprintf("The repo %v/%v/%v does not have any succeeded builds", pipeline.RepoSource, pipeline.RepoOwner, pipeline.RepoName)
+	}
+
+	return builds[0], ""
+}
+
 func (h *eventHandlerImpl) HasValidVerificationToken(slashCommand slcontracts.SlashCommand) bool {
-	return slashCommand.Token == h.config.AppVerificationToken
+	return slashCommand.Token == h.getConfig().AppVerificationToken
+}
+
+// UpdateConfig swaps in a reloaded config
+func (h *eventHandlerImpl) UpdateConfig(config config.SlackConfig, apiConfig config.APIServerConfig) {
+	h.configMutex.Lock()
+	defer h.configMutex.Unlock()
+
+	h.config = config
+	h.apiConfig = apiConfig
+}
+
+func (h *eventHandlerImpl) getConfig() config.SlackConfig {
+	h.configMutex.RLock()
+	defer h.configMutex.RUnlock()
+
+	return h.config
+}
+
+func (h *eventHandlerImpl) getAPIConfig() config.APIServerConfig {
+	h.configMutex.RLock()
+	defer h.configMutex.RUnlock()
+
+	return h.apiConfig
 }